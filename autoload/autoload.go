@@ -0,0 +1,272 @@
+// Package autoload installs extensions for osqueryd to autoload: it manages
+// the extensions.load file listing the extension binaries, secures the files
+// the way osqueryd requires, and generates the matching flagfile entries.
+//
+//	if err := autoload.Install("build/example.ext", "/usr/local/osquery_extensions/example.ext"); err != nil {
+//		return err
+//	}
+//	if err := autoload.Add(autoload.DefaultLoadPath(), "/usr/local/osquery_extensions/example.ext"); err != nil {
+//		return err
+//	}
+//	err := autoload.UpdateFlagfile("/etc/osquery/osquery.flags", autoload.Flags{
+//		LoadPath: autoload.DefaultLoadPath(),
+//		Require:  []string{"example"},
+//	})
+package autoload
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLoadPath returns the path of the extensions.load file of the
+// osquery packages on the platform.
+func DefaultLoadPath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return `C:\Program Files\osquery\extensions.load`
+	case "darwin":
+		return "/var/osquery/extensions.load"
+	default:
+		return "/etc/osquery/extensions.load"
+	}
+}
+
+// Suffix is the file name suffix osqueryd requires of the extensions it
+// autoloads on the platform.
+func Suffix() string {
+	if runtime.GOOS == "windows" {
+		return ".exe"
+	}
+	return ".ext"
+}
+
+// ReadLoadFile returns the extension paths in the extensions.load file.
+// Blank lines and comments starting with # are skipped. A missing file has
+// no extensions.
+func ReadLoadFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading extensions.load")
+	}
+
+	var extensions []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		extensions = append(extensions, line)
+	}
+	return extensions, errors.Wrap(scanner.Err(), "reading extensions.load")
+}
+
+// Add adds the extension paths to the extensions.load file, creating it if
+// needed. Paths already in the file are not added again. The paths must be
+// absolute and end with Suffix.
+func Add(loadPath string, extensions ...string) error {
+	for _, ext := range extensions {
+		if !filepath.IsAbs(ext) {
+			return errors.Errorf("extension path %s is not absolute", ext)
+		}
+		if !strings.HasSuffix(ext, Suffix()) {
+			return errors.Errorf("extension path %s does not end with %s", ext, Suffix())
+		}
+	}
+	return updateLoadFile(loadPath, func(lines []string) []string {
+		for _, ext := range extensions {
+			if !containsLine(lines, ext) {
+				lines = append(lines, ext)
+			}
+		}
+		return lines
+	})
+}
+
+// Remove removes the extension paths from the extensions.load file.
+func Remove(loadPath string, extensions ...string) error {
+	return updateLoadFile(loadPath, func(lines []string) []string {
+		kept := lines[:0]
+		for _, line := range lines {
+			if !contains(extensions, strings.TrimSpace(line)) {
+				kept = append(kept, line)
+			}
+		}
+		return kept
+	})
+}
+
+// updateLoadFile rewrites the lines of the extensions.load file with
+// update, keeping the comments.
+func updateLoadFile(path string, update func(lines []string) []string) error {
+	lines, err := readLines(path)
+	if err != nil {
+		return errors.Wrap(err, "reading extensions.load")
+	}
+	return errors.Wrap(writeFile(path, update(lines)), "writing extensions.load")
+}
+
+// Flags are the osqueryd flags for autoloading extensions.
+type Flags struct {
+	// LoadPath is the path of the extensions.load file.
+	LoadPath string
+	// Require lists the extensions osqueryd waits for before starting,
+	// by name.
+	Require []string
+	// Timeout is how long osqueryd waits for the required extensions,
+	// in whole seconds. Zero keeps the default of osqueryd.
+	Timeout time.Duration
+	// Interval is how often osqueryd checks the extensions, in whole
+	// seconds. Zero keeps the default of osqueryd.
+	Interval time.Duration
+}
+
+// Lines returns the flagfile lines setting the flags.
+func (f Flags) Lines() []string {
+	var lines []string
+	if f.LoadPath != "" {
+		lines = append(lines, "--extensions_autoload="+f.LoadPath)
+	}
+	if len(f.Require) > 0 {
+		lines = append(lines, "--extensions_require="+strings.Join(f.Require, ","))
+	}
+	if f.Timeout > 0 {
+		lines = append(lines, "--extensions_timeout="+strconv.Itoa(int(f.Timeout.Seconds())))
+	}
+	if f.Interval > 0 {
+		lines = append(lines, "--extensions_interval="+strconv.Itoa(int(f.Interval.Seconds())))
+	}
+	return lines
+}
+
+// UpdateFlagfile sets the flags in the osqueryd flagfile, creating it if
+// needed. Existing lines for the flags are replaced, the other lines are
+// kept.
+func UpdateFlagfile(path string, flags Flags) error {
+	lines, err := readLines(path)
+	if err != nil {
+		return errors.Wrap(err, "reading flagfile")
+	}
+
+	set := flags.Lines()
+	var updated []string
+	for _, line := range lines {
+		if !contains(set, line) && setsFlag(set, line) {
+			continue
+		}
+		updated = append(updated, line)
+	}
+	for _, line := range set {
+		if !contains(updated, line) {
+			updated = append(updated, line)
+		}
+	}
+	return errors.Wrap(writeFile(path, updated), "writing flagfile")
+}
+
+// setsFlag reports whether the flagfile line sets one of the flags of the
+// lines.
+func setsFlag(lines []string, line string) bool {
+	name := flagName(line)
+	for _, l := range lines {
+		if flagName(l) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// flagName returns the name of the flag set by a flagfile line.
+func flagName(line string) string {
+	name := strings.TrimLeft(strings.TrimSpace(line), "-")
+	if i := strings.IndexAny(name, "= "); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// readLines returns the lines of the file, none if it doesn't exist.
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// writeFile replaces the file with the lines, keeping its mode, or with
+// mode 0644 for a new file. osqueryd refuses files writable by others.
+func writeFile(path string, lines []string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm() &^ 0022
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	var data bytes.Buffer
+	for _, line := range lines {
+		data.WriteString(line)
+		data.WriteString("\n")
+	}
+	if _, err := io.Copy(tmp, &data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// containsLine reports whether a line is the value, ignoring surrounding
+// spaces.
+func containsLine(lines []string, value string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package autoload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	loadPath := filepath.Join(dir, "osquery", "extensions.load")
+	a := filepath.Join(dir, "a"+Suffix())
+	b := filepath.Join(dir, "b"+Suffix())
+
+	extensions, err := ReadLoadFile(loadPath)
+	require.NoError(t, err)
+	assert.Empty(t, extensions)
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(loadPath), 0755))
+	require.NoError(t, os.WriteFile(loadPath, []byte("# managed\n\n"+a+"\n"), 0666))
+
+	require.NoError(t, Add(loadPath, a, b))
+	extensions, err = ReadLoadFile(loadPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a, b}, extensions)
+
+	require.NoError(t, Remove(loadPath, a))
+	extensions, err = ReadLoadFile(loadPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{b}, extensions)
+
+	data, err := os.ReadFile(loadPath)
+	require.NoError(t, err)
+	assert.Equal(t, "# managed\n\n"+b+"\n", string(data))
+	info, err := os.Stat(loadPath)
+	require.NoError(t, err)
+	assert.Zero(t, info.Mode().Perm()&0022, "writable by others")
+
+	assert.Error(t, Add(loadPath, "relative"+Suffix()))
+	assert.Error(t, Add(loadPath, filepath.Join(dir, "a.so")))
+}
+
+func TestUpdateFlagfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "osquery.flags")
+	require.NoError(t, os.WriteFile(path, []byte("--verbose\n--extensions_timeout=3\n--extensions_require=old\n"), 0644))
+
+	flags := Flags{
+		LoadPath: "/etc/osquery/extensions.load",
+		Require:  []string{"a", "b"},
+		Timeout:  10 * time.Second,
+		Interval: 2 * time.Second,
+	}
+	require.NoError(t, UpdateFlagfile(path, flags))
+	require.NoError(t, UpdateFlagfile(path, flags))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `--verbose
+--extensions_autoload=/etc/osquery/extensions.load
+--extensions_require=a,b
+--extensions_timeout=10
+--extensions_interval=2
+`, string(data))
+}
+
+func TestInstall(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "build", "example")
+	require.NoError(t, os.MkdirAll(filepath.Dir(src), 0755))
+	require.NoError(t, os.WriteFile(src, []byte("binary"), 0666))
+
+	dst := filepath.Join(dir, "extensions", "example"+Suffix())
+	require.NoError(t, Install(src, dst))
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(data))
+	assert.NoError(t, CheckPermissions(dst))
+
+	assert.Error(t, Install(src, filepath.Join(dir, "extensions", "example.bin")))
+}
@@ -0,0 +1,50 @@
+package autoload
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Install copies the extension binary at src to dst, the path listed in the
+// extensions.load file, and secures it with Secure. dst must end with
+// Suffix.
+func Install(src, dst string) error {
+	if !strings.HasSuffix(dst, Suffix()) {
+		return errors.Errorf("extension path %s does not end with %s", dst, Suffix())
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrap(err, "creating extension directory")
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "opening extension")
+	}
+	defer in.Close()
+
+	// Copy next to dst then rename, so that osqueryd never loads a
+	// partial binary.
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".")
+	if err != nil {
+		return errors.Wrap(err, "creating extension")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "copying extension")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "copying extension")
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return errors.Wrap(err, "making extension executable")
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return errors.Wrap(err, "moving extension")
+	}
+	return Secure(dst)
+}
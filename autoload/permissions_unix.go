@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package autoload
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Secure gives the file the permissions osqueryd demands of the extensions
+// it autoloads: not writable by group or others, and owned by root when
+// running as root. Without root, osqueryd must run as the owner of the
+// file.
+func Secure(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrap(err, "securing extension")
+	}
+	if err := os.Chmod(path, info.Mode().Perm()&^0022); err != nil {
+		return errors.Wrap(err, "securing extension")
+	}
+	if os.Geteuid() == 0 {
+		if err := os.Chown(path, 0, 0); err != nil {
+			return errors.Wrap(err, "securing extension")
+		}
+	}
+	return nil
+}
+
+// CheckPermissions returns an error if osqueryd running as the current
+// user would refuse to autoload the extension: the file and its directory
+// must be owned by root or the current user, and not writable by others.
+// The file must not be writable by its group either.
+func CheckPermissions(path string) error {
+	if err := checkOwner(path, 0022); err != nil {
+		return err
+	}
+	return checkOwner(filepath.Dir(path), 0002)
+}
+
+func checkOwner(path string, unsafe os.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrap(err, "checking permissions")
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if uid := int(stat.Uid); uid != 0 && uid != os.Geteuid() {
+			return errors.Errorf("%s is owned by uid %d, not root or the current user", path, uid)
+		}
+	}
+	if info.Mode().Perm()&unsafe != 0 {
+		return errors.Errorf("%s has unsafe permissions %v", path, info.Mode().Perm())
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package autoload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "example.ext")
+	require.NoError(t, os.WriteFile(path, nil, 0755))
+	assert.NoError(t, CheckPermissions(path))
+
+	require.NoError(t, os.Chmod(path, 0775))
+	assert.Error(t, CheckPermissions(path))
+	require.NoError(t, Secure(path))
+	assert.NoError(t, CheckPermissions(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	require.NoError(t, os.Chmod(dir, 0777))
+	assert.Error(t, CheckPermissions(path))
+}
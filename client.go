@@ -2,6 +2,7 @@ package osquery
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/osquery/osquery-go/gen/osquery"
@@ -24,7 +25,9 @@ type ExtensionManagerClient struct {
 
 	waitTime    time.Duration
 	maxWaitTime time.Duration
+	framing     transport.Framing
 	lock        *locker
+	fromEnv     bool // Fall back to the environment, see ClientFromEnv
 }
 
 type ClientOption func(*ExtensionManagerClient)
@@ -45,9 +48,27 @@ func MaxWaitTime(d time.Duration) ClientOption {
 	}
 }
 
+// ClientFraming sets the Thrift message framing used when talking to the
+// osquery socket. The default, transport.FramingNone, matches osquery.
+func ClientFraming(f transport.Framing) ClientOption {
+	return func(c *ExtensionManagerClient) {
+		c.framing = f
+	}
+}
+
+// WithOsqueryThriftClient sets the underlying Thrift client, instead of
+// connecting to the socket. This can be used to set a mock, such as
+// mock.ExtensionManager, or a client with a custom transport.
+func WithOsqueryThriftClient(client osquery.ExtensionManager) ClientOption {
+	return func(c *ExtensionManagerClient) {
+		c.client = client
+	}
+}
+
 // NewClient creates a new client communicating to osquery over the socket at
-// the provided path. If resolving the address or connecting to the socket
-// fails, this function will error.
+// the provided path, or at DefaultSocketPath if the path is empty. If
+// resolving the address or connecting to the socket fails, this function will
+// error.
 func NewClient(path string, socketOpenTimeout time.Duration, opts ...ClientOption) (*ExtensionManagerClient, error) {
 	c := &ExtensionManagerClient{
 		waitTime:    defaultWaitTime,
@@ -64,6 +85,22 @@ func NewClient(path string, socketOpenTimeout time.Duration, opts ...ClientOptio
 
 	c.lock = NewLocker(c.waitTime, c.maxWaitTime)
 
+	if c.fromEnv {
+		if path == "" {
+			path = os.Getenv(SocketEnv)
+		}
+		if socketOpenTimeout == 0 {
+			timeout, _, err := durationEnv(TimeoutEnv)
+			if err != nil {
+				return nil, err
+			}
+			socketOpenTimeout = timeout
+		}
+	}
+	if path == "" {
+		path = DefaultSocketPath()
+	}
+
 	if c.client == nil {
 		trans, err := transport.Open(path, socketOpenTimeout)
 		if err != nil {
@@ -71,8 +108,8 @@ func NewClient(path string, socketOpenTimeout time.Duration, opts ...ClientOptio
 		}
 
 		c.client = osquery.NewExtensionManagerClientFactory(
-			trans,
-			thrift.NewTBinaryProtocolFactoryDefault(),
+			c.framing.Wrap(trans),
+			c.framing.ProtocolFactory(),
 		)
 	}
 
@@ -87,6 +124,31 @@ func (c *ExtensionManagerClient) Close() {
 	}
 }
 
+// ThriftClient calls fn with the underlying Thrift client, holding the lock of
+// the osquery socket like the other methods, so that advanced users can make
+// calls that are not wrapped by ExtensionManagerClient without interleaving
+// with them. Unless set with WithOsqueryThriftClient, the client is a
+// *osquery.ExtensionManagerClient of gen/osquery, whose Client_ method
+// returns the Thrift client used to send raw requests with the protocol of
+// the socket. The client must not be used after fn returns.
+func (c *ExtensionManagerClient) ThriftClient(ctx context.Context, fn func(ctx context.Context, client osquery.ExtensionManager) error) error {
+	ctx, span := traces.StartSpan(ctx, "ExtensionManagerClient.ThriftClient")
+	defer span.End()
+
+	if err := c.lock.Lock(ctx); err != nil {
+		return err
+	}
+	defer c.lock.Unlock()
+	return fn(ctx, c.client)
+}
+
+// LockStats returns the statistics of the waits of the calls of the client for
+// the osquery socket, which only allows one call at a time. Each wait is also
+// recorded as an event of the span of the call.
+func (c *ExtensionManagerClient) LockStats() LockStats {
+	return c.lock.Stats()
+}
+
 // Ping requests metadata from the extension manager, using a new background context
 func (c *ExtensionManagerClient) Ping() (*osquery.ExtensionStatus, error) {
 	return c.PingContext(context.Background())
@@ -107,9 +169,11 @@ func (c *ExtensionManagerClient) Call(registry, item string, request osquery.Ext
 }
 
 // CallContext requests a call to an extension (or core) registry plugin.
+// The trace context of ctx is added to the request, see traces.InjectRequest.
 func (c *ExtensionManagerClient) CallContext(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
 	ctx, span := traces.StartSpan(ctx, "ExtensionManagerClient.CallContext")
 	defer span.End()
+	request = traces.InjectRequest(ctx, request)
 
 	if err := c.lock.Lock(ctx); err != nil {
 		return nil, err
@@ -154,13 +154,6 @@ func TestLockTimeouts(t *testing.T) {
 	assert.Equal(t, 1, errCount, "expected error count")
 }
 
-// WithOsqueryThriftClient sets the underlying thrift client. This can be used to set a mock
-func WithOsqueryThriftClient(client osquery.ExtensionManager) ClientOption {
-	return func(c *ExtensionManagerClient) {
-		c.client = client
-	}
-}
-
 // SlowLocker attempts to emulate a slow sql routine, so we can test how lock timeouts work.
 func (c *ExtensionManagerClient) SlowLocker(ctx context.Context, d time.Duration) error {
 	if err := c.lock.Lock(ctx); err != nil {
@@ -170,3 +163,26 @@ func (c *ExtensionManagerClient) SlowLocker(ctx context.Context, d time.Duration
 	time.Sleep(d)
 	return nil
 }
+
+func TestThriftClient(t *testing.T) {
+	t.Parallel()
+	m := &mock.ExtensionManager{}
+	client, err := NewClient("", 5*time.Second, WithOsqueryThriftClient(m))
+	require.NoError(t, err)
+
+	err = client.ThriftClient(context.Background(), func(ctx context.Context, thriftClient osquery.ExtensionManager) error {
+		assert.Same(t, m, thriftClient)
+		// The socket is locked during the call
+		lockCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Error(t, client.lock.Lock(lockCtx))
+		return thriftClient.Shutdown(ctx)
+	})
+	require.NoError(t, err)
+	assert.True(t, m.ShutdownFuncInvoked)
+
+	boom := errors.New("boom")
+	assert.Equal(t, boom, client.ThriftClient(context.Background(), func(context.Context, osquery.ExtensionManager) error {
+		return boom
+	}))
+}
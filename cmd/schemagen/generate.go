@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"text/template"
+
+	"github.com/osquery/osquery-go/internal/codegen"
+	"github.com/pkg/errors"
+)
+
+// goColumnType describes how a column type of osquery maps to Go.
+type goColumnType struct {
+	// GoType is the type of the row struct field.
+	GoType string
+	// Parse is the call parsing the value v of the column, returning the
+	// parsed value and an error, and Conversion the conversion applied to
+	// the parsed value. Values are assigned as is when Parse is empty.
+	Parse, Conversion string
+}
+
+// columnTypes maps the column types of osquery to Go.
+var columnTypes = map[string]goColumnType{
+	"TEXT":            {"string", "", ""},
+	"DATETIME":        {"string", "", ""},
+	"INTEGER":         {"int32", "strconv.ParseInt(v, 10, 32)", "int32"},
+	"BIGINT":          {"int64", "strconv.ParseInt(v, 10, 64)", ""},
+	"UNSIGNED_BIGINT": {"uint64", "strconv.ParseUint(v, 10, 64)", ""},
+	"DOUBLE":          {"float64", "strconv.ParseFloat(v, 64)", ""},
+	"BLOB":            {"[]byte", "", "[]byte"},
+}
+
+type templateColumn struct {
+	columnSchema
+	goColumnType
+	Field string
+}
+
+type templateTable struct {
+	tableSchema
+	Prefix  string
+	Columns []templateColumn
+}
+
+type templateData struct {
+	Package string
+	Tables  []templateTable
+	Strconv bool
+}
+
+var codeTemplate = template.Must(template.New("schema").Parse(`// Code generated by schemagen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"context"
+	"fmt"
+{{- if .Strconv }}
+	"strconv"
+{{- end }}
+)
+
+// Querier runs SQL queries on osquery. It is implemented by
+// *osquery.ExtensionManagerClient.
+type Querier interface {
+	QueryRowsContext(ctx context.Context, sql string) ([]map[string]string, error)
+}
+
+// selectQuery returns the query selecting all the columns of the table,
+// filtered by the WHERE clause where if not empty.
+func selectQuery(table, where string) string {
+	if where == "" {
+		return "SELECT * FROM " + table
+	}
+	return "SELECT * FROM " + table + " WHERE " + where
+}
+{{- range .Tables }}
+
+// {{ .Prefix }}Row is a row of the {{ .Name }} table.{{ with .Description }}
+//
+// {{ . }}{{ end }}
+type {{ .Prefix }}Row struct {
+{{- range .Columns }}
+{{- with .Description }}
+	// {{ . }}
+{{- end }}
+	{{ .Field }} {{ .GoType }} ` + "`" + `column:"{{ .Name }}"` + "`" + `
+{{- end }}
+}
+
+// Parse{{ .Prefix }}Row converts a row of the {{ .Name }} table returned by
+// osquery. Missing columns, such as the columns of other platforms, and empty
+// values are left to the zero value.
+func Parse{{ .Prefix }}Row(row map[string]string) ({{ .Prefix }}Row, error) {
+	var r {{ .Prefix }}Row
+{{- $table := .Name }}
+{{- range .Columns }}
+{{- if .Parse }}
+	if v := row["{{ .Name }}"]; v != "" {
+		n, err := {{ .Parse }}
+		if err != nil {
+			return r, fmt.Errorf("{{ $table }} column {{ .Name }}: %w", err)
+		}
+		r.{{ .Field }} = {{ if .Conversion }}{{ .Conversion }}(n){{ else }}n{{ end }}
+	}
+{{- else }}
+	r.{{ .Field }} = {{ if .Conversion }}{{ .Conversion }}(row["{{ .Name }}"]){{ else }}row["{{ .Name }}"]{{ end }}
+{{- end }}
+{{- end }}
+	return r, nil
+}
+
+// Query{{ .Prefix }} runs SELECT * FROM {{ .Name }} with the WHERE clause where,
+// unless empty, and returns the parsed rows. The clause is sent as is, so
+// values in it must be quoted and escaped by the caller.
+func Query{{ .Prefix }}(ctx context.Context, q Querier, where string) ([]{{ .Prefix }}Row, error) {
+	rows, err := q.QueryRowsContext(ctx, selectQuery("{{ .Name }}", where))
+	if err != nil {
+		return nil, fmt.Errorf("querying {{ .Name }}: %w", err)
+	}
+	results := make([]{{ .Prefix }}Row, 0, len(rows))
+	for _, row := range rows {
+		r, err := Parse{{ .Prefix }}Row(row)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+{{- end }}
+`))
+
+// generate returns the Go source of the structs and query helpers of the
+// tables.
+func generate(schema []tableSchema, pkg string) ([]byte, error) {
+	data := templateData{Package: pkg}
+	for _, t := range schema {
+		table := templateTable{tableSchema: t, Prefix: codegen.GoName(t.Name)}
+		for _, col := range t.Columns {
+			c := templateColumn{
+				columnSchema: col,
+				goColumnType: columnTypes[col.Type],
+				Field:        codegen.GoName(col.Name),
+			}
+			if c.Parse != "" {
+				data.Strconv = true
+			}
+			table.Columns = append(table.Columns, c)
+		}
+		data.Tables = append(data.Tables, table)
+	}
+
+	var buf bytes.Buffer
+	if err := codeTemplate.Execute(&buf, data); err != nil {
+		return nil, errors.Wrap(err, "executing template")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "formatting generated code")
+	}
+	return src, nil
+}
@@ -0,0 +1,95 @@
+// Command schemagen generates typed Go structs and query helpers for osquery
+// tables, so that clients get compile-time checked access to query results.
+//
+// The columns of the tables are read either from a running osqueryd, through
+// its extensions socket, or from the osquery schema JSON published with each
+// osquery release (an array of tables with their name, description and
+// columns). For each table, the generated file holds a <Table>Row struct, a
+// Parse<Table>Row function converting the rows returned by osquery, and a
+// Query<Table> function running a query on the table with any client
+// implementing Querier, such as *osquery.ExtensionManagerClient.
+//
+// Usage:
+//
+//	schemagen -schema osquery.schema.json -tables processes,users -package tables -out tables.go
+//	schemagen -socket /var/osquery/osquery.em -tables listening_ports -out ports.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	var (
+		socket     = flag.String("socket", "", "Path to the extensions socket of osqueryd to read the columns from")
+		schemaPath = flag.String("schema", "", "Path to the osquery schema JSON to read the columns from")
+		tables     = flag.String("tables", "processes,users,listening_ports", "Comma separated names of the tables")
+		pkg        = flag.String("package", "tables", "Package of the generated file")
+		out        = flag.String("out", "", "Path of the generated file (defaults to stdout)")
+		timeout    = flag.Duration("timeout", 5*time.Second, "Timeout to open the socket")
+	)
+	flag.Parse()
+	if (*socket == "") == (*schemaPath == "") {
+		fmt.Fprintln(os.Stderr, "Exactly one of the -socket and -schema arguments is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	names := strings.Split(*tables, ",")
+	var (
+		schema []tableSchema
+		err    error
+	)
+	if *schemaPath != "" {
+		schema, err = readSchemaFile(*schemaPath, names)
+	} else {
+		schema, err = readSchemaSocket(*socket, *timeout, names)
+	}
+	if err == nil {
+		err = write(schema, *pkg, *out)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "schemagen: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func readSchemaFile(path string, names []string) ([]tableSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := parseSchemaJSON(data, names)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	return schema, nil
+}
+
+func readSchemaSocket(socket string, timeout time.Duration, names []string) ([]tableSchema, error) {
+	client, err := osquery.NewClient(socket, timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening osquery client")
+	}
+	defer client.Close()
+	return querySchema(client, names)
+}
+
+func write(schema []tableSchema, pkg, out string) error {
+	src, err := generate(schema, pkg)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0644)
+}
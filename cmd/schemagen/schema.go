@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+)
+
+// tableSchema is the schema of a table, in the format of the osquery schema
+// JSON.
+type tableSchema struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Columns     []columnSchema `json:"columns"`
+}
+
+type columnSchema struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Hidden      bool   `json:"hidden"`
+}
+
+// parseSchemaJSON returns the schema of the named tables, in order, from the
+// osquery schema JSON. Hidden columns are left out, as SELECT * doesn't
+// return them.
+func parseSchemaJSON(data []byte, names []string) ([]tableSchema, error) {
+	var all []tableSchema
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, errors.Wrap(err, "parsing schema JSON")
+	}
+	byName := make(map[string]tableSchema, len(all))
+	for _, t := range all {
+		byName[t.Name] = t
+	}
+
+	schema := make([]tableSchema, 0, len(names))
+	for _, name := range names {
+		t, ok := byName[name]
+		if !ok {
+			return nil, errors.Errorf("table %s not found in schema", name)
+		}
+		var columns []columnSchema
+		for _, col := range t.Columns {
+			if col.Hidden {
+				continue
+			}
+			col.Type = strings.ToUpper(col.Type)
+			columns = append(columns, col)
+		}
+		t.Columns = columns
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		schema = append(schema, t)
+	}
+	return schema, nil
+}
+
+// columnsQuerier is implemented by *osquery.ExtensionManagerClient.
+type columnsQuerier interface {
+	GetQueryColumnsContext(ctx context.Context, sql string) (*osquery.ExtensionResponse, error)
+}
+
+// querySchema returns the schema of the named tables, in order, as reported
+// by osqueryd for SELECT * on each table. osqueryd doesn't report the
+// descriptions of the tables and columns.
+func querySchema(client columnsQuerier, names []string) ([]tableSchema, error) {
+	schema := make([]tableSchema, 0, len(names))
+	for _, name := range names {
+		resp, err := client.GetQueryColumnsContext(context.Background(), "SELECT * FROM "+name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting columns of %s", name)
+		}
+		if resp.Status == nil {
+			return nil, errors.Errorf("getting columns of %s: no status", name)
+		}
+		if resp.Status.Code != 0 {
+			return nil, errors.Errorf("getting columns of %s: %s", name, resp.Status.Message)
+		}
+
+		t := tableSchema{Name: name}
+		// Each map of the response holds the name and type of one column.
+		for _, col := range resp.Response {
+			for colName, colType := range col {
+				t.Columns = append(t.Columns, columnSchema{Name: colName, Type: strings.ToUpper(colType)})
+			}
+		}
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		schema = append(schema, t)
+	}
+	return schema, nil
+}
+
+func (t tableSchema) validate() error {
+	if len(t.Columns) == 0 {
+		return errors.Errorf("table %s has no columns", t.Name)
+	}
+	for _, col := range t.Columns {
+		if _, ok := columnTypes[col.Type]; !ok {
+			return errors.Errorf("table %s: column %s has unknown type %q", t.Name, col.Name, col.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestSchema(t *testing.T, names ...string) []tableSchema {
+	t.Helper()
+	data, err := os.ReadFile("testdata/schema.json")
+	require.NoError(t, err)
+	schema, err := parseSchemaJSON(data, names)
+	require.NoError(t, err)
+	return schema
+}
+
+func TestParseSchemaJSON(t *testing.T) {
+	schema := readTestSchema(t, "users", "processes")
+	require.Len(t, schema, 2)
+	assert.Equal(t, "users", schema[0].Name)
+	assert.Equal(t, "processes", schema[1].Name)
+	assert.Equal(t, "All running processes on the host system.", schema[1].Description)
+	assert.Equal(t, columnSchema{Name: "pid", Type: "BIGINT", Description: "Process (or thread) ID"}, schema[1].Columns[0])
+	// Hidden columns are left out
+	for _, col := range schema[1].Columns {
+		assert.NotEqual(t, "upid", col.Name)
+	}
+
+	data, err := os.ReadFile("testdata/schema.json")
+	require.NoError(t, err)
+	_, err = parseSchemaJSON(data, []string{"bogus"})
+	assert.Error(t, err)
+	_, err = parseSchemaJSON([]byte(`[{"name": "foo", "columns": [{"name": "bar", "type": "bogus"}]}]`), []string{"foo"})
+	assert.Error(t, err)
+	_, err = parseSchemaJSON([]byte(`{}`), []string{"foo"})
+	assert.Error(t, err)
+}
+
+type mockColumnsQuerier map[string]osquery.ExtensionPluginResponse
+
+func (m mockColumnsQuerier) GetQueryColumnsContext(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+	columns, ok := m[sql]
+	if !ok {
+		return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 1, Message: "no such table"}}, nil
+	}
+	return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{}, Response: columns}, nil
+}
+
+func TestQuerySchema(t *testing.T) {
+	client := mockColumnsQuerier{
+		"SELECT * FROM users": {{"uid": "BIGINT"}, {"username": "TEXT"}},
+	}
+	schema, err := querySchema(client, []string{"users"})
+	require.NoError(t, err)
+	assert.Equal(t, []tableSchema{{
+		Name: "users",
+		Columns: []columnSchema{
+			{Name: "uid", Type: "BIGINT"},
+			{Name: "username", Type: "TEXT"},
+		},
+	}}, schema)
+
+	_, err = querySchema(client, []string{"users", "bogus"})
+	assert.Error(t, err)
+}
+
+// typeCheck parses and type checks the generated source.
+func typeCheck(t *testing.T, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "tables.go", src, parser.ParseComments)
+	require.NoError(t, err)
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("tables", fset, []*ast.File{file}, nil)
+	require.NoError(t, err)
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := generate(readTestSchema(t, "processes", "users", "listening_ports"), "tables")
+	require.NoError(t, err)
+	typeCheck(t, src)
+
+	code := string(src)
+	assert.Contains(t, code, "// Code generated by schemagen. DO NOT EDIT.")
+	assert.Contains(t, code, "package tables")
+	assert.Contains(t, code, "type ProcessesRow struct {")
+	assert.Contains(t, code, "\tPID int64 `column:\"pid\"`")
+	assert.Contains(t, code, "\tThreads int32 `column:\"threads\"`")
+	assert.Contains(t, code, "\tUsername string `column:\"username\"`")
+	assert.Contains(t, code, "func QueryListeningPorts(ctx context.Context, q Querier, where string) ([]ListeningPortsRow, error) {")
+	assert.Contains(t, code, "r.Threads = int32(n)")
+	assert.NotContains(t, code, "upid")
+
+	// Tables without numeric columns don't import strconv
+	src, err = generate([]tableSchema{{Name: "os_version", Columns: []columnSchema{{Name: "name", Type: "TEXT"}, {Name: "hash", Type: "BLOB"}}}}, "tables")
+	require.NoError(t, err)
+	typeCheck(t, src)
+	assert.NotContains(t, string(src), "strconv")
+}
+
+func TestWrite(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "tables.go")
+	require.NoError(t, write(readTestSchema(t, "users"), "tables", out))
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "func ParseUsersRow(row map[string]string) (UsersRow, error) {")
+}
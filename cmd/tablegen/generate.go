@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"text/template"
+
+	"github.com/osquery/osquery-go/internal/codegen"
+	"github.com/pkg/errors"
+)
+
+// goColumnType describes how a column type of a spec maps to Go.
+type goColumnType struct {
+	// Helper is the table package function that defines the column.
+	Helper string
+	// GoType is the type of the row struct field.
+	GoType string
+	// Setter is the table.Row method that sets the value, and Conversion
+	// the conversion applied to the field before calling it.
+	Setter, Conversion string
+}
+
+// columnTypes maps the column types of osquery specs to Go.
+var columnTypes = map[string]goColumnType{
+	"TEXT":            {"TextColumn", "string", "SetString", ""},
+	"DATETIME":        {"TextColumn", "string", "SetString", ""},
+	"INTEGER":         {"IntegerColumn", "int32", "SetInt", "int64"},
+	"BIGINT":          {"BigIntColumn", "int64", "SetInt", ""},
+	"UNSIGNED_BIGINT": {"UnsignedBigIntColumn", "uint64", "SetUint", ""},
+	"DOUBLE":          {"DoubleColumn", "float64", "SetFloat", ""},
+	"BLOB":            {"BlobColumn", "[]byte", "SetBlob", ""},
+}
+
+type templateColumn struct {
+	columnSpec
+	goColumnType
+	Field   string
+	Options []string
+}
+
+type templateData struct {
+	*tableSpec
+	Package    string
+	Source     string
+	Prefix     string
+	Columns    []templateColumn
+	Attributes []string
+}
+
+var codeTemplate = template.Must(template.New("table").Parse(`// Code scaffolded by tablegen{{ with .Source }} from {{ . }}{{ end }}.
+
+package {{ .Package }}
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// New{{ .Prefix }}Table creates the {{ .Name }} table plugin.{{ with .Description }}
+//
+// {{ . }}{{ end }}
+func New{{ .Prefix }}Table() *table.Plugin {
+	return table.NewPlugin("{{ .Name }}", {{ .Prefix }}Columns(), {{ .Prefix }}Generate{{ range .Attributes }}, {{ . }}{{ end }})
+}
+
+// {{ .Prefix }}Columns returns the columns of the {{ .Name }} table.
+func {{ .Prefix }}Columns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+{{- range .Columns }}
+		table.{{ .Helper }}("{{ .Name }}"{{ range .Options }}, {{ . }}{{ end }}),
+{{- end }}
+	}
+}
+
+// {{ .Prefix }}Row is a row of the {{ .Name }} table.
+type {{ .Prefix }}Row struct {
+{{- range .Columns }}
+{{- with .Description }}
+	// {{ . }}
+{{- end }}
+	{{ .Field }} {{ .GoType }} ` + "`" + `column:"{{ .Name }}"` + "`" + `
+{{- end }}
+}
+
+// Map converts the row to the column values returned to osquery.
+func (r {{ .Prefix }}Row) Map() map[string]string {
+	row := table.NewRow()
+{{- range .Columns }}
+	row.{{ .Setter }}("{{ .Name }}", {{ if .Conversion }}{{ .Conversion }}(r.{{ .Field }}){{ else }}r.{{ .Field }}{{ end }})
+{{- end }}
+	return row
+}
+
+// {{ .Prefix }}Generate generates the rows of the {{ .Name }} table.
+func {{ .Prefix }}Generate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	var rows []{{ .Prefix }}Row
+
+	// TODO: populate rows.
+
+	results := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, row.Map())
+	}
+	return results, nil
+}
+`))
+
+// generate returns the Go source scaffolding the table described by spec.
+func generate(spec *tableSpec, pkg, source string) ([]byte, error) {
+	data := templateData{
+		tableSpec: spec,
+		Package:   pkg,
+		Source:    source,
+		Prefix:    codegen.GoName(spec.Name),
+	}
+	for _, col := range spec.Columns {
+		c := templateColumn{
+			columnSpec:   col,
+			goColumnType: columnTypes[col.Type],
+			Field:        codegen.GoName(col.Name),
+		}
+		for _, opt := range []struct {
+			set  bool
+			name string
+		}{
+			{col.Index, "table.ColumnIndex()"},
+			{col.Required, "table.ColumnRequired()"},
+			{col.Additional, "table.ColumnAdditional()"},
+			{col.Optimized, "table.ColumnOptimized()"},
+			{col.Hidden, "table.ColumnHidden()"},
+		} {
+			if opt.set {
+				c.Options = append(c.Options, opt.name)
+			}
+		}
+		data.Columns = append(data.Columns, c)
+	}
+
+	attrs := spec.Attributes
+	if attrs.Cacheable {
+		data.Attributes = append(data.Attributes, "table.WithCacheable()")
+	}
+	if attrs.EventSubscriber {
+		data.Attributes = append(data.Attributes, "table.WithEventBased()")
+	}
+	if attrs.UserData {
+		data.Attributes = append(data.Attributes, "table.WithAttributes(table.TableAttributeUserBased)")
+	}
+	if attrs.Utility {
+		data.Attributes = append(data.Attributes, "table.WithAttributes(table.TableAttributeUtility)")
+	}
+
+	var buf bytes.Buffer
+	if err := codeTemplate.Execute(&buf, data); err != nil {
+		return nil, errors.Wrap(err, "executing template")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "formatting generated code")
+	}
+	return src, nil
+}
@@ -0,0 +1,77 @@
+// Command tablegen generates the scaffolding of an osquery-go table plugin
+// from a table spec: the column definitions, a typed row struct and a stub
+// Generate function wired to table.NewPlugin.
+//
+// The spec is either an osquery .table file or a JSON file (any other
+// extension) of the form:
+//
+//	{
+//	  "name": "processes",
+//	  "description": "All running processes.",
+//	  "columns": [
+//	    {"name": "pid", "type": "BIGINT", "description": "Process ID", "index": true},
+//	    {"name": "name", "type": "TEXT"}
+//	  ],
+//	  "attributes": {"cacheable": true}
+//	}
+//
+// Usage:
+//
+//	tablegen -spec processes.table -package main -out processes.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+func main() {
+	var (
+		specPath = flag.String("spec", "", "Path to the .table or JSON table spec")
+		pkg      = flag.String("package", "main", "Package of the generated file")
+		out      = flag.String("out", "", "Path of the generated file (defaults to stdout)")
+	)
+	flag.Parse()
+	if *specPath == "" {
+		fmt.Fprintln(os.Stderr, "Missing required -spec argument")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*specPath, *pkg, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "tablegen: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(specPath, pkg, out string) error {
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+
+	var spec *tableSpec
+	if filepath.Ext(specPath) == ".table" {
+		spec, err = parseTableSpec(data)
+	} else {
+		spec, err = parseJSONSpec(data)
+	}
+	if err != nil {
+		return errors.Wrap(err, specPath)
+	}
+
+	src, err := generate(spec, pkg, filepath.Base(specPath))
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0644)
+}
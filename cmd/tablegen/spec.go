@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tableSpec describes the table to generate code for.
+type tableSpec struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Columns     []columnSpec `json:"columns"`
+	Attributes  attributes   `json:"attributes"`
+}
+
+type columnSpec struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Index       bool   `json:"index"`
+	Required    bool   `json:"required"`
+	Additional  bool   `json:"additional"`
+	Optimized   bool   `json:"optimized"`
+	Hidden      bool   `json:"hidden"`
+}
+
+// attributes are the table attributes, named as in osquery .table files.
+type attributes struct {
+	Cacheable       bool `json:"cacheable"`
+	EventSubscriber bool `json:"event_subscriber"`
+	UserData        bool `json:"user_data"`
+	Utility         bool `json:"utility"`
+}
+
+// parseJSONSpec parses a table spec in JSON, for example:
+//
+//	{
+//	  "name": "processes",
+//	  "description": "All running processes.",
+//	  "columns": [
+//	    {"name": "pid", "type": "BIGINT", "description": "Process ID", "index": true},
+//	    {"name": "name", "type": "TEXT"}
+//	  ],
+//	  "attributes": {"cacheable": true}
+//	}
+func parseJSONSpec(data []byte) (*tableSpec, error) {
+	var spec tableSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, errors.Wrap(err, "parsing JSON spec")
+	}
+	spec.Description = strings.Join(strings.Fields(spec.Description), " ")
+	for i := range spec.Columns {
+		spec.Columns[i].Description = strings.Join(strings.Fields(spec.Columns[i].Description), " ")
+		spec.Columns[i].Type = strings.ToUpper(strings.ReplaceAll(spec.Columns[i].Type, " ", "_"))
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+var (
+	tableNameRe   = regexp.MustCompile(`table_name\(\s*"([^"]+)"`)
+	descriptionRe = regexp.MustCompile(`(?m)^description\(\s*((?:"(?:[^"\\]|\\.)*"\s*)+)\)`)
+	columnRe      = regexp.MustCompile(`Column\(\s*"([^"]+)"\s*,\s*([A-Z_]+)\s*(?:,\s*((?:"(?:[^"\\]|\\.)*"\s*)+))?([^)]*)\)`)
+	attributesRe  = regexp.MustCompile(`attributes\(([^)]*)\)`)
+	stringRe      = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+	optionRe      = regexp.MustCompile(`(\w+)\s*=\s*True`)
+)
+
+// parseTableSpec parses an osquery .table spec file. Only the parts relevant
+// to the generated code are parsed: the table name and description, the
+// columns (including those of extended schemas) and the attributes.
+func parseTableSpec(data []byte) (*tableSpec, error) {
+	src := string(data)
+	spec := &tableSpec{}
+
+	m := tableNameRe.FindStringSubmatch(src)
+	if m == nil {
+		return nil, errors.New("table_name not found")
+	}
+	spec.Name = m[1]
+
+	if m := descriptionRe.FindStringSubmatch(src); m != nil {
+		spec.Description = joinStrings(m[1])
+	}
+
+	seen := map[string]bool{}
+	for _, m := range columnRe.FindAllStringSubmatch(src, -1) {
+		if seen[m[1]] {
+			// Extended schemas may redeclare a column for
+			// several platforms.
+			continue
+		}
+		seen[m[1]] = true
+
+		col := columnSpec{
+			Name:        m[1],
+			Type:        m[2],
+			Description: joinStrings(m[3]),
+		}
+		for _, opt := range optionRe.FindAllStringSubmatch(m[4], -1) {
+			switch opt[1] {
+			case "index":
+				col.Index = true
+			case "required":
+				col.Required = true
+			case "additional":
+				col.Additional = true
+			case "optimized":
+				col.Optimized = true
+			case "hidden":
+				col.Hidden = true
+			}
+		}
+		spec.Columns = append(spec.Columns, col)
+	}
+
+	if m := attributesRe.FindStringSubmatch(src); m != nil {
+		for _, opt := range optionRe.FindAllStringSubmatch(m[1], -1) {
+			switch opt[1] {
+			case "cacheable":
+				spec.Attributes.Cacheable = true
+			case "event_subscriber":
+				spec.Attributes.EventSubscriber = true
+			case "user_data":
+				spec.Attributes.UserData = true
+			case "utility":
+				spec.Attributes.Utility = true
+			}
+		}
+	}
+
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// joinStrings concatenates adjacent Python string literals.
+func joinStrings(literals string) string {
+	var b strings.Builder
+	for _, m := range stringRe.FindAllStringSubmatch(literals, -1) {
+		b.WriteString(strings.ReplaceAll(m[1], `\"`, `"`))
+	}
+	return b.String()
+}
+
+func (s *tableSpec) validate() error {
+	if s.Name == "" {
+		return errors.New("table name is required")
+	}
+	if len(s.Columns) == 0 {
+		return errors.Errorf("table %s has no columns", s.Name)
+	}
+	for _, col := range s.Columns {
+		if col.Name == "" {
+			return errors.Errorf("table %s has a column without a name", s.Name)
+		}
+		if _, ok := columnTypes[col.Type]; !ok {
+			return errors.Errorf("column %s has unsupported type %q", col.Name, col.Type)
+		}
+	}
+	return nil
+}
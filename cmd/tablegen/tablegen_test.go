@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTableSpec(t *testing.T) {
+	data, err := os.ReadFile("testdata/processes.table")
+	require.NoError(t, err)
+
+	spec, err := parseTableSpec(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "processes", spec.Name)
+	assert.Equal(t, "All running processes on the host system.", spec.Description)
+	assert.Equal(t, attributes{Cacheable: true, UserData: true}, spec.Attributes)
+	require.Len(t, spec.Columns, 8)
+	assert.Equal(t, columnSpec{Name: "pid", Type: "BIGINT", Description: "Process (or thread) ID", Index: true}, spec.Columns[0])
+	assert.Equal(t, "CPU time in milliseconds spent in user space", spec.Columns[4].Description)
+	assert.Equal(t, columnSpec{Name: "hash", Type: "BLOB"}, spec.Columns[6])
+	// Columns redeclared by extended schemas are only included once
+	assert.Equal(t, columnSpec{
+		Name:        "cgroup_path",
+		Type:        "TEXT",
+		Description: "The full hierarchical path of the process's control group",
+		Additional:  true,
+		Optimized:   true,
+	}, spec.Columns[7])
+
+	_, err = parseTableSpec([]byte(`schema([Column("pid", BIGINT)])`))
+	assert.Error(t, err)
+	_, err = parseTableSpec([]byte(`table_name("foo")`))
+	assert.Error(t, err)
+	_, err = parseTableSpec([]byte(`table_name("foo") schema([Column("pid", BOGUS)])`))
+	assert.Error(t, err)
+}
+
+func TestParseJSONSpec(t *testing.T) {
+	spec, err := parseJSONSpec([]byte(`{
+		"name": "users",
+		"columns": [
+			{"name": "uid", "type": "unsigned bigint", "index": true},
+			{"name": "username", "type": "TEXT", "required": true}
+		],
+		"attributes": {"utility": true}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, &tableSpec{
+		Name: "users",
+		Columns: []columnSpec{
+			{Name: "uid", Type: "UNSIGNED_BIGINT", Index: true},
+			{Name: "username", Type: "TEXT", Required: true},
+		},
+		Attributes: attributes{Utility: true},
+	}, spec)
+
+	_, err = parseJSONSpec([]byte(`{"name": "users"}`))
+	assert.Error(t, err)
+	_, err = parseJSONSpec([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "processes.go")
+	require.NoError(t, run("testdata/processes.table", "tables", out))
+
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	for _, expected := range []string{
+		"// Code scaffolded by tablegen from processes.table.",
+		"package tables",
+		`return table.NewPlugin("processes", ProcessesColumns(), ProcessesGenerate, table.WithCacheable(), table.WithAttributes(table.TableAttributeUserBased))`,
+		`table.BigIntColumn("pid", table.ColumnIndex()),`,
+		`table.TextColumn("cgroup_path", table.ColumnAdditional(), table.ColumnOptimized()),`,
+		"\tPID int64 `column:\"pid\"`",
+		`row.SetInt("on_disk", int64(r.OnDisk))`,
+		"func ProcessesGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {",
+	} {
+		assert.Contains(t, string(src), expected)
+	}
+
+	assert.Error(t, run("testdata/missing.table", "tables", out))
+}
@@ -0,0 +1,80 @@
+package osquery
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Environment variables configuring clients and servers created with the
+// ClientFromEnv and ServerFromEnv options, for deployments where passing
+// flags is awkward, such as containers.
+const (
+	// SocketEnv is the path to the extensions socket of osquery.
+	SocketEnv = "OSQUERY_EXTENSION_SOCKET"
+	// TimeoutEnv is the timeout to open the socket, and of the server
+	// requests to osquery, in whole seconds or as a Go duration such as
+	// "500ms".
+	TimeoutEnv = "OSQUERY_EXTENSION_TIMEOUT"
+	// IntervalEnv is the delay between the pings of osquery by the server,
+	// in the format of TimeoutEnv.
+	IntervalEnv = "OSQUERY_EXTENSION_INTERVAL"
+)
+
+// ClientFromEnv makes NewClient read the socket path from SocketEnv when the
+// path is empty, before falling back to DefaultSocketPath, and the timeout
+// from TimeoutEnv when it is zero.
+func ClientFromEnv() ClientOption {
+	return func(c *ExtensionManagerClient) {
+		c.fromEnv = true
+	}
+}
+
+// ServerFromEnv makes NewExtensionManagerServer read the socket path from
+// SocketEnv when the path is empty, and sets the timeout and ping interval
+// from TimeoutEnv and IntervalEnv when set. Options following it take
+// precedence over the environment.
+func ServerFromEnv() ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.socketFromEnv = true
+		if timeout, ok, err := durationEnv(TimeoutEnv); err != nil {
+			s.envErr = err
+		} else if ok {
+			s.timeout = timeout
+		}
+		if interval, ok, err := durationEnv(IntervalEnv); err != nil {
+			s.envErr = err
+		} else if ok {
+			s.pingInterval = interval
+		}
+	}
+}
+
+// durationEnv returns the duration set in the environment variable, and
+// whether it is set.
+func durationEnv(name string) (time.Duration, bool, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return 0, false, nil
+	}
+	d, err := ParseSeconds(value)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "parsing %s", name)
+	}
+	return d, true, nil
+}
+
+// ParseSeconds parses a duration in whole seconds, as passed by osquery to
+// extensions, or in the format of time.ParseDuration.
+func ParseSeconds(value string) (time.Duration, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
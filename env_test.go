@@ -0,0 +1,82 @@
+package osquery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSeconds(t *testing.T) {
+	d, err := ParseSeconds("3")
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, d)
+	d, err = ParseSeconds("250ms")
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, d)
+	_, err = ParseSeconds("soon")
+	assert.Error(t, err)
+}
+
+func TestServerFromEnv(t *testing.T) {
+	t.Setenv(SocketEnv, "/env/osquery.em")
+	t.Setenv(TimeoutEnv, "7")
+	t.Setenv(IntervalEnv, "2s")
+
+	server, err := NewExtensionManagerServer("env", "", ServerFromEnv(), WithClient(&mock.Manager{}))
+	require.NoError(t, err)
+	assert.Equal(t, "/env/osquery.em", server.sockPath)
+	assert.Equal(t, 7*time.Second, server.timeout)
+	assert.Equal(t, 2*time.Second, server.pingInterval)
+
+	// Arguments and later options take precedence over the environment
+	server, err = NewExtensionManagerServer("env", "/arg/osquery.em", ServerFromEnv(), ServerTimeout(time.Second), WithClient(&mock.Manager{}))
+	require.NoError(t, err)
+	assert.Equal(t, "/arg/osquery.em", server.sockPath)
+	assert.Equal(t, time.Second, server.timeout)
+
+	// The environment is ignored without ServerFromEnv, the default
+	// socket is used instead.
+	defer func(paths []string) { defaultSocketPaths = paths }(defaultSocketPaths)
+	defaultSocketPaths = []string{filepath.Join(t.TempDir(), "missing.em")}
+	_, err = NewExtensionManagerServer("env", "", WithClient(&mock.Manager{}))
+	assert.ErrorContains(t, err, "no osquery extensions socket found")
+
+	defaultPath := filepath.Join(t.TempDir(), "osquery.em")
+	require.NoError(t, os.WriteFile(defaultPath, nil, 0644))
+	defaultSocketPaths = []string{defaultPath}
+	server, err = NewExtensionManagerServer("env", "", WithClient(&mock.Manager{}))
+	require.NoError(t, err)
+	assert.Equal(t, defaultPath, server.sockPath)
+	assert.Equal(t, defaultTimeout, server.timeout)
+
+	// An empty environment variable falls back to the default socket
+	t.Setenv(SocketEnv, "")
+	server, err = NewExtensionManagerServer("env", "", ServerFromEnv(), WithClient(&mock.Manager{}))
+	require.NoError(t, err)
+	assert.Equal(t, defaultPath, server.sockPath)
+
+	t.Setenv(IntervalEnv, "often")
+	_, err = NewExtensionManagerServer("env", "", ServerFromEnv(), WithClient(&mock.Manager{}))
+	assert.ErrorContains(t, err, IntervalEnv)
+}
+
+func TestClientFromEnv(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "missing.em")
+	t.Setenv(SocketEnv, sockPath)
+	t.Setenv(TimeoutEnv, "300ms")
+
+	start := time.Now()
+	_, err := NewClient("", 0, ClientFromEnv())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), sockPath)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	t.Setenv(TimeoutEnv, "often")
+	_, err = NewClient("", 0, ClientFromEnv())
+	assert.ErrorContains(t, err, TimeoutEnv)
+}
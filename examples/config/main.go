@@ -2,41 +2,19 @@ package main
 
 import (
 	"context"
-	"flag"
 	"log"
-	"time"
 
-	"github.com/osquery/osquery-go"
+	"github.com/osquery/osquery-go/extflags"
 	"github.com/osquery/osquery-go/plugin/config"
 )
 
-var (
-	socket   = flag.String("socket", "", "Path to the extensions UNIX domain socket")
-	timeout  = flag.Int("timeout", 3, "Seconds to wait for autoloaded extensions")
-	interval = flag.Int("interval", 3, "Seconds delay between connectivity checks")
-)
-
 func main() {
-	flag.Parse()
-
-	if *socket == "" {
-		log.Fatalln("Missing required --socket argument")
+	flags, err := extflags.Parse()
+	if err != nil {
+		log.Fatalln(err)
 	}
 
-	serverTimeout := osquery.ServerTimeout(
-		time.Second * time.Duration(*timeout),
-	)
-	serverPingInterval := osquery.ServerPingInterval(
-		time.Second * time.Duration(*interval),
-	)
-
-	server, err := osquery.NewExtensionManagerServer(
-		"example_extension",
-		*socket,
-		serverTimeout,
-		serverPingInterval,
-	)
-
+	server, err := flags.NewServer("example_extension")
 	if err != nil {
 		log.Fatalf("Error creating extension: %s\n", err)
 	}
@@ -2,21 +2,20 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"log"
 
-	"github.com/osquery/osquery-go"
+	"github.com/osquery/osquery-go/extflags"
 	"github.com/osquery/osquery-go/plugin/distributed"
 )
 
 func main() {
-	socketPath := flag.String("socket", "", "path to osqueryd extensions socket")
-	flag.Int("timeout", 0, "")
-	flag.Int("interval", 0, "")
-	flag.Parse()
+	flags, err := extflags.Parse()
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	server, err := osquery.NewExtensionManagerServer("example_distributed", *socketPath)
+	server, err := flags.NewServer("example_distributed")
 	if err != nil {
 		log.Fatalf("Error creating extension: %s\n", err)
 	}
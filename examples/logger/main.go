@@ -2,20 +2,19 @@ package main
 
 import (
 	"context"
-	"flag"
 	"log"
 
-	"github.com/osquery/osquery-go"
+	"github.com/osquery/osquery-go/extflags"
 	"github.com/osquery/osquery-go/plugin/logger"
 )
 
 func main() {
-	socketPath := flag.String("socket", "", "path to osqueryd extensions socket")
-	flag.Int("timeout", 0, "")
-	flag.Int("interval", 0, "")
-	flag.Parse()
+	flags, err := extflags.Parse()
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	server, err := osquery.NewExtensionManagerServer("example_logger", *socketPath)
+	server, err := flags.NewServer("example_logger")
 	if err != nil {
 		log.Fatalf("Error creating extension: %s\n", err)
 	}
@@ -0,0 +1,68 @@
+package osquery
+
+import (
+	"expvar"
+	"sync"
+)
+
+// ExpvarName is the name of the expvar map the servers created with
+// ServerExpvar are published in.
+const ExpvarName = "osquery_go"
+
+var (
+	expvarOnce    sync.Once
+	expvarServers *expvar.Map
+)
+
+// ServerExpvar publishes the counters of the server with the expvar package,
+// under the extension name in the ExpvarName map: the plugin calls and
+// errors, the reconnections to osquery, and the calls of the server's client
+// that gave up waiting for the osquery socket, on timeout or cancellation.
+// They are served at /debug/vars by net/http's default mux, for debugging in
+// the field without any dependency. A server created later with the same
+// name replaces the previous one.
+func ServerExpvar() ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.expvarEnabled = true
+	}
+}
+
+// publishExpvar publishes the counters of the server.
+func (s *ExtensionManagerServer) publishExpvar() {
+	expvarOnce.Do(func() {
+		expvarServers = expvar.NewMap(ExpvarName)
+	})
+	expvarServers.Set(s.name, expvar.Func(s.expvarValue))
+}
+
+// expvarValue returns the counters published by ServerExpvar.
+func (s *ExtensionManagerServer) expvarValue() interface{} {
+	var calls, errors uint64
+	plugins := map[string]interface{}{}
+	for _, st := range s.PluginStats() {
+		calls += st.Calls
+		errors += st.Errors
+		plugins[st.Registry+"/"+st.Name] = map[string]interface{}{
+			"calls":             st.Calls,
+			"errors":            st.Errors,
+			"total_duration_ms": st.TotalDuration.Milliseconds(),
+		}
+	}
+
+	s.mutex.Lock()
+	client, _ := s.serverClient.(*ExtensionManagerClient)
+	s.mutex.Unlock()
+	var lockTimeouts uint64
+	if client != nil {
+		lockTimeouts = client.LockStats().Failed
+	}
+
+	return map[string]interface{}{
+		"state":         s.State().String(),
+		"calls":         calls,
+		"errors":        errors,
+		"reconnects":    s.Reconnects(),
+		"lock_timeouts": lockTimeouts,
+		"plugins":       plugins,
+	}
+}
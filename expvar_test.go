@@ -0,0 +1,46 @@
+package osquery
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerExpvar(t *testing.T) {
+	server, err := NewExtensionManagerServer("expvarext", "/tmp/osquery.sock", WithClient(&MockExtensionManager{}), ServerExpvar())
+	require.NoError(t, err)
+	log := func(ctx context.Context, typ logger.LogType, logText string) error { return nil }
+	server.RegisterPlugin(logger.NewPlugin("mylogger", log))
+	_, err = server.Call(context.Background(), "logger", "mylogger", osquery.ExtensionPluginRequest{"string": "log"})
+	require.NoError(t, err)
+	_, err = server.Call(context.Background(), "logger", "mylogger", osquery.ExtensionPluginRequest{})
+	require.NoError(t, err)
+	server.supervisorEvent(SupervisorReconnected, nil)
+
+	published := expvar.Get(ExpvarName).(*expvar.Map).Get("expvarext")
+	require.NotNil(t, published)
+	var value map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(published.String()), &value))
+	plugin := value["plugins"].(map[string]interface{})["logger/mylogger"].(map[string]interface{})
+	assert.Contains(t, plugin, "total_duration_ms")
+	delete(plugin, "total_duration_ms")
+	assert.Equal(t, map[string]interface{}{
+		"state":         "created",
+		"calls":         float64(2),
+		"errors":        float64(1),
+		"reconnects":    float64(1),
+		"lock_timeouts": float64(0),
+		"plugins": map[string]interface{}{
+			"logger/mylogger": map[string]interface{}{
+				"calls":  float64(2),
+				"errors": float64(1),
+			},
+		},
+	}, value)
+}
@@ -0,0 +1,166 @@
+// Package extflags parses the flags osqueryd passes to the extensions it
+// autoloads, --socket, --timeout, --interval and --verbose, with fallbacks to
+// environment variables for extensions started by other means, such as in
+// containers.
+//
+//	func main() {
+//		flags, err := extflags.Parse()
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		server, err := flags.NewServer("example_extension")
+//		...
+//	}
+//
+// Extensions defining flags of their own register these on the same flag
+// set with Register, and call Resolve after parsing it.
+package extflags
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	"github.com/pkg/errors"
+)
+
+// Environment variables read for the flags not set on the command line. The
+// socket, timeout and interval variables are those of osquery.ServerFromEnv.
+const (
+	SocketEnv   = osquery.SocketEnv
+	TimeoutEnv  = osquery.TimeoutEnv
+	IntervalEnv = osquery.IntervalEnv
+	VerboseEnv  = "OSQUERY_EXTENSION_VERBOSE"
+)
+
+// Defaults of the timeout and interval, matching those of osqueryd.
+const (
+	DefaultTimeout  = 3 * time.Second
+	DefaultInterval = 3 * time.Second
+)
+
+// Flags are the values of the extension flags.
+type Flags struct {
+	// Socket is the path to the extensions socket of osqueryd.
+	Socket string
+	// Timeout is how long to wait for the socket, and for osqueryd to
+	// respond.
+	Timeout time.Duration
+	// Interval is the delay between the pings of osqueryd.
+	Interval time.Duration
+	// Verbose is set when osqueryd runs with --verbose. The library logs
+	// then follow, see Resolve.
+	Verbose bool
+
+	fs *flag.FlagSet
+}
+
+// Register defines the extension flags on fs. The returned Flags hold their
+// values once fs is parsed and Resolve called.
+func Register(fs *flag.FlagSet) *Flags {
+	f := &Flags{Timeout: DefaultTimeout, Interval: DefaultInterval, fs: fs}
+	fs.StringVar(&f.Socket, "socket", "", "Path to the extensions socket of osqueryd (env "+SocketEnv+")")
+	fs.Var((*seconds)(&f.Timeout), "timeout", "Seconds to wait for osqueryd (env "+TimeoutEnv+")")
+	fs.Var((*seconds)(&f.Interval), "interval", "Seconds between pings of osqueryd (env "+IntervalEnv+")")
+	fs.BoolVar(&f.Verbose, "verbose", false, "Enable verbose logging, set when osqueryd runs with --verbose (env "+VerboseEnv+")")
+	return f
+}
+
+// Parse registers the extension flags on flag.CommandLine, parses the
+// command line and resolves the flags.
+func Parse() (*Flags, error) {
+	f := Register(flag.CommandLine)
+	flag.Parse()
+	if err := f.Resolve(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Resolve sets the flags not given on the command line from their
+// environment variable, if set, and checks that the socket is set. When
+// verbose, the library logs are raised to debug with osquery.SetVerbose. It
+// is called after the flag set is parsed.
+func (f *Flags) Resolve() error {
+	set := map[string]bool{}
+	if f.fs != nil {
+		f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	}
+
+	if value, ok := os.LookupEnv(SocketEnv); ok && !set["socket"] {
+		f.Socket = value
+	}
+	for _, d := range []struct {
+		name, env string
+		value     *time.Duration
+	}{
+		{"timeout", TimeoutEnv, &f.Timeout},
+		{"interval", IntervalEnv, &f.Interval},
+	} {
+		value, ok := os.LookupEnv(d.env)
+		if !ok || set[d.name] {
+			continue
+		}
+		if err := (*seconds)(d.value).Set(value); err != nil {
+			return errors.Wrapf(err, "parsing %s", d.env)
+		}
+	}
+	if value, ok := os.LookupEnv(VerboseEnv); ok && !set["verbose"] {
+		verbose, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Wrapf(err, "parsing %s", VerboseEnv)
+		}
+		f.Verbose = verbose
+	}
+
+	if f.Verbose {
+		osquery.SetVerbose(true)
+	}
+
+	if f.Socket == "" {
+		return errors.New("missing required --socket argument or " + SocketEnv)
+	}
+	return nil
+}
+
+// ServerOptions returns the options of an extension server with the timeout
+// and ping interval of the flags.
+func (f *Flags) ServerOptions() []osquery.ServerOption {
+	return []osquery.ServerOption{
+		osquery.ServerTimeout(f.Timeout),
+		osquery.ServerPingInterval(f.Interval),
+	}
+}
+
+// NewServer creates an extension server on the socket of the flags, with
+// ServerOptions followed by opts.
+func (f *Flags) NewServer(name string, opts ...osquery.ServerOption) (*osquery.ExtensionManagerServer, error) {
+	return osquery.NewExtensionManagerServer(name, f.Socket, append(f.ServerOptions(), opts...)...)
+}
+
+// NewClient creates a client of osqueryd on the socket of the flags.
+func (f *Flags) NewClient(opts ...osquery.ClientOption) (*osquery.ExtensionManagerClient, error) {
+	return osquery.NewClient(f.Socket, f.Timeout, opts...)
+}
+
+// seconds is a duration flag accepting whole seconds, as passed by osqueryd,
+// or Go durations such as "500ms".
+type seconds time.Duration
+
+func (s *seconds) String() string {
+	if s == nil {
+		return ""
+	}
+	return time.Duration(*s).String()
+}
+
+func (s *seconds) Set(value string) error {
+	d, err := osquery.ParseSeconds(value)
+	if err != nil {
+		return err
+	}
+	*s = seconds(d)
+	return nil
+}
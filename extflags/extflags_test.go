@@ -0,0 +1,87 @@
+package extflags
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, args ...string) (*Flags, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := Register(fs)
+	require.NoError(t, fs.Parse(args))
+	t.Cleanup(func() { osquery.SetVerbose(false) })
+	return f, f.Resolve()
+}
+
+func TestOsquerydArgs(t *testing.T) {
+	// The arguments osqueryd passes to autoloaded extensions
+	f, err := parse(t, "--socket", "/var/osquery/osquery.em", "--timeout", "10", "--interval", "5", "--verbose")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/osquery/osquery.em", f.Socket)
+	assert.Equal(t, 10*time.Second, f.Timeout)
+	assert.Equal(t, 5*time.Second, f.Interval)
+	assert.True(t, f.Verbose)
+	assert.Len(t, f.ServerOptions(), 2)
+
+	f, err = parse(t, "--socket=/tmp/osquery.em", "--timeout=500ms")
+	require.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, f.Timeout)
+	assert.Equal(t, DefaultInterval, f.Interval)
+	assert.False(t, f.Verbose)
+}
+
+func TestMissingSocket(t *testing.T) {
+	_, err := parse(t, "--timeout", "1")
+	assert.Error(t, err)
+}
+
+func TestInvalidDuration(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	Register(fs)
+	assert.Error(t, fs.Parse([]string{"--timeout", "soon"}))
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv(SocketEnv, "/env/osquery.em")
+	t.Setenv(TimeoutEnv, "7")
+	t.Setenv(IntervalEnv, "2s")
+	t.Setenv(VerboseEnv, "true")
+
+	f, err := parse(t)
+	require.NoError(t, err)
+	assert.Equal(t, "/env/osquery.em", f.Socket)
+	assert.Equal(t, 7*time.Second, f.Timeout)
+	assert.Equal(t, 2*time.Second, f.Interval)
+	assert.True(t, f.Verbose)
+
+	// Flags take precedence over the environment
+	f, err = parse(t, "--socket", "/flag/osquery.em", "--timeout", "1", "--verbose=false")
+	require.NoError(t, err)
+	assert.Equal(t, "/flag/osquery.em", f.Socket)
+	assert.Equal(t, time.Second, f.Timeout)
+	assert.Equal(t, 2*time.Second, f.Interval)
+	assert.False(t, f.Verbose)
+
+	t.Setenv(IntervalEnv, "often")
+	_, err = parse(t)
+	assert.Error(t, err)
+}
+
+func TestVerbose(t *testing.T) {
+	_, err := parse(t, "--socket", "/tmp/osquery.em")
+	require.NoError(t, err)
+	assert.Equal(t, osquery.DefaultLogLevel, osquery.LogLevel())
+
+	_, err = parse(t, "--socket", "/tmp/osquery.em", "--verbose")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, osquery.LogLevel())
+}
@@ -0,0 +1,72 @@
+//go:build !windows
+// +build !windows
+
+package fakeosqueryd
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// trackingTransport is a server transport that closes the connections it
+// accepted when interrupted. The Thrift server waits for its connections to
+// end when stopped, and extensions keep their connection to osquery open.
+type trackingTransport struct {
+	thrift.TServerTransport
+
+	mutex       sync.Mutex
+	interrupted bool
+	conns       []*trackedConn
+}
+
+func (t *trackingTransport) Accept() (thrift.TTransport, error) {
+	trans, err := t.TServerTransport.Accept()
+	if err != nil {
+		return nil, err
+	}
+	conn := &trackedConn{TTransport: trans}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.interrupted {
+		conn.close()
+		return nil, thrift.NewTTransportException(thrift.NOT_OPEN, "transport interrupted")
+	}
+	t.conns = append(t.conns, conn)
+	return conn, nil
+}
+
+func (t *trackingTransport) Interrupt() error {
+	err := t.TServerTransport.Interrupt()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.interrupted = true
+	for _, conn := range t.conns {
+		conn.close()
+	}
+	t.conns = nil
+	return err
+}
+
+// trackedConn is an accepted connection. Reads failing after the connection
+// is closed by the fake report the end of the connection, which the Thrift
+// server expects from clients disconnecting.
+type trackedConn struct {
+	thrift.TTransport
+	closed atomic.Bool
+}
+
+func (c *trackedConn) Read(p []byte) (int, error) {
+	n, err := c.TTransport.Read(p)
+	if err != nil && c.closed.Load() {
+		return n, thrift.NewTTransportExceptionFromError(io.EOF)
+	}
+	return n, err
+}
+
+func (c *trackedConn) close() {
+	c.closed.Store(true)
+	c.TTransport.Close()
+}
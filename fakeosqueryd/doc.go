@@ -0,0 +1,19 @@
+// Package fakeosqueryd provides an in-process fake of osqueryd's extension
+// manager, serving the ExtensionManager Thrift service on a socket, so that
+// extensions and clients can be tested end-to-end without osqueryd.
+//
+// The fake registers extensions and routes plugin calls to them over their
+// sockets as osqueryd does:
+//
+//	osqueryd, err := fakeosqueryd.Start(filepath.Join(t.TempDir(), "osquery.em"))
+//	defer osqueryd.Close()
+//	server, err := osquery.NewExtensionManagerServer("example", osqueryd.SocketPath())
+//	server.RegisterPlugin(plugin)
+//	go server.Run()
+//	ext, err := osqueryd.WaitRegistered(ctx, "example")
+//	resp, err := osqueryd.Call(ctx, "table", "example_table", osquery.ExtensionPluginRequest{"action": "generate"})
+//
+// The fake listens on Unix domain sockets, and is not available on Windows.
+// It does not run SQL. Query and GetQueryColumns answer SELECT * FROM a table
+// of a registered extension, unless a QueryFunc is set with WithQuery.
+package fakeosqueryd
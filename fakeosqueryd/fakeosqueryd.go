@@ -0,0 +1,444 @@
+//go:build !windows
+// +build !windows
+
+package fakeosqueryd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/transport"
+	"github.com/pkg/errors"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// QueryFunc answers the queries sent to the fake with Query, returning the
+// rows of the query.
+type QueryFunc func(ctx context.Context, sql string) ([]map[string]string, error)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithOptions sets the osquery flags returned by Options, by name.
+func WithOptions(options map[string]string) Option {
+	return func(s *Server) {
+		for name, value := range options {
+			s.options[name] = value
+		}
+	}
+}
+
+// WithQuery sets the function answering the queries sent to the fake.
+func WithQuery(fn QueryFunc) Option {
+	return func(s *Server) {
+		s.query = fn
+	}
+}
+
+// WithFraming sets the framing of the Thrift messages, for the manager socket
+// and the extension sockets. It defaults to transport.FramingNone, as osquery.
+func WithFraming(f transport.Framing) Option {
+	return func(s *Server) {
+		s.framing = f
+	}
+}
+
+// WithTimeout sets the timeout opening the sockets of the extensions. It
+// defaults to 5 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.timeout = timeout
+	}
+}
+
+// Extension is an extension registered with the fake.
+type Extension struct {
+	UUID     osquery.ExtensionRouteUUID
+	Info     osquery.InternalExtensionInfo
+	Registry osquery.ExtensionRegistry
+}
+
+// Server is a fake osqueryd extension manager. It is safe for concurrent
+// use.
+type Server struct {
+	sockPath string
+	options  map[string]string
+	query    QueryFunc
+	framing  transport.Framing
+	timeout  time.Duration
+
+	server    *thrift.TSimpleServer
+	served    chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	mutex      sync.Mutex
+	nextUUID   osquery.ExtensionRouteUUID
+	extensions map[osquery.ExtensionRouteUUID]Extension
+	// changed is closed and replaced when extensions register or
+	// deregister.
+	changed chan struct{}
+}
+
+// Start starts a fake extension manager listening on sockPath, the path of
+// the osquery extensions socket given to extensions. Extensions listen on
+// sockPath followed by their UUID, as with osqueryd.
+func Start(sockPath string, opts ...Option) (*Server, error) {
+	s := &Server{
+		sockPath:   sockPath,
+		options:    map[string]string{"extensions_socket": sockPath},
+		timeout:    defaultTimeout,
+		served:     make(chan struct{}),
+		nextUUID:   1,
+		extensions: map[osquery.ExtensionRouteUUID]Extension{},
+		changed:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	trans, err := transport.OpenServer(sockPath, s.timeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening socket (%s)", sockPath)
+	}
+	if err := trans.Listen(); err != nil {
+		return nil, errors.Wrapf(err, "listening on socket (%s)", sockPath)
+	}
+	s.server = thrift.NewTSimpleServer4(
+		osquery.NewExtensionManagerProcessor(handler{s}),
+		&trackingTransport{TServerTransport: trans},
+		s.framing.TransportFactory(),
+		s.framing.ProtocolFactory(),
+	)
+	go func() {
+		defer close(s.served)
+		s.server.Serve()
+	}()
+	return s, nil
+}
+
+// SocketPath returns the path of the extensions socket of the fake.
+func (s *Server) SocketPath() string {
+	return s.sockPath
+}
+
+// Close stops the fake, as if osqueryd exited. Registered extensions notice
+// it the next time they ping osquery.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.server.Stop()
+		<-s.served
+		if err := transport.CloseServer(s.sockPath); err != nil && s.closeErr == nil {
+			s.closeErr = err
+		}
+	})
+	return s.closeErr
+}
+
+// Extensions returns the registered extensions, ordered by UUID.
+func (s *Server) Extensions() []Extension {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	extensions := make([]Extension, 0, len(s.extensions))
+	for _, ext := range s.extensions {
+		extensions = append(extensions, ext)
+	}
+	sort.Slice(extensions, func(i, j int) bool { return extensions[i].UUID < extensions[j].UUID })
+	return extensions
+}
+
+// WaitRegistered waits for an extension with the name to be registered, and
+// returns it.
+func (s *Server) WaitRegistered(ctx context.Context, name string) (Extension, error) {
+	for {
+		s.mutex.Lock()
+		ext, ok := s.extensionNamed(name)
+		changed := s.changed
+		s.mutex.Unlock()
+		if ok {
+			return ext, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return Extension{}, errors.Wrapf(ctx.Err(), "waiting for extension %s", name)
+		}
+	}
+}
+
+// WaitDeregistered waits for the extension with the UUID to be deregistered.
+func (s *Server) WaitDeregistered(ctx context.Context, uuid osquery.ExtensionRouteUUID) error {
+	for {
+		s.mutex.Lock()
+		_, ok := s.extensions[uuid]
+		changed := s.changed
+		s.mutex.Unlock()
+		if !ok {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for extension %d to deregister", uuid)
+		}
+	}
+}
+
+// Call calls the plugin of a registered extension, as osqueryd does when
+// the plugin is used.
+func (s *Server) Call(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+	uuid, ok := s.route(registry, item)
+	if !ok {
+		return &osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{Code: 1, Message: fmt.Sprintf("No registry item %s in registry %s", item, registry)},
+		}, nil
+	}
+	var resp *osquery.ExtensionResponse
+	err := s.withExtension(ctx, uuid, func(client *osquery.ExtensionClient) error {
+		var err error
+		resp, err = client.Call(ctx, registry, item, request)
+		return err
+	})
+	return resp, err
+}
+
+// Ping pings the extension with the UUID, as osqueryd does periodically.
+func (s *Server) Ping(ctx context.Context, uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+	var status *osquery.ExtensionStatus
+	err := s.withExtension(ctx, uuid, func(client *osquery.ExtensionClient) error {
+		var err error
+		status, err = client.Ping(ctx)
+		return err
+	})
+	return status, err
+}
+
+// ShutdownExtension asks the extension with the UUID to shut down, as
+// osqueryd does when it exits.
+func (s *Server) ShutdownExtension(ctx context.Context, uuid osquery.ExtensionRouteUUID) error {
+	return s.withExtension(ctx, uuid, func(client *osquery.ExtensionClient) error {
+		return client.Shutdown(ctx)
+	})
+}
+
+// withExtension calls fn with a client connected to the socket of the
+// extension.
+func (s *Server) withExtension(ctx context.Context, uuid osquery.ExtensionRouteUUID, fn func(client *osquery.ExtensionClient) error) error {
+	path := fmt.Sprintf("%s.%d", s.sockPath, uuid)
+	if err := s.waitForSocket(ctx, uuid, path); err != nil {
+		return err
+	}
+	trans := thrift.NewTSocketFromAddrTimeout(&net.UnixAddr{Name: path, Net: "unix"}, s.timeout, s.timeout)
+	if err := trans.Open(); err != nil {
+		return errors.Wrapf(err, "connecting to extension %d", uuid)
+	}
+	defer trans.Close()
+	client := osquery.NewExtensionClientFactory(s.framing.Wrap(trans), s.framing.ProtocolFactory())
+	return errors.Wrapf(fn(client), "calling extension %d", uuid)
+}
+
+// waitForSocket waits for the extension to listen on its socket, which it
+// does after registering. It fails as soon as the extension deregisters.
+func (s *Server) waitForSocket(ctx context.Context, uuid osquery.ExtensionRouteUUID, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.mutex.Lock()
+		_, ok := s.extensions[uuid]
+		changed := s.changed
+		s.mutex.Unlock()
+		if !ok {
+			return errors.Errorf("no extension with UUID %d", uuid)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for socket of extension %d", uuid)
+		}
+	}
+}
+
+// route returns the UUID of the extension registering the plugin.
+func (s *Server) route(registry, item string) (osquery.ExtensionRouteUUID, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for uuid, ext := range s.extensions {
+		if _, ok := ext.Registry[registry][item]; ok {
+			return uuid, true
+		}
+	}
+	return 0, false
+}
+
+// extensionNamed returns the registered extension with the name. The mutex
+// must be held.
+func (s *Server) extensionNamed(name string) (Extension, bool) {
+	for _, ext := range s.extensions {
+		if ext.Info.Name == name {
+			return ext, true
+		}
+	}
+	return Extension{}, false
+}
+
+// notify wakes up the waits for extensions. The mutex must be held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) register(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) *osquery.ExtensionStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.extensionNamed(info.Name); ok {
+		return &osquery.ExtensionStatus{Code: 1, Message: "Duplicate extension registered"}
+	}
+	for _, ext := range s.extensions {
+		for name, routes := range registry {
+			for item := range routes {
+				if _, ok := ext.Registry[name][item]; ok {
+					return &osquery.ExtensionStatus{Code: 1, Message: "Duplicate registry item: " + item}
+				}
+			}
+		}
+	}
+
+	uuid := s.nextUUID
+	s.nextUUID++
+	s.extensions[uuid] = Extension{UUID: uuid, Info: *info, Registry: registry}
+	s.notify()
+	return &osquery.ExtensionStatus{Code: 0, Message: "OK", UUID: uuid}
+}
+
+func (s *Server) deregister(uuid osquery.ExtensionRouteUUID) *osquery.ExtensionStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.extensions[uuid]; !ok {
+		return &osquery.ExtensionStatus{Code: 1, Message: "No extension UUID registered"}
+	}
+	delete(s.extensions, uuid)
+	s.notify()
+	return &osquery.ExtensionStatus{Code: 0, Message: "OK"}
+}
+
+// selectAll matches the queries the fake answers without a QueryFunc.
+var selectAll = regexp.MustCompile(`(?i)^\s*select\s+\*\s+from\s+(\w+)\s*;?\s*$`)
+
+func (s *Server) runQuery(ctx context.Context, sql string) ([]map[string]string, error) {
+	if s.query != nil {
+		return s.query(ctx, sql)
+	}
+	match := selectAll.FindStringSubmatch(sql)
+	if match == nil {
+		return nil, errors.Errorf("unsupported query: %s", sql)
+	}
+	return s.callTable(ctx, match[1], osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+}
+
+func (s *Server) queryColumns(ctx context.Context, sql string) ([]map[string]string, error) {
+	match := selectAll.FindStringSubmatch(sql)
+	if match == nil {
+		return nil, errors.Errorf("unsupported query: %s", sql)
+	}
+	routes, err := s.callTable(ctx, match[1], osquery.ExtensionPluginRequest{"action": "columns"})
+	if err != nil {
+		return nil, err
+	}
+	var columns []map[string]string
+	for _, route := range routes {
+		if id, ok := route["id"]; ok && id != "column" {
+			continue
+		}
+		columns = append(columns, map[string]string{route["name"]: route["type"]})
+	}
+	return columns, nil
+}
+
+func (s *Server) callTable(ctx context.Context, name string, request osquery.ExtensionPluginRequest) ([]map[string]string, error) {
+	resp, err := s.Call(ctx, "table", name, request)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != nil && resp.Status.Code != 0 {
+		return nil, errors.New(resp.Status.Message)
+	}
+	return resp.Response, nil
+}
+
+// handler implements the ExtensionManager Thrift service for the Server.
+type handler struct {
+	s *Server
+}
+
+func (h handler) Ping(ctx context.Context) (*osquery.ExtensionStatus, error) {
+	return &osquery.ExtensionStatus{Code: 0, Message: "OK"}, nil
+}
+
+func (h handler) Call(ctx context.Context, registry string, item string, request osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+	return h.s.Call(ctx, registry, item, request)
+}
+
+// Shutdown stops the fake, as osqueryd exits when its extension manager is
+// asked to shut down. The fake stops after the response is sent.
+func (h handler) Shutdown(ctx context.Context) error {
+	go h.s.Close()
+	return nil
+}
+
+func (h handler) Extensions(ctx context.Context) (osquery.InternalExtensionList, error) {
+	list := osquery.InternalExtensionList{}
+	for _, ext := range h.s.Extensions() {
+		info := ext.Info
+		list[ext.UUID] = &info
+	}
+	return list, nil
+}
+
+func (h handler) Options(ctx context.Context) (osquery.InternalOptionList, error) {
+	list := osquery.InternalOptionList{}
+	for name, value := range h.s.options {
+		list[name] = &osquery.InternalOptionInfo{Value: value, Type: "string"}
+	}
+	return list, nil
+}
+
+func (h handler) RegisterExtension(ctx context.Context, info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+	return h.s.register(info, registry), nil
+}
+
+func (h handler) DeregisterExtension(ctx context.Context, uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+	return h.s.deregister(uuid), nil
+}
+
+func (h handler) Query(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+	return queryResponse(h.s.runQuery(ctx, sql)), nil
+}
+
+func (h handler) GetQueryColumns(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+	return queryResponse(h.s.queryColumns(ctx, sql)), nil
+}
+
+func queryResponse(rows []map[string]string, err error) *osquery.ExtensionResponse {
+	if err != nil {
+		return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 1, Message: err.Error()}}
+	}
+	return &osquery.ExtensionResponse{
+		Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+		Response: rows,
+	}
+}
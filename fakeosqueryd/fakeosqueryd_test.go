@@ -0,0 +1,145 @@
+//go:build !windows
+// +build !windows
+
+package fakeosqueryd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	gen "github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startExtension(t *testing.T, osqueryd *Server, opts ...osquery.ServerOption) (*osquery.ExtensionManagerServer, <-chan error) {
+	t.Helper()
+	server, err := osquery.NewExtensionManagerServer("example", osqueryd.SocketPath(), opts...)
+	require.NoError(t, err)
+	server.RegisterPlugin(table.NewPlugin("example_table",
+		[]table.ColumnDefinition{table.TextColumn("name"), table.IntegerColumn("size")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return []map[string]string{{"name": "a", "size": "1"}}, nil
+		},
+	))
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Run()
+	}()
+	return server, errc
+}
+
+func TestServer(t *testing.T) {
+	osqueryd, err := Start(filepath.Join(t.TempDir(), "osquery.em"), WithOptions(map[string]string{"verbose": "true"}))
+	require.NoError(t, err)
+	defer osqueryd.Close()
+
+	_, errc := startExtension(t, osqueryd)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ext, err := osqueryd.WaitRegistered(ctx, "example")
+	require.NoError(t, err)
+	assert.Contains(t, ext.Registry["table"], "example_table")
+	assert.Len(t, osqueryd.Extensions(), 1)
+
+	resp, err := osqueryd.Call(ctx, "table", "example_table", gen.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, gen.ExtensionPluginResponse{{"name": "a", "size": "1"}}, resp.Response)
+
+	resp, err = osqueryd.Call(ctx, "table", "missing", gen.ExtensionPluginRequest{"action": "generate"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+
+	status, err := osqueryd.Ping(ctx, ext.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+
+	client, err := osquery.NewClient(osqueryd.SocketPath(), time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	rows, err := client.QueryRows("SELECT * FROM example_table;")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"name": "a", "size": "1"}}, rows)
+
+	_, err = client.QueryRows("SELECT name FROM example_table WHERE size > 1")
+	assert.Error(t, err)
+
+	resp, err = client.GetQueryColumns("select * from example_table")
+	require.NoError(t, err)
+	assert.Equal(t, gen.ExtensionPluginResponse{{"name": "TEXT"}, {"size": "INTEGER"}}, resp.Response)
+
+	options, err := client.Options()
+	require.NoError(t, err)
+	assert.Equal(t, "true", options["verbose"].Value)
+	assert.Equal(t, osqueryd.SocketPath(), options["extensions_socket"].Value)
+
+	extensions, err := client.Extensions()
+	require.NoError(t, err)
+	require.Contains(t, extensions, ext.UUID)
+	assert.Equal(t, "example", extensions[ext.UUID].Name)
+
+	require.NoError(t, osqueryd.ShutdownExtension(ctx, ext.UUID))
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("extension did not stop")
+	}
+	require.NoError(t, osqueryd.WaitDeregistered(ctx, ext.UUID))
+	assert.Empty(t, osqueryd.Extensions())
+}
+
+func TestServerDuplicateExtension(t *testing.T) {
+	osqueryd, err := Start(filepath.Join(t.TempDir(), "osquery.em"))
+	require.NoError(t, err)
+	defer osqueryd.Close()
+
+	info := &gen.InternalExtensionInfo{Name: "example"}
+	status := osqueryd.register(info, gen.ExtensionRegistry{})
+	assert.Equal(t, int32(0), status.Code)
+	status = osqueryd.register(info, gen.ExtensionRegistry{})
+	assert.Equal(t, int32(1), status.Code)
+	status = osqueryd.deregister(42)
+	assert.Equal(t, int32(1), status.Code)
+}
+
+func TestServerQueryFunc(t *testing.T) {
+	osqueryd, err := Start(filepath.Join(t.TempDir(), "osquery.em"), WithQuery(func(ctx context.Context, sql string) ([]map[string]string, error) {
+		return []map[string]string{{"sql": sql}}, nil
+	}))
+	require.NoError(t, err)
+	defer osqueryd.Close()
+
+	client, err := osquery.NewClient(osqueryd.SocketPath(), time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	row, err := client.QueryRow("select version from osquery_info")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"sql": "select version from osquery_info"}, row)
+}
+
+func TestServerClose(t *testing.T) {
+	osqueryd, err := Start(filepath.Join(t.TempDir(), "osquery.em"))
+	require.NoError(t, err)
+
+	_, errc := startExtension(t, osqueryd, osquery.ServerPingInterval(100*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = osqueryd.WaitRegistered(ctx, "example")
+	require.NoError(t, err)
+
+	// The extension stops when osqueryd goes away.
+	require.NoError(t, osqueryd.Close())
+	select {
+	case err := <-errc:
+		assert.Error(t, err)
+	case <-ctx.Done():
+		t.Fatal("extension did not stop")
+	}
+}
@@ -0,0 +1,11 @@
+package osquery
+
+// The Thrift bindings of gen/osquery are generated from osquery.thrift, the
+// single definition of the extension API, by running `go generate` (or
+// `make gen`) with the Thrift compiler matching the version of
+// github.com/apache/thrift in go.mod on the PATH.
+
+//go:generate mkdir -p ./gen
+//go:generate thrift --gen go:package_prefix=github.com/osquery/osquery-go/gen/ -out ./gen ./osquery.thrift
+//go:generate rm -rf gen/osquery/extension-remote gen/osquery/extension_manager-remote
+//go:generate gofmt -w ./gen
@@ -0,0 +1,132 @@
+package osquery
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// ExtensionGroup hosts several extensions from a single process. Each
+// extension has its own name, plugins and route UUID, but all of them share
+// one connection to osquery and are run and shut down together.
+type ExtensionGroup struct {
+	sockPath          string
+	opts              []ServerOption
+	client            ExtensionManager
+	clientShouldClose bool
+
+	mutex   sync.Mutex
+	servers []*ExtensionManagerServer
+}
+
+// NewExtensionGroup creates a group of extensions communicating with osquery
+// over the socket at the provided path. The options are applied to every
+// extension in the group. If WithClient is not among them, a client is
+// created that is shared by all extensions.
+//
+// ServerPprof and ServerExpvar publish process-wide state that the extensions
+// of a group would collide on, they are rejected here and must be passed to
+// Add for at most one extension.
+func NewExtensionGroup(sockPath string, opts ...ServerOption) (*ExtensionGroup, error) {
+	// Apply the options to a template to find the client settings.
+	template := &ExtensionManagerServer{timeout: defaultTimeout}
+	for _, opt := range opts {
+		opt(template)
+	}
+	if template.pprofAddr != "" {
+		return nil, errors.New("ServerPprof cannot be applied to a group, pass it to Add")
+	}
+	if template.expvarEnabled {
+		return nil, errors.New("ServerExpvar cannot be applied to a group, pass it to Add")
+	}
+
+	g := &ExtensionGroup{
+		sockPath: sockPath,
+		opts:     opts,
+		client:   template.serverClient,
+	}
+
+	if g.client == nil {
+		client, err := NewClient(sockPath, template.timeout, ClientFraming(template.framing))
+		if err != nil {
+			return nil, err
+		}
+		g.client = client
+		g.clientShouldClose = true
+	}
+
+	return g, nil
+}
+
+// Add creates a new extension in the group. Plugins should be registered
+// with RegisterPlugin on the returned server before calling Run. The options
+// are applied after the ones the group was created with.
+func (g *ExtensionGroup) Add(name string, opts ...ServerOption) (*ExtensionManagerServer, error) {
+	allOpts := make([]ServerOption, 0, len(g.opts)+len(opts)+1)
+	allOpts = append(allOpts, g.opts...)
+	allOpts = append(allOpts, opts...)
+	allOpts = append(allOpts, WithClient(g.client))
+
+	server, err := NewExtensionManagerServer(name, g.sockPath, allOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.servers = append(g.servers, server)
+	return server, nil
+}
+
+// Servers returns the extensions in the group.
+func (g *ExtensionGroup) Servers() []*ExtensionManagerServer {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]*ExtensionManagerServer(nil), g.servers...)
+}
+
+// Run registers and runs all extensions in the group until osquery calls
+// for a shutdown of any of them or the osquery instance goes away. All
+// extensions are then shut down and the shared client closed.
+func (g *ExtensionGroup) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for _, server := range g.Servers() {
+		server := server
+		eg.Go(func() error {
+			// When one extension stops, stop all of them.
+			defer cancel()
+			return server.run(ctx)
+		})
+	}
+
+	err := eg.Wait()
+	g.closeClient()
+	return err
+}
+
+// Shutdown deregisters and stops all extensions in the group, then closes
+// the shared client. The first error encountered is returned.
+func (g *ExtensionGroup) Shutdown(ctx context.Context) error {
+	var err error
+	for _, server := range g.Servers() {
+		if serr := server.Shutdown(ctx); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	g.closeClient()
+	return err
+}
+
+func (g *ExtensionGroup) closeClient() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.clientShouldClose && g.client != nil {
+		g.client.Close()
+		g.client = nil
+	}
+}
@@ -0,0 +1,111 @@
+package osquery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtensionGroup(t *testing.T) {
+	tempPath, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+
+	var mut sync.Mutex
+	registered := map[string]osquery.ExtensionRouteUUID{}
+	deregistered := map[osquery.ExtensionRouteUUID]bool{}
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			mut.Lock()
+			defer mut.Unlock()
+			uuid := osquery.ExtensionRouteUUID(len(registered) + 1)
+			registered[info.Name] = uuid
+			return &osquery.ExtensionStatus{UUID: uuid}, nil
+		},
+		PingFunc: func() (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			mut.Lock()
+			defer mut.Unlock()
+			deregistered[uuid] = true
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() {},
+	}
+
+	group, err := NewExtensionGroup(tempPath.Name(), WithClient(&syncMockExtensionManager{MockExtensionManager: mock}), ServerPingInterval(time.Second))
+	require.NoError(t, err)
+	first, err := group.Add("first")
+	require.NoError(t, err)
+	second, err := group.Add("second")
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- group.Run()
+	}()
+
+	<-first.Ready()
+	<-second.Ready()
+	assert.NotEqual(t, first.UUID(), second.UUID())
+
+	require.NoError(t, group.Shutdown(context.Background()))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("group did not stop")
+	}
+
+	mut.Lock()
+	defer mut.Unlock()
+	assert.Len(t, registered, 2)
+	assert.True(t, deregistered[registered["first"]])
+	assert.True(t, deregistered[registered["second"]])
+}
+
+// syncMockExtensionManager serializes calls to the mock, which is shared by
+// all extensions in the group.
+type syncMockExtensionManager struct {
+	*MockExtensionManager
+	mutex sync.Mutex
+}
+
+func (m *syncMockExtensionManager) Ping() (*osquery.ExtensionStatus, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.MockExtensionManager.Ping()
+}
+
+func (m *syncMockExtensionManager) RegisterExtension(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.MockExtensionManager.RegisterExtension(info, registry)
+}
+
+func (m *syncMockExtensionManager) DeregisterExtension(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.MockExtensionManager.DeregisterExtension(uuid)
+}
+
+func TestExtensionGroupRejectsProcessOptions(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "osquery.em")
+	mock := &MockExtensionManager{CloseFunc: func() {}}
+	for _, opt := range []ServerOption{ServerPprof("localhost:0"), ServerExpvar()} {
+		_, err := NewExtensionGroup(sockPath, WithClient(mock), opt)
+		assert.Error(t, err)
+	}
+
+	group, err := NewExtensionGroup(sockPath, WithClient(mock))
+	require.NoError(t, err)
+	_, err = group.Add("profiled", ServerPprof("localhost:0"))
+	assert.NoError(t, err)
+}
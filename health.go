@@ -0,0 +1,66 @@
+package osquery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// ServerAggregateHealth makes the server's Ping handler report the health of
+// the extension rather than always returning OK. Ping then fails if any
+// registered plugin's Ping fails, or if errorWindow is positive and a plugin
+// call returned an error within that window.
+//
+// Note that osquery treats a failed ping as the extension being unhealthy,
+// which may cause it to be removed.
+func ServerAggregateHealth(errorWindow time.Duration) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.aggregateHealth = true
+		s.healthErrorWindow = errorWindow
+	}
+}
+
+// health aggregates the status of all registered plugins and recent call
+// errors.
+func (s *ExtensionManagerServer) health() *osquery.ExtensionStatus {
+	s.mutex.Lock()
+	var plugins []OsqueryPlugin
+	for _, subreg := range s.registry {
+		for _, plugin := range subreg {
+			plugins = append(plugins, plugin)
+		}
+	}
+	lastCallError := s.lastCallError
+	lastCallErrorAt := s.lastCallErrorAt
+	s.mutex.Unlock()
+
+	var problems []string
+	for _, plugin := range plugins {
+		status := plugin.Ping()
+		if status.Code != 0 {
+			problems = append(problems, fmt.Sprintf("%s/%s: %s", plugin.RegistryName(), plugin.Name(), status.Message))
+		}
+	}
+	// Registry iteration order is random, keep the message stable.
+	sort.Strings(problems)
+
+	if s.healthErrorWindow > 0 && !lastCallErrorAt.IsZero() && time.Since(lastCallErrorAt) < s.healthErrorWindow {
+		problems = append(problems, "recent call error: "+lastCallError)
+	}
+
+	if len(problems) == 0 {
+		return &osquery.ExtensionStatus{Code: 0, Message: "OK"}
+	}
+	return &osquery.ExtensionStatus{Code: 1, Message: strings.Join(problems, "; ")}
+}
+
+// recordCallError remembers the most recent failed plugin call for health.
+func (s *ExtensionManagerServer) recordCallError(registry, item, message string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastCallError = fmt.Sprintf("%s/%s: %s", registry, item, message)
+	s.lastCallErrorAt = time.Now()
+}
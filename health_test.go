@@ -0,0 +1,58 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unhealthyPlugin struct {
+	logger.Plugin
+}
+
+func (p *unhealthyPlugin) Ping() osquery.ExtensionStatus {
+	return osquery.ExtensionStatus{Code: 1, Message: "disk full"}
+}
+
+func TestPingAggregateHealth(t *testing.T) {
+	failLog := func(ctx context.Context, typ logger.LogType, logText string) error {
+		return errors.New("boom")
+	}
+	okLog := func(ctx context.Context, typ logger.LogType, logText string) error {
+		return nil
+	}
+
+	// Without the option Ping is always OK.
+	server := &ExtensionManagerServer{}
+	server.RegisterPlugin(&unhealthyPlugin{*logger.NewPlugin("bad", okLog)})
+	status, err := server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+
+	ServerAggregateHealth(0)(server)
+	status, err = server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), status.Code)
+	assert.Equal(t, "logger/bad: disk full", status.Message)
+
+	// Recent call errors are reported within the window.
+	server = &ExtensionManagerServer{}
+	ServerAggregateHealth(time.Hour)(server)
+	server.RegisterPlugin(logger.NewPlugin("failing", failLog))
+	status, err = server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+
+	_, err = server.Call(context.Background(), "logger", "failing", osquery.ExtensionPluginRequest{"string": "log"})
+	require.NoError(t, err)
+	status, err = server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), status.Code)
+	assert.Contains(t, status.Message, "recent call error: logger/failing: error logging: boom")
+}
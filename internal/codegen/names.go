@@ -0,0 +1,36 @@
+// Package codegen holds the helpers shared by the code generators of
+// osquery-go.
+package codegen
+
+import (
+	"strings"
+	"unicode"
+)
+
+// initialisms are upper cased in Go identifiers.
+var initialisms = map[string]bool{
+	"api": true, "cpu": true, "gid": true, "guid": true, "id": true, "ip": true,
+	"mac": true, "os": true, "pid": true, "sid": true, "uid": true, "url": true,
+	"uuid": true,
+}
+
+// GoName converts a snake_case name to an exported Go identifier.
+func GoName(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	s := b.String()
+	if s == "" || unicode.IsDigit([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
@@ -0,0 +1,20 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"pid":         "PID",
+		"user_time":   "UserTime",
+		"cpu_percent": "CPUPercent",
+		"uuid":        "UUID",
+		"2fa_enabled": "X2faEnabled",
+		"name":        "Name",
+	} {
+		assert.Equal(t, expected, GoName(name))
+	}
+}
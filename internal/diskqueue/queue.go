@@ -0,0 +1,204 @@
+// Package diskqueue implements the durable FIFO queue behind the retry
+// queues of the logger and distributed plugins.
+package diskqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Queue is a durable FIFO queue of batches of T, stored as JSON segment
+// files in a directory, one segment per batch. It is safe for concurrent
+// use, but a directory must not be shared by several queues.
+type Queue[T any] struct {
+	dir      string
+	maxBytes int64
+
+	mutex sync.Mutex
+	// next is the sequence number of the next segment.
+	next uint64
+}
+
+// segmentExt is the extension of queue segment files.
+const segmentExt = ".queue"
+
+// Open opens the queue stored in dir, creating the directory if needed. If
+// maxBytes is positive, the oldest segments are discarded when the queue
+// grows larger than maxBytes.
+func Open[T any](dir string, maxBytes int64) (*Queue[T], error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "creating queue directory")
+	}
+	q := &Queue[T]{dir: dir, maxBytes: maxBytes}
+	segments, err := q.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		q.next = segments[len(segments)-1].seq + 1
+	}
+	return q, nil
+}
+
+type segment struct {
+	seq  uint64
+	path string
+	size int64
+}
+
+// segments returns the segments of the queue, oldest first.
+func (q *Queue[T]) segments() ([]segment, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading queue directory")
+	}
+	var segments []segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{seq: seq, path: filepath.Join(q.dir, name), size: info.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].seq < segments[j].seq })
+	return segments, nil
+}
+
+// Push adds a batch to the end of the queue, as a single segment. Empty
+// batches are ignored.
+func (q *Queue[T]) Push(batch []T) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return errors.Wrap(err, "encoding queue segment")
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	path := filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.next, segmentExt))
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return errors.Wrap(err, "writing queue segment")
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return errors.Wrap(err, "writing queue segment")
+	}
+	q.next++
+	return q.trim()
+}
+
+// trim discards the oldest segments while the queue exceeds maxBytes.
+func (q *Queue[T]) trim() error {
+	if q.maxBytes <= 0 {
+		return nil
+	}
+	segments, err := q.segments()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, s := range segments {
+		total += s.size
+	}
+	// Keep at least the newest segment
+	for i := 0; total > q.maxBytes && i < len(segments)-1; i++ {
+		if err := os.Remove(segments[i].path); err != nil {
+			return errors.Wrap(err, "discarding queue segment")
+		}
+		total -= segments[i].size
+	}
+	return nil
+}
+
+// Segments returns the number of batches in the queue.
+func (q *Queue[T]) Segments() (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	segments, err := q.segments()
+	if err != nil {
+		return 0, err
+	}
+	return len(segments), nil
+}
+
+// Len returns the number of items in the queue, across all batches.
+// Segments that can't be decoded are not counted.
+func (q *Queue[T]) Len() (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	segments, err := q.segments()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, s := range segments {
+		batch, err := readSegment[T](s.path)
+		if err != nil {
+			continue
+		}
+		n += len(batch)
+	}
+	return n, nil
+}
+
+// Replay calls fn with each batch, oldest first, removing the segments for
+// which fn succeeds. It stops at the first error, which is returned, leaving
+// the remaining segments in the queue. Segments that can't be decoded are
+// discarded.
+func (q *Queue[T]) Replay(ctx context.Context, fn func(ctx context.Context, batch []T) error) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	segments, err := q.segments()
+	if err != nil {
+		return err
+	}
+	for _, s := range segments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch, err := readSegment[T](s.path)
+		if err == nil {
+			if err := fn(ctx, batch); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(s.path); err != nil {
+			return errors.Wrap(err, "removing queue segment")
+		}
+	}
+	return nil
+}
+
+func readSegment[T any](path string) ([]T, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var batch []T
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
@@ -0,0 +1,73 @@
+package diskqueue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	q, err := Open[string](dir, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Push([]string{"a", "b"}))
+	require.NoError(t, q.Push([]string{"c"}))
+	require.NoError(t, q.Push(nil))
+	n, err := q.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = q.Segments()
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// The queue survives reopening
+	q, err = Open[string](dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Push([]string{"d"}))
+
+	// Replay stops at the first error
+	var replayed [][]string
+	fail := errors.New("unavailable")
+	err = q.Replay(context.Background(), func(ctx context.Context, batch []string) error {
+		if len(replayed) == 1 {
+			return fail
+		}
+		replayed = append(replayed, batch)
+		return nil
+	})
+	assert.Equal(t, fail, err)
+	assert.Equal(t, [][]string{{"a", "b"}}, replayed)
+
+	// Corrupt segments are discarded
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001.queue"), []byte("garbage"), 0o600))
+	replayed = nil
+	require.NoError(t, q.Replay(context.Background(), func(ctx context.Context, batch []string) error {
+		replayed = append(replayed, batch)
+		return nil
+	}))
+	assert.Equal(t, [][]string{{"d"}}, replayed)
+	n, err = q.Segments()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestQueueMaxBytes(t *testing.T) {
+	q, err := Open[string](t.TempDir(), 50)
+	require.NoError(t, err)
+	for _, s := range []string{"first item that is long enough", "second item that is long enough", "third"} {
+		require.NoError(t, q.Push([]string{s}))
+	}
+
+	var items []string
+	require.NoError(t, q.Replay(context.Background(), func(ctx context.Context, batch []string) error {
+		items = append(items, batch...)
+		return nil
+	}))
+	assert.Equal(t, []string{"second item that is long enough", "third"}, items)
+}
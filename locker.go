@@ -3,9 +3,28 @@ package osquery
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
+
+	"github.com/osquery/osquery-go/traces"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// LockStats are the statistics of the waits for the osquery socket of a
+// client, which only allows one call at a time.
+type LockStats struct {
+	// Acquired is the number of calls that got the socket.
+	Acquired uint64
+	// Failed is the number of calls that gave up waiting, on timeout or
+	// cancellation.
+	Failed uint64
+	// TotalWait is the total time spent waiting, by all calls.
+	TotalWait time.Duration
+	// MaxWait is the longest wait of a call that got the socket.
+	MaxWait time.Duration
+}
+
 // locker uses go channels to create a lock mechanism. We use channels, and not the more common mutexes, because the
 // latter cannot be interrupted. This allows callers to timeout without blocking on the mutex.
 //
@@ -15,6 +34,11 @@ type locker struct {
 	c              chan struct{}
 	defaultTimeout time.Duration // Default wait time is used if context does not have a deadline
 	maxWait        time.Duration // Maximum time something is allowed to wait
+
+	acquired  atomic.Uint64
+	failed    atomic.Uint64
+	totalWait atomic.Int64 // nanoseconds
+	longest   atomic.Int64 // nanoseconds
 }
 
 func NewLocker(defaultTimeout time.Duration, maxWait time.Duration) *locker {
@@ -42,16 +66,60 @@ func (l *locker) Lock(ctx context.Context) error {
 	defer timeout.Stop()
 
 	// Block until we get the lock, the context is canceled, or we time out.
+	start := time.Now()
 	select {
 	case l.c <- struct{}{}:
 		// lock acquired
+		l.record(ctx, time.Since(start), nil)
 		return nil
 	case <-ctx.Done():
 		// context has been canceled
-		return fmt.Errorf("context canceled: %w", ctx.Err())
+		err := fmt.Errorf("context canceled: %w", ctx.Err())
+		l.record(ctx, time.Since(start), err)
+		return err
 	case <-timeout.C:
 		// timed out
-		return fmt.Errorf(timeoutError, wait)
+		err := fmt.Errorf(timeoutError, wait)
+		l.record(ctx, time.Since(start), err)
+		return err
+	}
+}
+
+// record adds a wait to the statistics, and to the span of ctx as an
+// event, so that contention on the socket shows up in traces.
+func (l *locker) record(ctx context.Context, waited time.Duration, err error) {
+	l.totalWait.Add(int64(waited))
+	if err != nil {
+		l.failed.Add(1)
+	} else {
+		l.acquired.Add(1)
+		for {
+			longest := l.longest.Load()
+			if int64(waited) <= longest || l.longest.CompareAndSwap(longest, int64(waited)) {
+				break
+			}
+		}
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	attrs := []attribute.KeyValue{traces.Duration("osquery-go.lock_wait_ms", waited)}
+	if err != nil {
+		attrs = append(attrs, attribute.String("osquery-go.lock_error", err.Error()))
+	}
+	span.AddEvent("lock acquisition", trace.WithAttributes(attrs...))
+	span.SetAttributes(attrs[0])
+}
+
+// Stats returns the statistics of the waits for l.
+func (l *locker) Stats() LockStats {
+	return LockStats{
+		Acquired:  l.acquired.Load(),
+		Failed:    l.failed.Load(),
+		TotalWait: time.Duration(l.totalWait.Load()),
+		MaxWait:   time.Duration(l.longest.Load()),
 	}
 }
 
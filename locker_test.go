@@ -183,3 +183,25 @@ func assertBetween(t *testing.T, actual int, r [2]int, msg string) {
 	assert.GreaterOrEqual(t, actual, r[0], msg)
 	assert.LessOrEqual(t, actual, r[1], msg)
 }
+
+func TestLockerStats(t *testing.T) {
+	t.Parallel()
+
+	l := NewLocker(20*time.Millisecond, time.Second)
+	require.NoError(t, l.Lock(context.Background()))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Unlock()
+	}()
+	require.NoError(t, l.Lock(context.Background()))
+
+	// Times out while the lock is held
+	require.Error(t, l.Lock(context.Background()))
+	l.Unlock()
+
+	stats := l.Stats()
+	assert.Equal(t, uint64(2), stats.Acquired)
+	assert.Equal(t, uint64(1), stats.Failed)
+	assert.GreaterOrEqual(t, stats.MaxWait, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, stats.TotalWait, 30*time.Millisecond)
+}
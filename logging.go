@@ -0,0 +1,69 @@
+package osquery
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// DefaultLogLevel is the level of the library logs until set with
+// SetLogLevel or SetVerbose: only failures the library recovers from, such
+// as a failed ping of osquery, are logged.
+const DefaultLogLevel = slog.LevelWarn
+
+var (
+	logLevel = func() *slog.LevelVar {
+		level := new(slog.LevelVar)
+		level.Set(DefaultLogLevel)
+		return level
+	}()
+	currentLogger atomic.Pointer[slog.Logger]
+	verbose       atomic.Bool
+)
+
+// SetLogger sets the logger of the library. Records below the level set with
+// SetLogLevel are dropped before reaching the logger. The library logs
+// nothing until a logger is set, or SetVerbose enables logging to
+// slog.Default. A nil logger restores that default.
+func SetLogger(l *slog.Logger) {
+	currentLogger.Store(l)
+}
+
+// SetLogLevel sets the minimum level of the library logs.
+func SetLogLevel(level slog.Level) {
+	logLevel.Set(level)
+}
+
+// LogLevel returns the minimum level of the library logs.
+func LogLevel() slog.Level {
+	return logLevel.Level()
+}
+
+// SetVerbose sets the level of the library logs to debug, logging
+// registrations, plugin calls and shutdowns, or back to DefaultLogLevel.
+// Without a logger set with SetLogger, verbose logs go to slog.Default. It
+// is meant to follow the --verbose flag osqueryd passes to autoloaded
+// extensions, see the extflags package.
+func SetVerbose(v bool) {
+	verbose.Store(v)
+	if v {
+		SetLogLevel(slog.LevelDebug)
+	} else {
+		SetLogLevel(DefaultLogLevel)
+	}
+}
+
+// logAt logs the message with the key-value pairs if level is enabled.
+func logAt(level slog.Level, msg string, args ...any) {
+	if level < logLevel.Level() {
+		return
+	}
+	l := currentLogger.Load()
+	if l == nil {
+		if !verbose.Load() {
+			return
+		}
+		l = slog.Default()
+	}
+	l.Log(context.Background(), level, msg, args...)
+}
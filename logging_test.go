@@ -0,0 +1,80 @@
+package osquery
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs sends the library logs to the returned buffer until the end
+// of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() {
+		SetLogger(nil)
+		SetVerbose(false)
+	})
+	return &buf
+}
+
+func TestLogLevel(t *testing.T) {
+	buf := captureLogs(t)
+	assert.Equal(t, DefaultLogLevel, LogLevel())
+
+	logAt(slog.LevelDebug, "hidden")
+	logAt(slog.LevelWarn, "shown", "key", "value")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "msg=shown key=value")
+
+	SetVerbose(true)
+	assert.Equal(t, slog.LevelDebug, LogLevel())
+	logAt(slog.LevelDebug, "debug")
+	assert.Contains(t, buf.String(), "msg=debug")
+
+	SetVerbose(false)
+	assert.Equal(t, DefaultLogLevel, LogLevel())
+}
+
+func TestCallLogs(t *testing.T) {
+	buf := captureLogs(t)
+	server := &ExtensionManagerServer{name: "logs"}
+	server.RegisterPlugin(table.NewPlugin("log_table", []table.ColumnDefinition{table.TextColumn("text")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return nil, nil
+		}))
+
+	_, err := server.Call(context.Background(), "table", "log_table", osquery.ExtensionPluginRequest{"action": "columns"})
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+
+	SetVerbose(true)
+	_, err = server.Call(context.Background(), "table", "log_table", osquery.ExtensionPluginRequest{"action": "columns"})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `msg="plugin call" extension=logs registry=table item=log_table action=columns`)
+}
+
+func TestDefaultLogger(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+		SetVerbose(false)
+	})
+
+	// Nothing is logged until the user opts in
+	logAt(slog.LevelWarn, "quiet")
+	assert.Empty(t, buf.String())
+
+	SetVerbose(true)
+	logAt(slog.LevelDebug, "verbose")
+	assert.Contains(t, buf.String(), "msg=verbose")
+}
@@ -0,0 +1,159 @@
+// Package metrics exposes the statistics of osquery-go servers and clients as
+// Prometheus metrics, so that fleets using Prometheus can scrape the health of
+// their extensions.
+//
+// Register a Collector with a Prometheus registry, then serve the registry
+// with Serve, or write it periodically for the node_exporter textfile
+// collector with WriteTextfile:
+//
+//	collector := metrics.NewCollector()
+//	collector.AddServer(server)
+//	collector.AddClient("queries", client)
+//	registry := prometheus.NewRegistry()
+//	registry.MustRegister(collector)
+//	go metrics.Serve(ctx, "localhost:18080", registry)
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	osquery "github.com/osquery/osquery-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "osquery_go"
+
+var (
+	pluginCallsDesc = prometheus.NewDesc(
+		namespace+"_plugin_calls_total",
+		"Number of calls routed to the plugin.",
+		[]string{"extension", "registry", "plugin"}, nil,
+	)
+	pluginErrorsDesc = prometheus.NewDesc(
+		namespace+"_plugin_errors_total",
+		"Number of calls of the plugin that returned a non-zero status.",
+		[]string{"extension", "registry", "plugin"}, nil,
+	)
+	pluginDurationDesc = prometheus.NewDesc(
+		namespace+"_plugin_call_duration_seconds_total",
+		"Total time spent in the calls of the plugin.",
+		[]string{"extension", "registry", "plugin"}, nil,
+	)
+	serverUpDesc = prometheus.NewDesc(
+		namespace+"_server_up",
+		"Whether the extension is registered with osquery and running.",
+		[]string{"extension"}, nil,
+	)
+	serverLastPingDesc = prometheus.NewDesc(
+		namespace+"_server_last_ping_timestamp_seconds",
+		"Time of the last successful ping of osquery by the extension.",
+		[]string{"extension"}, nil,
+	)
+	lockAcquiredDesc = prometheus.NewDesc(
+		namespace+"_client_lock_acquired_total",
+		"Number of calls of the client that got the osquery socket.",
+		[]string{"client"}, nil,
+	)
+	lockFailedDesc = prometheus.NewDesc(
+		namespace+"_client_lock_failed_total",
+		"Number of calls of the client that gave up waiting for the osquery socket.",
+		[]string{"client"}, nil,
+	)
+	lockWaitDesc = prometheus.NewDesc(
+		namespace+"_client_lock_wait_seconds_total",
+		"Total time the calls of the client waited for the osquery socket.",
+		[]string{"client"}, nil,
+	)
+	lockMaxWaitDesc = prometheus.NewDesc(
+		namespace+"_client_lock_wait_max_seconds",
+		"Longest wait of a call of the client for the osquery socket.",
+		[]string{"client"}, nil,
+	)
+)
+
+// Collector is a prometheus.Collector of the statistics of extension
+// servers and clients. It is safe for concurrent use.
+type Collector struct {
+	mutex   sync.Mutex
+	servers []*osquery.ExtensionManagerServer
+	clients map[string]*osquery.ExtensionManagerClient
+}
+
+// NewCollector returns a Collector without servers or clients.
+func NewCollector() *Collector {
+	return &Collector{clients: map[string]*osquery.ExtensionManagerClient{}}
+}
+
+// AddServer adds the plugin statistics and state of the servers, labeled
+// with their extension name.
+func (c *Collector) AddServer(servers ...*osquery.ExtensionManagerServer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.servers = append(c.servers, servers...)
+}
+
+// AddClient adds the socket lock statistics of the client, labeled with
+// the name.
+func (c *Collector) AddClient(name string, client *osquery.ExtensionManagerClient) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clients[name] = client
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		pluginCallsDesc, pluginErrorsDesc, pluginDurationDesc,
+		serverUpDesc, serverLastPingDesc,
+		lockAcquiredDesc, lockFailedDesc, lockWaitDesc, lockMaxWaitDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	servers := append([]*osquery.ExtensionManagerServer(nil), c.servers...)
+	clients := make(map[string]*osquery.ExtensionManagerClient, len(c.clients))
+	for name, client := range c.clients {
+		clients[name] = client
+	}
+	c.mutex.Unlock()
+
+	for _, server := range servers {
+		collectServer(ch, server)
+	}
+
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		stats := clients[name].LockStats()
+		ch <- prometheus.MustNewConstMetric(lockAcquiredDesc, prometheus.CounterValue, float64(stats.Acquired), name)
+		ch <- prometheus.MustNewConstMetric(lockFailedDesc, prometheus.CounterValue, float64(stats.Failed), name)
+		ch <- prometheus.MustNewConstMetric(lockWaitDesc, prometheus.CounterValue, stats.TotalWait.Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(lockMaxWaitDesc, prometheus.GaugeValue, stats.MaxWait.Seconds(), name)
+	}
+}
+
+func collectServer(ch chan<- prometheus.Metric, server *osquery.ExtensionManagerServer) {
+	name := server.Name()
+	for _, st := range server.PluginStats() {
+		ch <- prometheus.MustNewConstMetric(pluginCallsDesc, prometheus.CounterValue, float64(st.Calls), name, st.Registry, st.Name)
+		ch <- prometheus.MustNewConstMetric(pluginErrorsDesc, prometheus.CounterValue, float64(st.Errors), name, st.Registry, st.Name)
+		ch <- prometheus.MustNewConstMetric(pluginDurationDesc, prometheus.CounterValue, st.TotalDuration.Seconds(), name, st.Registry, st.Name)
+	}
+
+	up := 0.0
+	if server.State() == osquery.ServerStateRunning {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(serverUpDesc, prometheus.GaugeValue, up, name)
+	if lastPing := server.LastPing(); !lastPing.IsZero() {
+		ch <- prometheus.MustNewConstMetric(serverLastPingDesc, prometheus.GaugeValue, float64(lastPing.UnixNano())/1e9, name)
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	gen "github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRegistry(t *testing.T) *prometheus.Registry {
+	server, err := osquery.NewExtensionManagerServer("myext", "/tmp/osquery.sock", osquery.WithClient(&osquery.MockExtensionManager{}))
+	require.NoError(t, err)
+	log := func(ctx context.Context, typ logger.LogType, logText string) error { return nil }
+	server.RegisterPlugin(logger.NewPlugin("mylogger", log))
+	_, err = server.Call(context.Background(), "logger", "mylogger", gen.ExtensionPluginRequest{"string": "log"})
+	require.NoError(t, err)
+	_, err = server.Call(context.Background(), "logger", "mylogger", gen.ExtensionPluginRequest{})
+	require.NoError(t, err)
+
+	collector := NewCollector()
+	collector.AddServer(server)
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(collector))
+	return registry
+}
+
+func TestServe(t *testing.T) {
+	registry := newRegistry(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- serve(ctx, listener, registry) }()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + MetricsPath)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), `osquery_go_plugin_calls_total{extension="myext",plugin="mylogger",registry="logger"} 2`)
+	assert.Contains(t, string(body), `osquery_go_plugin_errors_total{extension="myext",plugin="mylogger",registry="logger"} 1`)
+	assert.Contains(t, string(body), `osquery_go_server_up{extension="myext"} 0`)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWriteTextfile(t *testing.T) {
+	registry := newRegistry(t)
+	path := filepath.Join(t.TempDir(), "osquery.prom")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, WriteTextfile(ctx, path, time.Minute, registry))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `osquery_go_plugin_calls_total{extension="myext",plugin="mylogger",registry="logger"} 2`)
+}
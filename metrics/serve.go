@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsPath is the path Serve serves the metrics at.
+const MetricsPath = "/metrics"
+
+// Serve serves the metrics of the gatherer over HTTP at MetricsPath on addr,
+// until ctx is done. Listen on a loopback address unless the metrics are
+// meant to be scraped remotely.
+func Serve(ctx context.Context, addr string, gatherer prometheus.Gatherer) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "listening for metrics")
+	}
+	return serve(ctx, listener, gatherer)
+}
+
+func serve(ctx context.Context, listener net.Listener, gatherer prometheus.Gatherer) error {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return errors.Wrap(err, "serving metrics")
+	}
+	return nil
+}
+
+// WriteTextfile writes the metrics of the gatherer to path every interval,
+// in the text format read by the node_exporter textfile collector, until ctx
+// is done. The file is replaced atomically. Errors writing the file are
+// returned, stopping the writes.
+func WriteTextfile(ctx context.Context, path string, interval time.Duration, gatherer prometheus.Gatherer) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := prometheus.WriteToTextfile(path, gatherer); err != nil {
+			return errors.Wrap(err, "writing metrics textfile")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
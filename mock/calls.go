@@ -0,0 +1,75 @@
+// Package mock provides mocks of the osquery extension manager interfaces,
+// so that extensions can be unit tested without a live osqueryd.
+//
+// ExtensionManager implements the context-aware gen/osquery
+// ExtensionManager, and Manager implements the ExtensionManager of
+// osquery-go, used by ExtensionManagerServer (see osquery.WithClient). Each
+// method calls the corresponding Func field if set, and otherwise returns an
+// OK status with empty results. All calls are recorded, and the mocks are
+// safe for concurrent use.
+package mock
+
+import (
+	"sync"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// Call is a call of a method of a mock.
+type Call struct {
+	// Method is the name of the method.
+	Method string
+	// Args are the arguments of the call, without the context.
+	Args []interface{}
+}
+
+// recorder records the calls of a mock.
+type recorder struct {
+	mutex sync.Mutex
+	calls []Call
+}
+
+// record records a call, and sets the invoked flag under the lock.
+func (r *recorder) record(invoked *bool, method string, args ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	*invoked = true
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns the calls of the mock, in order.
+func (r *recorder) Calls() []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// CallsTo returns the calls of the method of the mock, in order.
+func (r *recorder) CallsTo(method string) []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var calls []Call
+	for _, c := range r.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets the recorded calls.
+func (r *recorder) ResetCalls() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = nil
+}
+
+// okStatus is the status returned by methods without a Func.
+func okStatus() *osquery.ExtensionStatus {
+	return &osquery.ExtensionStatus{Code: 0, Message: "OK"}
+}
+
+// okResponse is the response returned by methods without a Func.
+func okResponse() *osquery.ExtensionResponse {
+	return &osquery.ExtensionResponse{Status: okStatus(), Response: osquery.ExtensionPluginResponse{}}
+}
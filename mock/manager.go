@@ -0,0 +1,134 @@
+package mock
+
+import (
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// ManagerCloseFunc is the type of Manager.CloseFunc.
+type ManagerCloseFunc func()
+
+// ManagerPingFunc is the type of Manager.PingFunc.
+type ManagerPingFunc func() (*osquery.ExtensionStatus, error)
+
+// ManagerCallFunc is the type of Manager.CallFunc.
+type ManagerCallFunc func(registry string, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error)
+
+// ManagerExtensionsFunc is the type of Manager.ExtensionsFunc.
+type ManagerExtensionsFunc func() (osquery.InternalExtensionList, error)
+
+// ManagerRegisterExtensionFunc is the type of Manager.RegisterExtensionFunc.
+type ManagerRegisterExtensionFunc func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error)
+
+// ManagerDeregisterExtensionFunc is the type of Manager.DeregisterExtensionFunc.
+type ManagerDeregisterExtensionFunc func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error)
+
+// ManagerOptionsFunc is the type of Manager.OptionsFunc.
+type ManagerOptionsFunc func() (osquery.InternalOptionList, error)
+
+// ManagerQueryFunc is the type of Manager.QueryFunc and
+// Manager.GetQueryColumnsFunc.
+type ManagerQueryFunc func(sql string) (*osquery.ExtensionResponse, error)
+
+// Manager is a mock of the ExtensionManager of osquery-go, the client an
+// ExtensionManagerServer registers with, without contexts.
+type Manager struct {
+	recorder
+
+	CloseFunc        ManagerCloseFunc
+	CloseFuncInvoked bool
+
+	PingFunc        ManagerPingFunc
+	PingFuncInvoked bool
+
+	CallFunc        ManagerCallFunc
+	CallFuncInvoked bool
+
+	ExtensionsFunc        ManagerExtensionsFunc
+	ExtensionsFuncInvoked bool
+
+	RegisterExtensionFunc        ManagerRegisterExtensionFunc
+	RegisterExtensionFuncInvoked bool
+
+	DeregisterExtensionFunc        ManagerDeregisterExtensionFunc
+	DeregisterExtensionFuncInvoked bool
+
+	OptionsFunc        ManagerOptionsFunc
+	OptionsFuncInvoked bool
+
+	QueryFunc        ManagerQueryFunc
+	QueryFuncInvoked bool
+
+	GetQueryColumnsFunc        ManagerQueryFunc
+	GetQueryColumnsFuncInvoked bool
+}
+
+func (m *Manager) Close() {
+	m.record(&m.CloseFuncInvoked, "Close")
+	if m.CloseFunc != nil {
+		m.CloseFunc()
+	}
+}
+
+func (m *Manager) Ping() (*osquery.ExtensionStatus, error) {
+	m.record(&m.PingFuncInvoked, "Ping")
+	if m.PingFunc == nil {
+		return okStatus(), nil
+	}
+	return m.PingFunc()
+}
+
+func (m *Manager) Call(registry string, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+	m.record(&m.CallFuncInvoked, "Call", registry, item, req)
+	if m.CallFunc == nil {
+		return okResponse(), nil
+	}
+	return m.CallFunc(registry, item, req)
+}
+
+func (m *Manager) Extensions() (osquery.InternalExtensionList, error) {
+	m.record(&m.ExtensionsFuncInvoked, "Extensions")
+	if m.ExtensionsFunc == nil {
+		return osquery.InternalExtensionList{}, nil
+	}
+	return m.ExtensionsFunc()
+}
+
+func (m *Manager) RegisterExtension(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+	m.record(&m.RegisterExtensionFuncInvoked, "RegisterExtension", info, registry)
+	if m.RegisterExtensionFunc == nil {
+		return okStatus(), nil
+	}
+	return m.RegisterExtensionFunc(info, registry)
+}
+
+func (m *Manager) DeregisterExtension(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+	m.record(&m.DeregisterExtensionFuncInvoked, "DeregisterExtension", uuid)
+	if m.DeregisterExtensionFunc == nil {
+		return okStatus(), nil
+	}
+	return m.DeregisterExtensionFunc(uuid)
+}
+
+func (m *Manager) Options() (osquery.InternalOptionList, error) {
+	m.record(&m.OptionsFuncInvoked, "Options")
+	if m.OptionsFunc == nil {
+		return osquery.InternalOptionList{}, nil
+	}
+	return m.OptionsFunc()
+}
+
+func (m *Manager) Query(sql string) (*osquery.ExtensionResponse, error) {
+	m.record(&m.QueryFuncInvoked, "Query", sql)
+	if m.QueryFunc == nil {
+		return okResponse(), nil
+	}
+	return m.QueryFunc(sql)
+}
+
+func (m *Manager) GetQueryColumns(sql string) (*osquery.ExtensionResponse, error) {
+	m.record(&m.GetQueryColumnsFuncInvoked, "GetQueryColumns", sql)
+	if m.GetQueryColumnsFunc == nil {
+		return okResponse(), nil
+	}
+	return m.GetQueryColumnsFunc(sql)
+}
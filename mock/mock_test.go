@@ -0,0 +1,66 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtensionManager(t *testing.T) {
+	m := &ExtensionManager{
+		QueryFunc: func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+			return nil, errors.New("boom")
+		},
+	}
+
+	// Methods without a Func succeed
+	status, err := m.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+	resp, err := m.Call(context.Background(), "table", "processes", osquery.ExtensionPluginRequest{"action": "generate"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	m.Close()
+
+	_, err = m.Query(context.Background(), "select 1")
+	assert.EqualError(t, err, "boom")
+
+	assert.True(t, m.PingFuncInvoked)
+	assert.True(t, m.QueryFuncInvoked)
+	assert.False(t, m.OptionsFuncInvoked)
+	assert.Equal(t, []Call{
+		{Method: "Ping"},
+		{Method: "Call", Args: []interface{}{"table", "processes", osquery.ExtensionPluginRequest{"action": "generate"}}},
+		{Method: "Close"},
+		{Method: "Query", Args: []interface{}{"select 1"}},
+	}, m.Calls())
+	assert.Equal(t, []Call{{Method: "Query", Args: []interface{}{"select 1"}}}, m.CallsTo("Query"))
+
+	m.ResetCalls()
+	assert.Empty(t, m.Calls())
+}
+
+func TestManager(t *testing.T) {
+	m := &Manager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, UUID: 7}, nil
+		},
+	}
+
+	info := &osquery.InternalExtensionInfo{Name: "ext"}
+	status, err := m.RegisterExtension(info, osquery.ExtensionRegistry{})
+	require.NoError(t, err)
+	assert.Equal(t, osquery.ExtensionRouteUUID(7), status.UUID)
+	status, err = m.DeregisterExtension(7)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+
+	assert.Equal(t, []Call{
+		{Method: "RegisterExtension", Args: []interface{}{info, osquery.ExtensionRegistry{}}},
+		{Method: "DeregisterExtension", Args: []interface{}{osquery.ExtensionRouteUUID(7)}},
+	}, m.Calls())
+}
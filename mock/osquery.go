@@ -1,5 +1,3 @@
-// Automatically generated by mockimpl. DO NOT EDIT!
-
 package mock
 
 import (
@@ -30,7 +28,11 @@ type QueryFunc func(ctx context.Context, sql string) (*osquery.ExtensionResponse
 
 type GetQueryColumnsFunc func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error)
 
+// ExtensionManager is a mock of the context-aware osquery.ExtensionManager
+// of gen/osquery.
 type ExtensionManager struct {
+	recorder
+
 	CloseFunc        CloseFunc
 	CloseFuncInvoked bool
 
@@ -63,51 +65,80 @@ type ExtensionManager struct {
 }
 
 func (m *ExtensionManager) Close() {
-	m.CloseFuncInvoked = true
-	m.CloseFunc()
+	m.record(&m.CloseFuncInvoked, "Close")
+	if m.CloseFunc != nil {
+		m.CloseFunc()
+	}
 }
 
 func (m *ExtensionManager) Ping(ctx context.Context) (*osquery.ExtensionStatus, error) {
-	m.PingFuncInvoked = true
+	m.record(&m.PingFuncInvoked, "Ping")
+	if m.PingFunc == nil {
+		return okStatus(), nil
+	}
 	return m.PingFunc(ctx)
 }
 
 func (m *ExtensionManager) Call(ctx context.Context, registry string, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
-	m.CallFuncInvoked = true
+	m.record(&m.CallFuncInvoked, "Call", registry, item, req)
+	if m.CallFunc == nil {
+		return okResponse(), nil
+	}
 	return m.CallFunc(ctx, registry, item, req)
 }
 
 func (m *ExtensionManager) Shutdown(ctx context.Context) error {
-	m.ShutdownFuncInvoked = true
+	m.record(&m.ShutdownFuncInvoked, "Shutdown")
+	if m.ShutdownFunc == nil {
+		return nil
+	}
 	return m.ShutdownFunc(ctx)
 }
 
 func (m *ExtensionManager) Extensions(ctx context.Context) (osquery.InternalExtensionList, error) {
-	m.ExtensionsFuncInvoked = true
+	m.record(&m.ExtensionsFuncInvoked, "Extensions")
+	if m.ExtensionsFunc == nil {
+		return osquery.InternalExtensionList{}, nil
+	}
 	return m.ExtensionsFunc(ctx)
 }
 
 func (m *ExtensionManager) RegisterExtension(ctx context.Context, info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
-	m.RegisterExtensionFuncInvoked = true
+	m.record(&m.RegisterExtensionFuncInvoked, "RegisterExtension", info, registry)
+	if m.RegisterExtensionFunc == nil {
+		return okStatus(), nil
+	}
 	return m.RegisterExtensionFunc(ctx, info, registry)
 }
 
 func (m *ExtensionManager) DeregisterExtension(ctx context.Context, uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
-	m.DeregisterExtensionFuncInvoked = true
+	m.record(&m.DeregisterExtensionFuncInvoked, "DeregisterExtension", uuid)
+	if m.DeregisterExtensionFunc == nil {
+		return okStatus(), nil
+	}
 	return m.DeregisterExtensionFunc(ctx, uuid)
 }
 
 func (m *ExtensionManager) Options(ctx context.Context) (osquery.InternalOptionList, error) {
-	m.OptionsFuncInvoked = true
+	m.record(&m.OptionsFuncInvoked, "Options")
+	if m.OptionsFunc == nil {
+		return osquery.InternalOptionList{}, nil
+	}
 	return m.OptionsFunc(ctx)
 }
 
 func (m *ExtensionManager) Query(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
-	m.QueryFuncInvoked = true
+	m.record(&m.QueryFuncInvoked, "Query", sql)
+	if m.QueryFunc == nil {
+		return okResponse(), nil
+	}
 	return m.QueryFunc(ctx, sql)
 }
 
 func (m *ExtensionManager) GetQueryColumns(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
-	m.GetQueryColumnsFuncInvoked = true
+	m.record(&m.GetQueryColumnsFuncInvoked, "GetQueryColumns", sql)
+	if m.GetQueryColumnsFunc == nil {
+		return okResponse(), nil
+	}
 	return m.GetQueryColumnsFunc(ctx, sql)
 }
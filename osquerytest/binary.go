@@ -0,0 +1,172 @@
+package osquerytest
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Environment variables configuring how the osqueryd binary is found.
+const (
+	// PathEnv is the path of the osqueryd binary to use.
+	PathEnv = "OSQUERYD_PATH"
+	// DownloadVersionEnv is the version of osquery to download when no
+	// osqueryd binary is found, such as "5.12.1".
+	DownloadVersionEnv = "OSQUERYTEST_DOWNLOAD_VERSION"
+)
+
+// ErrNotFound is returned by Find when no osqueryd binary is found.
+var ErrNotFound = errors.New("osqueryd not found")
+
+// defaultPaths are where the osquery packages install osqueryd.
+var defaultPaths = map[string][]string{
+	"linux":   {"/opt/osquery/bin/osqueryd", "/usr/bin/osqueryd", "/usr/local/bin/osqueryd"},
+	"darwin":  {"/opt/osquery/lib/osquery.app/Contents/MacOS/osqueryd", "/usr/local/bin/osqueryd"},
+	"windows": {`C:\Program Files\osquery\osqueryd\osqueryd.exe`},
+}
+
+// Find returns the path of an osqueryd binary: the path in the
+// OSQUERYD_PATH environment variable, osqueryd in the PATH, or the install
+// path of the osquery packages, in that order.
+func Find() (string, error) {
+	if path := os.Getenv(PathEnv); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", errors.Wrapf(err, "osqueryd from %s", PathEnv)
+		}
+		return path, nil
+	}
+	if path, err := exec.LookPath("osqueryd"); err == nil {
+		return path, nil
+	}
+	for _, path := range defaultPaths[runtime.GOOS] {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Binary returns the path of an osqueryd binary for the test. If Find finds
+// none, the version in OSQUERYTEST_DOWNLOAD_VERSION is downloaded to the user
+// cache directory, and the test is skipped if the variable is not set.
+func Binary(t testing.TB) string {
+	t.Helper()
+	path, err := Find()
+	if err == nil {
+		return path
+	}
+	if err != ErrNotFound {
+		t.Fatalf("finding osqueryd: %v", err)
+	}
+
+	version := os.Getenv(DownloadVersionEnv)
+	if version == "" {
+		t.Skipf("osqueryd not found, set %s or %s to run the test", PathEnv, DownloadVersionEnv)
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatalf("finding cache directory: %v", err)
+	}
+	path, err = Download(context.Background(), version, filepath.Join(cache, "osquerytest"))
+	if err != nil {
+		t.Fatalf("downloading osqueryd %s: %v", version, err)
+	}
+	return path
+}
+
+// Download downloads osqueryd of the osquery version from the osquery
+// packages into dir, unless it is already there, and returns its path. Only
+// Linux packages are supported.
+func Download(ctx context.Context, version, dir string) (string, error) {
+	url, err := downloadURL(version, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "osquery-"+version, "osqueryd")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "creating request")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "downloading %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("downloading %s: %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", errors.Wrap(err, "creating download directory")
+	}
+	if err := extractOsqueryd(resp.Body, path); err != nil {
+		return "", errors.Wrapf(err, "extracting %s", url)
+	}
+	return path, nil
+}
+
+// downloadURL returns the URL of the osquery package of the version for the
+// platform.
+func downloadURL(version, goos, goarch string) (string, error) {
+	arch := map[string]string{"amd64": "x86_64", "arm64": "aarch64"}[goarch]
+	if goos != "linux" || arch == "" {
+		return "", errors.Errorf("downloading osqueryd is not supported on %s/%s", goos, goarch)
+	}
+	return fmt.Sprintf("https://pkg.osquery.io/linux/osquery-%s_1.linux_%s.tar.gz", version, arch), nil
+}
+
+// extractOsqueryd extracts the osqueryd binary from the gzipped tarball to
+// path. The file is written next to path then renamed, so that concurrent
+// downloads don't see a partial binary.
+func extractOsqueryd(r io.Reader, path string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrap(err, "reading gzip")
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return errors.New("no osqueryd in package")
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading tar")
+		}
+		if header.Typeflag != tar.TypeReg || filepath.Base(header.Name) != "osqueryd" {
+			continue
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(path), "osqueryd-")
+		if err != nil {
+			return errors.Wrap(err, "creating osqueryd")
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := io.Copy(tmp, archive); err != nil {
+			tmp.Close()
+			return errors.Wrap(err, "writing osqueryd")
+		}
+		if err := tmp.Close(); err != nil {
+			return errors.Wrap(err, "writing osqueryd")
+		}
+		if err := os.Chmod(tmp.Name(), 0755); err != nil {
+			return errors.Wrap(err, "making osqueryd executable")
+		}
+		return errors.Wrap(os.Rename(tmp.Name(), path), "moving osqueryd")
+	}
+}
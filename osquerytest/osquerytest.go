@@ -0,0 +1,291 @@
+// Package osquerytest runs a real osqueryd for end-to-end tests of
+// extensions: it launches osqueryd with a temporary extensions socket and
+// database, registers the plugins under test with it, and runs queries
+// against them.
+//
+//	func TestExampleTable(t *testing.T) {
+//		osqueryd := osquerytest.Start(t)
+//		osqueryd.Extension(t, "example", table.NewPlugin("example_table", columns, generate))
+//		rows := osqueryd.Query(t, "SELECT * FROM example_table")
+//		assert.Len(t, rows, 2)
+//	}
+//
+// The osqueryd binary is found with Binary, and the tests are skipped when
+// there is none. Everything is torn down when the test ends.
+package osquerytest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultStartTimeout = 30 * time.Second
+	stopTimeout         = 10 * time.Second
+)
+
+// Option configures the osqueryd started by Start.
+type Option func(*config)
+
+type config struct {
+	binary       string
+	flags        []string
+	startTimeout time.Duration
+}
+
+// WithBinary sets the osqueryd binary to run, instead of the one returned by
+// Binary.
+func WithBinary(path string) Option {
+	return func(c *config) {
+		c.binary = path
+	}
+}
+
+// WithFlags adds command line flags of osqueryd, such as
+// "--config_plugin=example". They are passed after the flags set by Start,
+// overriding them.
+func WithFlags(flags ...string) Option {
+	return func(c *config) {
+		c.flags = append(c.flags, flags...)
+	}
+}
+
+// WithStartTimeout sets how long to wait for osqueryd to accept extensions.
+// It defaults to 30 seconds.
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.startTimeout = timeout
+	}
+}
+
+// Osqueryd is an osqueryd process started for a test.
+type Osqueryd struct {
+	dir        string
+	socketPath string
+	cmd        *exec.Cmd
+	output     *syncBuffer
+	exited     chan struct{}
+	client     *osquery.ExtensionManagerClient
+}
+
+// Start starts osqueryd for the test, and waits for it to accept extensions.
+// osqueryd is stopped, and its files removed, when the test ends. Its output
+// is logged if the test fails.
+func Start(t testing.TB, opts ...Option) *Osqueryd {
+	t.Helper()
+	c := config{startTimeout: defaultStartTimeout}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.binary == "" {
+		c.binary = Binary(t)
+	}
+
+	// Not t.TempDir, whose paths can exceed the length of socket paths.
+	dir, err := os.MkdirTemp("", "osquerytest")
+	if err != nil {
+		t.Fatalf("creating osqueryd directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := os.WriteFile(filepath.Join(dir, "osquery.conf"), []byte("{}"), 0600); err != nil {
+		t.Fatalf("writing osqueryd config: %v", err)
+	}
+
+	o := &Osqueryd{
+		dir:        dir,
+		socketPath: socketPath(dir),
+		output:     &syncBuffer{},
+		exited:     make(chan struct{}),
+	}
+	o.cmd = exec.Command(c.binary, append(o.flags(), c.flags...)...)
+	o.cmd.Stdout = o.output
+	o.cmd.Stderr = o.output
+	if err := o.cmd.Start(); err != nil {
+		t.Fatalf("starting osqueryd: %v", err)
+	}
+	go func() {
+		o.cmd.Wait()
+		close(o.exited)
+	}()
+	t.Cleanup(func() {
+		o.stop()
+		if t.Failed() {
+			t.Logf("osqueryd output:\n%s", o.output.String())
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.startTimeout)
+	defer cancel()
+	if err := o.waitReady(ctx); err != nil {
+		t.Fatalf("waiting for osqueryd: %v\n%s", err, o.output.String())
+	}
+	return o
+}
+
+// flags returns the flags running osqueryd in the foreground with all its
+// files in the directory, and extensions enabled.
+func (o *Osqueryd) flags() []string {
+	return []string{
+		"--extensions_socket=" + o.socketPath,
+		"--extensions_timeout=10",
+		"--extensions_interval=1",
+		"--database_path=" + filepath.Join(o.dir, "osquery.db"),
+		"--pidfile=" + filepath.Join(o.dir, "osqueryd.pid"),
+		"--config_plugin=filesystem",
+		"--config_path=" + filepath.Join(o.dir, "osquery.conf"),
+		"--logger_plugin=filesystem",
+		"--logger_path=" + o.dir,
+		"--disable_watchdog",
+		"--disable_extensions=false",
+		"--force",
+	}
+}
+
+// socketPath returns the path of the extensions socket of an osqueryd with
+// its files in dir: a named pipe on Windows, a file in dir otherwise.
+func socketPath(dir string) string {
+	if runtime.GOOS == "windows" {
+		return `\\.\pipe\` + filepath.Base(dir)
+	}
+	return filepath.Join(dir, "osquery.em")
+}
+
+// waitReady waits for osqueryd to answer pings on its extensions socket.
+func (o *Osqueryd) waitReady(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		client, err := o.ping(ctx)
+		if err == nil {
+			o.client = client
+			return nil
+		}
+		select {
+		case <-o.exited:
+			return errors.Errorf("osqueryd exited: %v", o.cmd.ProcessState)
+		case <-ctx.Done():
+			return errors.Wrapf(err, "%v", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// ping returns a client connected to osqueryd if it answers a ping.
+func (o *Osqueryd) ping(ctx context.Context) (*osquery.ExtensionManagerClient, error) {
+	client, err := osquery.NewClient(o.socketPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	status, err := client.PingContext(ctx)
+	if err == nil && status.Code != 0 {
+		err = errors.Errorf("ping status %d: %s", status.Code, status.Message)
+	}
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// stop stops osqueryd, killing it if it doesn't exit in time.
+func (o *Osqueryd) stop() {
+	if o.client != nil {
+		o.client.Close()
+	}
+	if err := o.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		o.cmd.Process.Kill()
+	}
+	select {
+	case <-o.exited:
+	case <-time.After(stopTimeout):
+		o.cmd.Process.Kill()
+		<-o.exited
+	}
+}
+
+// SocketPath returns the path of the extensions socket of osqueryd.
+func (o *Osqueryd) SocketPath() string {
+	return o.socketPath
+}
+
+// Dir returns the directory of the database, config and logs of osqueryd.
+func (o *Osqueryd) Dir() string {
+	return o.dir
+}
+
+// Client returns a client connected to osqueryd.
+func (o *Osqueryd) Client() *osquery.ExtensionManagerClient {
+	return o.client
+}
+
+// Extension starts an extension with the plugins, and waits for osqueryd to
+// register it. The extension is shut down when the test ends.
+func (o *Osqueryd) Extension(t testing.TB, name string, plugins ...osquery.OsqueryPlugin) *osquery.ExtensionManagerServer {
+	t.Helper()
+	server, err := osquery.NewExtensionManagerServer(name, o.socketPath, osquery.ServerTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("creating extension %s: %v", name, err)
+	}
+	server.RegisterPlugin(plugins...)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Run()
+	}()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		defer cancel()
+		server.Shutdown(ctx)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStartTimeout)
+	defer cancel()
+	select {
+	case <-server.Ready():
+	case err := <-errc:
+		t.Fatalf("running extension %s: %v", name, err)
+	case <-ctx.Done():
+		t.Fatalf("waiting for extension %s: %v", name, ctx.Err())
+	}
+	return server
+}
+
+// Query runs the query in osqueryd and returns its rows, failing the test on
+// error.
+func (o *Osqueryd) Query(t testing.TB, sql string) []map[string]string {
+	t.Helper()
+	rows, err := o.client.QueryRows(sql)
+	if err != nil {
+		t.Fatalf("query %q: %v", sql, err)
+	}
+	return rows
+}
+
+// syncBuffer collects the output of osqueryd.
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
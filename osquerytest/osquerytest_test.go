@@ -0,0 +1,129 @@
+//go:build !windows
+// +build !windows
+
+package osquerytest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/osquery/osquery-go/fakeosqueryd"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// helperEnv makes the test binary run as a fake osqueryd.
+const helperEnv = "OSQUERYTEST_FAKE_OSQUERYD"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "1" {
+		runFakeOsqueryd()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeOsqueryd serves a fake extension manager on the socket given with
+// --extensions_socket until terminated.
+func runFakeOsqueryd() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	for _, arg := range os.Args[1:] {
+		if path := strings.TrimPrefix(arg, "--extensions_socket="); path != arg {
+			osqueryd, err := fakeosqueryd.Start(path)
+			if err != nil {
+				os.Exit(1)
+			}
+			<-signals
+			osqueryd.Close()
+			return
+		}
+	}
+	os.Exit(2)
+}
+
+func exampleTable() *table.Plugin {
+	return table.NewPlugin("osquerytest_example",
+		[]table.ColumnDefinition{table.TextColumn("name")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return []map[string]string{{"name": "a"}, {"name": "b"}}, nil
+		},
+	)
+}
+
+func TestStart(t *testing.T) {
+	t.Setenv(helperEnv, "1")
+	osqueryd := Start(t, WithBinary(os.Args[0]))
+	assert.FileExists(t, filepath.Join(osqueryd.Dir(), "osquery.conf"))
+
+	osqueryd.Extension(t, "osquerytest", exampleTable())
+	rows := osqueryd.Query(t, "SELECT * FROM osquerytest_example")
+	assert.Equal(t, []map[string]string{{"name": "a"}, {"name": "b"}}, rows)
+
+	extensions, err := osqueryd.Client().Extensions()
+	require.NoError(t, err)
+	require.Len(t, extensions, 1)
+}
+
+func TestOsqueryd(t *testing.T) {
+	osqueryd := Start(t)
+	osqueryd.Extension(t, "osquerytest", exampleTable())
+
+	rows := osqueryd.Query(t, "SELECT name FROM osquerytest_example WHERE name = 'b'")
+	assert.Equal(t, []map[string]string{{"name": "b"}}, rows)
+	rows = osqueryd.Query(t, "SELECT version FROM osquery_info")
+	assert.Len(t, rows, 1)
+}
+
+func TestFind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "osqueryd")
+	require.NoError(t, os.WriteFile(path, nil, 0755))
+
+	t.Setenv(PathEnv, path)
+	found, err := Find()
+	require.NoError(t, err)
+	assert.Equal(t, path, found)
+
+	t.Setenv(PathEnv, path+".missing")
+	_, err = Find()
+	assert.Error(t, err)
+}
+
+func TestDownloadURL(t *testing.T) {
+	url, err := downloadURL("5.12.1", "linux", "amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "https://pkg.osquery.io/linux/osquery-5.12.1_1.linux_x86_64.tar.gz", url)
+
+	_, err = downloadURL("5.12.1", "darwin", "arm64")
+	assert.Error(t, err)
+}
+
+func TestExtractOsqueryd(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	require.NoError(t, archive.WriteHeader(&tar.Header{Name: "usr/bin/osqueryd", Typeflag: tar.TypeSymlink, Linkname: "/opt/osquery/bin/osqueryd"}))
+	require.NoError(t, archive.WriteHeader(&tar.Header{Name: "opt/osquery/bin/osqueryd", Typeflag: tar.TypeReg, Size: 4, Mode: 0755}))
+	_, err := archive.Write([]byte("\x7fELF"))
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+	require.NoError(t, gz.Close())
+
+	path := filepath.Join(t.TempDir(), "osqueryd")
+	require.NoError(t, extractOsqueryd(&buf, path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "\x7fELF", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
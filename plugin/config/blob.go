@@ -0,0 +1,172 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBlobNotModified is returned by BlobStore.Get when the object still has
+// the requested ETag.
+var ErrBlobNotModified = errors.New("blob not modified")
+
+// BlobObject is an object read from a BlobStore.
+type BlobObject struct {
+	Data []byte
+	ETag string
+}
+
+// BlobStore reads objects from a bucket of an object storage service, such
+// as Amazon S3 or Google Cloud Storage. It keeps this package free of the
+// cloud SDK dependencies, and authentication is left to the SDK (for
+// example IAM roles through the default credential chain). If etag is not
+// empty and the object still has that ETag, Get may return
+// ErrBlobNotModified instead of the object. With
+// github.com/aws/aws-sdk-go-v2/service/s3, it can be implemented as:
+//
+//	type s3Store struct {
+//		client *s3.Client
+//		bucket string
+//	}
+//
+//	func (s s3Store) Get(ctx context.Context, key, etag string) (config.BlobObject, error) {
+//		input := &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)}
+//		if etag != "" {
+//			input.IfNoneMatch = aws.String(etag)
+//		}
+//		out, err := s.client.GetObject(ctx, input)
+//		var respErr *awshttp.ResponseError
+//		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotModified {
+//			return config.BlobObject{}, config.ErrBlobNotModified
+//		}
+//		if err != nil {
+//			return config.BlobObject{}, err
+//		}
+//		defer out.Body.Close()
+//		data, err := io.ReadAll(out.Body)
+//		return config.BlobObject{Data: data, ETag: aws.ToString(out.ETag)}, err
+//	}
+//
+// And with cloud.google.com/go/storage:
+//
+//	type gcsStore struct{ bucket *storage.BucketHandle }
+//
+//	func (s gcsStore) Get(ctx context.Context, key, etag string) (config.BlobObject, error) {
+//		obj := s.bucket.Object(key)
+//		attrs, err := obj.Attrs(ctx)
+//		if err != nil {
+//			return config.BlobObject{}, err
+//		}
+//		if attrs.Etag == etag {
+//			return config.BlobObject{}, config.ErrBlobNotModified
+//		}
+//		r, err := obj.Generation(attrs.Generation).NewReader(ctx)
+//		if err != nil {
+//			return config.BlobObject{}, err
+//		}
+//		defer r.Close()
+//		data, err := io.ReadAll(r)
+//		return config.BlobObject{Data: data, ETag: attrs.Etag}, err
+//	}
+type BlobStore interface {
+	Get(ctx context.Context, key, etag string) (BlobObject, error)
+}
+
+// BlobConfig configures a blob config plugin.
+type BlobConfig struct {
+	// Keys are the keys of the configuration objects in the bucket. Each
+	// object is a configuration source named after its key.
+	Keys []string
+	// Verify, if set, verifies each object, for example by checking a
+	// signature. Objects that fail verification are rejected.
+	Verify func(key string, data []byte) error
+	// CacheDir, if set, is the directory where the last good version of
+	// each object is saved, so that it can be served when the bucket
+	// can't be reached after a restart of the extension.
+	CacheDir string
+	// OnError, if set, is called with the errors hidden by serving the
+	// last good version of an object.
+	OnError func(err error)
+}
+
+// NewBlobPlugin creates a config plugin serving configurations staged in an
+// object storage bucket. The objects are read each time osquery requests
+// the configuration, and only transferred again when their ETag changes.
+// When reading an object fails, or the object is rejected, its last good
+// version is served instead, if any.
+func NewBlobPlugin(name string, store BlobStore, config BlobConfig, opts ...Option) (*Plugin, error) {
+	if len(config.Keys) == 0 {
+		return nil, errors.New("blob config plugin requires at least one key")
+	}
+	b := &blobConfig{store: store, config: config, lastGood: map[string]*lastGoodConfig{}}
+	for _, key := range config.Keys {
+		var file string
+		if config.CacheDir != "" {
+			file = filepath.Join(config.CacheDir, url.PathEscape(key)+".cache")
+		}
+		lastGood, err := loadLastGood(file)
+		if err != nil {
+			return nil, err
+		}
+		b.lastGood[key] = lastGood
+	}
+	return NewPlugin(name, b.generate, opts...), nil
+}
+
+// blobConfig serves the configurations of a BlobStore.
+type blobConfig struct {
+	store  BlobStore
+	config BlobConfig
+
+	// mutex guards the last good configurations and serializes reads.
+	mutex    sync.Mutex
+	lastGood map[string]*lastGoodConfig
+}
+
+func (b *blobConfig) generate(ctx context.Context) (map[string]string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	configs := make(map[string]string, len(b.config.Keys))
+	for _, key := range b.config.Keys {
+		lastGood := b.lastGood[key]
+		err := b.fetch(ctx, key, lastGood)
+		if err != nil {
+			if lastGood.cached == nil {
+				return nil, err
+			}
+			if b.config.OnError != nil {
+				b.config.OnError(errors.Wrapf(err, "serving last good config of %s", key))
+			}
+		}
+		configs[key] = lastGood.cached.Config
+	}
+	return configs, nil
+}
+
+// fetch reads an object, updating its last good version if it changed.
+func (b *blobConfig) fetch(ctx context.Context, key string, lastGood *lastGoodConfig) error {
+	obj, err := b.store.Get(ctx, key, lastGood.etag())
+	if err == ErrBlobNotModified && lastGood.cached != nil {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "reading %s", key)
+	}
+	if b.config.Verify != nil {
+		if err := b.config.Verify(key, obj.Data); err != nil {
+			return errors.Wrapf(err, "verifying %s", key)
+		}
+	}
+	if !json.Valid(obj.Data) {
+		return errors.Errorf("%s is not valid JSON", key)
+	}
+	if err := lastGood.set(&cachedConfig{ETag: obj.ETag, Config: string(obj.Data)}); err != nil && b.config.OnError != nil {
+		b.config.OnError(err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is a BlobStore of objects in memory, with their content as
+// ETag.
+type memoryStore struct {
+	objects     map[string]string
+	err         error
+	notModified int
+}
+
+func (s *memoryStore) Get(ctx context.Context, key, etag string) (BlobObject, error) {
+	if s.err != nil {
+		return BlobObject{}, s.err
+	}
+	data, ok := s.objects[key]
+	if !ok {
+		return BlobObject{}, errors.Errorf("no such key: %s", key)
+	}
+	if etag == data {
+		s.notModified++
+		return BlobObject{}, ErrBlobNotModified
+	}
+	return BlobObject{Data: []byte(data), ETag: data}, nil
+}
+
+func TestBlobPlugin(t *testing.T) {
+	store := &memoryStore{objects: map[string]string{
+		"osquery/base.json":  `{"options": {"verbose": true}}`,
+		"osquery/hosts.json": `{"schedule": {}}`,
+	}}
+	var errs []error
+	config := BlobConfig{
+		Keys:     []string{"osquery/base.json", "osquery/hosts.json"},
+		CacheDir: t.TempDir(),
+		Verify: func(key string, data []byte) error {
+			if string(data) == `{"unsigned": true}` {
+				return errors.New("bad signature")
+			}
+			return nil
+		},
+		OnError: func(err error) { errs = append(errs, err) },
+	}
+	plugin, err := NewBlobPlugin("blob", store, config)
+	require.NoError(t, err)
+
+	resp := genConfig(t, plugin)
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{
+		"osquery/base.json":  `{"options": {"verbose": true}}`,
+		"osquery/hosts.json": `{"schedule": {}}`,
+	}}, resp.Response)
+
+	// Not modified
+	resp = genConfig(t, plugin)
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, 2, store.notModified)
+
+	// Rejected objects serve the last good version
+	store.objects["osquery/base.json"] = `{"unsigned": true}`
+	resp = genConfig(t, plugin)
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, `{"options": {"verbose": true}}`, resp.Response[0]["osquery/base.json"])
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "bad signature")
+
+	// A new plugin serves the cached objects when the store fails
+	plugin, err = NewBlobPlugin("blob", store, config)
+	require.NoError(t, err)
+	store.err = errors.New("access denied")
+	resp = genConfig(t, plugin)
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, `{"schedule": {}}`, resp.Response[0]["osquery/hosts.json"])
+}
+
+func TestBlobPluginErrors(t *testing.T) {
+	_, err := NewBlobPlugin("blob", &memoryStore{}, BlobConfig{})
+	assert.Error(t, err)
+
+	plugin, err := NewBlobPlugin("blob", &memoryStore{objects: map[string]string{"bad": "{"}}, BlobConfig{Keys: []string{"bad", "missing"}})
+	require.NoError(t, err)
+	resp := genConfig(t, plugin)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error getting config: bad is not valid JSON", resp.Status.Message)
+}
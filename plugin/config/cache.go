@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// cachedConfig is the last good configuration of a source, as saved to its
+// cache file.
+type cachedConfig struct {
+	ETag   string `json:"etag,omitempty"`
+	Config string `json:"config"`
+}
+
+// lastGoodConfig holds the last good configuration of a source, served when
+// the source fails. If file is set, the configuration is saved there so that
+// it survives restarts.
+type lastGoodConfig struct {
+	file   string
+	cached *cachedConfig
+}
+
+// loadLastGood returns the configuration saved in file, if any.
+func loadLastGood(file string) (*lastGoodConfig, error) {
+	l := &lastGoodConfig{file: file}
+	if file == "" {
+		return l, nil
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading config cache")
+	}
+	var cached cachedConfig
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, errors.Wrapf(err, "parsing config cache %s", file)
+	}
+	l.cached = &cached
+	return l, nil
+}
+
+// etag returns the ETag of the configuration, if any.
+func (l *lastGoodConfig) etag() string {
+	if l.cached == nil {
+		return ""
+	}
+	return l.cached.ETag
+}
+
+// set replaces the configuration and saves it, atomically, to the cache
+// file. The configuration is replaced even if saving fails.
+func (l *lastGoodConfig) set(cached *cachedConfig) error {
+	l.cached = cached
+	if l.file == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.file), filepath.Base(l.file)+".*")
+	if err != nil {
+		return errors.Wrap(err, "saving config cache")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "saving config cache")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "saving config cache")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), l.file), "saving config cache")
+}
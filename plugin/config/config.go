@@ -5,9 +5,11 @@ package config
 
 import (
 	"context"
+	"crypto/ed25519"
 
 	"github.com/osquery/osquery-go/gen/osquery"
 	"github.com/osquery/osquery-go/traces"
+	"github.com/pkg/errors"
 )
 
 // GenerateConfigsFunc returns the configurations generated by this plugin.
@@ -21,13 +23,36 @@ type GenerateConfigsFunc func(ctx context.Context) (map[string]string, error)
 type Plugin struct {
 	name     string
 	generate GenerateConfigsFunc
+
+	// generatePack answers genPack requests, see WithPackGenerator.
+	generatePack GeneratePackFunc
+	// signingKeys verify the generated configs, see WithSignedConfigs.
+	signingKeys map[string]ed25519.PublicKey
+	// packLoader inlines the packs of generated configs, see
+	// WithInlinePacks.
+	packLoader PackLoader
+	// validator validates the generated configs, see WithValidator.
+	validator *Validator
+	// update answers update requests, see WithUpdateFunc.
+	update UpdateFunc
+	// actions answers requests with other actions, see WithAction.
+	actions map[string]ActionFunc
+	// shutdown is called by Shutdown, if set.
+	shutdown func()
 }
 
+// Option configures optional behavior of a config Plugin.
+type Option func(*Plugin)
+
 // NewConfigPlugin takes a value that implements ConfigPlugin and wraps it with
 // the appropriate methods to satisfy the OsqueryPlugin interface. Use this to
 // easily create configuration plugins.
-func NewPlugin(name string, fn GenerateConfigsFunc) *Plugin {
-	return &Plugin{name: name, generate: fn}
+func NewPlugin(name string, fn GenerateConfigsFunc, opts ...Option) *Plugin {
+	p := &Plugin{name: name, generate: fn}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (t *Plugin) Name() string {
@@ -55,13 +80,29 @@ const requestActionKey = "action"
 // Action value used when config is requested
 const genConfigAction = "genConfig"
 
-func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+// Action value used when a pack referenced by the config is requested
+const genPackAction = "genPack"
+
+// Action value used when a config update is pushed to the plugin
+const updateAction = "update"
+
+func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) (resp osquery.ExtensionResponse) {
 	ctx, span := traces.StartSpan(ctx, "Config.Call", "action", request[requestActionKey])
 	defer span.End()
+	defer func() { traces.RecordStatus(span, resp.Status) }()
 
 	switch request[requestActionKey] {
 	case genConfigAction:
 		configs, err := t.generate(ctx)
+		if err == nil && t.signingKeys != nil {
+			configs, err = t.openBundles(configs)
+		}
+		if err == nil && t.packLoader != nil {
+			configs, err = t.inlinePacks(configs)
+		}
+		if err == nil && t.validator != nil {
+			err = t.validator.validate(ctx, configs)
+		}
 		if err != nil {
 			return osquery.ExtensionResponse{
 				Status: &osquery.ExtensionStatus{
@@ -76,15 +117,105 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 			Response: osquery.ExtensionPluginResponse{configs},
 		}
 
+	case genPackAction:
+		if t.generatePack == nil {
+			return osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: "packs are not supported by this config plugin",
+				},
+			}
+		}
+		pack, err := t.generatePack(ctx, request["name"], request["value"])
+		if err != nil {
+			return osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: "error getting pack: " + err.Error(),
+				},
+			}
+		}
+
+		return osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: osquery.ExtensionPluginResponse{{request["name"]: pack}},
+		}
+
+	case updateAction:
+		if t.update == nil {
+			break
+		}
+		source, ok := request["source"]
+		data, hasData := request["data"]
+		if !ok || !hasData {
+			return osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: "update requires a source and data",
+				},
+			}
+		}
+		if err := t.update(ctx, source, data); err != nil {
+			return osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: "error updating config: " + err.Error(),
+				},
+			}
+		}
+
+		return osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: osquery.ExtensionPluginResponse{},
+		}
+
 	default:
+		fn, ok := t.actions[request[requestActionKey]]
+		if !ok {
+			break
+		}
+		response, err := fn(ctx, request)
+		if err != nil {
+			return osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: "error handling " + request[requestActionKey] + ": " + err.Error(),
+				},
+			}
+		}
+		if response == nil {
+			response = osquery.ExtensionPluginResponse{}
+		}
+
 		return osquery.ExtensionResponse{
-			Status: &osquery.ExtensionStatus{
-				Code:    1,
-				Message: "unknown action: " + request["action"],
-			},
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: response,
 		}
 	}
 
+	return osquery.ExtensionResponse{
+		Status: &osquery.ExtensionStatus{
+			Code:    1,
+			Message: "unknown action: " + request["action"],
+		},
+	}
 }
 
-func (t *Plugin) Shutdown() {}
+// inlinePacks returns the configs with their packs inlined.
+func (t *Plugin) inlinePacks(configs map[string]string) (map[string]string, error) {
+	inlined := make(map[string]string, len(configs))
+	for source, config := range configs {
+		config, err := InlinePacks(config, t.packLoader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "source %s", source)
+		}
+		inlined[source] = config
+	}
+	return inlined, nil
+}
+
+func (t *Plugin) Shutdown() {
+	if t.shutdown != nil {
+		t.shutdown()
+	}
+}
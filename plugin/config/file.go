@@ -0,0 +1,312 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig configures a file config plugin.
+type FileConfig struct {
+	// Paths are the configuration files. Files with a .yaml or .yml
+	// extension are YAML, other files are JSON (lines starting with // are
+	// ignored, as in osquery config files). The files are merged in order:
+	// the queries, packs, options and file paths of a file are added to
+	// those of the previous files, replacing the ones with the same name,
+	// and other sections replace the previous ones.
+	Paths []string
+	// Source is the source name of the merged configuration. Empty means
+	// "file".
+	Source string
+	// PollInterval is how often the files are checked for changes. Zero
+	// means 5 seconds.
+	PollInterval time.Duration
+	// Validate, if set, validates the merged configuration, in addition
+	// to the checks of ValidateConfig. A configuration that fails
+	// validation is not served, the last valid one is served instead.
+	Validate func(Config) error
+	// OnReload, if set, is called after the files changed, with the error
+	// that prevented the new configuration from being served, if any.
+	OnReload func(err error)
+}
+
+// NewFilePlugin creates a config plugin serving the configuration read from
+// local files, for extensions that distribute the configuration themselves.
+// The files are loaded when the plugin is created, which fails if they
+// aren't valid, and reloaded whenever their modification time or size
+// changes, until the plugin is shut down. osquery picks up the new
+// configuration on its next refresh (see the config_refresh flag). Files
+// should be replaced atomically (written to a temporary file then renamed),
+// so that they are never read partially written.
+func NewFilePlugin(name string, config FileConfig, opts ...Option) (*Plugin, error) {
+	if len(config.Paths) == 0 {
+		return nil, errors.New("file config plugin requires at least one path")
+	}
+	if config.Source == "" {
+		config.Source = "file"
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 5 * time.Second
+	}
+
+	f := &fileConfig{config: config, stop: make(chan struct{}), done: make(chan struct{})}
+	if _, err := f.reload(); err != nil {
+		return nil, err
+	}
+	go f.watch()
+
+	p := NewPlugin(name, f.generate, opts...)
+	p.shutdown = f.close
+	return p, nil
+}
+
+// fileConfig serves the last valid configuration read from the files.
+type fileConfig struct {
+	config FileConfig
+
+	mutex   sync.Mutex
+	json    string
+	modTime map[string]time.Time
+	size    map[string]int64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (f *fileConfig) generate(ctx context.Context) (map[string]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return map[string]string{f.config.Source: f.json}, nil
+}
+
+func (f *fileConfig) watch() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+		changed, err := f.reload()
+		if changed && f.config.OnReload != nil {
+			f.config.OnReload(err)
+		}
+	}
+}
+
+func (f *fileConfig) close() {
+	f.closeOnce.Do(func() {
+		close(f.stop)
+		<-f.done
+	})
+}
+
+// reload reads the files if they changed since the last reload, and serves
+// the merged configuration if it is valid.
+func (f *fileConfig) reload() (changed bool, err error) {
+	modTime := make(map[string]time.Time, len(f.config.Paths))
+	size := make(map[string]int64, len(f.config.Paths))
+	for _, path := range f.config.Paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return true, err
+		}
+		modTime[path] = info.ModTime()
+		size[path] = info.Size()
+	}
+
+	f.mutex.Lock()
+	changed = f.modTime == nil
+	for _, path := range f.config.Paths {
+		if !modTime[path].Equal(f.modTime[path]) || size[path] != f.size[path] {
+			changed = true
+		}
+	}
+	f.mutex.Unlock()
+	if !changed {
+		return false, nil
+	}
+
+	// Files are checked again only once they change again
+	defer func() {
+		f.mutex.Lock()
+		f.modTime, f.size = modTime, size
+		f.mutex.Unlock()
+	}()
+
+	var merged Config
+	for _, path := range f.config.Paths {
+		c, err := LoadConfigFile(path)
+		if err != nil {
+			return true, err
+		}
+		merged = MergeConfigs(merged, c)
+	}
+	if err := ValidateConfig(merged); err != nil {
+		return true, err
+	}
+	if f.config.Validate != nil {
+		if err := f.config.Validate(merged); err != nil {
+			return true, errors.Wrap(err, "invalid config")
+		}
+	}
+	data, err := merged.JSON()
+	if err != nil {
+		return true, err
+	}
+
+	f.mutex.Lock()
+	f.json = data
+	f.mutex.Unlock()
+	return true, nil
+}
+
+// LoadConfigFile reads a configuration file. Files with a .yaml or .yml
+// extension are YAML, other files are JSON, with lines starting with //
+// ignored.
+func LoadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "reading config")
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var parsed interface{}
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			return Config{}, errors.Wrapf(err, "parsing config %s", path)
+		}
+		if data, err = json.Marshal(parsed); err != nil {
+			return Config{}, errors.Wrapf(err, "converting config %s", path)
+		}
+	default:
+		data = stripComments(data)
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Config{}, errors.Wrapf(err, "parsing config %s", path)
+	}
+	return c, nil
+}
+
+// MergeConfigs returns the configuration base updated with the sections of
+// c. Options, scheduled queries, packs, file path categories and automatic
+// tables are merged by name, with those of c replacing those of base. Decorators and
+// additional sections of c replace those of base.
+func MergeConfigs(base, c Config) Config {
+	merged := Config{
+		Options:    Options{},
+		Schedule:   Schedule{},
+		Packs:      map[string]Pack{},
+		Decorators: base.Decorators,
+		FilePaths:  map[string][]string{},
+		AutoTables: map[string]AutoTable{},
+		Extra:      map[string]interface{}{},
+	}
+	for _, src := range []Config{base, c} {
+		for k, v := range src.Options {
+			merged.Options[k] = v
+		}
+		for k, v := range src.Schedule {
+			merged.Schedule[k] = v
+		}
+		for k, v := range src.Packs {
+			merged.Packs[k] = v
+		}
+		for k, v := range src.FilePaths {
+			merged.FilePaths[k] = v
+		}
+		for k, v := range src.AutoTables {
+			merged.AutoTables[k] = v
+		}
+		for k, v := range src.Extra {
+			merged.Extra[k] = v
+		}
+	}
+	if c.Decorators != nil {
+		merged.Decorators = c.Decorators
+	}
+	return merged
+}
+
+// ValidateConfig checks that the scheduled queries and the queries of the
+// packs have SQL and an interval, that the decorator intervals are
+// multiples of 60 seconds, and that the automatic tables have a query, a
+// path and columns.
+func ValidateConfig(c Config) error {
+	for name, query := range c.Schedule {
+		if err := validateQuery(query); err != nil {
+			return errors.Wrapf(err, "scheduled query %s", name)
+		}
+	}
+	for packName, pack := range c.Packs {
+		for name, query := range pack.Queries {
+			if err := validateQuery(query); err != nil {
+				return errors.Wrapf(err, "pack %s query %s", packName, name)
+			}
+		}
+	}
+	if c.Decorators != nil {
+		if err := validateDecorators(*c.Decorators); err != nil {
+			return errors.Wrap(err, "decorators")
+		}
+	}
+	for name, table := range c.AutoTables {
+		if err := validateAutoTable(table); err != nil {
+			return errors.Wrapf(err, "automatic table %s", name)
+		}
+	}
+	return nil
+}
+
+func validateQuery(query Query) error {
+	if strings.TrimSpace(query.Query) == "" {
+		return errors.New("missing query")
+	}
+	if query.Interval == 0 {
+		return errors.New("missing interval")
+	}
+	return nil
+}
+
+func validateAutoTable(table AutoTable) error {
+	if strings.TrimSpace(table.Query) == "" {
+		return errors.New("missing query")
+	}
+	if table.Path == "" {
+		return errors.New("missing path")
+	}
+	if len(table.Columns) == 0 {
+		return errors.New("missing columns")
+	}
+	return nil
+}
+
+func validateDecorators(d Decorators) error {
+	for interval := range d.Interval {
+		if err := validateDecoratorInterval(interval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateDecoratorInterval(interval string) error {
+	seconds, err := strconv.ParseUint(interval, 10, 32)
+	if err != nil || seconds == 0 || seconds%60 != 0 {
+		return errors.Errorf("interval %q is not a multiple of 60 seconds", interval)
+	}
+	return nil
+}
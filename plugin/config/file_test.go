@@ -0,0 +1,144 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "osquery.conf")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`// Base config
+{"options": {"verbose": true}, "schedule": {"uptime": {"query": "SELECT * FROM uptime", "interval": 60}}}`), 0644))
+	yamlPath := filepath.Join(dir, "osquery.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+options:
+  logger_tls_period: 10
+schedule:
+  users:
+    query: SELECT * FROM users
+    interval: 3600
+yara:
+  signatures: {}
+`), 0644))
+
+	c, err := LoadConfigFile(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, Options{"verbose": true}, c.Options)
+	assert.Equal(t, uint(60), c.Schedule["uptime"].Interval)
+
+	c, err = LoadConfigFile(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, Options{"logger_tls_period": float64(10)}, c.Options)
+	assert.Equal(t, "SELECT * FROM users", c.Schedule["users"].Query)
+	assert.Contains(t, c.Extra, "yara")
+
+	_, err = LoadConfigFile(filepath.Join(dir, "missing.conf"))
+	assert.Error(t, err)
+}
+
+func TestMergeConfigs(t *testing.T) {
+	base := NewBuilder().
+		Option("verbose", true).
+		Option("logger_tls_period", 10).
+		Query("uptime", Query{Query: "SELECT * FROM uptime", Interval: 60}).
+		Decorators(Decorators{Load: []string{"SELECT uuid FROM system_info"}}).
+		Section("yara", "base").
+		Config()
+	override := NewBuilder().
+		Option("verbose", false).
+		Query("users", Query{Query: "SELECT * FROM users", Interval: 3600}).
+		Section("yara", "override").
+		Config()
+
+	merged := MergeConfigs(base, override)
+	assert.Equal(t, Options{"verbose": false, "logger_tls_period": 10}, merged.Options)
+	assert.Len(t, merged.Schedule, 2)
+	assert.Equal(t, base.Decorators, merged.Decorators)
+	assert.Equal(t, "override", merged.Extra["yara"])
+}
+
+func TestValidateConfig(t *testing.T) {
+	assert.NoError(t, ValidateConfig(NewBuilder().Query("uptime", Query{Query: "SELECT 1", Interval: 60}).Config()))
+	assert.Error(t, ValidateConfig(NewBuilder().Query("uptime", Query{Interval: 60}).Config()))
+	assert.Error(t, ValidateConfig(NewBuilder().Pack("p", Pack{Queries: map[string]Query{"q": {Query: "SELECT 1"}}}).Config()))
+}
+
+func TestFilePlugin(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.json")
+	local := filepath.Join(dir, "local.yml")
+	writeFile(t, base, []byte(`{"options": {"verbose": true}}`))
+	writeFile(t, local, []byte("options:\n  verbose: false\n"))
+
+	reloaded := make(chan error, 10)
+	plugin, err := NewFilePlugin("file", FileConfig{
+		Paths:        []string{base, local},
+		PollInterval: 10 * time.Millisecond,
+		Validate: func(c Config) error {
+			if c.Options["host_identifier"] == "invalid" {
+				return errors.New("bad host identifier")
+			}
+			return nil
+		},
+		OnReload: func(err error) { reloaded <- err },
+	})
+	require.NoError(t, err)
+	defer plugin.Shutdown()
+
+	options := func() Options {
+		resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+		require.Equal(t, &StatusOK, resp.Status)
+		var c Config
+		require.NoError(t, json.Unmarshal([]byte(resp.Response[0]["file"]), &c))
+		return c.Options
+	}
+	assert.Equal(t, Options{"verbose": false}, options())
+
+	// Changes are picked up
+	writeFile(t, local, []byte("options:\n  verbose: false\n  host_identifier: uuid\n"))
+	require.NoError(t, waitReload(t, reloaded))
+	assert.Equal(t, Options{"verbose": false, "host_identifier": "uuid"}, options())
+
+	// Invalid configs are not served
+	writeFile(t, local, []byte("options:\n  host_identifier: invalid\n"))
+	assert.EqualError(t, waitReload(t, reloaded), "invalid config: bad host identifier")
+	writeFile(t, base, []byte(`{"options": `))
+	assert.Error(t, waitReload(t, reloaded))
+	assert.Equal(t, Options{"verbose": false, "host_identifier": "uuid"}, options())
+}
+
+// writeFile replaces a file atomically, so that it is never read partially
+// written.
+func writeFile(t *testing.T, path string, data []byte) {
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, data, 0644))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func waitReload(t *testing.T, reloaded chan error) error {
+	select {
+	case err := <-reloaded:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("config not reloaded")
+		return nil
+	}
+}
+
+func TestFilePluginErrors(t *testing.T) {
+	_, err := NewFilePlugin("file", FileConfig{})
+	assert.Error(t, err)
+
+	_, err = NewFilePlugin("file", FileConfig{Paths: []string{filepath.Join(t.TempDir(), "missing.json")}})
+	assert.Error(t, err)
+}
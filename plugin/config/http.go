@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPConfig configures an HTTPS config plugin.
+type HTTPConfig struct {
+	// URL is the HTTPS endpoint the configuration is requested from, with
+	// GET requests. The response body is the configuration JSON.
+	URL string
+	// Source is the source name of the configuration. Empty means
+	// "https".
+	Source string
+	// TLSConfig configures TLS, for example with a client certificate for
+	// mutual TLS. If nil, the default TLS configuration is used.
+	TLSConfig *tls.Config
+	// Header holds additional headers sent with each request, for example
+	// an Authorization header.
+	Header http.Header
+	// Timeout limits the duration of each request. Zero means 30 seconds.
+	Timeout time.Duration
+	// Verify, if set, verifies the response body, for example by checking
+	// a signature sent in a response header. Configurations that fail
+	// verification are rejected.
+	Verify func(body []byte, header http.Header) error
+	// CacheFile, if set, is where the last good configuration is saved,
+	// so that it can be served when the endpoint can't be reached after a
+	// restart of the extension.
+	CacheFile string
+	// OnError, if set, is called with the errors hidden by serving the
+	// last good configuration.
+	OnError func(err error)
+}
+
+// NewHTTPPlugin creates a config plugin serving the configuration fetched
+// from a remote HTTPS endpoint each time osquery requests it. Requests are
+// conditional (If-None-Match) once the endpoint returned an ETag, and a 304
+// Not Modified response serves the cached configuration. When the request
+// fails, or the configuration is rejected, the last good configuration is
+// served instead, if any.
+func NewHTTPPlugin(name string, config HTTPConfig, opts ...Option) (*Plugin, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing config URL")
+	}
+	if u.Scheme != "https" {
+		return nil, errors.Errorf("config URL must use https, not %q", u.Scheme)
+	}
+	if config.Source == "" {
+		config.Source = "https"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig
+	}
+	lastGood, err := loadLastGood(config.CacheFile)
+	if err != nil {
+		return nil, err
+	}
+	h := &httpConfig{
+		config:   config,
+		client:   &http.Client{Transport: transport, Timeout: config.Timeout},
+		lastGood: lastGood,
+	}
+	return NewPlugin(name, h.generate, opts...), nil
+}
+
+// httpConfig serves the configuration of an HTTPS endpoint.
+type httpConfig struct {
+	config HTTPConfig
+	client *http.Client
+
+	// mutex guards the last good configuration and serializes requests.
+	mutex    sync.Mutex
+	lastGood *lastGoodConfig
+}
+
+func (h *httpConfig) generate(ctx context.Context) (map[string]string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fetched, err := h.fetch(ctx)
+	if err == nil {
+		if fetched != nil {
+			if err := h.lastGood.set(fetched); err != nil && h.config.OnError != nil {
+				h.config.OnError(err)
+			}
+		}
+		return map[string]string{h.config.Source: h.lastGood.cached.Config}, nil
+	}
+
+	if h.lastGood.cached == nil {
+		return nil, err
+	}
+	if h.config.OnError != nil {
+		h.config.OnError(errors.Wrap(err, "serving last good config"))
+	}
+	return map[string]string{h.config.Source: h.lastGood.cached.Config}, nil
+}
+
+// fetch requests the configuration. It returns nil if it didn't change
+// since the cached one.
+func (h *httpConfig) fetch(ctx context.Context) (*cachedConfig, error) {
+	req, err := http.NewRequest(http.MethodGet, h.config.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	req = req.WithContext(ctx)
+	for k, v := range h.config.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if etag := h.lastGood.etag(); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "requesting config")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && h.lastGood.cached != nil {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, errors.Errorf("requesting config: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading config")
+	}
+	if h.config.Verify != nil {
+		if err := h.config.Verify(body, resp.Header); err != nil {
+			return nil, errors.Wrap(err, "verifying config")
+		}
+	}
+	if !json.Valid(body) {
+		return nil, errors.New("config is not valid JSON")
+	}
+	return &cachedConfig{ETag: resp.Header.Get("ETag"), Config: string(body)}, nil
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configServer serves a configuration with an ETag.
+type configServer struct {
+	mutex       sync.Mutex
+	config      string
+	etag        string
+	status      int
+	requests    int
+	notModified int
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests++
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if r.Header.Get("If-None-Match") == s.etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	w.Header().Set("X-Signature", "signed:"+s.config)
+	w.Write([]byte(s.config))
+}
+
+func (s *configServer) set(config, etag string, status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.config, s.etag, s.status = config, etag, status
+}
+
+func genConfig(t *testing.T, plugin *Plugin) osquery.ExtensionResponse {
+	return plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+}
+
+func TestHTTPPlugin(t *testing.T) {
+	s := &configServer{config: `{"options": {"verbose": true}}`, etag: `"v1"`}
+	srv := httptest.NewTLSServer(s)
+	defer srv.Close()
+
+	var errs []error
+	cacheFile := filepath.Join(t.TempDir(), "config.cache")
+	config := HTTPConfig{
+		URL:       srv.URL,
+		TLSConfig: srv.Client().Transport.(*http.Transport).TLSClientConfig,
+		CacheFile: cacheFile,
+		Verify: func(body []byte, header http.Header) error {
+			if header.Get("X-Signature") != "signed:"+string(body) {
+				return errors.New("bad signature")
+			}
+			return nil
+		},
+		OnError: func(err error) { errs = append(errs, err) },
+	}
+	plugin, err := NewHTTPPlugin("https", config)
+	require.NoError(t, err)
+
+	resp := genConfig(t, plugin)
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"https": `{"options": {"verbose": true}}`}}, resp.Response)
+
+	// Not modified
+	resp = genConfig(t, plugin)
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, `{"options": {"verbose": true}}`, resp.Response[0]["https"])
+	assert.Equal(t, 1, s.notModified)
+
+	// Updated
+	s.set(`{"options": {"verbose": false}}`, `"v2"`, 0)
+	resp = genConfig(t, plugin)
+	assert.Equal(t, `{"options": {"verbose": false}}`, resp.Response[0]["https"])
+
+	// Failures serve the last good config
+	s.set("", `"v3"`, http.StatusInternalServerError)
+	resp = genConfig(t, plugin)
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, `{"options": {"verbose": false}}`, resp.Response[0]["https"])
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "500")
+
+	// Invalid configs are rejected
+	s.set(`{"options": [`, `"v4"`, 0)
+	resp = genConfig(t, plugin)
+	assert.Equal(t, `{"options": {"verbose": false}}`, resp.Response[0]["https"])
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[1].Error(), "not valid JSON")
+
+	// A new plugin serves the cached config when the endpoint fails
+	plugin, err = NewHTTPPlugin("https", config)
+	require.NoError(t, err)
+	s.set("", "", http.StatusServiceUnavailable)
+	resp = genConfig(t, plugin)
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, `{"options": {"verbose": false}}`, resp.Response[0]["https"])
+}
+
+func TestHTTPPluginVerify(t *testing.T) {
+	s := &configServer{config: `{}`, etag: `"v1"`}
+	srv := httptest.NewTLSServer(s)
+	defer srv.Close()
+
+	plugin, err := NewHTTPPlugin("https", HTTPConfig{
+		URL:       srv.URL,
+		TLSConfig: srv.Client().Transport.(*http.Transport).TLSClientConfig,
+		Verify: func(body []byte, header http.Header) error {
+			return errors.New("bad signature")
+		},
+	})
+	require.NoError(t, err)
+
+	resp := genConfig(t, plugin)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "verifying config: bad signature")
+}
+
+func TestHTTPPluginErrors(t *testing.T) {
+	_, err := NewHTTPPlugin("https", HTTPConfig{URL: "http://example.com/config"})
+	assert.Error(t, err)
+
+	// Certificates are verified
+	srv := httptest.NewTLSServer(&configServer{config: `{}`})
+	defer srv.Close()
+	plugin, err := NewHTTPPlugin("https", HTTPConfig{URL: srv.URL})
+	require.NoError(t, err)
+	resp := genConfig(t, plugin)
+	assert.Equal(t, int32(1), resp.Status.Code)
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Layer is a configuration layer merged by Merge, such as a base
+// configuration, team overrides or host specific additions.
+type Layer struct {
+	// Name identifies the layer in errors.
+	Name     string
+	Generate GenerateConfigsFunc
+	// Optional layers are skipped when they fail, instead of failing the
+	// merge. Wrap Generate to report their errors.
+	Optional bool
+}
+
+// Merge returns a GenerateConfigsFunc combining the configurations of the
+// layers into a single configuration, under the given source name, so that
+// the precedence of the layers is defined by the extension instead of by
+// the order in which osquery reads the sources.
+//
+// Layers are merged in order, each taking precedence over the previous
+// ones, as in MergeConfigs: options, scheduled queries, packs and file path
+// categories are merged by name, a later layer replacing the entries of the
+// same name (a pack is replaced as a whole), and decorators and other
+// sections of a later layer replace the previous ones. The sources of a
+// layer are merged in the order of their names.
+func Merge(source string, layers ...Layer) GenerateConfigsFunc {
+	return func(ctx context.Context) (map[string]string, error) {
+		var merged Config
+		for _, layer := range layers {
+			configs, err := layer.Generate(ctx)
+			if err == nil {
+				merged, err = mergeLayer(merged, configs)
+			}
+			if err != nil {
+				if layer.Optional {
+					continue
+				}
+				return nil, errors.Wrapf(err, "layer %s", layer.Name)
+			}
+		}
+
+		data, err := merged.JSON()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{source: data}, nil
+	}
+}
+
+// mergeLayer merges the configurations of a layer into merged. merged is
+// left unchanged on error.
+func mergeLayer(merged Config, configs map[string]string) (Config, error) {
+	for _, source := range sortedKeys(configs) {
+		var c Config
+		if err := json.Unmarshal([]byte(configs[source]), &c); err != nil {
+			return Config{}, errors.Wrapf(err, "parsing source %s", source)
+		}
+		merged = MergeConfigs(merged, c)
+	}
+	return merged, nil
+}
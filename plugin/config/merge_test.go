@@ -0,0 +1,55 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	base := Static("base", NewBuilder().
+		Option("verbose", false).
+		Option("logger_tls_period", 10).
+		Query("uptime", Query{Query: "SELECT * FROM uptime", Interval: 60}).
+		Pack("hw", Pack{Queries: map[string]Query{"usb": {Query: "SELECT * FROM usb_devices", Interval: 3600}}}).
+		Config())
+	team := func(context.Context) (map[string]string, error) {
+		return map[string]string{
+			"a": `{"options": {"verbose": true}, "schedule": {"uptime": {"query": "SELECT * FROM uptime", "interval": 10}}}`,
+			"b": `{"options": {"verbose": false, "host_identifier": "uuid"}}`,
+		}, nil
+	}
+	host := func(context.Context) (map[string]string, error) {
+		return nil, errors.New("no host config")
+	}
+
+	fn := Merge("merged",
+		Layer{Name: "base", Generate: base},
+		Layer{Name: "team", Generate: team},
+		Layer{Name: "host", Generate: host, Optional: true},
+	)
+	configs, err := fn(context.Background())
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+
+	var merged Config
+	require.NoError(t, json.Unmarshal([]byte(configs["merged"]), &merged))
+	assert.Equal(t, Options{"verbose": false, "logger_tls_period": float64(10), "host_identifier": "uuid"}, merged.Options)
+	assert.Equal(t, uint(10), merged.Schedule["uptime"].Interval)
+	assert.Contains(t, merged.Packs, "hw")
+
+	// Required layers fail the merge
+	fn = Merge("merged", Layer{Name: "base", Generate: base}, Layer{Name: "host", Generate: host})
+	_, err = fn(context.Background())
+	assert.EqualError(t, err, "layer host: no host config")
+
+	fn = Merge("merged", Layer{Name: "base", Generate: base}, Layer{Name: "invalid", Generate: func(context.Context) (map[string]string, error) {
+		return map[string]string{"x": "{"}, nil
+	}})
+	_, err = fn(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+)
+
+// OptionsGetter returns the options (flags) of osquery. It is implemented by
+// *osquery.ExtensionManagerClient.
+type OptionsGetter interface {
+	OptionsContext(ctx context.Context) (osquery.InternalOptionList, error)
+}
+
+// OptionChange is the change of an osquery option by a configuration.
+type OptionChange struct {
+	Name string
+	// Current is the current value of the option in osquery, and Default
+	// its default value. Both are empty for unknown options.
+	Current string
+	Default string
+	// New is the value of the option in the configuration, formatted as
+	// osquery formats it.
+	New string
+	// Unknown is true if osquery has no such option, for example because
+	// of a typo, or because it is an option of a newer osquery version.
+	// osquery ignores such options.
+	Unknown bool
+}
+
+func (c OptionChange) String() string {
+	if c.Unknown {
+		return fmt.Sprintf("%s: unknown option (set to %s)", c.Name, c.New)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Name, c.Current, c.New)
+}
+
+// DiffOptions fetches the current options of osquery and returns the
+// options of the configuration that differ from them, in order of name.
+// Options set to their current value are left out. Note that osquery only
+// applies some options at startup, see the documentation of each flag.
+func DiffOptions(ctx context.Context, client OptionsGetter, options Options) ([]OptionChange, error) {
+	current, err := client.OptionsContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting osquery options")
+	}
+
+	var changes []OptionChange
+	for _, name := range sortedKeys(options) {
+		value := formatOption(options[name])
+		info, ok := current[name]
+		if !ok || info == nil {
+			changes = append(changes, OptionChange{Name: name, New: value, Unknown: true})
+			continue
+		}
+		if optionEqual(info.Type, info.Value, value) {
+			continue
+		}
+		changes = append(changes, OptionChange{
+			Name:    name,
+			Current: info.Value,
+			Default: info.DefaultValue,
+			New:     value,
+		})
+	}
+	return changes, nil
+}
+
+// formatOption formats an option value as osquery does.
+func formatOption(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// optionEqual compares option values according to their osquery type.
+func optionEqual(typ, a, b string) bool {
+	switch {
+	case typ == "bool":
+		x, errX := strconv.ParseBool(a)
+		y, errY := strconv.ParseBool(b)
+		if errX == nil && errY == nil {
+			return x == y
+		}
+	case strings.HasPrefix(typ, "int") || strings.HasPrefix(typ, "uint"):
+		x, errX := strconv.ParseFloat(a, 64)
+		y, errY := strconv.ParseFloat(b, 64)
+		if errX == nil && errY == nil {
+			return x == y
+		}
+	}
+	return a == b
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type optionsGetter struct {
+	options osquery.InternalOptionList
+	err     error
+}
+
+func (g optionsGetter) OptionsContext(ctx context.Context) (osquery.InternalOptionList, error) {
+	return g.options, g.err
+}
+
+func TestDiffOptions(t *testing.T) {
+	client := optionsGetter{options: osquery.InternalOptionList{
+		"verbose":           {Value: "false", DefaultValue: "false", Type: "bool"},
+		"logger_tls_period": {Value: "4", DefaultValue: "4", Type: "uint64"},
+		"host_identifier":   {Value: "hostname", DefaultValue: "hostname", Type: "string"},
+		"disable_events":    {Value: "1", DefaultValue: "true", Type: "bool"},
+	}}
+
+	changes, err := DiffOptions(context.Background(), client, NewBuilder().
+		Option("verbose", true).
+		Option("logger_tls_period", 4).
+		Option("host_identifier", "uuid").
+		Option("disable_events", true).
+		Option("logger_tls_perod", 10).
+		Config().Options)
+	require.NoError(t, err)
+	assert.Equal(t, []OptionChange{
+		{Name: "host_identifier", Current: "hostname", Default: "hostname", New: "uuid"},
+		{Name: "logger_tls_perod", New: "10", Unknown: true},
+		{Name: "verbose", Current: "false", Default: "false", New: "true"},
+	}, changes)
+	assert.Equal(t, "verbose: false -> true", changes[2].String())
+	assert.Equal(t, "logger_tls_perod: unknown option (set to 10)", changes[1].String())
+
+	// Options parsed from JSON
+	var c Config
+	require.NoError(t, c.UnmarshalJSON([]byte(`{"options": {"logger_tls_period": 4, "verbose": false}}`)))
+	changes, err = DiffOptions(context.Background(), client, c.Options)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = DiffOptions(context.Background(), optionsGetter{err: errors.New("broken pipe")}, Options{})
+	assert.EqualError(t, err, "getting osquery options: broken pipe")
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GeneratePackFunc returns the JSON of the pack referenced in a
+// configuration by name and value, where value is the string the pack name
+// maps to (usually a path, as in "packs": {"name": "/path/to/pack.conf"}).
+// osquery requests such packs from the config plugin with the genPack
+// action. See WithPackGenerator.
+type GeneratePackFunc func(ctx context.Context, name, value string) (string, error)
+
+// PackLoader loads the pack referenced by name and value. See InlinePacks.
+type PackLoader func(name, value string) (Pack, error)
+
+// WithPackGenerator makes the plugin answer genPack requests with fn.
+// Without it, genPack requests fail, as packs referenced by path can't be
+// read by osquery from an extension-served config. PackFileGenerator reads
+// the pack from the referenced file.
+func WithPackGenerator(fn GeneratePackFunc) Option {
+	return func(p *Plugin) {
+		p.generatePack = fn
+	}
+}
+
+// WithInlinePacks makes the plugin inline the packs referenced by path in
+// the generated configurations (see InlinePacks), so that they behave like
+// inline packs. If load is nil, packs are read from the referenced files
+// with LoadPackFile.
+func WithInlinePacks(load PackLoader) Option {
+	return func(p *Plugin) {
+		if load == nil {
+			load = loadPackFileRef
+		}
+		p.packLoader = load
+	}
+}
+
+// PackFileGenerator is a GeneratePackFunc reading the pack from the file at
+// the path it is referenced by.
+func PackFileGenerator(ctx context.Context, name, value string) (string, error) {
+	pack, err := LoadPackFile(value)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(pack)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// LoadPackFile reads a pack from a JSON file. Lines starting with // are
+// ignored, as in osquery pack files.
+func LoadPackFile(path string) (Pack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Pack{}, errors.Wrap(err, "reading pack")
+	}
+	var pack Pack
+	if err := json.Unmarshal(stripComments(data), &pack); err != nil {
+		return Pack{}, errors.Wrapf(err, "parsing pack %s", path)
+	}
+	return pack, nil
+}
+
+func loadPackFileRef(name, value string) (Pack, error) {
+	return LoadPackFile(value)
+}
+
+// stripComments removes the lines starting with //.
+func stripComments(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			kept = append(kept, line)
+		}
+	}
+	return []byte(strings.Join(kept, "\n"))
+}
+
+// InlinePacks replaces the packs referenced by a string in the JSON
+// configuration with the packs returned by load. A pack named "*" is a glob
+// of pack files, as supported by osquery: every matching file is loaded as
+// a pack named after the file without its extension. Other sections of the
+// configuration are kept as they are.
+func InlinePacks(configJSON string, load PackLoader) (string, error) {
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(configJSON), &sections); err != nil {
+		return "", errors.Wrap(err, "parsing config")
+	}
+	rawPacks, ok := sections["packs"]
+	if !ok {
+		return configJSON, nil
+	}
+	var packs map[string]json.RawMessage
+	if err := json.Unmarshal(rawPacks, &packs); err != nil {
+		return "", errors.Wrap(err, "parsing packs")
+	}
+
+	inlined := make(map[string]interface{}, len(packs))
+	for name, raw := range packs {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// Already inline
+			inlined[name] = raw
+			continue
+		}
+
+		if name == "*" {
+			paths, err := filepath.Glob(value)
+			if err != nil {
+				return "", errors.Wrapf(err, "pack glob %s", value)
+			}
+			for _, path := range paths {
+				base := filepath.Base(path)
+				packName := strings.TrimSuffix(base, filepath.Ext(base))
+				pack, err := load(packName, path)
+				if err != nil {
+					return "", errors.Wrapf(err, "pack %s", packName)
+				}
+				inlined[packName] = pack
+			}
+			continue
+		}
+
+		pack, err := load(name, value)
+		if err != nil {
+			return "", errors.Wrapf(err, "pack %s", name)
+		}
+		inlined[name] = pack
+	}
+
+	data, err := json.Marshal(inlined)
+	if err != nil {
+		return "", err
+	}
+	sections["packs"] = data
+	data, err = json.Marshal(sections)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
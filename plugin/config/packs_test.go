@@ -0,0 +1,89 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPackFile(t *testing.T) {
+	pack, err := LoadPackFile("testdata/hardware.conf")
+	require.NoError(t, err)
+	assert.Equal(t, Pack{
+		Platform: "darwin",
+		Queries:  map[string]Query{"usb": {Query: "SELECT * FROM usb_devices", Interval: 3600}},
+	}, pack)
+
+	_, err = LoadPackFile("testdata/missing.conf")
+	assert.Error(t, err)
+}
+
+func TestInlinePacks(t *testing.T) {
+	config := `{
+		"options": {"verbose": true},
+		"packs": {
+			"hardware": "testdata/hardware.conf",
+			"inline": {"queries": {"uptime": {"query": "SELECT * FROM uptime", "interval": 60}}}
+		}
+	}`
+	inlined, err := InlinePacks(config, loadPackFileRef)
+	require.NoError(t, err)
+
+	var parsed Config
+	require.NoError(t, json.Unmarshal([]byte(inlined), &parsed))
+	assert.Equal(t, Options{"verbose": true}, parsed.Options)
+	assert.Equal(t, "darwin", parsed.Packs["hardware"].Platform)
+	assert.Equal(t, "SELECT * FROM uptime", parsed.Packs["inline"].Queries["uptime"].Query)
+
+	// Globs load every matching pack
+	inlined, err = InlinePacks(`{"packs": {"*": "testdata/*.conf"}}`, loadPackFileRef)
+	require.NoError(t, err)
+	parsed = Config{}
+	require.NoError(t, json.Unmarshal([]byte(inlined), &parsed))
+	assert.Len(t, parsed.Packs, 2)
+	assert.True(t, parsed.Packs["network"].Queries["listening"].Snapshot)
+
+	// Configs without packs are unchanged
+	inlined, err = InlinePacks(`{"schedule": {}}`, loadPackFileRef)
+	require.NoError(t, err)
+	assert.Equal(t, `{"schedule": {}}`, inlined)
+
+	_, err = InlinePacks(`{"packs": {"missing": "testdata/missing.conf"}}`, loadPackFileRef)
+	assert.Error(t, err)
+	_, err = InlinePacks(`not json`, loadPackFileRef)
+	assert.Error(t, err)
+}
+
+func TestConfigPluginPacks(t *testing.T) {
+	plugin := NewPlugin("mock", func(context.Context) (map[string]string, error) {
+		return map[string]string{"main": `{"packs": {"hardware": "testdata/hardware.conf"}}`}, nil
+	}, WithInlinePacks(nil), WithPackGenerator(PackFileGenerator))
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+	require.Equal(t, &StatusOK, resp.Status)
+	var parsed Config
+	require.NoError(t, json.Unmarshal([]byte(resp.Response[0]["main"]), &parsed))
+	assert.Equal(t, "darwin", parsed.Packs["hardware"].Platform)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genPack", "name": "hardware", "value": "testdata/hardware.conf"})
+	require.Equal(t, &StatusOK, resp.Status)
+	var pack Pack
+	require.NoError(t, json.Unmarshal([]byte(resp.Response[0]["hardware"]), &pack))
+	assert.Equal(t, "darwin", pack.Platform)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genPack", "name": "missing", "value": "testdata/missing.conf"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+
+	// Without a pack generator
+	plugin = NewPlugin("mock", func(context.Context) (map[string]string, error) {
+		return nil, errors.New("unused")
+	})
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genPack", "name": "hardware", "value": "testdata/hardware.conf"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "packs are not supported by this config plugin", resp.Status.Message)
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+// Config is an osquery configuration. It marshals to the JSON returned by
+// GenerateConfigsFunc. See
+// https://osquery.readthedocs.io/en/stable/deployment/configuration/ for the
+// meaning of each section.
+type Config struct {
+	Options    Options             `json:"options,omitempty"`
+	Schedule   Schedule            `json:"schedule,omitempty"`
+	Packs      map[string]Pack     `json:"packs,omitempty"`
+	Decorators *Decorators         `json:"decorators,omitempty"`
+	FilePaths  map[string][]string `json:"file_paths,omitempty"`
+	// AutoTables are the tables osquery constructs from SQLite databases,
+	// keyed by table name.
+	AutoTables map[string]AutoTable `json:"auto_table_construction,omitempty"`
+	// Extra holds additional top level sections, such as "yara" or
+	// "events", that are marshaled as they are. When a Config is
+	// unmarshaled, they are json.RawMessage values.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// Options are osquery options, keyed by option name (without the leading
+// dashes), for example {"logger_tls_period": 10}.
+type Options map[string]interface{}
+
+// Schedule maps query names to scheduled queries.
+type Schedule map[string]Query
+
+// Query is a scheduled query, in the schedule or in a pack.
+type Query struct {
+	Query       string `json:"query"`
+	Interval    uint   `json:"interval"`
+	Platform    string `json:"platform,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+	Value       string `json:"value,omitempty"`
+	// Snapshot logs all the results of each run instead of differences.
+	Snapshot bool `json:"snapshot,omitempty"`
+	// Removed, if set to false, doesn't log removed rows.
+	Removed *bool `json:"removed,omitempty"`
+	// Shard restricts the query to a percentage of hosts.
+	Shard uint `json:"shard,omitempty"`
+	// Denylist, if set to false, never denylists the query.
+	Denylist *bool `json:"denylist,omitempty"`
+}
+
+// Pack is a query pack.
+type Pack struct {
+	// Discovery queries make the pack run only on hosts where they all
+	// return rows.
+	Discovery []string         `json:"discovery,omitempty"`
+	Platform  string           `json:"platform,omitempty"`
+	Version   string           `json:"version,omitempty"`
+	Shard     uint             `json:"shard,omitempty"`
+	Queries   map[string]Query `json:"queries"`
+}
+
+// AutoTable is an automatically constructed table (ATC): osquery exposes
+// the results of Query, run against the SQLite database at Path, as a
+// table with the given columns.
+type AutoTable struct {
+	Query string `json:"query"`
+	// Path is the path of the database. It may contain % wildcards, in
+	// which case the table has a path column.
+	Path string `json:"path"`
+	// Columns are the names of the columns of the table, in the order of
+	// the columns of the query results.
+	Columns  []string `json:"columns"`
+	Platform string   `json:"platform,omitempty"`
+}
+
+// Decorators are queries whose results are added to every log.
+type Decorators struct {
+	// Load queries run when the configuration is loaded.
+	Load []string `json:"load,omitempty"`
+	// Always queries run before each scheduled query.
+	Always []string `json:"always,omitempty"`
+	// Interval maps intervals in seconds to queries run at that interval.
+	Interval map[string][]string `json:"interval,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, adding the Extra sections.
+func (c Config) MarshalJSON() ([]byte, error) {
+	type config Config
+	data, err := json.Marshal(config(c))
+	if err != nil || len(c.Extra) == 0 {
+		return data, err
+	}
+
+	sections := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, err
+	}
+	for k, v := range c.Extra {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		sections[k] = raw
+	}
+	return json.Marshal(sections)
+}
+
+// configSections are the sections of Config other than Extra.
+var configSections = map[string]bool{
+	"options":                 true,
+	"schedule":                true,
+	"packs":                   true,
+	"decorators":              true,
+	"file_paths":              true,
+	"auto_table_construction": true,
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown sections in
+// Extra.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type config Config
+	var parsed config
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return err
+	}
+	for k, v := range sections {
+		if configSections[k] {
+			continue
+		}
+		if parsed.Extra == nil {
+			parsed.Extra = map[string]interface{}{}
+		}
+		parsed.Extra[k] = v
+	}
+	*c = Config(parsed)
+	return nil
+}
+
+// JSON returns the configuration as JSON.
+func (c Config) JSON() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Static returns a GenerateConfigsFunc that always returns the configuration
+// under the given source name.
+func Static(source string, c Config) GenerateConfigsFunc {
+	return func(ctx context.Context) (map[string]string, error) {
+		data, err := c.JSON()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{source: data}, nil
+	}
+}
+
+// Builder builds a Config. Its methods return the builder so that calls can
+// be chained:
+//
+//	cfg := config.NewBuilder().
+//		Option("logger_tls_period", 10).
+//		Query("uptime", config.Query{Query: "SELECT * FROM uptime", Interval: 60}).
+//		Config()
+type Builder struct {
+	config Config
+}
+
+// NewBuilder returns a builder of an empty Config.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Option sets an osquery option.
+func (b *Builder) Option(name string, value interface{}) *Builder {
+	if b.config.Options == nil {
+		b.config.Options = Options{}
+	}
+	b.config.Options[name] = value
+	return b
+}
+
+// Query adds a query to the schedule.
+func (b *Builder) Query(name string, query Query) *Builder {
+	if b.config.Schedule == nil {
+		b.config.Schedule = Schedule{}
+	}
+	b.config.Schedule[name] = query
+	return b
+}
+
+// Pack adds a query pack.
+func (b *Builder) Pack(name string, pack Pack) *Builder {
+	if b.config.Packs == nil {
+		b.config.Packs = map[string]Pack{}
+	}
+	b.config.Packs[name] = pack
+	return b
+}
+
+// Decorators sets the decorator queries.
+func (b *Builder) Decorators(decorators Decorators) *Builder {
+	b.config.Decorators = &decorators
+	return b
+}
+
+// DecoratorLoad adds decorator queries run when the configuration is
+// loaded.
+func (b *Builder) DecoratorLoad(queries ...string) *Builder {
+	d := b.decorators()
+	d.Load = append(d.Load, queries...)
+	return b
+}
+
+// DecoratorAlways adds decorator queries run before each scheduled query.
+func (b *Builder) DecoratorAlways(queries ...string) *Builder {
+	d := b.decorators()
+	d.Always = append(d.Always, queries...)
+	return b
+}
+
+// DecoratorInterval adds decorator queries run every interval seconds,
+// which osquery requires to be a multiple of 60.
+func (b *Builder) DecoratorInterval(interval uint, queries ...string) *Builder {
+	d := b.decorators()
+	if d.Interval == nil {
+		d.Interval = map[string][]string{}
+	}
+	key := strconv.FormatUint(uint64(interval), 10)
+	d.Interval[key] = append(d.Interval[key], queries...)
+	return b
+}
+
+func (b *Builder) decorators() *Decorators {
+	if b.config.Decorators == nil {
+		b.config.Decorators = &Decorators{}
+	}
+	return b.config.Decorators
+}
+
+// FilePaths adds a category of monitored file paths.
+func (b *Builder) FilePaths(category string, paths ...string) *Builder {
+	if b.config.FilePaths == nil {
+		b.config.FilePaths = map[string][]string{}
+	}
+	b.config.FilePaths[category] = append(b.config.FilePaths[category], paths...)
+	return b
+}
+
+// AutoTable adds an automatically constructed table.
+func (b *Builder) AutoTable(name string, table AutoTable) *Builder {
+	if b.config.AutoTables == nil {
+		b.config.AutoTables = map[string]AutoTable{}
+	}
+	b.config.AutoTables[name] = table
+	return b
+}
+
+// Section sets an additional top level section. See Config.Extra.
+func (b *Builder) Section(name string, value interface{}) *Builder {
+	if b.config.Extra == nil {
+		b.config.Extra = map[string]interface{}{}
+	}
+	b.config.Extra[name] = value
+	return b
+}
+
+// Config returns the built configuration.
+func (b *Builder) Config() Config {
+	return b.config
+}
+
+// JSON returns the built configuration as JSON.
+func (b *Builder) JSON() (string, error) {
+	return b.config.JSON()
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	removed := false
+	b := NewBuilder().
+		Option("logger_tls_period", 10).
+		Option("host_identifier", "uuid").
+		Query("uptime", Query{Query: "SELECT * FROM uptime", Interval: 60, Removed: &removed}).
+		Pack("hardware", Pack{
+			Discovery: []string{"SELECT 1 FROM os_version WHERE platform = 'darwin'"},
+			Queries:   map[string]Query{"usb": {Query: "SELECT * FROM usb_devices", Interval: 3600, Snapshot: true}},
+		}).
+		Decorators(Decorators{Load: []string{"SELECT uuid FROM system_info"}}).
+		FilePaths("etc", "/etc/%%").
+		Section("events", map[string][]string{"disable_subscribers": {"user_events"}})
+
+	data, err := b.JSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"options": {"logger_tls_period": 10, "host_identifier": "uuid"},
+		"schedule": {"uptime": {"query": "SELECT * FROM uptime", "interval": 60, "removed": false}},
+		"packs": {"hardware": {
+			"discovery": ["SELECT 1 FROM os_version WHERE platform = 'darwin'"],
+			"queries": {"usb": {"query": "SELECT * FROM usb_devices", "interval": 3600, "snapshot": true}}
+		}},
+		"decorators": {"load": ["SELECT uuid FROM system_info"]},
+		"file_paths": {"etc": ["/etc/%%"]},
+		"events": {"disable_subscribers": ["user_events"]}
+	}`, data)
+
+	// Configs round trip
+	var parsed Config
+	require.NoError(t, json.Unmarshal([]byte(data), &parsed))
+	assert.Equal(t, "SELECT * FROM usb_devices", parsed.Packs["hardware"].Queries["usb"].Query)
+	assert.Contains(t, parsed.Extra, "events")
+	roundTrip, err := parsed.JSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, data, roundTrip)
+
+	data, err = NewBuilder().JSON()
+	require.NoError(t, err)
+	assert.Equal(t, "{}", data)
+}
+
+func TestStatic(t *testing.T) {
+	generate := Static("main", NewBuilder().Option("verbose", true).Config())
+	configs, err := generate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"main": `{"options":{"verbose":true}}`}, configs)
+
+	generate = Static("main", NewBuilder().Section("bad", func() {}).Config())
+	_, err = generate(context.Background())
+	assert.Error(t, err)
+}
+
+func TestBuilderAutoTable(t *testing.T) {
+	cfg := NewBuilder().
+		AutoTable("tcc_entries", AutoTable{
+			Query:    "SELECT service, client FROM access",
+			Path:     "/Users/%/Library/Application Support/com.apple.TCC/TCC.db",
+			Columns:  []string{"service", "client"},
+			Platform: "darwin",
+		}).
+		Config()
+
+	data, err := cfg.JSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"auto_table_construction": {
+			"tcc_entries": {
+				"query": "SELECT service, client FROM access",
+				"path": "/Users/%/Library/Application Support/com.apple.TCC/TCC.db",
+				"columns": ["service", "client"],
+				"platform": "darwin"
+			}
+		}
+	}`, data)
+
+	var parsed Config
+	require.NoError(t, json.Unmarshal([]byte(data), &parsed))
+	assert.Equal(t, cfg.AutoTables, parsed.AutoTables)
+	assert.Empty(t, parsed.Extra)
+
+	assert.NoError(t, ValidateConfig(cfg))
+	assert.Error(t, ValidateConfig(NewBuilder().AutoTable("t", AutoTable{Query: "SELECT 1", Path: "/db"}).Config()))
+}
+
+func TestBuilderDecorators(t *testing.T) {
+	cfg := NewBuilder().
+		DecoratorLoad("SELECT uuid AS host_uuid FROM system_info").
+		DecoratorAlways("SELECT user AS username FROM logged_in_users LIMIT 1").
+		DecoratorInterval(3600, "SELECT total_seconds AS uptime FROM uptime").
+		DecoratorLoad("SELECT hostname FROM system_info").
+		Config()
+
+	assert.Equal(t, &Decorators{
+		Load:     []string{"SELECT uuid AS host_uuid FROM system_info", "SELECT hostname FROM system_info"},
+		Always:   []string{"SELECT user AS username FROM logged_in_users LIMIT 1"},
+		Interval: map[string][]string{"3600": {"SELECT total_seconds AS uptime FROM uptime"}},
+	}, cfg.Decorators)
+	assert.NoError(t, ValidateConfig(cfg))
+
+	cfg = NewBuilder().DecoratorInterval(90, "SELECT 1").Config()
+	assert.EqualError(t, ValidateConfig(cfg), `decorators: interval "90" is not a multiple of 60 seconds`)
+}
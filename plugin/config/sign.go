@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// SignedBundle is a configuration signed with Ed25519, as served by the
+// sources wrapped with Signed. The signature covers the compacted
+// configuration JSON, so that bundles can be reformatted without breaking
+// it.
+type SignedBundle struct {
+	Config json.RawMessage `json:"config"`
+	// KeyID identifies the signing key, so that keys can be rotated.
+	KeyID     string `json:"key_id"`
+	Signature []byte `json:"signature"`
+}
+
+// SignBundle signs a configuration, returning the JSON of the SignedBundle.
+func SignBundle(configJSON, keyID string, key ed25519.PrivateKey) (string, error) {
+	config, err := compactJSON(configJSON)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(SignedBundle{
+		Config:    config,
+		KeyID:     keyID,
+		Signature: ed25519.Sign(key, config),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// OpenBundle verifies the signature of a SignedBundle with the public key
+// of its KeyID, and returns the configuration it holds.
+func OpenBundle(bundleJSON string, keys map[string]ed25519.PublicKey) (string, error) {
+	var bundle SignedBundle
+	if err := json.Unmarshal([]byte(bundleJSON), &bundle); err != nil {
+		return "", errors.Wrap(err, "parsing signed config")
+	}
+	if len(bundle.Config) == 0 || len(bundle.Signature) == 0 {
+		return "", errors.New("config is not signed")
+	}
+	key, ok := keys[bundle.KeyID]
+	if !ok {
+		return "", errors.Errorf("unknown signing key %q", bundle.KeyID)
+	}
+	config, err := compactJSON(string(bundle.Config))
+	if err != nil {
+		return "", err
+	}
+	if !ed25519.Verify(key, config, bundle.Signature) {
+		return "", errors.Errorf("invalid config signature for key %q", bundle.KeyID)
+	}
+	return string(config), nil
+}
+
+// Signed returns a GenerateConfigsFunc serving the configurations of fn as
+// signed bundles, for extensions distributing configurations to other
+// extensions, through object storage or HTTPS for example.
+func Signed(fn GenerateConfigsFunc, keyID string, key ed25519.PrivateKey) GenerateConfigsFunc {
+	return func(ctx context.Context) (map[string]string, error) {
+		configs, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		signed := make(map[string]string, len(configs))
+		for source, config := range configs {
+			if signed[source], err = SignBundle(config, keyID, key); err != nil {
+				return nil, errors.Wrapf(err, "signing source %s", source)
+			}
+		}
+		return signed, nil
+	}
+}
+
+// WithSignedConfigs makes the plugin expect its configurations to be signed
+// bundles (see Signed): their signatures are verified with keys, by key ID,
+// and only the verified configurations are returned to osquery. genConfig
+// requests fail if any signature is missing or invalid, so that osquery
+// keeps its current configuration. Signatures are verified before packs are
+// inlined and configurations are validated.
+func WithSignedConfigs(keys map[string]ed25519.PublicKey) Option {
+	return func(p *Plugin) {
+		p.signingKeys = keys
+	}
+}
+
+// openBundles returns the verified configurations of the signed bundles.
+func (t *Plugin) openBundles(configs map[string]string) (map[string]string, error) {
+	opened := make(map[string]string, len(configs))
+	for source, bundle := range configs {
+		config, err := OpenBundle(bundle, t.signingKeys)
+		if err != nil {
+			return nil, errors.Wrapf(err, "source %s", source)
+		}
+		opened[source] = config
+	}
+	return opened, nil
+}
+
+func compactJSON(data string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(data)); err != nil {
+		return nil, errors.Wrap(err, "parsing config")
+	}
+	return buf.Bytes(), nil
+}
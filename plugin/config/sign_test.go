@@ -0,0 +1,75 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignBundle(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	keys := map[string]ed25519.PublicKey{"2024": pub}
+
+	bundle, err := SignBundle(`{"options": {"verbose": true}}`, "2024", priv)
+	require.NoError(t, err)
+
+	config, err := OpenBundle(bundle, keys)
+	require.NoError(t, err)
+	assert.Equal(t, `{"options":{"verbose":true}}`, config)
+
+	// Reformatting keeps the signature valid
+	var parsed SignedBundle
+	require.NoError(t, json.Unmarshal([]byte(bundle), &parsed))
+	parsed.Config = json.RawMessage(`{ "options": { "verbose": true } }`)
+	reformatted, err := json.MarshalIndent(parsed, "", "  ")
+	require.NoError(t, err)
+	_, err = OpenBundle(string(reformatted), keys)
+	assert.NoError(t, err)
+
+	// Tampering doesn't
+	parsed.Config = json.RawMessage(`{"options": {"verbose": false}}`)
+	tampered, err := json.Marshal(parsed)
+	require.NoError(t, err)
+	_, err = OpenBundle(string(tampered), keys)
+	assert.EqualError(t, err, `invalid config signature for key "2024"`)
+
+	_, err = OpenBundle(bundle, map[string]ed25519.PublicKey{"2024": otherPub})
+	assert.Error(t, err)
+	_, err = OpenBundle(bundle, map[string]ed25519.PublicKey{"2025": pub})
+	assert.EqualError(t, err, `unknown signing key "2024"`)
+	_, err = OpenBundle(`{"options": {}}`, keys)
+	assert.EqualError(t, err, "config is not signed")
+	_, err = SignBundle(`{`, "2024", priv)
+	assert.Error(t, err)
+}
+
+func TestConfigPluginSignedConfigs(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	configs := map[string]string{"main": `{"options": {"verbose": true}}`}
+	source := Signed(func(context.Context) (map[string]string, error) {
+		return configs, nil
+	}, "main-key", priv)
+
+	plugin := NewPlugin("signed", source, WithSignedConfigs(map[string]ed25519.PublicKey{"main-key": pub}))
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"main": `{"options":{"verbose":true}}`}}, resp.Response)
+
+	// Unsigned configs are rejected
+	plugin = NewPlugin("signed", func(context.Context) (map[string]string, error) {
+		return configs, nil
+	}, WithSignedConfigs(map[string]ed25519.PublicKey{"main-key": pub}))
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error getting config: source main: config is not signed", resp.Status.Message)
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// UpdateFunc is called with the configuration updates pushed to the plugin.
+// osquery config plugins receive these as requests with the update action,
+// carrying the source name and the config JSON, for example when a config is
+// pushed through the extension manager rather than pulled with genConfig.
+// Returning an error rejects the update, and the error is reported to the
+// caller.
+type UpdateFunc func(ctx context.Context, source, data string) error
+
+// ActionFunc handles a request with an action not otherwise supported by the
+// config plugin. The request holds the action and its parameters.
+type ActionFunc func(ctx context.Context, request osquery.ExtensionPluginRequest) (osquery.ExtensionPluginResponse, error)
+
+// WithUpdateFunc makes the plugin answer update requests with fn. Without
+// it, update requests fail as unknown actions.
+func WithUpdateFunc(fn UpdateFunc) Option {
+	return func(p *Plugin) {
+		p.update = fn
+	}
+}
+
+// WithAction makes the plugin answer requests with the given action with
+// fn, so that extensions can handle actions added by newer osquery versions
+// or sent by their own tooling. The genConfig, genPack and update actions
+// can't be overridden.
+func WithAction(action string, fn ActionFunc) Option {
+	return func(p *Plugin) {
+		if p.actions == nil {
+			p.actions = map[string]ActionFunc{}
+		}
+		p.actions[action] = fn
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigPluginUpdate(t *testing.T) {
+	var updates []string
+	plugin := NewPlugin("mock", func(context.Context) (map[string]string, error) {
+		return nil, nil
+	}, WithUpdateFunc(func(ctx context.Context, source, data string) error {
+		if data == "bad" {
+			return errors.New("invalid config")
+		}
+		updates = append(updates, source+"="+data)
+		return nil
+	}))
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "update", "source": "main", "data": "{}"})
+	assert.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, []string{"main={}"}, updates)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "update", "source": "main", "data": "bad"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error updating config: invalid config", resp.Status.Message)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "update", "source": "main"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Len(t, updates, 1)
+
+	// Without an update function
+	plugin = NewPlugin("mock", func(context.Context) (map[string]string, error) {
+		return nil, nil
+	})
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "update", "source": "main", "data": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "unknown action: update", resp.Status.Message)
+}
+
+func TestConfigPluginAction(t *testing.T) {
+	plugin := NewPlugin("mock", func(context.Context) (map[string]string, error) {
+		return map[string]string{"main": "{}"}, nil
+	}, WithAction("optionUpdate", func(ctx context.Context, request osquery.ExtensionPluginRequest) (osquery.ExtensionPluginResponse, error) {
+		if request["name"] == "" {
+			return nil, errors.New("missing name")
+		}
+		return osquery.ExtensionPluginResponse{{"name": request["name"]}}, nil
+	}), WithAction("genConfig", func(ctx context.Context, request osquery.ExtensionPluginRequest) (osquery.ExtensionPluginResponse, error) {
+		return nil, errors.New("overridden")
+	}))
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "optionUpdate", "name": "verbose"})
+	assert.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "verbose"}}, resp.Response)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "optionUpdate"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error handling optionUpdate: missing name", resp.Status.Message)
+
+	// Built in actions can't be overridden
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+	assert.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"main": "{}"}}, resp.Response)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "other"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+}
@@ -0,0 +1,321 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// FindingSeverity is the severity of a validation Finding.
+type FindingSeverity int
+
+const (
+	// FindingError is a problem that makes osquery reject the
+	// configuration, or ignore part of it.
+	FindingError FindingSeverity = iota
+	// FindingWarning is a likely mistake.
+	FindingWarning
+)
+
+func (s FindingSeverity) String() string {
+	switch s {
+	case FindingError:
+		return "error"
+	case FindingWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("FindingSeverity(%d)", int(s))
+	}
+}
+
+// Finding is a problem found in a configuration by a Validator.
+type Finding struct {
+	Severity FindingSeverity
+	// Source is the source of the configuration.
+	Source string
+	// Path locates the problem in the configuration, as the keys leading
+	// to it joined with dots, for example "packs.hardware.queries.usb".
+	// It is empty for problems with the whole configuration.
+	Path    string
+	Message string
+}
+
+func (f Finding) String() string {
+	location := f.Source
+	if f.Path != "" {
+		location += ": " + f.Path
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Severity, location, f.Message)
+}
+
+// Findings are the problems found in configurations.
+type Findings []Finding
+
+// Err returns a *ValidationError holding the findings if any of them is an
+// error, and nil otherwise.
+func (f Findings) Err() error {
+	for _, finding := range f {
+		if finding.Severity == FindingError {
+			return &ValidationError{Findings: f}
+		}
+	}
+	return nil
+}
+
+// ValidationError is returned for configurations with error findings.
+type ValidationError struct {
+	Findings Findings
+}
+
+func (e *ValidationError) Error() string {
+	var errs []string
+	for _, f := range e.Findings {
+		if f.Severity == FindingError {
+			errs = append(errs, f.String())
+		}
+	}
+	return "invalid config: " + strings.Join(errs, "; ")
+}
+
+// QueryColumnsGetter parses queries with osquery. It is implemented by
+// *osquery.ExtensionManagerClient.
+type QueryColumnsGetter interface {
+	GetQueryColumnsContext(ctx context.Context, sql string) (*osquery.ExtensionResponse, error)
+}
+
+// QueryRowsRunner runs queries with osquery. It is implemented by
+// *osquery.ExtensionManagerClient.
+type QueryRowsRunner interface {
+	QueryRowsContext(ctx context.Context, sql string) ([]map[string]string, error)
+}
+
+// Validator checks configurations for:
+//   - invalid JSON;
+//   - duplicate keys, such as two scheduled queries with the same name,
+//     of which osquery only keeps one;
+//   - scheduled queries colliding with the queries of packs, which osquery
+//     schedules as "pack_<pack>_<query>";
+//   - queries without SQL or interval;
+//   - decorator intervals that aren't multiples of 60 seconds;
+//   - automatic tables without query, path or columns;
+//   - queries osquery can't parse, if Client is set;
+//   - decorator queries returning more than one row, of which osquery only
+//     uses the first, if Querier is set.
+type Validator struct {
+	// Client, if set, is used to check that osquery can parse each query,
+	// with GetQueryColumns.
+	Client QueryColumnsGetter
+	// Querier, if set, is used to run the decorator queries and check that
+	// they return a single row.
+	Querier QueryRowsRunner
+	// OnFindings, if set, is called by config plugins with the findings of
+	// each generated configuration, including warnings.
+	OnFindings func(source string, findings Findings)
+}
+
+// WithValidator makes the plugin validate the configurations it generates.
+// genConfig requests fail if any configuration has error findings, so that
+// osquery keeps its current configuration.
+func WithValidator(v *Validator) Option {
+	return func(p *Plugin) {
+		p.validator = v
+	}
+}
+
+// Validate returns the problems found in the configuration from source.
+func (v *Validator) Validate(ctx context.Context, source, configJSON string) Findings {
+	var findings Findings
+	add := func(severity FindingSeverity, path, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Severity: severity,
+			Source:   source,
+			Path:     path,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	var c Config
+	if err := json.Unmarshal([]byte(configJSON), &c); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			add(FindingError, "", "invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr)
+		} else {
+			add(FindingError, "", "invalid config: %s", err)
+		}
+		return findings
+	}
+
+	for _, path := range duplicateKeys(configJSON) {
+		add(FindingError, path, "duplicate key, only the last value is used")
+	}
+
+	// Names of the queries in osquery's schedule
+	scheduled := map[string]string{}
+	for _, name := range sortedKeys(c.Schedule) {
+		scheduled[name] = "schedule." + name
+	}
+	for _, packName := range sortedKeys(c.Packs) {
+		for _, name := range sortedKeys(c.Packs[packName].Queries) {
+			path := "packs." + packName + ".queries." + name
+			scheduledName := "pack_" + packName + "_" + name
+			if other, ok := scheduled[scheduledName]; ok {
+				add(FindingError, path, "scheduled as %s, which conflicts with %s", scheduledName, other)
+				continue
+			}
+			scheduled[scheduledName] = path
+		}
+	}
+
+	for _, name := range sortedKeys(c.Schedule) {
+		v.validateQuery(ctx, "schedule."+name, c.Schedule[name], add)
+	}
+	for _, packName := range sortedKeys(c.Packs) {
+		pack := c.Packs[packName]
+		for _, name := range sortedKeys(pack.Queries) {
+			v.validateQuery(ctx, "packs."+packName+".queries."+name, pack.Queries[name], add)
+		}
+	}
+	if c.Decorators != nil {
+		v.validateDecorators(ctx, *c.Decorators, add)
+	}
+	for _, name := range sortedKeys(c.AutoTables) {
+		if err := validateAutoTable(c.AutoTables[name]); err != nil {
+			add(FindingError, "auto_table_construction."+name, "%s", err)
+		}
+	}
+	return findings
+}
+
+func (v *Validator) validateQuery(ctx context.Context, path string, query Query, add func(FindingSeverity, string, string, ...interface{})) {
+	if err := validateQuery(query); err != nil {
+		add(FindingError, path, "%s", err)
+	}
+	if v.Client == nil || strings.TrimSpace(query.Query) == "" {
+		return
+	}
+	resp, err := v.Client.GetQueryColumnsContext(ctx, query.Query)
+	if err != nil {
+		add(FindingWarning, path, "checking query: %s", err)
+		return
+	}
+	if resp.Status != nil && resp.Status.Code != 0 {
+		add(FindingError, path, "invalid query: %s", resp.Status.Message)
+	}
+}
+
+func (v *Validator) validateDecorators(ctx context.Context, d Decorators, add func(FindingSeverity, string, string, ...interface{})) {
+	validate := func(path string, queries []string) {
+		for i, sql := range queries {
+			v.validateDecorator(ctx, fmt.Sprintf("%s[%d]", path, i), sql, add)
+		}
+	}
+	validate("decorators.load", d.Load)
+	validate("decorators.always", d.Always)
+	for _, interval := range sortedKeys(d.Interval) {
+		path := "decorators.interval." + interval
+		if err := validateDecoratorInterval(interval); err != nil {
+			add(FindingError, path, "%s", err)
+		}
+		validate(path, d.Interval[interval])
+	}
+}
+
+func (v *Validator) validateDecorator(ctx context.Context, path, sql string, add func(FindingSeverity, string, string, ...interface{})) {
+	if strings.TrimSpace(sql) == "" {
+		add(FindingError, path, "missing query")
+		return
+	}
+	if v.Client != nil {
+		resp, err := v.Client.GetQueryColumnsContext(ctx, sql)
+		if err != nil {
+			add(FindingWarning, path, "checking query: %s", err)
+		} else if resp.Status != nil && resp.Status.Code != 0 {
+			add(FindingError, path, "invalid query: %s", resp.Status.Message)
+			return
+		}
+	}
+	if v.Querier != nil {
+		rows, err := v.Querier.QueryRowsContext(ctx, sql)
+		if err != nil {
+			add(FindingWarning, path, "running query: %s", err)
+		} else if len(rows) > 1 {
+			add(FindingWarning, path, "returns %d rows, only the first one decorates the logs", len(rows))
+		}
+	}
+}
+
+// validate validates the generated configurations.
+func (v *Validator) validate(ctx context.Context, configs map[string]string) error {
+	var all Findings
+	for _, source := range sortedKeys(configs) {
+		findings := v.Validate(ctx, source, configs[source])
+		if v.OnFindings != nil && len(findings) > 0 {
+			v.OnFindings(source, findings)
+		}
+		all = append(all, findings...)
+	}
+	return all.Err()
+}
+
+// duplicateKeys returns the paths of the duplicate keys of the objects of a
+// JSON document.
+func duplicateKeys(doc string) []string {
+	var paths []string
+	dec := json.NewDecoder(strings.NewReader(doc))
+	var walk func(path string) error
+	walk = func(path string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			seen := map[string]bool{}
+			for dec.More() {
+				tok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := tok.(string)
+				keyPath := key
+				if path != "" {
+					keyPath = path + "." + key
+				}
+				if seen[key] {
+					paths = append(paths, keyPath)
+				}
+				seen[key] = true
+				if err := walk(keyPath); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	if err := walk(""); err != nil && err != io.EOF {
+		return nil
+	}
+	return paths
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parser is a QueryColumnsGetter rejecting queries that don't start with
+// SELECT.
+type parser struct {
+	err error
+}
+
+func (p parser) GetQueryColumnsContext(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if !strings.HasPrefix(sql, "SELECT") {
+		return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 1, Message: "near \"SELEC\": syntax error"}}, nil
+	}
+	return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 0, Message: "OK"}}, nil
+}
+
+func TestValidatorValidate(t *testing.T) {
+	v := &Validator{Client: parser{}}
+	findings := v.Validate(context.Background(), "main", `{
+		"schedule": {
+			"uptime": {"query": "SELECT * FROM uptime", "interval": 60},
+			"uptime": {"query": "SELECT * FROM uptime", "interval": 120},
+			"pack_hw_usb": {"query": "SELECT * FROM usb_devices", "interval": 60},
+			"typo": {"query": "SELEC * FROM users", "interval": 60},
+			"nointerval": {"query": "SELECT 1"}
+		},
+		"packs": {
+			"hw": {"queries": {"usb": {"query": "SELECT * FROM usb_devices", "interval": 60}}}
+		}
+	}`)
+	assert.Equal(t, Findings{
+		{Severity: FindingError, Source: "main", Path: "schedule.uptime", Message: "duplicate key, only the last value is used"},
+		{Severity: FindingError, Source: "main", Path: "packs.hw.queries.usb", Message: "scheduled as pack_hw_usb, which conflicts with schedule.pack_hw_usb"},
+		{Severity: FindingError, Source: "main", Path: "schedule.nointerval", Message: "missing interval"},
+		{Severity: FindingError, Source: "main", Path: "schedule.typo", Message: "invalid query: near \"SELEC\": syntax error"},
+	}, findings)
+
+	findings = v.Validate(context.Background(), "main", `{"schedule": {`)
+	require.Len(t, findings, 1)
+	assert.Contains(t, findings[0].Message, "invalid JSON at offset")
+
+	// Failures to check queries are warnings
+	v = &Validator{Client: parser{err: errors.New("connection refused")}}
+	findings = v.Validate(context.Background(), "main", `{"schedule": {"uptime": {"query": "SELECT * FROM uptime", "interval": 60}}}`)
+	require.Len(t, findings, 1)
+	assert.Equal(t, FindingWarning, findings[0].Severity)
+	assert.NoError(t, findings.Err())
+	assert.Equal(t, "warning: main: schedule.uptime: checking query: connection refused", findings[0].String())
+}
+
+func TestConfigPluginValidator(t *testing.T) {
+	configs := map[string]string{"main": `{"schedule": {"uptime": {"query": "SELECT * FROM uptime", "interval": 60}}}`}
+	reported := map[string]Findings{}
+	plugin := NewPlugin("mock", func(context.Context) (map[string]string, error) {
+		return configs, nil
+	}, WithValidator(&Validator{
+		Client: parser{},
+		OnFindings: func(source string, findings Findings) {
+			reported[source] = findings
+		},
+	}))
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+	assert.Equal(t, &StatusOK, resp.Status)
+	assert.Empty(t, reported)
+
+	configs["extra"] = `{"schedule": {"typo": {"query": "SELEC 1", "interval": 60}}}`
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, `error getting config: invalid config: error: extra: schedule.typo: invalid query: near "SELEC": syntax error`, resp.Status.Message)
+	assert.Len(t, reported["extra"], 1)
+}
+
+// rowsRunner is a QueryRowsRunner returning rows by query.
+type rowsRunner map[string][]map[string]string
+
+func (r rowsRunner) QueryRowsContext(ctx context.Context, sql string) ([]map[string]string, error) {
+	rows, ok := r[sql]
+	if !ok {
+		return nil, errors.New("no such table")
+	}
+	return rows, nil
+}
+
+func TestValidatorDecorators(t *testing.T) {
+	v := &Validator{
+		Client: parser{},
+		Querier: rowsRunner{
+			"SELECT uuid FROM system_info": {{"uuid": "1"}},
+			"SELECT user FROM users":       {{"user": "root"}, {"user": "alice"}},
+		},
+	}
+	cfg, err := NewBuilder().
+		DecoratorLoad("SELECT uuid FROM system_info", "SELEC 1").
+		DecoratorAlways("SELECT user FROM users").
+		DecoratorInterval(90, "SELECT missing FROM nowhere").
+		JSON()
+	require.NoError(t, err)
+
+	assert.Equal(t, Findings{
+		{Severity: FindingError, Source: "main", Path: "decorators.load[1]", Message: "invalid query: near \"SELEC\": syntax error"},
+		{Severity: FindingWarning, Source: "main", Path: "decorators.always[0]", Message: "returns 2 rows, only the first one decorates the logs"},
+		{Severity: FindingError, Source: "main", Path: "decorators.interval.90", Message: "interval \"90\" is not a multiple of 60 seconds"},
+		{Severity: FindingWarning, Source: "main", Path: "decorators.interval.90[0]", Message: "running query: no such table"},
+	}, v.Validate(context.Background(), "main", cfg))
+}
@@ -0,0 +1,287 @@
+package distributed
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CarveQuery returns a distributed query carving the files matching the
+// given path patterns (in SQL LIKE syntax, such as "/tmp/%.log"). osquery
+// uploads the carved files, as a tar archive, to its carver_start_endpoint
+// and carver_continue_endpoint (see CarveReceiver), which requires
+// the carver_disable_function flag to be false.
+func CarveQuery(paths ...string) string {
+	conditions := make([]string, 0, len(paths))
+	for _, path := range paths {
+		conditions = append(conditions, "path LIKE '"+strings.ReplaceAll(path, "'", "''")+"'")
+	}
+	return "SELECT * FROM carves WHERE carve = 1 AND (" + strings.Join(conditions, " OR ") + ")"
+}
+
+// CarveResult is a row of the carves table, as returned by the carve
+// queries.
+type CarveResult struct {
+	Time      int64
+	SHA256    string
+	Size      int64
+	Path      string
+	Status    string
+	CarveGUID string
+	RequestID string
+}
+
+// Carves returns the carves of the results of a carve query. Rows without
+// a carve_guid are skipped.
+func (r Result) Carves() ([]CarveResult, error) {
+	var carves []CarveResult
+	for _, row := range r.Rows {
+		if row["carve_guid"] == "" {
+			continue
+		}
+		carve := CarveResult{
+			SHA256:    row["sha256"],
+			Path:      row["path"],
+			Status:    row["status"],
+			CarveGUID: row["carve_guid"],
+			RequestID: row["request_id"],
+		}
+		var err error
+		if carve.Time, err = parseCarveInt(row["time"]); err != nil {
+			return nil, errors.Wrapf(err, "carve %s time", carve.CarveGUID)
+		}
+		if carve.Size, err = parseCarveInt(row["size"]); err != nil {
+			return nil, errors.Wrapf(err, "carve %s size", carve.CarveGUID)
+		}
+		carves = append(carves, carve)
+	}
+	return carves, nil
+}
+
+func parseCarveInt(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// CarvedFile is a carve received by a CarveReceiver.
+type CarvedFile struct {
+	// CarveID is the carve_guid of the carve in the results of the carve
+	// query.
+	CarveID   string
+	RequestID string
+	// NodeKey is the node key of the host sending the carve.
+	NodeKey string
+	// Path is the path of a temporary file holding the carve, a tar
+	// archive (compressed with zstd if the carver_compression flag is
+	// set). It is removed once the OnCarve function returns.
+	Path string
+	Size int64
+}
+
+// CarveReceiverConfig configures a CarveReceiver.
+type CarveReceiverConfig struct {
+	// OnCarve is called with each complete carve.
+	OnCarve func(ctx context.Context, carve CarvedFile) error
+	// Dir is the directory where the carves are assembled. Empty means
+	// the default temporary directory.
+	Dir string
+	// MaxSize is the maximum size of a carve. Zero means no limit.
+	MaxSize int64
+	// Authorize, if set, is called with the node key of the hosts
+	// starting carves, to reject unknown hosts.
+	Authorize func(ctx context.Context, nodeKey string) error
+	// SessionTimeout is how long an incomplete carve is kept after its
+	// last block. Zero means 1 hour.
+	SessionTimeout time.Duration
+}
+
+// CarveReceiver receives the carves uploaded by osquery, implementing the
+// carver start and continue endpoints. It is an http.Handler to serve at
+// the URLs of the carver_start_endpoint and carver_continue_endpoint flags,
+// which can be the same URL.
+type CarveReceiver struct {
+	config CarveReceiverConfig
+	now    func() time.Time
+
+	mutex    sync.Mutex
+	sessions map[string]*carveSession
+}
+
+// carveSession is a carve being received.
+type carveSession struct {
+	carve      CarvedFile
+	file       *os.File
+	blockSize  int64
+	blockCount int64
+	received   map[int64]bool
+	lastBlock  time.Time
+}
+
+// NewCarveReceiver creates a CarveReceiver.
+func NewCarveReceiver(config CarveReceiverConfig) (*CarveReceiver, error) {
+	if config.OnCarve == nil {
+		return nil, errors.New("carve receiver requires an OnCarve function")
+	}
+	if config.SessionTimeout <= 0 {
+		config.SessionTimeout = time.Hour
+	}
+	return &CarveReceiver{config: config, now: time.Now, sessions: map[string]*carveSession{}}, nil
+}
+
+// carveRequest holds the fields of the start and continue requests.
+type carveRequest struct {
+	// Start requests
+	BlockCount int64  `json:"block_count"`
+	BlockSize  int64  `json:"block_size"`
+	CarveSize  int64  `json:"carve_size"`
+	CarveID    string `json:"carve_id"`
+	NodeKey    string `json:"node_key"`
+	// Continue requests
+	SessionID string `json:"session_id"`
+	BlockID   int64  `json:"block_id"`
+	Data      []byte `json:"data"`
+
+	RequestID string `json:"request_id"`
+}
+
+func (c *CarveReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req carveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid carve request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var response interface{}
+	var err error
+	if req.SessionID == "" {
+		response, err = c.start(r.Context(), req)
+	} else {
+		response, err = c.block(r.Context(), req)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Cause(err) == errCarveUnauthorized {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+var errCarveUnauthorized = errors.New("unauthorized")
+
+// start starts a carve session.
+func (c *CarveReceiver) start(ctx context.Context, req carveRequest) (interface{}, error) {
+	if c.config.Authorize != nil {
+		if err := c.config.Authorize(ctx, req.NodeKey); err != nil {
+			return nil, errors.Wrap(errCarveUnauthorized, err.Error())
+		}
+	}
+	if req.BlockCount <= 0 || req.BlockSize <= 0 || req.CarveSize < 0 {
+		return nil, errors.New("invalid carve size")
+	}
+	if req.CarveSize > req.BlockCount*req.BlockSize {
+		return nil, errors.New("carve size exceeds its blocks")
+	}
+	if c.config.MaxSize > 0 && req.CarveSize > c.config.MaxSize {
+		return nil, errors.Errorf("carve of %d bytes exceeds the maximum size", req.CarveSize)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	sessionID := hex.EncodeToString(id)
+	file, err := os.CreateTemp(c.config.Dir, "carve-*.tar")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating carve file")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expireSessions()
+	c.sessions[sessionID] = &carveSession{
+		carve: CarvedFile{
+			CarveID:   req.CarveID,
+			RequestID: req.RequestID,
+			NodeKey:   req.NodeKey,
+			Path:      file.Name(),
+			Size:      req.CarveSize,
+		},
+		file:       file,
+		blockSize:  req.BlockSize,
+		blockCount: req.BlockCount,
+		received:   map[int64]bool{},
+		lastBlock:  c.now(),
+	}
+	return map[string]interface{}{"success": true, "session_id": sessionID}, nil
+}
+
+// block writes a block of a carve, completing it with the last block.
+func (c *CarveReceiver) block(ctx context.Context, req carveRequest) (interface{}, error) {
+	c.mutex.Lock()
+	session, ok := c.sessions[req.SessionID]
+	if !ok {
+		c.mutex.Unlock()
+		return nil, errors.New("unknown carve session")
+	}
+	if req.BlockID < 0 || req.BlockID >= session.blockCount || int64(len(req.Data)) > session.blockSize {
+		c.mutex.Unlock()
+		return nil, errors.New("invalid carve block")
+	}
+	if _, err := session.file.WriteAt(req.Data, req.BlockID*session.blockSize); err != nil {
+		c.mutex.Unlock()
+		return nil, errors.Wrap(err, "writing carve block")
+	}
+	session.received[req.BlockID] = true
+	session.lastBlock = c.now()
+	complete := int64(len(session.received)) == session.blockCount
+	if complete {
+		delete(c.sessions, req.SessionID)
+	}
+	c.mutex.Unlock()
+
+	if complete {
+		defer os.Remove(session.carve.Path)
+		if err := session.file.Truncate(session.carve.Size); err != nil {
+			session.file.Close()
+			return nil, errors.Wrap(err, "writing carve")
+		}
+		if err := session.file.Close(); err != nil {
+			return nil, errors.Wrap(err, "writing carve")
+		}
+		if err := c.config.OnCarve(ctx, session.carve); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{"success": true}, nil
+}
+
+// expireSessions removes the sessions without blocks for SessionTimeout. It
+// must be called with the mutex held.
+func (c *CarveReceiver) expireSessions() {
+	for id, session := range c.sessions {
+		if c.now().Sub(session.lastBlock) > c.config.SessionTimeout {
+			session.file.Close()
+			os.Remove(session.carve.Path)
+			delete(c.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarveQuery(t *testing.T) {
+	assert.Equal(t,
+		`SELECT * FROM carves WHERE carve = 1 AND (path LIKE '/tmp/%.log' OR path LIKE '/home/o''brien/notes')`,
+		CarveQuery("/tmp/%.log", "/home/o'brien/notes"))
+}
+
+func TestDistributedPluginCarves(t *testing.T) {
+	plugin := NewPlugin("mock",
+		func(context.Context) (*GetQueriesResult, error) {
+			return &GetQueriesResult{
+				Queries: map[string]string{"uptime": "SELECT * FROM uptime"},
+				Carves:  map[string][]string{"carve_logs": {"/var/log/%.log"}},
+			}, nil
+		},
+		func(ctx context.Context, res []Result) error { return nil },
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.JSONEq(t, `{"queries": {
+		"uptime": "SELECT * FROM uptime",
+		"carve_logs": "SELECT * FROM carves WHERE carve = 1 AND (path LIKE '/var/log/%.log')"
+	}}`, resp.Response[0]["results"])
+}
+
+func TestResultCarves(t *testing.T) {
+	r := Result{Rows: []map[string]string{
+		{"time": "1700000000", "sha256": "", "size": "-1", "path": "/var/log/system.log", "status": "STARTING", "carve_guid": "guid-1", "request_id": "carve_logs", "carve": "1"},
+		{"path": "/var/log/other.log"},
+	}}
+	carves, err := r.Carves()
+	require.NoError(t, err)
+	assert.Equal(t, []CarveResult{{
+		Time:      1700000000,
+		Size:      -1,
+		Path:      "/var/log/system.log",
+		Status:    "STARTING",
+		CarveGUID: "guid-1",
+		RequestID: "carve_logs",
+	}}, carves)
+
+	r.Rows[0]["size"] = "big"
+	_, err = r.Carves()
+	assert.Error(t, err)
+}
+
+func postCarve(t *testing.T, url string, body interface{}) (int, map[string]interface{}) {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var decoded map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp.StatusCode, decoded
+}
+
+func TestCarveReceiver(t *testing.T) {
+	dir := t.TempDir()
+	var received []CarvedFile
+	var contents [][]byte
+	receiver, err := NewCarveReceiver(CarveReceiverConfig{
+		Dir:     dir,
+		MaxSize: 1024,
+		Authorize: func(ctx context.Context, nodeKey string) error {
+			if nodeKey != "node-1" {
+				return errors.New("unknown node")
+			}
+			return nil
+		},
+		OnCarve: func(ctx context.Context, carve CarvedFile) error {
+			data, err := os.ReadFile(carve.Path)
+			require.NoError(t, err)
+			received = append(received, carve)
+			contents = append(contents, data)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	status, resp := postCarve(t, srv.URL, map[string]interface{}{
+		"block_count": 3, "block_size": 4, "carve_size": 10,
+		"carve_id": "guid-1", "request_id": "carve_logs", "node_key": "node-1",
+	})
+	require.Equal(t, http.StatusOK, status)
+	sessionID, _ := resp["session_id"].(string)
+	require.NotEmpty(t, sessionID)
+
+	// Blocks may arrive in any order
+	for _, block := range []struct {
+		id   int
+		data string
+	}{{2, "89"}, {0, "0123"}, {1, "4567"}} {
+		status, _ := postCarve(t, srv.URL, map[string]interface{}{
+			"block_id": block.id, "session_id": sessionID, "request_id": "carve_logs", "data": []byte(block.data),
+		})
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	require.Len(t, received, 1)
+	assert.Equal(t, "guid-1", received[0].CarveID)
+	assert.Equal(t, "carve_logs", received[0].RequestID)
+	assert.Equal(t, "node-1", received[0].NodeKey)
+	assert.Equal(t, int64(10), received[0].Size)
+	assert.Equal(t, "0123456789", string(contents[0]))
+
+	// Completed carves are removed
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	status, _ = postCarve(t, srv.URL, map[string]interface{}{"block_id": 0, "session_id": sessionID, "data": []byte("0123")})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Invalid requests
+	status, _ = postCarve(t, srv.URL, map[string]interface{}{"block_count": 1, "block_size": 4, "carve_size": 4, "node_key": "node-2"})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = postCarve(t, srv.URL, map[string]interface{}{"block_count": 1000, "block_size": 4, "carve_size": 4000, "node_key": "node-1"})
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = postCarve(t, srv.URL, map[string]interface{}{"block_count": 1, "block_size": 4, "carve_size": 8, "node_key": "node-1"})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestCarveReceiverExpiry(t *testing.T) {
+	dir := t.TempDir()
+	receiver, err := NewCarveReceiver(CarveReceiverConfig{
+		Dir:            dir,
+		SessionTimeout: time.Minute,
+		OnCarve:        func(ctx context.Context, carve CarvedFile) error { return nil },
+	})
+	require.NoError(t, err)
+	now := time.Now()
+	receiver.now = func() time.Time { return now }
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	start := map[string]interface{}{"block_count": 2, "block_size": 4, "carve_size": 8}
+	status, resp := postCarve(t, srv.URL, start)
+	require.Equal(t, http.StatusOK, status)
+	abandoned := resp["session_id"].(string)
+
+	now = now.Add(2 * time.Minute)
+	status, _ = postCarve(t, srv.URL, start)
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = postCarve(t, srv.URL, map[string]interface{}{"block_id": 0, "session_id": abandoned, "data": []byte("0123")})
+	assert.Equal(t, http.StatusBadRequest, status)
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,152 @@
+package distributed
+
+import "context"
+
+// ResultLimit limits the size of the results delivered to the
+// WriteResultsFunc in a single call, or to the WriteResultFunc of plugins
+// created with WithStreamingResults. A zero field means no limit.
+type ResultLimit struct {
+	// MaxRows is the maximum number of rows.
+	MaxRows int
+	// MaxBytes is the maximum size of the rows, counted as the length of
+	// their column names and values.
+	MaxBytes int
+	// Truncate drops the rows over the limits of each query, setting the
+	// Truncated field of its result, instead of splitting the results
+	// into several deliveries.
+	Truncate bool
+}
+
+// ResultChunk locates a part of the results of a query split into several
+// deliveries by WithResultLimit.
+type ResultChunk struct {
+	// Index is the index of the part, starting at 0.
+	Index int `json:"index"`
+	// Count is the number of parts of the results of the query.
+	Count int `json:"count"`
+}
+
+// WithResultLimit makes the plugin split the results written by osquery
+// into several deliveries within limit, so that large result sets don't
+// exceed the message limits of the backend. The results of a query split
+// across deliveries have their Chunk set, and each part carries the status,
+// message and stats of the query. With limit.Truncate, the results are
+// truncated instead.
+func WithResultLimit(limit ResultLimit) Option {
+	return func(p *Plugin) {
+		p.limit = &limit
+	}
+}
+
+// split returns the deliveries of the results within the limit.
+func (l ResultLimit) split(results []Result) [][]Result {
+	if l.Truncate {
+		truncated := make([]Result, len(results))
+		for i, r := range results {
+			truncated[i] = l.truncate(r)
+		}
+		return [][]Result{truncated}
+	}
+
+	var deliveries [][]Result
+	var current []Result
+	rows, bytes := 0, 0
+	flush := func() {
+		if len(current) > 0 {
+			deliveries = append(deliveries, current)
+		}
+		current, rows, bytes = nil, 0, 0
+	}
+
+	for _, r := range results {
+		// Positions of the parts of the query, to set their count
+		type position struct{ delivery, result int }
+		var parts []position
+		addPart := func(part Result) {
+			current = append(current, part)
+			parts = append(parts, position{len(deliveries), len(current) - 1})
+		}
+
+		start := 0
+		for i, row := range r.Rows {
+			size := rowSize(row)
+			// A row is always delivered, even if it is over the limit
+			// on its own
+			if rows > 0 && !l.fits(rows+1, bytes+size) {
+				if i > start {
+					part := r
+					part.Rows = r.Rows[start:i]
+					addPart(part)
+				}
+				flush()
+				start = i
+			}
+			rows++
+			bytes += size
+		}
+		part := r
+		part.Rows = r.Rows[start:]
+		addPart(part)
+
+		if len(parts) > 1 {
+			for i, p := range parts {
+				var d []Result
+				if p.delivery < len(deliveries) {
+					d = deliveries[p.delivery]
+				} else {
+					d = current
+				}
+				d[p.result].Chunk = &ResultChunk{Index: i, Count: len(parts)}
+			}
+		}
+	}
+	flush()
+	return deliveries
+}
+
+// truncate returns the result with the rows over the limit dropped.
+func (l ResultLimit) truncate(r Result) Result {
+	bytes := 0
+	for i, row := range r.Rows {
+		bytes += rowSize(row)
+		if !l.fits(i+1, bytes) {
+			r.Rows = r.Rows[:i]
+			r.Truncated = true
+			break
+		}
+	}
+	return r
+}
+
+func (l ResultLimit) fits(rows, bytes int) bool {
+	return (l.MaxRows <= 0 || rows <= l.MaxRows) && (l.MaxBytes <= 0 || bytes <= l.MaxBytes)
+}
+
+func rowSize(row map[string]string) int {
+	size := 0
+	for k, v := range row {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// write delivers the results to the WriteResultsFunc, within the limit and
+// through the queue if set.
+func (t *Plugin) write(ctx context.Context, results []Result) error {
+	deliveries := [][]Result{results}
+	if t.limit != nil {
+		deliveries = t.limit.split(results)
+	}
+	for _, d := range deliveries {
+		var err error
+		if t.queue != nil {
+			err = t.writeQueued(ctx, d)
+		} else {
+			err = t.writeResults(ctx, d)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
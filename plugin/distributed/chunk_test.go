@@ -0,0 +1,93 @@
+package distributed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rowsOf(values ...string) []map[string]string {
+	rows := make([]map[string]string, len(values))
+	for i, v := range values {
+		rows[i] = map[string]string{"v": v}
+	}
+	return rows
+}
+
+func TestResultLimitSplit(t *testing.T) {
+	results := []Result{
+		{QueryName: "a", Rows: rowsOf("1", "2", "3", "4", "5")},
+		{QueryName: "b", Status: 1, Message: "no such table"},
+		{QueryName: "c", Rows: rowsOf("6")},
+	}
+
+	deliveries := ResultLimit{MaxRows: 2}.split(results)
+	assert.Equal(t, [][]Result{
+		{{QueryName: "a", Rows: rowsOf("1", "2"), Chunk: &ResultChunk{Index: 0, Count: 3}}},
+		{{QueryName: "a", Rows: rowsOf("3", "4"), Chunk: &ResultChunk{Index: 1, Count: 3}}},
+		{
+			{QueryName: "a", Rows: rowsOf("5"), Chunk: &ResultChunk{Index: 2, Count: 3}},
+			{QueryName: "b", Status: 1, Message: "no such table", Rows: nil},
+			{QueryName: "c", Rows: rowsOf("6")},
+		},
+	}, deliveries)
+
+	// Each row is 2 bytes, rows over the limit on their own are delivered
+	deliveries = ResultLimit{MaxBytes: 5}.split([]Result{
+		{QueryName: "a", Rows: rowsOf("1", "2", "3")},
+		{QueryName: "big", Rows: rowsOf("0123456789")},
+	})
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, rowsOf("1", "2"), deliveries[0][0].Rows)
+	assert.Equal(t, rowsOf("3"), deliveries[1][0].Rows)
+	assert.Equal(t, "big", deliveries[2][0].QueryName)
+	assert.Nil(t, deliveries[2][0].Chunk)
+
+	// No limit
+	assert.Equal(t, [][]Result{results}, ResultLimit{}.split(results))
+}
+
+func TestResultLimitTruncate(t *testing.T) {
+	deliveries := ResultLimit{MaxRows: 2, Truncate: true}.split([]Result{
+		{QueryName: "a", Rows: rowsOf("1", "2", "3")},
+		{QueryName: "b", Rows: rowsOf("4")},
+	})
+	assert.Equal(t, [][]Result{{
+		{QueryName: "a", Rows: rowsOf("1", "2"), Truncated: true},
+		{QueryName: "b", Rows: rowsOf("4")},
+	}}, deliveries)
+}
+
+func TestDistributedPluginResultLimit(t *testing.T) {
+	var deliveries [][]Result
+	plugin := NewPlugin("mock",
+		func(context.Context) (*GetQueriesResult, error) { return &GetQueriesResult{}, nil },
+		func(ctx context.Context, results []Result) error {
+			deliveries = append(deliveries, results)
+			return nil
+		},
+		WithResultLimit(ResultLimit{MaxRows: 1}),
+	)
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"q":[{"v":"1"},{"v":"2"}]},"statuses":{"q":0}}`})
+	require.Equal(t, &StatusOK, resp.Status)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, &ResultChunk{Index: 1, Count: 2}, deliveries[1][0].Chunk)
+
+	// Streaming
+	var streamed []Result
+	plugin = NewPlugin("mock",
+		func(context.Context) (*GetQueriesResult, error) { return &GetQueriesResult{}, nil },
+		nil,
+		WithStreamingResults(func(ctx context.Context, result Result) error {
+			streamed = append(streamed, result)
+			return nil
+		}),
+		WithResultLimit(ResultLimit{MaxRows: 1, Truncate: true}),
+	)
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"q":[{"v":"1"},{"v":"2"}]},"statuses":{"q":0}}`})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, []Result{{QueryName: "q", Rows: rowsOf("1"), Truncated: true}}, streamed)
+}
@@ -0,0 +1,191 @@
+package distributed
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+)
+
+// Column is a column of the results of a query, with its osquery type, such
+// as "TEXT", "INTEGER", "BIGINT", "UNSIGNED_BIGINT" or "DOUBLE".
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// QueryColumnsGetter parses queries with osquery. It is implemented by
+// *osquery.ExtensionManagerClient.
+type QueryColumnsGetter interface {
+	GetQueryColumnsContext(ctx context.Context, sql string) (*osquery.ExtensionResponse, error)
+}
+
+// Option configures optional behavior of a distributed Plugin.
+type Option func(*Plugin)
+
+// WithColumnTypes makes the plugin set the Columns of the results, as
+// osquery doesn't send them with the results. The columns of each query
+// returned by GetQueriesFunc are requested from osquery with
+// GetQueryColumns, and cached by SQL. Results of queries whose columns
+// can't be determined have no Columns.
+func WithColumnTypes(client QueryColumnsGetter) Option {
+	return func(p *Plugin) {
+		p.columns = &columnTypes{client: client, bySQL: map[string][]Column{}}
+	}
+}
+
+// columnTypes resolves the columns of the distributed queries.
+type columnTypes struct {
+	client QueryColumnsGetter
+
+	mutex sync.Mutex
+	// queries maps the names of the last queries returned to osquery to
+	// their SQL.
+	queries map[string]string
+	bySQL   map[string][]Column
+}
+
+// setQueries records the queries returned to osquery.
+func (c *columnTypes) setQueries(queries map[string]string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.queries = queries
+}
+
+// annotate sets the columns of the results.
+func (c *columnTypes) annotate(ctx context.Context, results []Result) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := range results {
+		sql, ok := c.queries[results[i].QueryName]
+		if !ok {
+			continue
+		}
+		columns, ok := c.bySQL[sql]
+		if !ok {
+			var err error
+			if columns, err = c.get(ctx, sql); err != nil {
+				continue
+			}
+			c.bySQL[sql] = columns
+		}
+		results[i].Columns = columns
+	}
+}
+
+func (c *columnTypes) get(ctx context.Context, sql string) ([]Column, error) {
+	resp, err := c.client.GetQueryColumnsContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == nil || resp.Status.Code != 0 {
+		return nil, errors.New("query columns not available")
+	}
+	var columns []Column
+	for _, col := range resp.Response {
+		for name, typ := range col {
+			columns = append(columns, Column{Name: name, Type: typ})
+		}
+	}
+	return columns, nil
+}
+
+// ColumnType returns the osquery type of a column of the results, or an
+// empty string if the columns are not known.
+func (r Result) ColumnType(column string) string {
+	for _, c := range r.Columns {
+		if c.Name == column {
+			return c.Type
+		}
+	}
+	return ""
+}
+
+// Value returns the value of a column of a row, converted according to the
+// column type: int64 for INTEGER and BIGINT columns, uint64 for
+// UNSIGNED_BIGINT columns, float64 for DOUBLE columns and string
+// otherwise, including when the type is unknown. Empty values of numeric
+// columns are nil, as osquery returns NULL as an empty string.
+func (r Result) Value(row int, column string) (interface{}, error) {
+	if row < 0 || row >= len(r.Rows) {
+		return nil, errors.Errorf("row %d out of range", row)
+	}
+	value, ok := r.Rows[row][column]
+	if !ok {
+		return nil, errors.Errorf("no column %q", column)
+	}
+	typ := strings.ToUpper(r.ColumnType(column))
+	if value == "" && typ != "" && typ != "TEXT" && typ != "BLOB" {
+		return nil, nil
+	}
+
+	var v interface{}
+	var err error
+	switch typ {
+	case "INTEGER", "BIGINT":
+		v, err = strconv.ParseInt(value, 10, 64)
+	case "UNSIGNED_BIGINT":
+		v, err = strconv.ParseUint(value, 10, 64)
+	case "DOUBLE":
+		v, err = strconv.ParseFloat(value, 64)
+	default:
+		v = value
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "column %q", column)
+	}
+	return v, nil
+}
+
+// Int returns the value of a column of a row as an integer, whatever the
+// column type.
+func (r Result) Int(row int, column string) (int64, error) {
+	value, err := r.String(row, column)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	return i, errors.Wrapf(err, "column %q", column)
+}
+
+// Float returns the value of a column of a row as a float, whatever the
+// column type.
+func (r Result) Float(row int, column string) (float64, error) {
+	value, err := r.String(row, column)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	return f, errors.Wrapf(err, "column %q", column)
+}
+
+// String returns the value of a column of a row.
+func (r Result) String(row int, column string) (string, error) {
+	if row < 0 || row >= len(r.Rows) {
+		return "", errors.Errorf("row %d out of range", row)
+	}
+	value, ok := r.Rows[row][column]
+	if !ok {
+		return "", errors.Errorf("no column %q", column)
+	}
+	return value, nil
+}
+
+// WallDuration returns the wall clock time of the query.
+func (s Stats) WallDuration() time.Duration {
+	return time.Duration(s.WallTimeMs) * time.Millisecond
+}
+
+// UserDuration returns the user CPU time of the query.
+func (s Stats) UserDuration() time.Duration {
+	return time.Duration(s.UserTime) * time.Millisecond
+}
+
+// SystemDuration returns the system CPU time of the query.
+func (s Stats) SystemDuration() time.Duration {
+	return time.Duration(s.SystemTime) * time.Millisecond
+}
@@ -0,0 +1,119 @@
+package distributed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// columnsGetter returns the columns of known queries, counting calls.
+type columnsGetter struct {
+	columns map[string]osquery.ExtensionPluginResponse
+	calls   int
+}
+
+func (g *columnsGetter) GetQueryColumnsContext(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+	g.calls++
+	columns, ok := g.columns[sql]
+	if !ok {
+		return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 1, Message: "no such table"}}, nil
+	}
+	return &osquery.ExtensionResponse{Status: &StatusOK, Response: columns}, nil
+}
+
+func TestDistributedPluginColumnTypes(t *testing.T) {
+	getter := &columnsGetter{columns: map[string]osquery.ExtensionPluginResponse{
+		"select pid, name, resident_size from processes": {{"pid": "BIGINT"}, {"name": "TEXT"}, {"resident_size": "BIGINT"}},
+	}}
+	var results []Result
+	plugin := NewPlugin("mock",
+		func(context.Context) (*GetQueriesResult, error) {
+			return &GetQueriesResult{Queries: map[string]string{
+				"procs": "select pid, name, resident_size from processes",
+				"bad":   "select foo from bar",
+			}}, nil
+		},
+		func(ctx context.Context, res []Result) error {
+			results = res
+			return nil
+		},
+		WithColumnTypes(getter),
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	require.Equal(t, &StatusOK, resp.Status)
+
+	write := `{"queries":{"procs":[{"pid":"1","name":"launchd","resident_size":""}],"bad":""},"statuses":{"procs":"0","bad":"1"},"stats":{"procs":{"wall_time_ms":12,"user_time":3,"system_time":4,"memory":1024}}}`
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": write})
+	require.Equal(t, &StatusOK, resp.Status)
+	require.Len(t, results, 2)
+
+	var procs, bad Result
+	for _, r := range results {
+		if r.QueryName == "procs" {
+			procs = r
+		} else {
+			bad = r
+		}
+	}
+	assert.Equal(t, []Column{{Name: "pid", Type: "BIGINT"}, {Name: "name", Type: "TEXT"}, {Name: "resident_size", Type: "BIGINT"}}, procs.Columns)
+	assert.Nil(t, bad.Columns)
+
+	v, err := procs.Value(0, "pid")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	v, err = procs.Value(0, "name")
+	require.NoError(t, err)
+	assert.Equal(t, "launchd", v)
+	v, err = procs.Value(0, "resident_size")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+	_, err = procs.Value(0, "missing")
+	assert.Error(t, err)
+	_, err = procs.Value(1, "pid")
+	assert.Error(t, err)
+
+	assert.Equal(t, 12*time.Millisecond, procs.QueryStats.WallDuration())
+	assert.Equal(t, 3*time.Millisecond, procs.QueryStats.UserDuration())
+	assert.Equal(t, 4*time.Millisecond, procs.QueryStats.SystemDuration())
+
+	// Columns are cached by SQL
+	calls := getter.calls
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": write})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, calls+1, getter.calls)
+}
+
+func TestResultAccessors(t *testing.T) {
+	r := Result{
+		Rows:    []map[string]string{{"size": "10", "ratio": "0.5", "name": "x", "free": "18446744073709551615"}},
+		Columns: []Column{{Name: "ratio", Type: "DOUBLE"}, {Name: "free", Type: "UNSIGNED_BIGINT"}},
+	}
+
+	i, err := r.Int(0, "size")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), i)
+	_, err = r.Int(0, "name")
+	assert.Error(t, err)
+
+	f, err := r.Float(0, "ratio")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, f)
+
+	v, err := r.Value(0, "ratio")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, v)
+	v, err = r.Value(0, "free")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(18446744073709551615), v)
+	v, err = r.Value(0, "size")
+	require.NoError(t, err)
+	assert.Equal(t, "10", v)
+
+	assert.Equal(t, "DOUBLE", r.ColumnType("ratio"))
+	assert.Equal(t, "", r.ColumnType("size"))
+}
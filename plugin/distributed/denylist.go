@@ -0,0 +1,213 @@
+package distributed
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DenylistConfig configures a Denylist.
+type DenylistConfig struct {
+	// CoolDown is how long queries stay denylisted after failing.
+	// Defaults to one hour.
+	CoolDown time.Duration
+	// Failed also denylists the queries that failed for other reasons
+	// than being interrupted by osquery, such as SQL errors. By default
+	// only the interrupted queries are denylisted, see
+	// Result.Interrupted.
+	Failed bool
+	// OnSkip, if set, is called for each query not sent to osquery
+	// because it is denylisted, with the end of its cool-down.
+	OnSkip func(name, sql string, until time.Time)
+}
+
+// Denylist tracks the distributed queries that osquery reported as failed,
+// so that they are not sent to osquery again until a cool-down passes.
+// Without it, a backend re-issuing a query that gets the worker killed by
+// the watchdog has osquery restart its worker on every check-in, while
+// osquery itself only denylists the query locally.
+//
+// Queries are tracked by SQL, like osquery does, so a query re-issued under
+// another name is denylisted too. A Denylist is safe for concurrent use
+// and can be shared by several plugins.
+type Denylist struct {
+	config DenylistConfig
+	now    func() time.Time
+
+	mutex sync.Mutex
+	// queries maps the names of the queries sent to osquery to their SQL
+	// and when they were sent.
+	queries map[string]sentQuery
+	// swept is the last time unanswered queries were removed from queries.
+	swept time.Time
+	// until maps the SQL of the denylisted queries to the end of their
+	// cool-down.
+	until map[string]time.Time
+}
+
+// NewDenylist returns an empty Denylist.
+func NewDenylist(config DenylistConfig) *Denylist {
+	if config.CoolDown <= 0 {
+		config.CoolDown = time.Hour
+	}
+	return &Denylist{
+		config:  config,
+		now:     time.Now,
+		queries: map[string]sentQuery{},
+		until:   map[string]time.Time{},
+	}
+}
+
+type sentQuery struct {
+	sql  string
+	sent time.Time
+}
+
+// skippedQuery is a query left out by Filter, reported to OnSkip.
+type skippedQuery struct {
+	name, sql string
+	until     time.Time
+}
+
+// WithDenylist makes the plugin record the failed queries in d, and leave
+// the queries denylisted by d out of those returned by GetQueriesFunc.
+func WithDenylist(d *Denylist) Option {
+	return func(p *Plugin) {
+		p.denylist = d
+	}
+}
+
+// Denylisted returns the end of the cool-down of the query, and whether it
+// is currently denylisted.
+func (d *Denylist) Denylisted(sql string) (time.Time, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.denylisted(sql)
+}
+
+func (d *Denylist) denylisted(sql string) (time.Time, bool) {
+	until, ok := d.until[sql]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !d.now().Before(until) {
+		delete(d.until, sql)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// Add denylists the query until the end of the cool-down.
+func (d *Denylist) Add(sql string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.until[sql] = d.now().Add(d.config.CoolDown)
+}
+
+// Remove removes the query from the denylist.
+func (d *Denylist) Remove(sql string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.until, sql)
+}
+
+// Queries returns the SQL of the denylisted queries, sorted.
+func (d *Denylist) Queries() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var queries []string
+	for sql := range d.until {
+		if _, ok := d.denylisted(sql); ok {
+			queries = append(queries, sql)
+		}
+	}
+	sort.Strings(queries)
+	return queries
+}
+
+// Record denylists the queries of the results that failed. The queries
+// must have been returned by Filter, which records their SQL, and each
+// is recorded once. Queries that succeeded are removed from the denylist.
+func (d *Denylist) Record(results []Result) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, r := range results {
+		query, ok := d.queries[r.QueryName]
+		if !ok {
+			continue
+		}
+		delete(d.queries, r.QueryName)
+		sql := query.sql
+		switch {
+		case r.Interrupted || (d.config.Failed && r.Status != 0):
+			d.until[sql] = d.now().Add(d.config.CoolDown)
+		case r.Status == 0:
+			delete(d.until, sql)
+		}
+	}
+}
+
+// Filter returns a copy of the queries without the denylisted ones, and
+// records the SQL of those returned for Record. Discovery queries of the
+// denylisted queries are dropped with them, and a denylisted discovery
+// query drops its query. Queries osquery doesn't report results for are
+// forgotten after the cool-down.
+func (d *Denylist) Filter(queries *GetQueriesResult) *GetQueriesResult {
+	if queries == nil {
+		return nil
+	}
+	filtered, skipped := d.filter(queries)
+	// OnSkip is called without the lock, so that it may use d.
+	if d.config.OnSkip != nil {
+		for _, q := range skipped {
+			d.config.OnSkip(q.name, q.sql, q.until)
+		}
+	}
+	return filtered
+}
+
+func (d *Denylist) filter(queries *GetQueriesResult) (*GetQueriesResult, []skippedQuery) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	now := d.now()
+
+	var skipped []skippedQuery
+
+	filtered := *queries
+	filtered.Queries = make(map[string]string, len(queries.Queries))
+	for _, name := range sortedNames(queries.Queries) {
+		sql := queries.Queries[name]
+		until, ok := d.denylisted(sql)
+		if discovery, has := queries.Discovery[name]; has && !ok {
+			until, ok = d.denylisted(discovery)
+		}
+		if !ok {
+			filtered.Queries[name] = sql
+			continue
+		}
+		skipped = append(skipped, skippedQuery{name: name, sql: sql, until: until})
+	}
+	if queries.Discovery != nil {
+		filtered.Discovery = make(map[string]string, len(queries.Discovery))
+		for name, sql := range queries.Discovery {
+			if _, ok := filtered.Queries[name]; ok {
+				filtered.Discovery[name] = sql
+			}
+		}
+	}
+
+	// Forget the queries osquery never answered, at most once per
+	// cool-down to keep check-ins cheap.
+	if now.Sub(d.swept) >= d.config.CoolDown {
+		for name, query := range d.queries {
+			if now.Sub(query.sent) >= d.config.CoolDown {
+				delete(d.queries, name)
+			}
+		}
+		d.swept = now
+	}
+	for name, sql := range filtered.Queries {
+		d.queries[name] = sentQuery{sql: sql, sent: now}
+	}
+	return &filtered, skipped
+}
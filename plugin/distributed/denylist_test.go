@@ -0,0 +1,120 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenylist(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var skipped []string
+	d := NewDenylist(DenylistConfig{
+		CoolDown: time.Minute,
+		OnSkip:   func(name, sql string, until time.Time) { skipped = append(skipped, name) },
+	})
+	d.now = func() time.Time { return now }
+
+	queries := &GetQueriesResult{
+		Queries:   map[string]string{"slow": "select * from hash", "ok": "select 1", "bad": "selec 1"},
+		Discovery: map[string]string{"slow": "select 1"},
+	}
+	assert.Equal(t, queries, d.Filter(queries))
+
+	d.Record([]Result{
+		{QueryName: "slow", Status: 1, Message: "distributed query is denylisted", Interrupted: true},
+		{QueryName: "ok"},
+		{QueryName: "bad", Status: 1, Message: "near \"selec\": syntax error"},
+	})
+	until, ok := d.Denylisted("select * from hash")
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(time.Minute), until)
+	assert.Equal(t, []string{"select * from hash"}, d.Queries())
+
+	// The denylisted query is left out under any name, with its discovery
+	// query, and the original queries are unchanged
+	queries.Queries["renamed"] = "select * from hash"
+	filtered := d.Filter(queries)
+	assert.Equal(t, map[string]string{"ok": "select 1", "bad": "selec 1"}, filtered.Queries)
+	assert.Equal(t, map[string]string{}, filtered.Discovery)
+	assert.Equal(t, []string{"renamed", "slow"}, skipped)
+	assert.Len(t, queries.Queries, 4)
+
+	// The cool-down passes
+	now = now.Add(time.Minute)
+	_, ok = d.Denylisted("select * from hash")
+	assert.False(t, ok)
+	assert.Len(t, d.Filter(queries).Queries, 4)
+
+	// Results of unknown queries are ignored
+	d.Record([]Result{{QueryName: "other", Status: 1, Interrupted: true}})
+	assert.Empty(t, d.Queries())
+}
+
+func TestDenylistFailed(t *testing.T) {
+	d := NewDenylist(DenylistConfig{Failed: true})
+	d.Filter(&GetQueriesResult{Queries: map[string]string{"bad": "selec 1"}})
+	d.Record([]Result{{QueryName: "bad", Status: 1}})
+	assert.Equal(t, []string{"selec 1"}, d.Queries())
+
+	d.Remove("selec 1")
+	assert.Empty(t, d.Queries())
+	d.Add("select 2")
+	assert.Equal(t, []string{"select 2"}, d.Queries())
+}
+
+func TestDenylistOnSkip(t *testing.T) {
+	var d *Denylist
+	var denylisted [][]string
+	d = NewDenylist(DenylistConfig{
+		// The callback may use the denylist
+		OnSkip: func(name, sql string, until time.Time) { denylisted = append(denylisted, d.Queries()) },
+	})
+	d.Add("select 1")
+	d.Filter(&GetQueriesResult{Queries: map[string]string{"q": "select 1"}})
+	assert.Equal(t, [][]string{{"select 1"}}, denylisted)
+}
+
+func TestDenylistUnanswered(t *testing.T) {
+	now := time.Unix(1000, 0)
+	d := NewDenylist(DenylistConfig{CoolDown: time.Minute})
+	d.now = func() time.Time { return now }
+
+	d.Filter(&GetQueriesResult{Queries: map[string]string{"lost": "select 1"}})
+	now = now.Add(time.Minute)
+	d.Filter(&GetQueriesResult{Queries: map[string]string{"next": "select 2"}})
+	assert.Len(t, d.queries, 1)
+
+	// A late result of the forgotten query is ignored
+	d.Record([]Result{{QueryName: "lost", Status: 1, Interrupted: true}})
+	assert.Empty(t, d.Queries())
+}
+
+func TestDistributedPluginDenylist(t *testing.T) {
+	d := NewDenylist(DenylistConfig{})
+	plugin := NewPlugin("mock",
+		func(context.Context) (*GetQueriesResult, error) {
+			return &GetQueriesResult{Queries: map[string]string{"slow": "select * from hash", "ok": "select 1"}}, nil
+		},
+		func(ctx context.Context, results []Result) error { return nil },
+		WithDenylist(d),
+	)
+
+	getQueries := func() map[string]string {
+		resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+		require.Equal(t, &StatusOK, resp.Status)
+		var queries GetQueriesResult
+		require.NoError(t, json.Unmarshal([]byte(resp.Response[0]["results"]), &queries))
+		return queries.Queries
+	}
+
+	assert.Len(t, getQueries(), 2)
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"ok":[]},"statuses":{"ok":0,"slow":1},"messages":{"slow":"distributed query is denylisted"}}`})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, map[string]string{"ok": "select 1"}, getQueries())
+}
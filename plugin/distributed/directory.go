@@ -0,0 +1,114 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DirectoryConfig configures a directory distributed plugin.
+type DirectoryConfig struct {
+	// QueryDir is the directory of the query files. Each file with a .sql
+	// extension holds a query, named after the file without its
+	// extension.
+	QueryDir string
+	// ResultDir is the directory where the results of each query are
+	// written, as the JSON of its Result in <name>.json. The query file
+	// is then moved to ResultDir, so that the query is run once.
+	ResultDir string
+}
+
+// NewDirectoryPlugin creates a distributed plugin serving the queries of
+// the files of a local directory, and writing their results to another
+// directory, for air-gapped investigations and local testing. The query
+// directory is read each time osquery requests the distributed queries, so
+// queries can be added while the extension runs. Query files should be
+// written atomically (to a temporary file without the .sql extension, then
+// renamed).
+func NewDirectoryPlugin(name string, config DirectoryConfig, opts ...Option) (*Plugin, error) {
+	if config.QueryDir == "" || config.ResultDir == "" {
+		return nil, errors.New("directory distributed plugin requires a query and a result directory")
+	}
+	if err := os.MkdirAll(config.ResultDir, 0700); err != nil {
+		return nil, errors.Wrap(err, "creating result directory")
+	}
+	d := &directoryQueries{config: config}
+	return NewPlugin(name, d.getQueries, d.writeResults, opts...), nil
+}
+
+// directoryQueries serves the queries of a directory.
+type directoryQueries struct {
+	config DirectoryConfig
+}
+
+func (d *directoryQueries) getQueries(ctx context.Context) (*GetQueriesResult, error) {
+	paths, err := filepath.Glob(filepath.Join(d.config.QueryDir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	queries := make(map[string]string, len(paths))
+	for _, path := range paths {
+		sql, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			// Completed since listed
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading query")
+		}
+		if strings.TrimSpace(string(sql)) == "" {
+			continue
+		}
+		queries[queryName(path)] = string(sql)
+	}
+	return &GetQueriesResult{Queries: queries}, nil
+}
+
+func (d *directoryQueries) writeResults(ctx context.Context, results []Result) error {
+	for _, result := range results {
+		if err := d.writeResult(result); err != nil {
+			return errors.Wrapf(err, "writing results of %s", result.QueryName)
+		}
+	}
+	return nil
+}
+
+// writeResult writes the results of a query, then moves the query file to
+// the result directory.
+func (d *directoryQueries) writeResult(result Result) error {
+	name := filepath.Base(result.QueryName)
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.config.ResultDir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(d.config.ResultDir, name+".json")); err != nil {
+		return err
+	}
+
+	err = os.Rename(filepath.Join(d.config.QueryDir, name+".sql"), filepath.Join(d.config.ResultDir, name+".sql"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// queryName returns the name of the query of a query file.
+func queryName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".sql")
+}
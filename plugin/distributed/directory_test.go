@@ -0,0 +1,46 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryPlugin(t *testing.T) {
+	queryDir, resultDir := t.TempDir(), filepath.Join(t.TempDir(), "results")
+	require.NoError(t, os.WriteFile(filepath.Join(queryDir, "users.sql"), []byte("SELECT * FROM users"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(queryDir, "empty.sql"), nil, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(queryDir, "notes.txt"), []byte("not a query"), 0600))
+
+	plugin, err := NewDirectoryPlugin("dir", DirectoryConfig{QueryDir: queryDir, ResultDir: resultDir})
+	require.NoError(t, err)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.JSONEq(t, `{"queries": {"users": "SELECT * FROM users"}}`, resp.Response[0]["results"])
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"users":[{"username":"root"}]},"statuses":{"users":0}}`})
+	require.Equal(t, &StatusOK, resp.Status)
+
+	data, err := os.ReadFile(filepath.Join(resultDir, "users.json"))
+	require.NoError(t, err)
+	var result Result
+	require.NoError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, Result{QueryName: "users", Rows: []map[string]string{{"username": "root"}}}, result)
+
+	// The query is moved with its results
+	assert.FileExists(t, filepath.Join(resultDir, "users.sql"))
+	assert.NoFileExists(t, filepath.Join(queryDir, "users.sql"))
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.JSONEq(t, `{"queries": {}}`, resp.Response[0]["results"])
+
+	_, err = NewDirectoryPlugin("dir", DirectoryConfig{QueryDir: queryDir})
+	assert.Error(t, err)
+}
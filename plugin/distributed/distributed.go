@@ -29,6 +29,23 @@ type GetQueriesResult struct {
 	// for a given number of seconds after this checkin. Currently this
 	// means that checkins will occur every 5 seconds.
 	AccelerateSeconds int `json:"accelerate,omitempty"`
+	// Carves maps query names to the path patterns of the files to carve,
+	// which are sent to osquery as queries built with CarveQuery.
+	Carves map[string][]string `json:"-"`
+}
+
+// withCarves returns a copy of the result with the carves added to the
+// queries.
+func (r *GetQueriesResult) withCarves() *GetQueriesResult {
+	merged := *r
+	merged.Queries = make(map[string]string, len(r.Queries)+len(r.Carves))
+	for name, sql := range r.Queries {
+		merged.Queries[name] = sql
+	}
+	for name, paths := range r.Carves {
+		merged.Queries[name] = CarveQuery(paths...)
+	}
+	return &merged
 }
 
 // GetQueriesFunc returns the queries that should be executed.
@@ -50,6 +67,20 @@ type Result struct {
 	QueryStats *Stats `json:"stats"`
 	// Message is the message string indicating the status of the query
 	Message string `json:"message"`
+	// Columns are the columns of the results, in order, with their types.
+	// They are only set by plugins created with WithColumnTypes.
+	Columns []Column `json:"columns,omitempty"`
+	// Interrupted is true if the query failed because osquery stopped
+	// running it, rather than because of an error of the query: the
+	// watchdog killed the worker running it, or osquery denylisted it
+	// after such kills. See Err.
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Truncated is true if rows were dropped by the ResultLimit of the
+	// plugin, see WithResultLimit.
+	Truncated bool `json:"truncated,omitempty"`
+	// Chunk is set if the results of the query are split into several
+	// deliveries, see WithResultLimit.
+	Chunk *ResultChunk `json:"chunk,omitempty"`
 }
 
 // WriteResultsFunc writes the results of the executed distributed queries. The
@@ -63,13 +94,30 @@ type Plugin struct {
 	name         string
 	getQueries   GetQueriesFunc
 	writeResults WriteResultsFunc
+	// writeResult writes results one query at a time, see
+	// WithStreamingResults.
+	writeResult WriteResultFunc
+	// queue holds the results that could not be written, see
+	// WithResultQueue.
+	queue *ResultQueue
+	// limit limits the size of the deliveries, see WithResultLimit.
+	limit *ResultLimit
+	// denylist holds the queries that failed, see WithDenylist.
+	denylist *Denylist
+
+	// columns sets the columns of the results, see WithColumnTypes.
+	columns *columnTypes
 }
 
 // NewPlugin takes the distributed query functions and returns a struct
 // implementing the OsqueryPlugin interface. Use this to wrap the appropriate
 // functions into an osquery plugin.
-func NewPlugin(name string, getQueries GetQueriesFunc, writeResults WriteResultsFunc) *Plugin {
-	return &Plugin{name: name, getQueries: getQueries, writeResults: writeResults}
+func NewPlugin(name string, getQueries GetQueriesFunc, writeResults WriteResultsFunc, opts ...Option) *Plugin {
+	p := &Plugin{name: name, getQueries: getQueries, writeResults: writeResults}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (t *Plugin) Name() string {
@@ -218,6 +266,7 @@ func (rs *ResultsStruct) toResults() ([]Result, error) {
 		if msg, ok := rs.Messages[queryName]; ok {
 			result.Message = msg
 		}
+		result.Interrupted = isInterrupted(result.Status, result.Message)
 		results = append(results, result)
 	}
 	return results, nil
@@ -243,12 +292,18 @@ func convertRows(rows []interface{}) ([]map[string]string, error) {
 	return results, nil
 }
 
-func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) (resp osquery.ExtensionResponse) {
 	ctx, span := traces.StartSpan(ctx, "Distributed.Call", "action", request[requestActionKey])
 	defer span.End()
+	defer func() { traces.RecordStatus(span, resp.Status) }()
 
 	switch request[requestActionKey] {
 	case getQueriesAction:
+		if t.queue != nil && t.writeResult == nil {
+			// Results stay queued until the next check-in on failure
+			_ = t.queue.Replay(ctx, t.writeResults)
+		}
+
 		queries, err := t.getQueries(ctx)
 		if err != nil {
 			return osquery.ExtensionResponse{
@@ -259,6 +314,16 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 			}
 		}
 
+		if queries != nil && len(queries.Carves) > 0 {
+			queries = queries.withCarves()
+		}
+		if t.denylist != nil {
+			queries = t.denylist.Filter(queries)
+		}
+		if t.columns != nil && queries != nil {
+			t.columns.setQueries(queries.Queries)
+		}
+
 		queryJSON, err := json.Marshal(queries)
 		if err != nil {
 			return osquery.ExtensionResponse{
@@ -275,6 +340,10 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 		}
 
 	case writeResultsAction:
+		if t.writeResult != nil {
+			return t.streamResults(ctx, request[requestResultKey])
+		}
+
 		var rs ResultsStruct
 		if err := json.Unmarshal([]byte(request[requestResultKey]), &rs); err != nil {
 			return osquery.ExtensionResponse{
@@ -293,8 +362,14 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 				},
 			}
 		}
+		if t.columns != nil {
+			t.columns.annotate(ctx, results)
+		}
+		if t.denylist != nil {
+			t.denylist.Record(results)
+		}
 		// invoke callback
-		err = t.writeResults(ctx, results)
+		err = t.write(ctx, results)
 		if err != nil {
 			return osquery.ExtensionResponse{
 				Status: &osquery.ExtensionStatus{
@@ -320,4 +395,52 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 
 }
 
+// streamResults writes the results with writeResult.
+func (t *Plugin) streamResults(ctx context.Context, data string) osquery.ExtensionResponse {
+	var writeErr error
+	err := StreamResults(data, func(result Result) error {
+		if t.columns != nil {
+			results := []Result{result}
+			t.columns.annotate(ctx, results)
+			result = results[0]
+		}
+		if t.denylist != nil {
+			t.denylist.Record([]Result{result})
+		}
+		deliveries := [][]Result{{result}}
+		if t.limit != nil {
+			deliveries = t.limit.split(deliveries[0])
+		}
+		for _, d := range deliveries {
+			for _, r := range d {
+				if writeErr = t.writeResult(ctx, r); writeErr != nil {
+					return writeErr
+				}
+			}
+		}
+		return nil
+	})
+	if writeErr != nil {
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    1,
+				Message: "error writing results: " + writeErr.Error(),
+			},
+		}
+	}
+	if err != nil {
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    1,
+				Message: "error unmarshalling results: " + err.Error(),
+			},
+		}
+	}
+
+	return osquery.ExtensionResponse{
+		Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+		Response: osquery.ExtensionPluginResponse{},
+	}
+}
+
 func (t *Plugin) Shutdown() {}
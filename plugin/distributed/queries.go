@@ -0,0 +1,179 @@
+package distributed
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// QueryOption configures a query enqueued in a Queue.
+type QueryOption func(*queuedQuery)
+
+// WithDiscovery makes osquery run the query only if the discovery query
+// returns rows.
+func WithDiscovery(sql string) QueryOption {
+	return func(q *queuedQuery) {
+		q.discovery = sql
+	}
+}
+
+// WithAccelerate makes osquery check in every few seconds for the duration
+// after the query is sent, so that follow-up queries are picked up
+// quickly. The duration is rounded up to seconds.
+func WithAccelerate(d time.Duration) QueryOption {
+	return func(q *queuedQuery) {
+		q.accelerate = d
+	}
+}
+
+// queuedQuery is a query of a Queue.
+type queuedQuery struct {
+	sql        string
+	discovery  string
+	accelerate time.Duration
+	results    chan Result
+	// sent is true once the query is returned to osquery.
+	sent bool
+}
+
+// Queue holds distributed queries until osquery requests them, and
+// delivers their results to subscription channels, so that backends don't
+// have to build the GetQueriesResult maps and match the results to the
+// queries. Use it with NewPlugin(name, q.GetQueries, q.WriteResults).
+// A Queue is safe for concurrent use.
+type Queue struct {
+	mutex   sync.Mutex
+	queries map[string]*queuedQuery
+}
+
+// NewQueue returns an empty Queue.
+func NewQueue() *Queue {
+	return &Queue{queries: map[string]*queuedQuery{}}
+}
+
+// Enqueue adds a query, sent to osquery on its next check-in, and returns
+// the channel its result is delivered to. The channel is closed after the
+// result, or its last chunk if the plugin splits results with
+// WithResultLimit, and should be received from promptly. It is closed
+// without a result if osquery doesn't run the query, such as when its
+// discovery query returns no rows: its results are expected before the
+// following check-in. The name must not be used by a query of the queue
+// not completed yet.
+func (q *Queue) Enqueue(name, sql string, opts ...QueryOption) (<-chan Result, error) {
+	if name == "" || sql == "" {
+		return nil, errors.New("enqueuing query: name and sql required")
+	}
+	query := &queuedQuery{sql: sql, results: make(chan Result, 1)}
+	for _, opt := range opts {
+		opt(query)
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, ok := q.queries[name]; ok {
+		return nil, errors.Errorf("enqueuing query: %s already queued", name)
+	}
+	q.queries[name] = query
+	return query.results, nil
+}
+
+// Cancel removes a query not sent to osquery yet, closing its channel. It
+// returns false if the query is not queued or already sent.
+func (q *Queue) Cancel(name string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	query, ok := q.queries[name]
+	if !ok || query.sent {
+		return false
+	}
+	delete(q.queries, name)
+	close(query.results)
+	return true
+}
+
+// Len returns the number of queries not sent to osquery yet.
+func (q *Queue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	n := 0
+	for _, query := range q.queries {
+		if !query.sent {
+			n++
+		}
+	}
+	return n
+}
+
+// GetQueries returns the queries enqueued since the last check-in. It is a
+// GetQueriesFunc. Queries sent on the previous check-in that got no results
+// are completed, closing their channels.
+func (q *Queue) GetQueries(ctx context.Context) (*GetQueriesResult, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	result := &GetQueriesResult{Queries: map[string]string{}}
+	var accelerate time.Duration
+	for name, query := range q.queries {
+		if query.sent {
+			delete(q.queries, name)
+			close(query.results)
+			continue
+		}
+		query.sent = true
+		result.Queries[name] = query.sql
+		if query.discovery != "" {
+			if result.Discovery == nil {
+				result.Discovery = map[string]string{}
+			}
+			result.Discovery[name] = query.discovery
+		}
+		if query.accelerate > accelerate {
+			accelerate = query.accelerate
+		}
+	}
+	result.AccelerateSeconds = int((accelerate + time.Second - 1) / time.Second)
+	return result, nil
+}
+
+// WriteResults delivers the results to the channels of their queries. It
+// is a WriteResultsFunc. Results of queries not in the queue are ignored.
+// It returns the error of the context if it is done before a channel
+// receives.
+func (q *Queue) WriteResults(ctx context.Context, results []Result) error {
+	for _, r := range results {
+		query, last := q.complete(r)
+		if query == nil {
+			continue
+		}
+		select {
+		case query.results <- r:
+		case <-ctx.Done():
+			if last {
+				close(query.results)
+			}
+			return ctx.Err()
+		}
+		if last {
+			close(query.results)
+		}
+	}
+	return nil
+}
+
+// complete returns the sent query of the result, removing it from the
+// queue if the result is its last.
+func (q *Queue) complete(r Result) (query *queuedQuery, last bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	query, ok := q.queries[r.QueryName]
+	if !ok || !query.sent {
+		return nil, false
+	}
+	last = r.Chunk == nil || r.Chunk.Index >= r.Chunk.Count-1
+	if last {
+		delete(q.queries, r.QueryName)
+	}
+	return query, last
+}
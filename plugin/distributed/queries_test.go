@@ -0,0 +1,103 @@
+package distributed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue()
+	procs, err := q.Enqueue("procs", "select pid from processes", WithAccelerate(1500*time.Millisecond))
+	require.NoError(t, err)
+	docker, err := q.Enqueue("docker", "select id from docker_containers", WithDiscovery("select 1 from processes where name = 'dockerd'"))
+	require.NoError(t, err)
+	canceled, err := q.Enqueue("canceled", "select 1")
+	require.NoError(t, err)
+
+	_, err = q.Enqueue("procs", "select 1")
+	assert.Error(t, err)
+	_, err = q.Enqueue("", "select 1")
+	assert.Error(t, err)
+
+	assert.True(t, q.Cancel("canceled"))
+	assert.False(t, q.Cancel("canceled"))
+	_, ok := <-canceled
+	assert.False(t, ok)
+	assert.Equal(t, 2, q.Len())
+
+	queries, err := q.GetQueries(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &GetQueriesResult{
+		Queries: map[string]string{
+			"procs":  "select pid from processes",
+			"docker": "select id from docker_containers",
+		},
+		Discovery:         map[string]string{"docker": "select 1 from processes where name = 'dockerd'"},
+		AccelerateSeconds: 2,
+	}, queries)
+	assert.Equal(t, 0, q.Len())
+	assert.False(t, q.Cancel("procs"))
+
+	require.NoError(t, q.WriteResults(context.Background(), []Result{
+		{QueryName: "procs", Rows: rowsOf("1")},
+		{QueryName: "unknown"},
+	}))
+	assert.Equal(t, Result{QueryName: "procs", Rows: rowsOf("1")}, <-procs)
+	_, ok = <-procs
+	assert.False(t, ok)
+
+	// The discovery query returned no rows, so docker got no results
+	queries, err = q.GetQueries(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &GetQueriesResult{Queries: map[string]string{}}, queries)
+	_, ok = <-docker
+	assert.False(t, ok)
+
+	// The name can be reused once completed
+	_, err = q.Enqueue("procs", "select 1")
+	assert.NoError(t, err)
+}
+
+func TestQueueChunks(t *testing.T) {
+	q := NewQueue()
+	results, err := q.Enqueue("q", "select 1")
+	require.NoError(t, err)
+
+	plugin := NewPlugin("mock", q.GetQueries, q.WriteResults, WithResultLimit(ResultLimit{MaxRows: 1}))
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.JSONEq(t, `{"queries":{"q":"select 1"}}`, resp.Response[0]["results"])
+
+	done := make(chan osquery.ExtensionResponse)
+	go func() {
+		done <- plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"q":[{"v":"1"},{"v":"2"}]},"statuses":{"q":0}}`})
+	}()
+	var rows []map[string]string
+	for r := range results {
+		rows = append(rows, r.Rows...)
+	}
+	assert.Equal(t, rowsOf("1", "2"), rows)
+	resp = <-done
+	assert.Equal(t, &StatusOK, resp.Status)
+}
+
+func TestQueueWriteResultsCanceled(t *testing.T) {
+	q := NewQueue()
+	results, err := q.Enqueue("q", "select 1")
+	require.NoError(t, err)
+	_, err = q.GetQueries(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	chunk := func(i int) Result { return Result{QueryName: "q", Chunk: &ResultChunk{Index: i, Count: 3}} }
+	// The first chunk is buffered, the second isn't received
+	require.NoError(t, q.WriteResults(context.Background(), []Result{chunk(0)}))
+	assert.Equal(t, context.Canceled, q.WriteResults(ctx, []Result{chunk(1)}))
+	assert.Equal(t, chunk(0), <-results)
+}
@@ -0,0 +1,69 @@
+package distributed
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/internal/diskqueue"
+)
+
+// ResultQueue is a durable FIFO queue of distributed results that could not
+// be written, stored as segment files in a directory, so that query answers
+// survive backend outages and restarts of the extension. It is safe for
+// concurrent use, but a directory must not be shared by several queues.
+type ResultQueue struct {
+	queue *diskqueue.Queue[Result]
+}
+
+// OpenResultQueue opens the queue stored in dir, creating the directory if
+// needed. If maxBytes is positive, the oldest segments are discarded when
+// the queue grows larger than maxBytes.
+func OpenResultQueue(dir string, maxBytes int64) (*ResultQueue, error) {
+	queue, err := diskqueue.Open[Result](dir, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultQueue{queue: queue}, nil
+}
+
+// Push adds the results of a writeResults request to the end of the queue,
+// as a single segment.
+func (q *ResultQueue) Push(results []Result) error {
+	return q.queue.Push(results)
+}
+
+// Len returns the number of queued writeResults requests.
+func (q *ResultQueue) Len() (int, error) {
+	return q.queue.Segments()
+}
+
+// Replay calls fn with the results of each segment, oldest first, removing
+// the segments for which fn succeeds. It stops at the first error, which is
+// returned, leaving the remaining segments in the queue. Segments that
+// can't be decoded are discarded.
+func (q *ResultQueue) Replay(ctx context.Context, fn WriteResultsFunc) error {
+	return q.queue.Replay(ctx, fn)
+}
+
+// WithResultQueue makes the plugin persist the results its WriteResultsFunc
+// fails to write in queue, instead of failing the writeResults request.
+// Queued results are written, oldest first, on the next check-ins of
+// osquery (getQueries and writeResults requests) once the WriteResultsFunc
+// succeeds again. Results may be written more than once if a later write of
+// the same replay fails. It has no effect on plugins created with
+// WithStreamingResults.
+func WithResultQueue(queue *ResultQueue) Option {
+	return func(p *Plugin) {
+		p.queue = queue
+	}
+}
+
+// writeQueued writes the results after the queued ones, queuing them if
+// that fails.
+func (t *Plugin) writeQueued(ctx context.Context, results []Result) error {
+	if err := t.queue.Replay(ctx, t.writeResults); err == nil {
+		if err := t.writeResults(ctx, results); err == nil {
+			return nil
+		}
+	}
+	return t.queue.Push(results)
+}
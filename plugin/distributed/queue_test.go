@@ -0,0 +1,116 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenResultQueue(dir, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Push([]Result{{QueryName: "a", Rows: []map[string]string{{"x": "1"}}}}))
+	require.NoError(t, q.Push([]Result{{QueryName: "b", Status: 1, Message: "no such table"}}))
+	require.NoError(t, q.Push(nil))
+	n, err := q.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// Reopening keeps the queue
+	q, err = OpenResultQueue(dir, 0)
+	require.NoError(t, err)
+
+	var written []string
+	fail := errors.New("backend down")
+	err = q.Replay(context.Background(), func(ctx context.Context, results []Result) error {
+		if results[0].QueryName == "b" {
+			return fail
+		}
+		written = append(written, results[0].QueryName)
+		return nil
+	})
+	assert.Equal(t, fail, err)
+	assert.Equal(t, []string{"a"}, written)
+
+	require.NoError(t, q.Replay(context.Background(), func(ctx context.Context, results []Result) error {
+		assert.Equal(t, []Result{{QueryName: "b", Status: 1, Message: "no such table"}}, results)
+		return nil
+	}))
+	n, err = q.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestResultQueueMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenResultQueue(dir, 150)
+	require.NoError(t, err)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, q.Push([]Result{{QueryName: name}}))
+	}
+	segments, err := filepath.Glob(filepath.Join(dir, "*.queue"))
+	require.NoError(t, err)
+	assert.Less(t, len(segments), 4)
+
+	var names []string
+	require.NoError(t, q.Replay(context.Background(), func(ctx context.Context, results []Result) error {
+		names = append(names, results[0].QueryName)
+		return nil
+	}))
+	assert.Equal(t, "d", names[len(names)-1])
+
+	// Undecodable segments are discarded
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000100.queue"), []byte("{"), 0600))
+	require.NoError(t, q.Replay(context.Background(), func(ctx context.Context, results []Result) error {
+		t.Fatal("unexpected results")
+		return nil
+	}))
+}
+
+func TestDistributedPluginResultQueue(t *testing.T) {
+	q, err := OpenResultQueue(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	down := true
+	var written [][]Result
+	plugin := NewPlugin("mock",
+		func(context.Context) (*GetQueriesResult, error) { return &GetQueriesResult{}, nil },
+		func(ctx context.Context, results []Result) error {
+			if down {
+				return errors.New("backend down")
+			}
+			written = append(written, results)
+			return nil
+		},
+		WithResultQueue(q),
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"q1":[{"x":"1"}]},"statuses":{"q1":0}}`})
+	require.Equal(t, &StatusOK, resp.Status)
+	n, err := q.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Replayed on the next check-in
+	down = false
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	require.Equal(t, &StatusOK, resp.Status)
+	require.Len(t, written, 1)
+	assert.Equal(t, "q1", written[0][0].QueryName)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"q2":[]},"statuses":{"q2":0}}`})
+	require.Equal(t, &StatusOK, resp.Status)
+	require.Len(t, written, 2)
+	assert.Equal(t, "q2", written[1][0].QueryName)
+	n, err = q.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
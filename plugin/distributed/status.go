@@ -0,0 +1,61 @@
+package distributed
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryError is the error of a distributed query that failed.
+type QueryError struct {
+	QueryName string
+	Status    int
+	Message   string
+	// Interrupted is true if osquery stopped running the query, see
+	// Result.Interrupted.
+	Interrupted bool
+}
+
+func (e *QueryError) Error() string {
+	kind := "failed"
+	if e.Interrupted {
+		kind = "interrupted"
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("query %s %s with status %d", e.QueryName, kind, e.Status)
+	}
+	return fmt.Sprintf("query %s %s with status %d: %s", e.QueryName, kind, e.Status, e.Message)
+}
+
+// Err returns a *QueryError if the query failed, and nil otherwise.
+func (r Result) Err() error {
+	if r.Status == 0 {
+		return nil
+	}
+	return &QueryError{
+		QueryName:   r.QueryName,
+		Status:      r.Status,
+		Message:     r.Message,
+		Interrupted: r.Interrupted,
+	}
+}
+
+// interruptedMessages are parts of the messages osquery reports for the
+// distributed queries it stopped running: queries running when the
+// watchdog killed the worker are reported as interrupted on the next
+// check-in, and then denylisted for a while (distributed_denylist_duration).
+var interruptedMessages = []string{"interrupted", "denylisted", "blacklisted"}
+
+// isInterrupted returns true if the status and message of a query show
+// that osquery stopped running it.
+func isInterrupted(status int, message string) bool {
+	if status == 0 {
+		return false
+	}
+	message = strings.ToLower(message)
+	for _, m := range interruptedMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package distributed
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultErr(t *testing.T) {
+	payload := `{"queries":{"ok":[{"x":"1"}],"bad":"","killed":""},"statuses":{"ok":0,"bad":1,"killed":1},"messages":{"bad":"no such table: foo","killed":"Distributed query has been interrupted"}}`
+
+	var rs ResultsStruct
+	require.NoError(t, json.Unmarshal([]byte(payload), &rs))
+	results, err := rs.toResults()
+	require.NoError(t, err)
+
+	var streamed []Result
+	require.NoError(t, StreamResults(payload, func(r Result) error {
+		streamed = append(streamed, r)
+		return nil
+	}))
+
+	for _, results := range [][]Result{results, streamed} {
+		byName := map[string]Result{}
+		for _, r := range results {
+			byName[r.QueryName] = r
+		}
+		require.Len(t, byName, 3)
+
+		assert.NoError(t, byName["ok"].Err())
+
+		assert.False(t, byName["bad"].Interrupted)
+		assert.EqualError(t, byName["bad"].Err(), "query bad failed with status 1: no such table: foo")
+
+		assert.True(t, byName["killed"].Interrupted)
+		var queryErr *QueryError
+		require.ErrorAs(t, byName["killed"].Err(), &queryErr)
+		assert.True(t, queryErr.Interrupted)
+		assert.Equal(t, "query killed interrupted with status 1: Distributed query has been interrupted", queryErr.Error())
+	}
+
+	assert.EqualError(t, Result{QueryName: "q", Status: 2}.Err(), "query q failed with status 2")
+}
@@ -0,0 +1,213 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteResultFunc writes the results of a single distributed query. See
+// WithStreamingResults.
+type WriteResultFunc func(ctx context.Context, result Result) error
+
+// WithStreamingResults makes the plugin write results with fn, one query
+// at a time as the results are decoded, instead of calling the
+// WriteResultsFunc with all the results at once. This bounds the memory
+// used for large payloads to the results of the largest query. The
+// WriteResultsFunc given to NewPlugin is not used and may be nil.
+func WithStreamingResults(fn WriteResultFunc) Option {
+	return func(p *Plugin) {
+		p.writeResult = fn
+	}
+}
+
+// StreamResults decodes the results written by osquery (the results field
+// of writeResults requests), calling fn with the results of each query as
+// they are decoded. Like the WriteResultsFunc, fn is called for each query
+// with a status, and not for queries with results but no status. Decoding
+// stops at the first error of fn, which is returned.
+func StreamResults(data string, fn func(Result) error) error {
+	meta, err := decodeResultsMeta(data)
+	if err != nil {
+		return err
+	}
+
+	written := map[string]bool{}
+	write := func(name string, rows []map[string]string) error {
+		status, ok := meta.Statuses[name]
+		if !ok {
+			return nil
+		}
+		written[name] = true
+		result := Result{
+			QueryName: name,
+			Status:    int(status),
+			Rows:      rows,
+			Message:   meta.Messages[name],
+		}
+		if stats, ok := meta.Stats[name]; ok {
+			result.QueryStats = &stats
+		}
+		result.Interrupted = isInterrupted(result.Status, result.Message)
+		return fn(result)
+	}
+
+	dec := json.NewDecoder(strings.NewReader(data))
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "queries" {
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := decodeQueries(dec, write); err != nil {
+			return err
+		}
+	}
+
+	// Queries with a status but no results
+	for _, name := range sortedNames(meta.Statuses) {
+		if !written[name] {
+			if err := write(name, []map[string]string{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resultsMeta are the fields of the results other than the queries.
+type resultsMeta struct {
+	Statuses map[string]OsqueryInt `json:"statuses"`
+	Stats    map[string]Stats      `json:"stats"`
+	Messages map[string]string     `json:"messages"`
+}
+
+// decodeResultsMeta decodes the fields of the results other than the
+// queries, skipping the queries.
+func decodeResultsMeta(data string) (resultsMeta, error) {
+	var meta resultsMeta
+	dec := json.NewDecoder(strings.NewReader(data))
+	if err := expectDelim(dec, '{'); err != nil {
+		return meta, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return meta, err
+		}
+		switch key {
+		case "statuses":
+			err = dec.Decode(&meta.Statuses)
+		case "stats":
+			err = dec.Decode(&meta.Stats)
+		case "messages":
+			err = dec.Decode(&meta.Messages)
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return meta, err
+		}
+	}
+	return meta, nil
+}
+
+// decodeQueries decodes the queries object, calling write with the rows of
+// each query.
+func decodeQueries(dec *json.Decoder, write func(name string, rows []map[string]string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, _ := tok.(string)
+
+		// Queries without results may be an empty string instead of an
+		// array
+		rows := []map[string]string{}
+		tok, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('['):
+			for dec.More() {
+				var row map[string]string
+				if err := dec.Decode(&row); err != nil {
+					return fmt.Errorf("invalid row for query %q: %w", name, err)
+				}
+				rows = append(rows, row)
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+		default:
+			if _, ok := tok.(string); !ok {
+				return fmt.Errorf("results for %q unknown type", name)
+			}
+		}
+		if err := write(name, rows); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// sortedNames returns the query names of the map in order.
+func sortedNames[V any](queries map[string]V) []string {
+	names := make([]string, 0, len(queries))
+	for name := range queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %s, got %v", delim, tok)
+	}
+	return nil
+}
+
+// skipValue skips the next value of the decoder.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamResults(t *testing.T) {
+	payloads := []string{
+		`{"queries":{"query1":[{"iso_8601":"2017-07-10T22:08:40Z"}],"query2":[{"version":"2.4.0"}]},"statuses":{"query1":"0","query2":"0","query3":"1"},"stats":{"query1":{"wall_time_ms":1,"user_time":1,"system_time":1,"memory":1}},"messages":{"query3":"no such table: bar"}}`,
+		`{"statuses":{"a":0,"b":0},"messages":{},"queries":{"a":"","b":[{"x":"1"},{"x":"2"}],"c":[{"y":"1"}]},"extra":{"nested":[1,{"z":[]}]}}`,
+		`{"queries":{},"statuses":{}}`,
+	}
+	for _, payload := range payloads {
+		var rs ResultsStruct
+		require.NoError(t, json.Unmarshal([]byte(payload), &rs))
+		expected, err := rs.toResults()
+		require.NoError(t, err)
+
+		var streamed []Result
+		require.NoError(t, StreamResults(payload, func(r Result) error {
+			streamed = append(streamed, r)
+			return nil
+		}))
+
+		sort.Slice(expected, func(i, j int) bool { return expected[i].QueryName < expected[j].QueryName })
+		sort.Slice(streamed, func(i, j int) bool { return streamed[i].QueryName < streamed[j].QueryName })
+		assert.Equal(t, expected, streamed, payload)
+	}
+}
+
+func TestStreamResultsErrors(t *testing.T) {
+	noop := func(Result) error { return nil }
+	assert.Error(t, StreamResults(`[]`, noop))
+	assert.Error(t, StreamResults(`{"queries":{"a":[{"x":1}]},"statuses":{"a":0}}`, noop))
+	assert.Error(t, StreamResults(`{"queries":{"a":5},"statuses":{"a":0}}`, noop))
+	assert.Error(t, StreamResults(`{"queries":{"a":[{"x":"1"}`, noop))
+	assert.Error(t, StreamResults(`{"statuses":{"a":"x"}}`, noop))
+
+	// Errors of the callback stop decoding
+	var calls int
+	err := StreamResults(`{"queries":{"a":[],"b":[]},"statuses":{"a":0,"b":0}}`, func(Result) error {
+		calls++
+		return errors.New("disk full")
+	})
+	assert.EqualError(t, err, "disk full")
+	assert.Equal(t, 1, calls)
+}
+
+func TestDistributedPluginStreamingResults(t *testing.T) {
+	var results []Result
+	fail := false
+	plugin := NewPlugin("mock",
+		func(context.Context) (*GetQueriesResult, error) { return &GetQueriesResult{}, nil },
+		nil,
+		WithStreamingResults(func(ctx context.Context, result Result) error {
+			if fail {
+				return errors.New("disk full")
+			}
+			results = append(results, result)
+			return nil
+		}),
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"query1":[{"version":"5.9.1"}]},"statuses":{"query1":"0"}}`})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, []Result{{QueryName: "query1", Rows: []map[string]string{{"version": "5.9.1"}}}}, results)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":`})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "error unmarshalling results")
+
+	fail = true
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"query1":[]},"statuses":{"query1":"0"}}`})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error writing results: disk full", resp.Status.Message)
+}
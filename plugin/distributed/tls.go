@@ -0,0 +1,297 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TLSServerConfig configures a distributed plugin bridging to a server
+// implementing the osquery TLS distributed protocol, such as a fleet
+// manager.
+type TLSServerConfig struct {
+	// URL is the base URL of the server, such as https://fleet.example.com.
+	URL string
+	// EnrollEndpoint, ReadEndpoint and WriteEndpoint are the paths of the
+	// endpoints, as set with the enroll_tls_endpoint,
+	// distributed_tls_read_endpoint and distributed_tls_write_endpoint
+	// osquery flags. Empty means the endpoints of Fleet, under
+	// /api/v1/osquery.
+	EnrollEndpoint string
+	ReadEndpoint   string
+	WriteEndpoint  string
+	// NodeKey is the node key of the host. If empty, or when the server
+	// reports it as invalid, the host enrolls with EnrollSecret and
+	// HostIdentifier to get a new one.
+	NodeKey        string
+	EnrollSecret   string
+	HostIdentifier string
+	// HostDetails are sent when enrolling, such as the system_info and
+	// os_version rows of the host, keyed by table name.
+	HostDetails map[string]map[string]string
+	// TLSConfig configures TLS, for example with a client certificate or
+	// the CA of the server (as with the tls_server_certs flag). If nil,
+	// the default TLS configuration is used.
+	TLSConfig *tls.Config
+	// MaxRetries is the number of times a failed request is retried, with
+	// exponential backoff starting at RetryBackoff (zero means 1 second).
+	// Requests rejected with a 4xx status other than 429 are not retried.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// Timeout limits the duration of each request. Zero means 30 seconds.
+	Timeout time.Duration
+	// OnNodeKey, if set, is called with the node key obtained by
+	// enrolling, so that it can be saved for the next runs.
+	OnNodeKey func(nodeKey string)
+}
+
+// NewTLSPlugin creates a distributed plugin reading the distributed
+// queries from, and writing their results to, a server implementing the
+// osquery TLS distributed protocol, so that extension-based distributed
+// queries can be pointed at an existing fleet manager. The plugin
+// authenticates with the node key of the host, enrolling when it has none
+// or the server reports it as invalid.
+func NewTLSPlugin(name string, config TLSServerConfig, opts ...Option) (*Plugin, error) {
+	if config.URL == "" {
+		return nil, errors.New("TLS distributed plugin requires a URL")
+	}
+	if config.NodeKey == "" && config.EnrollSecret == "" {
+		return nil, errors.New("TLS distributed plugin requires a node key or an enroll secret")
+	}
+	if config.EnrollEndpoint == "" {
+		config.EnrollEndpoint = "/api/v1/osquery/enroll"
+	}
+	if config.ReadEndpoint == "" {
+		config.ReadEndpoint = "/api/v1/osquery/distributed/read"
+	}
+	if config.WriteEndpoint == "" {
+		config.WriteEndpoint = "/api/v1/osquery/distributed/write"
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig
+	}
+	r := &tlsRemote{
+		config:  config,
+		client:  &http.Client{Transport: transport, Timeout: config.Timeout},
+		sleep:   sleepContext,
+		nodeKey: config.NodeKey,
+	}
+	return NewPlugin(name, r.getQueries, r.writeResults, opts...), nil
+}
+
+// tlsRemote is a client of an osquery TLS server.
+type tlsRemote struct {
+	config TLSServerConfig
+	client *http.Client
+	sleep  func(ctx context.Context, d time.Duration) error
+
+	mutex   sync.Mutex
+	nodeKey string
+}
+
+// errNodeInvalid is returned for requests rejected because of the node key.
+var errNodeInvalid = errors.New("node key invalid")
+
+func (r *tlsRemote) getQueries(ctx context.Context) (*GetQueriesResult, error) {
+	var resp struct {
+		GetQueriesResult
+		NodeInvalid bool `json:"node_invalid"`
+	}
+	err := r.withNodeKey(ctx, func(nodeKey string) error {
+		resp.NodeInvalid = false
+		if err := r.post(ctx, r.config.ReadEndpoint, map[string]string{"node_key": nodeKey}, &resp); err != nil {
+			return err
+		}
+		if resp.NodeInvalid {
+			return errNodeInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "reading distributed queries")
+	}
+	if resp.Queries == nil {
+		resp.Queries = map[string]string{}
+	}
+	return &resp.GetQueriesResult, nil
+}
+
+// tlsWriteRequest is the body of write requests.
+type tlsWriteRequest struct {
+	NodeKey  string                         `json:"node_key"`
+	Queries  map[string][]map[string]string `json:"queries"`
+	Statuses map[string]int                 `json:"statuses"`
+	Messages map[string]string              `json:"messages"`
+	Stats    map[string]Stats               `json:"stats,omitempty"`
+}
+
+func (r *tlsRemote) writeResults(ctx context.Context, results []Result) error {
+	req := tlsWriteRequest{
+		Queries:  make(map[string][]map[string]string, len(results)),
+		Statuses: make(map[string]int, len(results)),
+		Messages: map[string]string{},
+	}
+	for _, result := range results {
+		req.Queries[result.QueryName] = result.Rows
+		req.Statuses[result.QueryName] = result.Status
+		if result.Message != "" {
+			req.Messages[result.QueryName] = result.Message
+		}
+		if result.QueryStats != nil {
+			if req.Stats == nil {
+				req.Stats = map[string]Stats{}
+			}
+			req.Stats[result.QueryName] = *result.QueryStats
+		}
+	}
+
+	err := r.withNodeKey(ctx, func(nodeKey string) error {
+		req.NodeKey = nodeKey
+		var resp struct {
+			NodeInvalid bool `json:"node_invalid"`
+		}
+		if err := r.post(ctx, r.config.WriteEndpoint, req, &resp); err != nil {
+			return err
+		}
+		if resp.NodeInvalid {
+			return errNodeInvalid
+		}
+		return nil
+	})
+	return errors.Wrap(err, "writing distributed results")
+}
+
+// withNodeKey calls fn with the node key, enrolling first if there is
+// none, and enrolling again if fn returns errNodeInvalid.
+func (r *tlsRemote) withNodeKey(ctx context.Context, fn func(nodeKey string) error) error {
+	r.mutex.Lock()
+	nodeKey := r.nodeKey
+	r.mutex.Unlock()
+
+	if nodeKey != "" {
+		err := fn(nodeKey)
+		if err != errNodeInvalid || r.config.EnrollSecret == "" {
+			return err
+		}
+	}
+	nodeKey, err := r.enroll(ctx, nodeKey)
+	if err != nil {
+		return err
+	}
+	return fn(nodeKey)
+}
+
+// enroll gets a new node key, unless another request already replaced the
+// invalid one.
+func (r *tlsRemote) enroll(ctx context.Context, invalid string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.nodeKey != invalid {
+		return r.nodeKey, nil
+	}
+
+	var resp struct {
+		NodeKey     string `json:"node_key"`
+		NodeInvalid bool   `json:"node_invalid"`
+	}
+	req := map[string]interface{}{
+		"enroll_secret":   r.config.EnrollSecret,
+		"host_identifier": r.config.HostIdentifier,
+	}
+	if r.config.HostDetails != nil {
+		req["host_details"] = r.config.HostDetails
+	}
+	if err := r.post(ctx, r.config.EnrollEndpoint, req, &resp); err != nil {
+		return "", errors.Wrap(err, "enrolling")
+	}
+	if resp.NodeInvalid || resp.NodeKey == "" {
+		return "", errors.New("enrolling: enrollment rejected")
+	}
+	r.nodeKey = resp.NodeKey
+	if r.config.OnNodeKey != nil {
+		r.config.OnNodeKey(resp.NodeKey)
+	}
+	return resp.NodeKey, nil
+}
+
+// post sends a JSON request to an endpoint, retrying failures with
+// exponential backoff, and decodes the JSON response into out.
+func (r *tlsRemote) post(ctx context.Context, endpoint string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "encoding request")
+	}
+	url := strings.TrimSuffix(r.config.URL, "/") + endpoint
+
+	backoff := r.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := r.postOnce(ctx, url, data, out)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= r.config.MaxRetries {
+			return err
+		}
+		if err := r.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// postOnce sends a request, returning whether it may be retried if it
+// failed.
+func (r *tlsRemote) postOnce(ctx context.Context, url string, data []byte, out interface{}) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return false, errors.Wrap(err, "creating request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, errors.Wrap(err, "sending request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, errors.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return false, errors.Wrap(err, "decoding response")
+	}
+	return false, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,133 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fleetServer is a minimal osquery TLS server.
+type fleetServer struct {
+	mutex    sync.Mutex
+	nodeKey  string
+	enrolls  int
+	failures int
+	writes   []map[string]interface{}
+}
+
+func (s *fleetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/api/v1/osquery/enroll":
+		if req["enroll_secret"] != "secret" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"node_invalid": true})
+			return
+		}
+		s.enrolls++
+		s.nodeKey = "key-" + string(rune('0'+s.enrolls))
+		json.NewEncoder(w).Encode(map[string]interface{}{"node_key": s.nodeKey})
+	case "/api/v1/osquery/distributed/read":
+		if req["node_key"] != s.nodeKey {
+			json.NewEncoder(w).Encode(map[string]interface{}{"node_invalid": true})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"queries":    map[string]string{"q1": "SELECT * FROM osquery_info"},
+			"accelerate": 60,
+		})
+	case "/api/v1/osquery/distributed/write":
+		if req["node_key"] != s.nodeKey {
+			json.NewEncoder(w).Encode(map[string]interface{}{"node_invalid": true})
+			return
+		}
+		s.writes = append(s.writes, req)
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestTLSPlugin(t *testing.T) {
+	s := &fleetServer{nodeKey: "key-0"}
+	srv := httptest.NewTLSServer(s)
+	defer srv.Close()
+
+	var saved []string
+	plugin, err := NewTLSPlugin("tls", TLSServerConfig{
+		URL:            srv.URL,
+		NodeKey:        "stale",
+		EnrollSecret:   "secret",
+		HostIdentifier: "host-1",
+		TLSConfig:      srv.Client().Transport.(*http.Transport).TLSClientConfig,
+		MaxRetries:     2,
+		RetryBackoff:   time.Millisecond,
+		OnNodeKey:      func(nodeKey string) { saved = append(saved, nodeKey) },
+	})
+	require.NoError(t, err)
+
+	// The stale node key is replaced by enrolling
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.JSONEq(t, `{"queries": {"q1": "SELECT * FROM osquery_info"}, "accelerate": 60}`, resp.Response[0]["results"])
+	assert.Equal(t, []string{"key-1"}, saved)
+
+	// Failures are retried
+	s.failures = 2
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "writeResults", "results": `{"queries":{"q1":[{"version":"5.9.1"}]},"statuses":{"q1":0},"stats":{"q1":{"wall_time_ms":5}}}`})
+	require.Equal(t, &StatusOK, resp.Status)
+	require.Len(t, s.writes, 1)
+	assert.Equal(t, map[string]interface{}{
+		"node_key": "key-1",
+		"queries":  map[string]interface{}{"q1": []interface{}{map[string]interface{}{"version": "5.9.1"}}},
+		"statuses": map[string]interface{}{"q1": float64(0)},
+		"messages": map[string]interface{}{},
+		"stats":    map[string]interface{}{"q1": map[string]interface{}{"wall_time_ms": float64(5), "user_time": float64(0), "system_time": float64(0), "memory": float64(0)}},
+	}, s.writes[0])
+
+	// Too many failures
+	s.failures = 3
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "503")
+	assert.Equal(t, 1, s.enrolls)
+}
+
+func TestTLSPluginErrors(t *testing.T) {
+	_, err := NewTLSPlugin("tls", TLSServerConfig{NodeKey: "key"})
+	assert.Error(t, err)
+	_, err = NewTLSPlugin("tls", TLSServerConfig{URL: "https://fleet.example.com"})
+	assert.Error(t, err)
+
+	s := &fleetServer{}
+	srv := httptest.NewTLSServer(s)
+	defer srv.Close()
+	plugin, err := NewTLSPlugin("tls", TLSServerConfig{
+		URL:          srv.URL,
+		EnrollSecret: "wrong",
+		TLSConfig:    srv.Client().Transport.(*http.Transport).TLSClientConfig,
+	})
+	require.NoError(t, err)
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error getting queries: reading distributed queries: enrolling: enrollment rejected", resp.Status.Message)
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+)
+
+// Compressor compresses the payloads of the forwarding loggers, such as
+// HTTPForwarder and the Pub/Sub logger. The package provides gzip with
+// NewGzipCompressor. Other codings can be added by implementing the
+// interface, for example zstd with github.com/klauspost/compress/zstd:
+//
+//	type zstdCompressor struct{ enc *zstd.Encoder }
+//
+//	func (zstdCompressor) Encoding() string { return "zstd" }
+//
+//	func (c zstdCompressor) Compress(data []byte) ([]byte, error) {
+//		return c.enc.EncodeAll(data, nil), nil
+//	}
+type Compressor interface {
+	// Encoding is the HTTP content coding of the compressed data, such
+	// as "gzip" or "zstd".
+	Encoding() string
+	// Compress returns the compressed data.
+	Compress(data []byte) ([]byte, error)
+}
+
+// NewGzipCompressor returns a Compressor using gzip at the given level, such
+// as gzip.BestSpeed or gzip.DefaultCompression.
+func NewGzipCompressor(level int) Compressor {
+	return gzipCompressor{level: level}
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (c gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// negotiateCompressor returns the index of the compressor to use after a
+// server rejected the encoding of compressors[current] (or no encoding, if
+// current is -1), or -1 for no compression. acceptEncoding is the
+// Accept-Encoding header of the rejection, as in RFC 7694. The boolean
+// result is false if there is nothing else to try.
+func negotiateCompressor(compressors []Compressor, current int, acceptEncoding string) (int, bool) {
+	if acceptEncoding == "" {
+		// Try the next preferred compressor, then no compression
+		if current < 0 {
+			return -1, false
+		}
+		if current+1 < len(compressors) {
+			return current + 1, true
+		}
+		return -1, true
+	}
+
+	accepted := map[string]bool{}
+	for _, enc := range strings.Split(acceptEncoding, ",") {
+		if i := strings.Index(enc, ";"); i >= 0 {
+			enc = enc[:i]
+		}
+		accepted[strings.ToLower(strings.TrimSpace(enc))] = true
+	}
+	for i, c := range compressors {
+		if i != current && accepted[c.Encoding()] {
+			return i, true
+		}
+	}
+	return -1, current >= 0
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCompressor "compresses" by prefixing the data with its encoding.
+type fakeCompressor string
+
+func (c fakeCompressor) Encoding() string {
+	return string(c)
+}
+
+func (c fakeCompressor) Compress(data []byte) ([]byte, error) {
+	return append([]byte(c+":"), data...), nil
+}
+
+func TestGzipCompressor(t *testing.T) {
+	c := NewGzipCompressor(gzip.BestSpeed)
+	assert.Equal(t, "gzip", c.Encoding())
+	compressed, err := c.Compress([]byte("hello"))
+	require.NoError(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	_, err = NewGzipCompressor(42).Compress([]byte("hello"))
+	assert.Error(t, err)
+}
+
+func TestNegotiateCompressor(t *testing.T) {
+	compressors := []Compressor{fakeCompressor("zstd"), fakeCompressor("gzip")}
+
+	next, ok := negotiateCompressor(compressors, 0, "")
+	assert.Equal(t, 1, next)
+	assert.True(t, ok)
+	next, ok = negotiateCompressor(compressors, 1, "")
+	assert.Equal(t, -1, next)
+	assert.True(t, ok)
+	_, ok = negotiateCompressor(compressors, -1, "")
+	assert.False(t, ok)
+
+	next, ok = negotiateCompressor(compressors, 0, "br, GZIP;q=0.5")
+	assert.Equal(t, 1, next)
+	assert.True(t, ok)
+	next, ok = negotiateCompressor(compressors, -1, "zstd")
+	assert.Equal(t, 0, next)
+	assert.True(t, ok)
+	next, ok = negotiateCompressor(compressors, 0, "identity")
+	assert.Equal(t, -1, next)
+	assert.True(t, ok)
+}
@@ -0,0 +1,424 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPConfig configures an HTTPForwarder.
+type HTTPConfig struct {
+	// URL is the endpoint the logs are POSTed to.
+	URL string
+	// TLSConfig configures TLS, for example with a client certificate for
+	// mutual TLS (see LoadClientTLSConfig). If nil, the default TLS
+	// configuration is used.
+	TLSConfig *tls.Config
+	// Header holds additional headers sent with each request, for
+	// example an Authorization header.
+	Header http.Header
+	// Compression lists the compressors of the request bodies, in order
+	// of preference. The first one is used until the endpoint rejects its
+	// encoding with 415 Unsupported Media Type, in which case the
+	// forwarder switches to an encoding listed in the Accept-Encoding
+	// header of the response (RFC 7694), or to the next compressor, and
+	// eventually to no compression.
+	Compression []Compressor
+	// Gzip is a shorthand for compressing with gzip, when Compression is
+	// empty.
+	Gzip bool
+	// BatchSize is the number of logs sent in a single request. Zero
+	// means 100.
+	BatchSize int
+	// FlushInterval is how often buffered logs are sent when the batch is
+	// not full. Zero means 5 seconds.
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a failed request is retried, with
+	// exponential backoff starting at RetryBackoff (zero means 1 second).
+	// Requests rejected with a 4xx status other than 429 are not retried.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// SpillDir, if set, is the directory of a DiskQueue where batches
+	// that could not be sent are written, to be sent again once the
+	// endpoint recovers (including after a restart of the extension).
+	// Without it, batches that could not be sent are dropped.
+	SpillDir string
+	// MaxSpillBytes limits the size of the spilled batches, see
+	// OpenDiskQueue. Zero means no limit.
+	MaxSpillBytes int64
+	// MaxPending is the maximum number of logs buffered in memory while
+	// waiting to be sent, for example while the endpoint is down. When it
+	// is exceeded, the oldest batch is spilled to SpillDir, or dropped and
+	// reported to OnError without it. Zero means 100 times BatchSize.
+	MaxPending int
+	// OnError, if set, is called with the errors of background flushes,
+	// and when buffered logs are dropped.
+	OnError func(err error)
+}
+
+// HTTPForwarder sends osquery logs to a remote endpoint in batches. Each
+// request is a JSON object of the form {"logs": [{"type": "string", "log":
+// "..."}, ...]}. Use it as the LogFunc of a logger plugin, or create the
+// plugin with NewHTTPPlugin.
+type HTTPForwarder struct {
+	config HTTPConfig
+	client *http.Client
+	sleep  func(ctx context.Context, d time.Duration) error
+	spill  *DiskQueue
+
+	// compressor is the index in config.Compression of the compressor of
+	// the requests, or -1 for none. It is guarded by sendMutex.
+	compressor int
+
+	mutex   sync.Mutex
+	pending []QueuedLog
+
+	// sendMutex serializes flushes.
+	sendMutex sync.Mutex
+
+	flushNow  chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type httpLogEntry struct {
+	Type string `json:"type"`
+	Log  string `json:"log"`
+}
+
+type httpLogBatch struct {
+	Logs []httpLogEntry `json:"logs"`
+}
+
+// NewHTTPForwarder creates an HTTPForwarder and starts flushing logs in the
+// background. Close must be called to flush the remaining logs and stop it.
+func NewHTTPForwarder(config HTTPConfig) (*HTTPForwarder, error) {
+	if config.URL == "" {
+		return nil, errors.New("HTTP logger requires a URL")
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.MaxPending <= 0 {
+		config.MaxPending = 100 * config.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Second
+	}
+	if len(config.Compression) == 0 && config.Gzip {
+		config.Compression = []Compressor{NewGzipCompressor(gzip.DefaultCompression)}
+	}
+	var spill *DiskQueue
+	if config.SpillDir != "" {
+		var err error
+		if spill, err = OpenDiskQueue(config.SpillDir, config.MaxSpillBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.TLSConfig != nil {
+		transport.TLSClientConfig = config.TLSConfig
+	}
+	f := &HTTPForwarder{
+		config:     config,
+		client:     &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		sleep:      sleepContext,
+		spill:      spill,
+		compressor: -1,
+		flushNow:   make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if len(config.Compression) > 0 {
+		f.compressor = 0
+	}
+	go f.run()
+	return f, nil
+}
+
+// NewHTTPPlugin creates a logger plugin that forwards the logs with an
+// HTTPForwarder. The forwarder is closed when the plugin is shut down.
+func NewHTTPPlugin(name string, config HTTPConfig, opts ...Option) (*Plugin, error) {
+	f, err := NewHTTPForwarder(config)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPlugin(name, f.Log, opts...)
+	p.shutdown = func() { f.Close() }
+	return p, nil
+}
+
+// LoadClientTLSConfig returns a TLS configuration for mutual TLS, with the
+// client certificate and key read from the PEM files certFile and keyFile.
+// If caFile is not empty, the server certificate is verified with the CA
+// certificates it holds instead of the system roots.
+func LoadClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading client certificate")
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading CA certificates")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// Log buffers a log to be sent with the next batch. Beyond MaxPending
+// buffered logs, the oldest batch is spilled to disk, or dropped without
+// SpillDir. It implements LogFunc.
+func (f *HTTPForwarder) Log(ctx context.Context, typ LogType, log string) error {
+	var overflow []QueuedLog
+	f.mutex.Lock()
+	f.pending = append(f.pending, QueuedLog{Type: typ, Log: log})
+	if excess := len(f.pending) - f.config.MaxPending; excess > 0 {
+		// Remove a whole batch at once, so that the buffer is only
+		// shifted once every BatchSize logs.
+		n := f.config.BatchSize
+		if n < excess || n > f.config.MaxPending {
+			n = excess
+		}
+		overflow = append([]QueuedLog(nil), f.pending[:n]...)
+		f.pending = f.pending[:copy(f.pending, f.pending[n:])]
+	}
+	full := len(f.pending) >= f.config.BatchSize
+	f.mutex.Unlock()
+
+	if len(overflow) > 0 {
+		f.overflow(overflow)
+	}
+	if full {
+		select {
+		case f.flushNow <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush sends the buffered logs, after the batches spilled to disk by
+// previous failures. If sending fails, the logs are spilled to disk (when
+// SpillDir is set) or dropped, and the error is returned.
+func (f *HTTPForwarder) Flush(ctx context.Context) error {
+	f.sendMutex.Lock()
+	defer f.sendMutex.Unlock()
+
+	f.mutex.Lock()
+	pending := f.pending
+	f.pending = nil
+	f.mutex.Unlock()
+
+	if f.spill != nil {
+		if err := f.spill.Replay(ctx, f.send); err != nil {
+			if len(pending) > 0 {
+				return f.spillLogs(pending, err)
+			}
+			return err
+		}
+	}
+
+	for len(pending) > 0 {
+		n := len(pending)
+		if n > f.config.BatchSize {
+			n = f.config.BatchSize
+		}
+		if err := f.send(ctx, pending[:n]); err != nil {
+			return f.spillLogs(pending, err)
+		}
+		pending = pending[n:]
+	}
+	return nil
+}
+
+// Close flushes the buffered logs and stops the background flushes.
+func (f *HTTPForwarder) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		close(f.stop)
+		<-f.done
+		ctx, cancel := context.WithTimeout(context.Background(), f.client.Timeout)
+		defer cancel()
+		err = f.Flush(ctx)
+	})
+	return err
+}
+
+func (f *HTTPForwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.config.FlushInterval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-f.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		case <-f.flushNow:
+		}
+		if err := f.Flush(ctx); err != nil && f.config.OnError != nil {
+			f.config.OnError(err)
+		}
+	}
+}
+
+// send POSTs a batch, retrying failures with exponential backoff.
+func (f *HTTPForwarder) send(ctx context.Context, logs []QueuedLog) error {
+	batch := httpLogBatch{Logs: make([]httpLogEntry, 0, len(logs))}
+	for _, l := range logs {
+		batch.Logs = append(batch.Logs, httpLogEntry{Type: l.Type.String(), Log: l.Log})
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return errors.Wrap(err, "encoding logs")
+	}
+
+	backoff := f.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, encoding := data, ""
+		if f.compressor >= 0 {
+			c := f.config.Compression[f.compressor]
+			if body, err = c.Compress(data); err != nil {
+				return errors.Wrapf(err, "compressing logs with %s", c.Encoding())
+			}
+			encoding = c.Encoding()
+		}
+
+		resp, err := f.post(ctx, body, encoding)
+		if err == nil {
+			return nil
+		}
+		if resp.status == http.StatusUnsupportedMediaType {
+			if next, ok := negotiateCompressor(f.config.Compression, f.compressor, resp.acceptEncoding); ok {
+				// Not a failure of the endpoint, try again right away
+				f.compressor = next
+				attempt--
+				continue
+			}
+		}
+		if !resp.retry || attempt >= f.config.MaxRetries {
+			return err
+		}
+		if err := f.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// postResult describes a failed request.
+type postResult struct {
+	status         int
+	acceptEncoding string
+	// retry is true if the request may be retried.
+	retry bool
+}
+
+// post sends a request with a body in the given content coding.
+func (f *HTTPForwarder) post(ctx context.Context, body []byte, encoding string) (postResult, error) {
+	req, err := http.NewRequest(http.MethodPost, f.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return postResult{}, errors.Wrap(err, "creating request")
+	}
+	req = req.WithContext(ctx)
+	for k, v := range f.config.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return postResult{retry: ctx.Err() == nil}, errors.Wrap(err, "sending logs")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		io.Copy(io.Discard, resp.Body)
+		return postResult{}, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return postResult{
+		status:         resp.StatusCode,
+		acceptEncoding: resp.Header.Get("Accept-Encoding"),
+		retry:          resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+	}, errors.Errorf("sending logs: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// spillLogs queues logs that could not be sent in the spill queue. It
+// returns sendErr, annotated with the outcome.
+// overflow spills or drops logs removed from the buffer beyond MaxPending.
+func (f *HTTPForwarder) overflow(logs []QueuedLog) {
+	err := errors.Errorf("more than %d logs pending", f.config.MaxPending)
+	if f.spill != nil {
+		if err = f.spill.Push(logs); err == nil {
+			return
+		}
+		err = errors.Wrap(err, "spilling")
+	}
+	if f.config.OnError != nil {
+		f.config.OnError(errors.Wrapf(err, "dropped %d logs", len(logs)))
+	}
+}
+
+func (f *HTTPForwarder) spillLogs(logs []QueuedLog, sendErr error) error {
+	if f.spill == nil {
+		return errors.Wrapf(sendErr, "dropped %d logs", len(logs))
+	}
+	if err := f.spill.Push(logs); err != nil {
+		return errors.Wrapf(sendErr, "dropped %d logs (spilling: %s)", len(logs), err)
+	}
+	return errors.Wrapf(sendErr, "spilled %d logs", len(logs))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
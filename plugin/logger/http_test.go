@@ -0,0 +1,258 @@
+package logger
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logServer records the batches POSTed by an HTTPForwarder.
+type logServer struct {
+	mutex   sync.Mutex
+	status  int
+	batches []httpLogBatch
+	posts   int
+}
+
+func (s *logServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.posts++
+	if s.status != 0 {
+		http.Error(w, "unavailable", s.status)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	var batch httpLogBatch
+	if err := json.NewDecoder(body).Decode(&batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.batches = append(s.batches, batch)
+}
+
+func (s *logServer) setStatus(status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status = status
+	s.posts = 0
+}
+
+func TestHTTPForwarder(t *testing.T) {
+	logs := &logServer{}
+	server := httptest.NewServer(logs)
+	defer server.Close()
+
+	plugin, err := NewHTTPPlugin("http", HTTPConfig{
+		URL:           server.URL,
+		Gzip:          true,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	plugin.Call(ctx, osquery.ExtensionPluginRequest{"string": "a"})
+	plugin.Call(ctx, osquery.ExtensionPluginRequest{"snapshot": "b"})
+	plugin.Call(ctx, osquery.ExtensionPluginRequest{"string": "c"})
+
+	// The full batch is sent in the background
+	assert.Eventually(t, func() bool {
+		logs.mutex.Lock()
+		defer logs.mutex.Unlock()
+		return len(logs.batches) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	// Shutdown flushes the rest
+	plugin.Shutdown()
+	var sent []httpLogEntry
+	for _, batch := range logs.batches {
+		sent = append(sent, batch.Logs...)
+	}
+	assert.Equal(t, []httpLogEntry{{"string", "a"}, {"snapshot", "b"}, {"string", "c"}}, sent)
+}
+
+func TestHTTPForwarderRetryAndSpill(t *testing.T) {
+	logs := &logServer{}
+	server := httptest.NewServer(logs)
+	defer server.Close()
+
+	spillDir := filepath.Join(t.TempDir(), "spill")
+	f, err := NewHTTPForwarder(HTTPConfig{
+		URL:           server.URL,
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+		SpillDir:      spillDir,
+	})
+	require.NoError(t, err)
+	defer f.Close()
+	var sleeps []time.Duration
+	f.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	ctx := context.Background()
+
+	// Server errors are retried with backoff, then the logs are spilled
+	logs.setStatus(http.StatusServiceUnavailable)
+	require.NoError(t, f.Log(ctx, LogTypeString, "a"))
+	err = f.Flush(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "spilled 1 logs")
+	assert.Equal(t, 3, logs.posts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, sleeps)
+	spilled, _ := filepath.Glob(filepath.Join(spillDir, "*.queue"))
+	assert.Len(t, spilled, 1)
+
+	// Client errors are not retried
+	logs.setStatus(http.StatusBadRequest)
+	require.NoError(t, f.Log(ctx, LogTypeString, "b"))
+	assert.Error(t, f.Flush(ctx))
+	assert.Equal(t, 1, logs.posts)
+
+	// Spilled logs are sent first once the server recovers
+	logs.setStatus(0)
+	require.NoError(t, f.Log(ctx, LogTypeString, "c"))
+	require.NoError(t, f.Flush(ctx))
+	require.Len(t, logs.batches, 3)
+	assert.Equal(t, []httpLogEntry{{"string", "a"}}, logs.batches[0].Logs)
+	assert.Equal(t, []httpLogEntry{{"string", "b"}}, logs.batches[1].Logs)
+	assert.Equal(t, []httpLogEntry{{"string", "c"}}, logs.batches[2].Logs)
+	spilled, _ = filepath.Glob(filepath.Join(spillDir, "*"))
+	assert.Empty(t, spilled)
+}
+
+func TestHTTPForwarderDrop(t *testing.T) {
+	logs := &logServer{status: http.StatusInternalServerError}
+	server := httptest.NewServer(logs)
+	defer server.Close()
+
+	f, err := NewHTTPForwarder(HTTPConfig{URL: server.URL, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	defer f.Close()
+
+	require.NoError(t, f.Log(context.Background(), LogTypeString, "a"))
+	assert.EqualError(t, f.Flush(context.Background()), "dropped 1 logs: sending logs: 500 Internal Server Error: unavailable")
+
+	_, err = NewHTTPForwarder(HTTPConfig{})
+	assert.Error(t, err)
+}
+
+func TestLoadClientTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadClientTLSConfig(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "")
+	assert.Error(t, err)
+}
+
+func TestHTTPForwarderNegotiateEncoding(t *testing.T) {
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		encodings = append(encodings, encoding)
+		if encoding == "zstd" {
+			w.Header().Set("Accept-Encoding", "gzip")
+			http.Error(w, "unsupported encoding", http.StatusUnsupportedMediaType)
+		}
+	}))
+	defer server.Close()
+
+	f, err := NewHTTPForwarder(HTTPConfig{
+		URL:           server.URL,
+		FlushInterval: time.Hour,
+		Compression:   []Compressor{fakeCompressor("zstd"), NewGzipCompressor(gzip.DefaultCompression)},
+	})
+	require.NoError(t, err)
+	defer f.Close()
+
+	ctx := context.Background()
+	require.NoError(t, f.Log(ctx, LogTypeString, "a"))
+	require.NoError(t, f.Flush(ctx))
+	require.NoError(t, f.Log(ctx, LogTypeString, "b"))
+	require.NoError(t, f.Flush(ctx))
+
+	// The negotiated encoding is remembered
+	assert.Equal(t, []string{"zstd", "gzip", "gzip"}, encodings)
+}
+
+func TestHTTPForwarderMaxPending(t *testing.T) {
+	logs := &logServer{}
+	server := httptest.NewServer(logs)
+	defer server.Close()
+
+	var errs []error
+	f, err := NewHTTPForwarder(HTTPConfig{
+		URL:           server.URL,
+		BatchSize:     10,
+		MaxPending:    2,
+		FlushInterval: time.Hour,
+		OnError:       func(err error) { errs = append(errs, err) },
+	})
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, log := range []string{"a", "b", "c"} {
+		require.NoError(t, f.Log(context.Background(), LogTypeString, log))
+	}
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "dropped 1 logs: more than 2 logs pending")
+
+	// The oldest log was dropped
+	require.NoError(t, f.Flush(context.Background()))
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+	require.Len(t, logs.batches, 1)
+	assert.Equal(t, []httpLogEntry{{"string", "b"}, {"string", "c"}}, logs.batches[0].Logs)
+}
+
+func TestHTTPForwarderMaxPendingSpill(t *testing.T) {
+	logs := &logServer{}
+	server := httptest.NewServer(logs)
+	defer server.Close()
+
+	spillDir := filepath.Join(t.TempDir(), "spill")
+	var errs []error
+	f, err := NewHTTPForwarder(HTTPConfig{
+		URL:           server.URL,
+		BatchSize:     10,
+		MaxPending:    2,
+		FlushInterval: time.Hour,
+		SpillDir:      spillDir,
+		OnError:       func(err error) { errs = append(errs, err) },
+	})
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, log := range []string{"a", "b", "c"} {
+		require.NoError(t, f.Log(context.Background(), LogTypeString, log))
+	}
+	assert.Empty(t, errs)
+	spilled, _ := filepath.Glob(filepath.Join(spillDir, "*.queue"))
+	assert.Len(t, spilled, 1)
+
+	// The overflow is sent from disk before the buffered logs
+	require.NoError(t, f.Flush(context.Background()))
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+	require.Len(t, logs.batches, 2)
+	assert.Equal(t, []httpLogEntry{{"string", "a"}}, logs.batches[0].Logs)
+	assert.Equal(t, []httpLogEntry{{"string", "b"}, {"string", "c"}}, logs.batches[1].Logs)
+}
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// journalSocket is the socket of the systemd journal native protocol.
+const journalSocket = "/run/systemd/journal/socket"
+
+// NewJournaldPlugin creates a logger plugin that writes the osquery logs to
+// the systemd journal with the given SYSLOG_IDENTIFIER. The priority of
+// status logs matches their osquery severity. Each entry has structured
+// fields describing the log: OSQUERY_LOG_TYPE, the source location of status
+// logs (CODE_FILE and CODE_LINE), and the query name, action and host
+// identifier of result logs (OSQUERY_QUERY_NAME, OSQUERY_ACTION and
+// OSQUERY_HOST_IDENTIFIER).
+func NewJournaldPlugin(name string, identifier string) (*Plugin, error) {
+	return newJournaldPlugin(name, identifier, journalSocket)
+}
+
+func newJournaldPlugin(name, identifier, socket string) (*Plugin, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to journald")
+	}
+	return NewPlugin(name, func(ctx context.Context, typ LogType, log string) error {
+		fields := logFields(typ, log)
+		fields["MESSAGE"] = logMessage(typ, log)
+		fields["PRIORITY"] = strconv.Itoa(journalPriority(logSeverity(typ, log)))
+		fields["SYSLOG_IDENTIFIER"] = identifier
+		_, err := conn.Write(encodeJournalEntry(fields))
+		return errors.Wrap(err, "writing to journald")
+	}), nil
+}
+
+// journalPriority returns the syslog priority of a severity.
+func journalPriority(s Severity) int {
+	switch s {
+	case SeverityWarning:
+		return 4
+	case SeverityError:
+		return 3
+	case SeverityFatal:
+		return 2
+	}
+	return 6
+}
+
+// encodeJournalEntry encodes the fields of an entry in the journal native
+// protocol. Values containing newlines are encoded with their length.
+func encodeJournalEntry(fields map[string]string) []byte {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		v := fields[k]
+		if !strings.Contains(v, "\n") {
+			buf.WriteString(k + "=" + v + "\n")
+			continue
+		}
+		buf.WriteString(k + "\n")
+		binary.Write(&buf, binary.LittleEndian, uint64(len(v)))
+		buf.WriteString(v + "\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournaldPlugin(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	plugin, err := newJournaldPlugin("journald", "osqueryd", socket)
+	require.NoError(t, err)
+
+	read := func() string {
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"status": "true", "log": `{"":{"s":"1","f":"events.cpp","i":"828","m":"publisher failed"}}`})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, "CODE_FILE=events.cpp\nCODE_LINE=828\nMESSAGE=events.cpp:828 publisher failed\nOSQUERY_LOG_TYPE=status\nPRIORITY=4\nSYSLOG_IDENTIFIER=osqueryd\n", read())
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"string": `{"name":"users","hostIdentifier":"host1","action":"added","columns":{}}`})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Contains(t, read(), "OSQUERY_ACTION=added\nOSQUERY_HOST_IDENTIFIER=host1\nOSQUERY_LOG_TYPE=string\nOSQUERY_QUERY_NAME=users\nPRIORITY=6\n")
+
+	_, err = newJournaldPlugin("journald", "osqueryd", filepath.Join(t.TempDir(), "missing.sock"))
+	assert.Error(t, err)
+}
+
+func TestEncodeJournalEntry(t *testing.T) {
+	assert.Equal(t,
+		"A=1\nMESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n",
+		string(encodeJournalEntry(map[string]string{"MESSAGE": "a\nb", "A": "1"})))
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 
 	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/traces"
 )
 
 // LogFunc is the logger function used by an osquery Logger plugin.
@@ -22,15 +23,27 @@ type LogFunc func(ctx context.Context, typ LogType, log string) error
 // Plugin is an osquery logger plugin.
 // The Plugin struct implements the OsqueryPlugin interface.
 type Plugin struct {
-	name  string
-	logFn LogFunc
+	name     string
+	logFn    LogFunc
+	statusFn LogStatusFunc
+	resultFn LogResultFunc
+
+	// shutdown is called by Shutdown, if set.
+	shutdown func()
 }
 
+// Option configures optional behavior of a logger Plugin.
+type Option func(*Plugin)
+
 // NewPlugin takes a value that implements LoggerPlugin and wraps it with
 // the appropriate methods to satisfy the OsqueryPlugin interface. Use this to
 // easily create plugins implementing osquery loggers.
-func NewPlugin(name string, fn LogFunc) *Plugin {
-	return &Plugin{name: name, logFn: fn}
+func NewPlugin(name string, fn LogFunc, opts ...Option) *Plugin {
+	p := &Plugin{name: name, logFn: fn}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (t *Plugin) Name() string {
@@ -49,12 +62,16 @@ func (t *Plugin) Ping() osquery.ExtensionStatus {
 	return osquery.ExtensionStatus{Code: 0, Message: "OK"}
 }
 
-func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) (resp osquery.ExtensionResponse) {
+	ctx, span := traces.StartSpan(ctx, "Logger.Call")
+	defer span.End()
+	defer func() { traces.RecordStatus(span, resp.Status) }()
+
 	var err error
 	if log, ok := request["string"]; ok {
-		err = t.logFn(ctx, LogTypeString, log)
+		err = t.logResult(ctx, LogTypeString, log)
 	} else if log, ok := request["snapshot"]; ok {
-		err = t.logFn(ctx, LogTypeSnapshot, log)
+		err = t.logResult(ctx, LogTypeSnapshot, log)
 	} else if log, ok := request["health"]; ok {
 		err = t.logFn(ctx, LogTypeHealth, log)
 	} else if log, ok := request["init"]; ok {
@@ -86,7 +103,22 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 		}
 
 		for _, s := range parsedStatuses {
-			err = t.logFn(ctx, LogTypeStatus, string(s))
+			if t.statusFn == nil {
+				err = t.logFn(ctx, LogTypeStatus, string(s))
+				continue
+			}
+			status, parseErr := ParseStatusLog(string(s))
+			if parseErr != nil {
+				return osquery.ExtensionResponse{
+					Status: &osquery.ExtensionStatus{
+						Code:    1,
+						Message: "error parsing status log: " + parseErr.Error(),
+					},
+				}
+			}
+			if err = t.statusFn(ctx, status); err != nil {
+				break
+			}
 		}
 	} else {
 		return osquery.ExtensionResponse{
@@ -112,7 +144,24 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 	}
 }
 
-func (t *Plugin) Shutdown() {}
+// logResult logs a scheduled query result, parsing it if the plugin was
+// created with WithResultFunc.
+func (t *Plugin) logResult(ctx context.Context, typ LogType, log string) error {
+	if t.resultFn == nil {
+		return t.logFn(ctx, typ, log)
+	}
+	result, err := ParseResultLog(log)
+	if err != nil {
+		return err
+	}
+	return t.resultFn(ctx, typ, result)
+}
+
+func (t *Plugin) Shutdown() {
+	if t.shutdown != nil {
+		t.shutdown()
+	}
+}
 
 // LogType encodes the type of log osquery is outputting.
 type LogType int
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Multi returns a LogFunc that writes every log to all of the given sinks,
+// so that a single logger plugin can send logs to several destinations. All
+// sinks are called even if some fail. The returned error reports the sinks
+// that failed; wrap a sink with IgnoreErrors if its failures should not fail
+// the log request.
+func Multi(fns ...LogFunc) LogFunc {
+	return func(ctx context.Context, typ LogType, log string) error {
+		var failures []string
+		for i, fn := range fns {
+			if err := fn(ctx, typ, log); err != nil {
+				failures = append(failures, "sink "+strconv.Itoa(i)+": "+err.Error())
+			}
+		}
+		if len(failures) > 0 {
+			return errors.New(strings.Join(failures, "; "))
+		}
+		return nil
+	}
+}
+
+// IgnoreErrors returns a LogFunc that calls fn and ignores its errors. If
+// onError is not nil, it is called with each error, for example to count or
+// report them.
+func IgnoreErrors(fn LogFunc, onError func(err error)) LogFunc {
+	return func(ctx context.Context, typ LogType, log string) error {
+		if err := fn(ctx, typ, log); err != nil && onError != nil {
+			onError(err)
+		}
+		return nil
+	}
+}
+
+// Route returns a LogFunc that sends each log to the sink registered for its
+// LogType in routes, or to fallback if there is none. Logs with no sink are
+// dropped, as are logs routed to Discard. For example, to send status logs
+// to one sink, results to another and drop health logs:
+//
+//	logger.Route(map[logger.LogType]logger.LogFunc{
+//		logger.LogTypeStatus:   statusSink,
+//		logger.LogTypeString:   resultSink,
+//		logger.LogTypeSnapshot: resultSink,
+//	}, nil)
+func Route(routes map[LogType]LogFunc, fallback LogFunc) LogFunc {
+	return func(ctx context.Context, typ LogType, log string) error {
+		fn, ok := routes[typ]
+		if !ok {
+			fn = fallback
+		}
+		if fn == nil {
+			return nil
+		}
+		return fn(ctx, typ, log)
+	}
+}
+
+// Discard is a LogFunc that drops all logs.
+func Discard(ctx context.Context, typ LogType, log string) error {
+	return nil
+}
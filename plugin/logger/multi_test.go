@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMulti(t *testing.T) {
+	var logs []string
+	ok := func(ctx context.Context, typ LogType, log string) error {
+		logs = append(logs, typ.String()+":"+log)
+		return nil
+	}
+	fail := func(ctx context.Context, typ LogType, log string) error {
+		return errors.New("unreachable")
+	}
+
+	assert.NoError(t, Multi(ok, ok)(context.Background(), LogTypeString, "a"))
+	assert.Equal(t, []string{"string:a", "string:a"}, logs)
+
+	// All sinks are called when one fails
+	logs = nil
+	err := Multi(fail, ok, fail)(context.Background(), LogTypeSnapshot, "b")
+	assert.EqualError(t, err, "sink 0: unreachable; sink 2: unreachable")
+	assert.Equal(t, []string{"snapshot:b"}, logs)
+
+	var ignored []error
+	err = Multi(ok, IgnoreErrors(fail, func(err error) { ignored = append(ignored, err) }))(context.Background(), LogTypeString, "c")
+	assert.NoError(t, err)
+	assert.Len(t, ignored, 1)
+	assert.NoError(t, IgnoreErrors(fail, nil)(context.Background(), LogTypeString, "d"))
+
+	assert.NoError(t, Multi()(context.Background(), LogTypeString, "e"))
+}
+
+func TestRoute(t *testing.T) {
+	var status, results, other []string
+	sink := func(logs *[]string) LogFunc {
+		return func(ctx context.Context, typ LogType, log string) error {
+			*logs = append(*logs, log)
+			return nil
+		}
+	}
+	ctx := context.Background()
+
+	logFn := Route(map[LogType]LogFunc{
+		LogTypeStatus:   sink(&status),
+		LogTypeString:   sink(&results),
+		LogTypeSnapshot: sink(&results),
+		LogTypeHealth:   Discard,
+	}, sink(&other))
+	for _, typ := range []LogType{LogTypeStatus, LogTypeString, LogTypeSnapshot, LogTypeHealth, LogTypeInit} {
+		assert.NoError(t, logFn(ctx, typ, typ.String()))
+	}
+	assert.Equal(t, []string{"status"}, status)
+	assert.Equal(t, []string{"string", "snapshot"}, results)
+	assert.Equal(t, []string{"init"}, other)
+
+	// Without a fallback, unrouted logs are dropped
+	results = nil
+	logFn = Route(map[LogType]LogFunc{LogTypeString: sink(&results)}, nil)
+	assert.NoError(t, logFn(ctx, LogTypeInit, "init"))
+	assert.NoError(t, logFn(ctx, LogTypeString, "string"))
+	assert.Equal(t, []string{"string"}, results)
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// PubSubMessage is a message published by a Pub/Sub logger plugin.
+type PubSubMessage struct {
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+}
+
+// PubSubPublisher publishes messages to a Google Cloud Pub/Sub topic. It
+// keeps this package free of the Cloud SDK dependency. With
+// cloud.google.com/go/pubsub, it can be implemented as:
+//
+//	type topicPublisher struct{ topic *pubsub.Topic }
+//
+//	func (p topicPublisher) Publish(ctx context.Context, msg logger.PubSubMessage) error {
+//		_, err := p.topic.Publish(ctx, &pubsub.Message{
+//			Data:        msg.Data,
+//			Attributes:  msg.Attributes,
+//			OrderingKey: msg.OrderingKey,
+//		}).Get(ctx)
+//		return err
+//	}
+//
+// Note that the topic must have EnableMessageOrdering set for ordering keys
+// to be used.
+type PubSubPublisher interface {
+	Publish(ctx context.Context, msg PubSubMessage) error
+}
+
+// PubSubConfig configures the messages published by a Pub/Sub logger.
+type PubSubConfig struct {
+	// Attributes are added to every message. Each message also has a
+	// "log_type" attribute with the LogType of the log, and for result
+	// logs a "query_name" attribute with the name of the query.
+	Attributes map[string]string
+	// OrderingKey, if set, returns the ordering key of a log. Messages
+	// with the same ordering key are delivered in order. For example,
+	// returning the query name keeps the results of each query in order.
+	OrderingKey func(typ LogType, log string) string
+	// Compressor, if set, compresses the message data. The encoding is
+	// set in the "content_encoding" attribute of the messages.
+	Compressor Compressor
+}
+
+// NewPubSubPlugin creates a logger plugin that publishes every log as a
+// message to a Pub/Sub topic. The message data is the log, as received from
+// osquery, compressed if config.Compressor is set.
+func NewPubSubPlugin(name string, publisher PubSubPublisher, config PubSubConfig, opts ...Option) *Plugin {
+	return NewPlugin(name, func(ctx context.Context, typ LogType, log string) error {
+		attributes := make(map[string]string, len(config.Attributes)+2)
+		for k, v := range config.Attributes {
+			attributes[k] = v
+		}
+		attributes["log_type"] = typ.String()
+		if typ == LogTypeString || typ == LogTypeSnapshot {
+			if result, err := ParseResultLog(log); err == nil && result.Name != "" {
+				attributes["query_name"] = result.Name
+			}
+		}
+
+		data := []byte(log)
+		if config.Compressor != nil {
+			var err error
+			if data, err = config.Compressor.Compress(data); err != nil {
+				return errors.Wrap(err, "compressing log")
+			}
+			attributes["content_encoding"] = config.Compressor.Encoding()
+		}
+
+		msg := PubSubMessage{Data: data, Attributes: attributes}
+		if config.OrderingKey != nil {
+			msg.OrderingKey = config.OrderingKey(typ, log)
+		}
+		return errors.Wrap(publisher.Publish(ctx, msg), "publishing log")
+	}, opts...)
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockPublisher struct {
+	messages []PubSubMessage
+	err      error
+}
+
+func (p *mockPublisher) Publish(ctx context.Context, msg PubSubMessage) error {
+	p.messages = append(p.messages, msg)
+	return p.err
+}
+
+func TestPubSubPlugin(t *testing.T) {
+	publisher := &mockPublisher{}
+	plugin := NewPubSubPlugin("pubsub", publisher, PubSubConfig{
+		Attributes: map[string]string{"fleet": "prod"},
+		OrderingKey: func(typ LogType, log string) string {
+			return typ.String()
+		},
+	})
+
+	ctx := context.Background()
+	resp := plugin.Call(ctx, osquery.ExtensionPluginRequest{"string": `{"name":"users","action":"added","columns":{}}`})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	resp = plugin.Call(ctx, osquery.ExtensionPluginRequest{"health": "ok"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+
+	assert.Equal(t, []PubSubMessage{
+		{
+			Data:        []byte(`{"name":"users","action":"added","columns":{}}`),
+			Attributes:  map[string]string{"fleet": "prod", "log_type": "string", "query_name": "users"},
+			OrderingKey: "string",
+		},
+		{
+			Data:        []byte("ok"),
+			Attributes:  map[string]string{"fleet": "prod", "log_type": "health"},
+			OrderingKey: "health",
+		},
+	}, publisher.messages)
+
+	publisher.err = errors.New("topic not found")
+	resp = plugin.Call(ctx, osquery.ExtensionPluginRequest{"health": "ok"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error logging: publishing log: topic not found", resp.Status.Message)
+}
+
+func TestPubSubPluginCompression(t *testing.T) {
+	publisher := &mockPublisher{}
+	plugin := NewPubSubPlugin("pubsub", publisher, PubSubConfig{Compressor: fakeCompressor("fake")})
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"health": "ok"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	require.Len(t, publisher.messages, 1)
+	assert.Equal(t, "fake:ok", string(publisher.messages[0].Data))
+	assert.Equal(t, "fake", publisher.messages[0].Attributes["content_encoding"])
+}
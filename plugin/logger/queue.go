@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/internal/diskqueue"
+)
+
+// QueuedLog is a log stored in a DiskQueue.
+type QueuedLog struct {
+	Type LogType `json:"type"`
+	Log  string  `json:"log"`
+}
+
+// DiskQueue is a durable FIFO queue of logs that could not be delivered,
+// stored as segment files in a directory. Loggers use it to keep logs across
+// restarts of the extension and deliver them once the sink recovers. It is
+// safe for concurrent use, but a directory must not be shared by several
+// queues.
+type DiskQueue struct {
+	queue *diskqueue.Queue[QueuedLog]
+}
+
+// OpenDiskQueue opens the queue stored in dir, creating the directory if
+// needed. If maxBytes is positive, the oldest segments are discarded when
+// the queue grows larger than maxBytes.
+func OpenDiskQueue(dir string, maxBytes int64) (*DiskQueue, error) {
+	queue, err := diskqueue.Open[QueuedLog](dir, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskQueue{queue: queue}, nil
+}
+
+// Push adds logs to the end of the queue, as a single segment.
+func (q *DiskQueue) Push(logs []QueuedLog) error {
+	return q.queue.Push(logs)
+}
+
+// Len returns the number of logs in the queue.
+func (q *DiskQueue) Len() (int, error) {
+	return q.queue.Len()
+}
+
+// Replay calls fn with the logs of each segment, oldest first, removing the
+// segments for which fn succeeds. It stops at the first error, which is
+// returned, leaving the remaining segments in the queue. Segments that
+// can't be decoded are discarded.
+func (q *DiskQueue) Replay(ctx context.Context, fn func(ctx context.Context, logs []QueuedLog) error) error {
+	return q.queue.Replay(ctx, fn)
+}
+
+// WithRetryQueue returns a LogFunc that persists the logs fn fails to
+// deliver in queue, instead of failing the log request. Queued logs are
+// delivered, oldest first, before the next log once fn succeeds again.
+// Delivery is at least once: a log may be delivered again if a later log of
+// the same segment fails.
+func WithRetryQueue(fn LogFunc, queue *DiskQueue) LogFunc {
+	deliver := func(ctx context.Context, logs []QueuedLog) error {
+		for _, l := range logs {
+			if err := fn(ctx, l.Type, l.Log); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return func(ctx context.Context, typ LogType, log string) error {
+		if err := queue.Replay(ctx, deliver); err == nil {
+			if err := fn(ctx, typ, log); err == nil {
+				return nil
+			}
+		}
+		return queue.Push([]QueuedLog{{Type: typ, Log: log}})
+	}
+}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskQueue(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	q, err := OpenDiskQueue(dir, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Push([]QueuedLog{{LogTypeString, "a"}, {LogTypeSnapshot, "b"}}))
+	require.NoError(t, q.Push([]QueuedLog{{LogTypeStatus, "c"}}))
+	require.NoError(t, q.Push(nil))
+	n, err := q.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// The queue survives reopening
+	q, err = OpenDiskQueue(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, q.Push([]QueuedLog{{LogTypeString, "d"}}))
+
+	// Replay stops at the first error
+	var replayed [][]QueuedLog
+	fail := errors.New("unavailable")
+	err = q.Replay(context.Background(), func(ctx context.Context, logs []QueuedLog) error {
+		if len(replayed) == 1 {
+			return fail
+		}
+		replayed = append(replayed, logs)
+		return nil
+	})
+	assert.Equal(t, fail, err)
+	assert.Equal(t, [][]QueuedLog{{{LogTypeString, "a"}, {LogTypeSnapshot, "b"}}}, replayed)
+
+	// Corrupt segments are discarded
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001.queue"), []byte("garbage"), 0o600))
+	replayed = nil
+	err = q.Replay(context.Background(), func(ctx context.Context, logs []QueuedLog) error {
+		replayed = append(replayed, logs)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]QueuedLog{{{LogTypeString, "d"}}}, replayed)
+	n, err = q.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestDiskQueueMaxBytes(t *testing.T) {
+	q, err := OpenDiskQueue(t.TempDir(), 100)
+	require.NoError(t, err)
+	for _, log := range []string{"first log that is long enough", "second log that is long enough", "third"} {
+		require.NoError(t, q.Push([]QueuedLog{{LogTypeString, log}}))
+	}
+
+	var logs []string
+	require.NoError(t, q.Replay(context.Background(), func(ctx context.Context, queued []QueuedLog) error {
+		for _, l := range queued {
+			logs = append(logs, l.Log)
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"second log that is long enough", "third"}, logs)
+}
+
+func TestWithRetryQueue(t *testing.T) {
+	q, err := OpenDiskQueue(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	var delivered []string
+	var down bool
+	logFn := WithRetryQueue(func(ctx context.Context, typ LogType, log string) error {
+		if down {
+			return errors.New("unavailable")
+		}
+		delivered = append(delivered, log)
+		return nil
+	}, q)
+	ctx := context.Background()
+
+	require.NoError(t, logFn(ctx, LogTypeString, "a"))
+	down = true
+	require.NoError(t, logFn(ctx, LogTypeString, "b"))
+	require.NoError(t, logFn(ctx, LogTypeString, "c"))
+	n, _ := q.Len()
+	assert.Equal(t, 2, n)
+
+	down = false
+	require.NoError(t, logFn(ctx, LogTypeString, "d"))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, delivered)
+	n, _ = q.Len()
+	assert.Equal(t, 0, n)
+}
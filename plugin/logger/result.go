@@ -0,0 +1,183 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ResultAction is the action of a scheduled query result log.
+type ResultAction string
+
+const (
+	// ResultAdded is a row added since the previous run of the query.
+	ResultAdded ResultAction = "added"
+	// ResultRemoved is a row removed since the previous run of the query.
+	ResultRemoved ResultAction = "removed"
+	// ResultSnapshot holds all the rows of a snapshot query.
+	ResultSnapshot ResultAction = "snapshot"
+	// ResultBatch holds both added and removed rows, when osquery logs
+	// differential results in batches (--logger_event_type=false).
+	ResultBatch ResultAction = "batch"
+)
+
+// ResultLog is a scheduled query result log. See ParseResultLog.
+type ResultLog struct {
+	// Name is the name of the scheduled query.
+	Name           string
+	HostIdentifier string
+	// Time is the time at which the query ran.
+	Time time.Time
+	// Epoch and Counter identify the run of the query, see the osquery
+	// documentation on differential logs.
+	Epoch   uint64
+	Counter uint64
+	Action  ResultAction
+	// Columns holds the row of an added or removed event.
+	Columns map[string]string
+	// Snapshot holds the rows of a snapshot.
+	Snapshot []map[string]string
+	// Added and Removed hold the rows of a batch.
+	Added   []map[string]string
+	Removed []map[string]string
+	// Decorations holds the values of the configured decorators.
+	Decorations map[string]string
+}
+
+// LogResultFunc is called for every result log received by a logger plugin
+// created with WithResultFunc. typ is LogTypeSnapshot for snapshot queries
+// and LogTypeString for differential results.
+type LogResultFunc func(ctx context.Context, typ LogType, result ResultLog) error
+
+// WithResultFunc makes the logger plugin parse scheduled query results
+// (snapshot and string logs) and pass them to fn, instead of passing the raw
+// JSON to the LogFunc.
+func WithResultFunc(fn LogResultFunc) Option {
+	return func(p *Plugin) {
+		p.resultFn = fn
+	}
+}
+
+// resultLogJSON is the JSON encoding of a result log. Column values are
+// numbers rather than strings with --logger_numerics.
+type resultLogJSON struct {
+	Name           string                       `json:"name"`
+	HostIdentifier string                       `json:"hostIdentifier"`
+	UnixTime       jsonString                   `json:"unixTime"`
+	Epoch          jsonString                   `json:"epoch"`
+	Counter        jsonString                   `json:"counter"`
+	Action         ResultAction                 `json:"action"`
+	Columns        map[string]json.RawMessage   `json:"columns"`
+	Snapshot       []map[string]json.RawMessage `json:"snapshot"`
+	DiffResults    *struct {
+		Added   []map[string]json.RawMessage `json:"added"`
+		Removed []map[string]json.RawMessage `json:"removed"`
+	} `json:"diffResults"`
+	Decorations map[string]json.RawMessage `json:"decorations"`
+}
+
+// ParseResultLog parses the JSON of a scheduled query result log, as passed
+// to a LogFunc with LogTypeSnapshot or LogTypeString. Event, batch and
+// snapshot formats are supported. Column values are returned as strings,
+// also when osquery logs them as numbers.
+func ParseResultLog(log string) (ResultLog, error) {
+	var parsed resultLogJSON
+	if err := json.Unmarshal([]byte(log), &parsed); err != nil {
+		return ResultLog{}, errors.Wrap(err, "unmarshaling result log")
+	}
+
+	result := ResultLog{
+		Name:           parsed.Name,
+		HostIdentifier: parsed.HostIdentifier,
+		Action:         parsed.Action,
+	}
+	var err error
+	if result.Epoch, err = parsed.Epoch.uint(); err != nil {
+		return ResultLog{}, errors.Wrap(err, "epoch")
+	}
+	if result.Counter, err = parsed.Counter.uint(); err != nil {
+		return ResultLog{}, errors.Wrap(err, "counter")
+	}
+	unixTime, err := parsed.UnixTime.uint()
+	if err != nil {
+		return ResultLog{}, errors.Wrap(err, "unixTime")
+	}
+	if unixTime != 0 {
+		result.Time = time.Unix(int64(unixTime), 0).UTC()
+	}
+
+	if result.Decorations, err = resultRow(parsed.Decorations); err != nil {
+		return ResultLog{}, errors.Wrap(err, "decorations")
+	}
+	switch {
+	case parsed.DiffResults != nil:
+		result.Action = ResultBatch
+		if result.Added, err = resultRows(parsed.DiffResults.Added); err != nil {
+			return ResultLog{}, errors.Wrap(err, "added")
+		}
+		if result.Removed, err = resultRows(parsed.DiffResults.Removed); err != nil {
+			return ResultLog{}, errors.Wrap(err, "removed")
+		}
+	case parsed.Snapshot != nil:
+		result.Action = ResultSnapshot
+		if result.Snapshot, err = resultRows(parsed.Snapshot); err != nil {
+			return ResultLog{}, errors.Wrap(err, "snapshot")
+		}
+	default:
+		if result.Columns, err = resultRow(parsed.Columns); err != nil {
+			return ResultLog{}, errors.Wrap(err, "columns")
+		}
+	}
+	return result, nil
+}
+
+func (s jsonString) uint() (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	var v uint64
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return 0, errors.Errorf("invalid number %q", string(s))
+	}
+	return v, nil
+}
+
+func resultRows(rows []map[string]json.RawMessage) ([]map[string]string, error) {
+	results := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		r, err := resultRow(row)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func resultRow(row map[string]json.RawMessage) (map[string]string, error) {
+	if row == nil {
+		return nil, nil
+	}
+	result := make(map[string]string, len(row))
+	for k, raw := range row {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			result[k] = s
+			continue
+		}
+		raw = bytes.TrimSpace(raw)
+		if bytes.Equal(raw, []byte("null")) {
+			result[k] = ""
+			continue
+		}
+		var num json.Number
+		if err := json.Unmarshal(raw, &num); err != nil {
+			return nil, errors.Errorf("column %s: expected string or number, got %s", k, raw)
+		}
+		result[k] = string(num)
+	}
+	return result, nil
+}
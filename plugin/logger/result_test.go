@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResultLog(t *testing.T) {
+	// Event format
+	result, err := ParseResultLog(`{"name":"pack_users","hostIdentifier":"host1","calendarTime":"Mon Nov 13 22:13:20 2023 UTC","unixTime":1699913600,"epoch":0,"counter":3,"numerics":true,"decorations":{"uuid":"abc"},"columns":{"uid":501,"username":"alice","shell":null},"action":"removed"}`)
+	require.NoError(t, err)
+	assert.Equal(t, ResultLog{
+		Name:           "pack_users",
+		HostIdentifier: "host1",
+		Time:           time.Unix(1699913600, 0).UTC(),
+		Counter:        3,
+		Action:         ResultRemoved,
+		Columns:        map[string]string{"uid": "501", "username": "alice", "shell": ""},
+		Decorations:    map[string]string{"uuid": "abc"},
+	}, result)
+
+	// Batch format
+	result, err = ParseResultLog(`{"name":"q","unixTime":"1699913600","epoch":"1","counter":"0","diffResults":{"added":[{"pid":"1"}],"removed":[{"pid":"2"},{"pid":"3"}]}}`)
+	require.NoError(t, err)
+	assert.Equal(t, ResultBatch, result.Action)
+	assert.Equal(t, uint64(1), result.Epoch)
+	assert.Equal(t, []map[string]string{{"pid": "1"}}, result.Added)
+	assert.Equal(t, []map[string]string{{"pid": "2"}, {"pid": "3"}}, result.Removed)
+
+	// Snapshot format
+	result, err = ParseResultLog(`{"name":"q","snapshot":[{"pid":"1"},{"pid":"2"}],"action":"snapshot"}`)
+	require.NoError(t, err)
+	assert.Equal(t, ResultSnapshot, result.Action)
+	assert.Equal(t, []map[string]string{{"pid": "1"}, {"pid": "2"}}, result.Snapshot)
+	assert.True(t, result.Time.IsZero())
+
+	_, err = ParseResultLog(`not json`)
+	assert.Error(t, err)
+	_, err = ParseResultLog(`{"epoch":"x"}`)
+	assert.EqualError(t, err, `epoch: invalid number "x"`)
+	_, err = ParseResultLog(`{"columns":{"a":[1]}}`)
+	assert.EqualError(t, err, "columns: column a: expected string or number, got [1]")
+}
+
+func TestLoggerPluginResultFunc(t *testing.T) {
+	var results []ResultLog
+	var types []LogType
+	var logged bool
+	plugin := NewPlugin("mock", func(ctx context.Context, typ LogType, log string) error {
+		logged = true
+		return nil
+	}, WithResultFunc(func(ctx context.Context, typ LogType, result ResultLog) error {
+		types = append(types, typ)
+		results = append(results, result)
+		return nil
+	}))
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"string": `{"name":"q","columns":{"a":"1"},"action":"added"}`})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"snapshot": `{"name":"q","snapshot":[],"action":"snapshot"}`})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.False(t, logged)
+	assert.Equal(t, []LogType{LogTypeString, LogTypeSnapshot}, types)
+	require.Len(t, results, 2)
+	assert.Equal(t, ResultAdded, results[0].Action)
+	assert.Equal(t, map[string]string{"a": "1"}, results[0].Columns)
+	assert.Equal(t, []map[string]string{}, results[1].Snapshot)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"health": "{}"})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.True(t, logged)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"string": "not json"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "unmarshaling result log")
+}
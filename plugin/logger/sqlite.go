@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SQLiteConfig configures a SQLite logger plugin.
+type SQLiteConfig struct {
+	// Table is the name of the table holding the logs. Empty means
+	// "osquery_logs".
+	Table string
+	// MaxAge is how long logs are kept. Zero means forever.
+	MaxAge time.Duration
+	// MaxRows is the maximum number of logs kept, the oldest logs are
+	// deleted first. Zero means no limit.
+	MaxRows int
+}
+
+// sqlitePruneEvery is the number of inserted logs between applications of
+// the retention policy.
+const sqlitePruneEvery = 100
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// NewSQLitePlugin creates a logger plugin that stores the logs in a SQLite
+// database, giving hosts without a remote log pipeline a queryable local log
+// store. The database is opened by the caller with the SQLite driver of its
+// choice (such as github.com/mattn/go-sqlite3 or modernc.org/sqlite), which
+// keeps this package free of the driver dependency.
+//
+// The table is created if it doesn't exist, with the columns id, time (Unix
+// timestamp of the log), type (the LogType), name and action (of result
+// logs) and log (the log as received from osquery). The retention policy is
+// applied when the plugin is created and periodically as logs are
+// inserted.
+func NewSQLitePlugin(name string, db *sql.DB, config SQLiteConfig, opts ...Option) (*Plugin, error) {
+	if config.Table == "" {
+		config.Table = "osquery_logs"
+	}
+	if !sqlIdentifier.MatchString(config.Table) {
+		return nil, errors.Errorf("invalid table name %q", config.Table)
+	}
+
+	s := &sqliteLogger{db: db, config: config, now: time.Now}
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+config.Table+` (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		action TEXT NOT NULL DEFAULT '',
+		log TEXT NOT NULL
+	)`); err != nil {
+		return nil, errors.Wrap(err, "creating log table")
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS `+config.Table+`_time ON `+config.Table+` (time)`); err != nil {
+		return nil, errors.Wrap(err, "creating log table index")
+	}
+	if err := s.prune(ctx); err != nil {
+		return nil, err
+	}
+	return NewPlugin(name, s.log, opts...), nil
+}
+
+type sqliteLogger struct {
+	db     *sql.DB
+	config SQLiteConfig
+	now    func() time.Time
+
+	mutex    sync.Mutex
+	inserted int
+}
+
+func (s *sqliteLogger) log(ctx context.Context, typ LogType, log string) error {
+	var name, action string
+	if typ == LogTypeString || typ == LogTypeSnapshot {
+		if result, err := ParseResultLog(log); err == nil {
+			name, action = result.Name, string(result.Action)
+		}
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO `+s.config.Table+` (time, type, name, action, log) VALUES (?, ?, ?, ?, ?)`,
+		s.now().Unix(), typ.String(), name, action, log)
+	if err != nil {
+		return errors.Wrap(err, "inserting log")
+	}
+
+	s.mutex.Lock()
+	s.inserted++
+	prune := s.inserted%sqlitePruneEvery == 0
+	s.mutex.Unlock()
+	if prune {
+		return s.prune(ctx)
+	}
+	return nil
+}
+
+// prune applies the retention policy.
+func (s *sqliteLogger) prune(ctx context.Context) error {
+	if s.config.MaxAge > 0 {
+		cutoff := s.now().Add(-s.config.MaxAge).Unix()
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM `+s.config.Table+` WHERE time < ?`, cutoff); err != nil {
+			return errors.Wrap(err, "deleting expired logs")
+		}
+	}
+	if s.config.MaxRows > 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`DELETE FROM `+s.config.Table+` WHERE id <= (SELECT MAX(id) FROM `+s.config.Table+`) - ?`,
+			s.config.MaxRows); err != nil {
+			return errors.Wrap(err, "deleting old logs")
+		}
+	}
+	return nil
+}
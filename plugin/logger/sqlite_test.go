@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDriver is a database/sql driver that records the executed
+// statements, standing in for a SQLite driver.
+type recordingDriver struct {
+	mutex sync.Mutex
+	execs []recordedExec
+	fail  bool
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+	return &recordingConn{d}, nil
+}
+
+type recordingConn struct {
+	d *recordingDriver
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c.d, query}, nil
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s *recordingStmt) Close() error {
+	return nil
+}
+
+func (s *recordingStmt) NumInput() int {
+	return -1
+}
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mutex.Lock()
+	defer s.d.mutex.Unlock()
+	if s.d.fail {
+		return nil, errors.New("database is locked")
+	}
+	s.d.execs = append(s.d.execs, recordedExec{strings.Join(strings.Fields(s.query), " "), args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("queries are not supported")
+}
+
+var testDriver = &recordingDriver{}
+
+func init() {
+	sql.Register("osquery-go-recording", testDriver)
+}
+
+func TestSQLitePlugin(t *testing.T) {
+	db, err := sql.Open("osquery-go-recording", "")
+	require.NoError(t, err)
+	defer db.Close()
+	testDriver.execs = nil
+
+	_, err = NewSQLitePlugin("sqlite", db, SQLiteConfig{Table: "logs; DROP TABLE x"})
+	assert.Error(t, err)
+
+	plugin, err := NewSQLitePlugin("sqlite", db, SQLiteConfig{Table: "logs", MaxAge: time.Hour, MaxRows: 1000})
+	require.NoError(t, err)
+	require.Len(t, testDriver.execs, 4)
+	assert.True(t, strings.HasPrefix(testDriver.execs[0].query, "CREATE TABLE IF NOT EXISTS logs ("))
+	assert.Equal(t, "CREATE INDEX IF NOT EXISTS logs_time ON logs (time)", testDriver.execs[1].query)
+	assert.Equal(t, "DELETE FROM logs WHERE time < ?", testDriver.execs[2].query)
+	assert.Equal(t, "DELETE FROM logs WHERE id <= (SELECT MAX(id) FROM logs) - ?", testDriver.execs[3].query)
+	assert.Equal(t, []driver.Value{int64(1000)}, testDriver.execs[3].args)
+
+	testDriver.execs = nil
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"string": `{"name":"users","action":"added","columns":{}}`})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	require.Len(t, testDriver.execs, 1)
+	assert.Equal(t, "INSERT INTO logs (time, type, name, action, log) VALUES (?, ?, ?, ?, ?)", testDriver.execs[0].query)
+	assert.Equal(t, []driver.Value{"string", "users", "added", `{"name":"users","action":"added","columns":{}}`}, testDriver.execs[0].args[1:])
+
+	// The retention policy is applied periodically
+	for i := 1; i < sqlitePruneEvery; i++ {
+		plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"health": "ok"})
+	}
+	assert.Len(t, testDriver.execs, sqlitePruneEvery+2)
+
+	testDriver.fail = true
+	defer func() { testDriver.fail = false }()
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"health": "ok"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error logging: inserting log: database is locked", resp.Status.Message)
+}
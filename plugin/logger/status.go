@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Severity is the glog severity of a status log.
+type Severity int
+
+// The following severities are used by osquery (glog).
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+	SeverityFatal
+)
+
+// String implements the fmt.Stringer interface for Severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "INFO"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	case SeverityFatal:
+		return "FATAL"
+	}
+	return "UNKNOWN"
+}
+
+// StatusLog is a status log of the osquery process, such as a warning
+// printed by osqueryd. See ParseStatusLog.
+type StatusLog struct {
+	Severity Severity
+	// Filename and Line are the location in the osquery source that
+	// emitted the log.
+	Filename string
+	Line     int
+	Message  string
+	// HostIdentifier and Version are the host identifier and osquery
+	// version, if osquery provided them.
+	HostIdentifier string
+	Version        string
+	// Time is the time of the log, or the zero time if osquery did not
+	// provide it.
+	Time time.Time
+}
+
+// LogStatusFunc is called for every status log received by a logger plugin
+// created with WithStatusFunc.
+type LogStatusFunc func(ctx context.Context, status StatusLog) error
+
+// WithStatusFunc makes the logger plugin parse status logs and pass them to
+// fn, instead of passing the raw JSON to the LogFunc with LogTypeStatus.
+func WithStatusFunc(fn LogStatusFunc) Option {
+	return func(p *Plugin) {
+		p.statusFn = fn
+	}
+}
+
+// statusLogJSON is the JSON encoding of a status log. Older osquery
+// versions encode the numbers as strings.
+type statusLogJSON struct {
+	Severity       jsonString `json:"s"`
+	Filename       string     `json:"f"`
+	Line           jsonString `json:"i"`
+	Message        string     `json:"m"`
+	HostIdentifier string     `json:"h"`
+	CalendarTime   string     `json:"c"`
+	UnixTime       jsonString `json:"u"`
+	Version        string     `json:"v"`
+}
+
+// jsonString is a JSON string or number.
+type jsonString string
+
+func (s *jsonString) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*s = jsonString(str)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return errors.Errorf("expected string or number, got %s", b)
+	}
+	*s = jsonString(num)
+	return nil
+}
+
+// ParseStatusLog parses the JSON of a status log, as passed to a LogFunc
+// with LogTypeStatus.
+func ParseStatusLog(log string) (StatusLog, error) {
+	var parsed statusLogJSON
+	if err := json.Unmarshal([]byte(log), &parsed); err != nil {
+		return StatusLog{}, errors.Wrap(err, "unmarshaling status log")
+	}
+
+	status := StatusLog{
+		Filename:       parsed.Filename,
+		Message:        parsed.Message,
+		HostIdentifier: parsed.HostIdentifier,
+		Version:        parsed.Version,
+	}
+	if parsed.Severity != "" {
+		severity, err := strconv.Atoi(string(parsed.Severity))
+		if err != nil {
+			return StatusLog{}, errors.Errorf("invalid severity %q", parsed.Severity)
+		}
+		status.Severity = Severity(severity)
+	}
+	if parsed.Line != "" {
+		line, err := strconv.Atoi(string(parsed.Line))
+		if err != nil {
+			return StatusLog{}, errors.Errorf("invalid line %q", parsed.Line)
+		}
+		status.Line = line
+	}
+	if parsed.UnixTime != "" {
+		unix, err := strconv.ParseInt(string(parsed.UnixTime), 10, 64)
+		if err != nil {
+			return StatusLog{}, errors.Errorf("invalid unix time %q", parsed.UnixTime)
+		}
+		status.Time = time.Unix(unix, 0).UTC()
+	} else if parsed.CalendarTime != "" {
+		// osquery formats the calendar time as in "Mon Jan  2 15:04:05 2006 UTC"
+		if t, err := time.Parse("Mon Jan _2 15:04:05 2006 MST", parsed.CalendarTime); err == nil {
+			status.Time = t
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatusLog(t *testing.T) {
+	status, err := ParseStatusLog(`{"s":"1","f":"events.cpp","i":"828","m":"Event publisher failed setup"}`)
+	require.NoError(t, err)
+	assert.Equal(t, StatusLog{
+		Severity: SeverityWarning,
+		Filename: "events.cpp",
+		Line:     828,
+		Message:  "Event publisher failed setup",
+	}, status)
+	assert.Equal(t, "WARNING", status.Severity.String())
+
+	status, err = ParseStatusLog(`{"s":2,"f":"config.cpp","i":42,"m":"bad config","h":"host1","c":"Mon Nov 13 22:13:20 2023 UTC","u":1699913600,"v":"5.10.2"}`)
+	require.NoError(t, err)
+	assert.Equal(t, StatusLog{
+		Severity:       SeverityError,
+		Filename:       "config.cpp",
+		Line:           42,
+		Message:        "bad config",
+		HostIdentifier: "host1",
+		Version:        "5.10.2",
+		Time:           time.Unix(1699913600, 0).UTC(),
+	}, status)
+
+	status, err = ParseStatusLog(`{"s":0,"c":"Mon Nov 13 22:13:20 2023 UTC"}`)
+	require.NoError(t, err)
+	assert.True(t, status.Time.Equal(time.Unix(1699913600, 0)))
+
+	_, err = ParseStatusLog(`not json`)
+	assert.Error(t, err)
+	_, err = ParseStatusLog(`{"s":"warning"}`)
+	assert.EqualError(t, err, `invalid severity "warning"`)
+	_, err = ParseStatusLog(`{"i":true}`)
+	assert.Error(t, err)
+}
+
+func TestLoggerPluginStatusFunc(t *testing.T) {
+	var statuses []StatusLog
+	var logged bool
+	plugin := NewPlugin("mock", func(ctx context.Context, typ LogType, log string) error {
+		logged = true
+		return nil
+	}, WithStatusFunc(func(ctx context.Context, status StatusLog) error {
+		statuses = append(statuses, status)
+		return nil
+	}))
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"status": "true", "log": `{"":{"s":"0","f":"events.cpp","i":"828","m":"first"},"":{"s":"2","f":"scheduler.cpp","i":"74","m":"second"}}`})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.False(t, logged)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "first", statuses[0].Message)
+	assert.Equal(t, SeverityError, statuses[1].Severity)
+	assert.Equal(t, 74, statuses[1].Line)
+
+	// Other log types still go to the LogFunc
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"string": "result"})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.True(t, logged)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"status": "true", "log": `{"":{"s":"bad"}}`})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "error parsing status log")
+}
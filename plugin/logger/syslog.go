@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"strconv"
+)
+
+// logSeverity returns the severity of a log. Status logs have the severity
+// reported by osquery, other logs are informational.
+func logSeverity(typ LogType, log string) Severity {
+	if typ != LogTypeStatus {
+		return SeverityInfo
+	}
+	status, err := ParseStatusLog(log)
+	if err != nil {
+		return SeverityInfo
+	}
+	return status.Severity
+}
+
+// logFields returns structured fields describing a log, in the form of
+// journald field names. It is used by the system logger plugins.
+func logFields(typ LogType, log string) map[string]string {
+	fields := map[string]string{"OSQUERY_LOG_TYPE": typ.String()}
+	switch typ {
+	case LogTypeStatus:
+		status, err := ParseStatusLog(log)
+		if err != nil {
+			break
+		}
+		fields["CODE_FILE"] = status.Filename
+		fields["CODE_LINE"] = strconv.Itoa(status.Line)
+		if status.HostIdentifier != "" {
+			fields["OSQUERY_HOST_IDENTIFIER"] = status.HostIdentifier
+		}
+	case LogTypeString, LogTypeSnapshot:
+		result, err := ParseResultLog(log)
+		if err != nil {
+			break
+		}
+		fields["OSQUERY_QUERY_NAME"] = result.Name
+		if result.HostIdentifier != "" {
+			fields["OSQUERY_HOST_IDENTIFIER"] = result.HostIdentifier
+		}
+		if result.Action != "" {
+			fields["OSQUERY_ACTION"] = string(result.Action)
+		}
+	}
+	return fields
+}
+
+// logMessage returns the message of a log for a system logger. For status
+// logs this is the status message with its source location, other logs are
+// returned as they are.
+func logMessage(typ LogType, log string) string {
+	if typ != LogTypeStatus {
+		return log
+	}
+	status, err := ParseStatusLog(log)
+	if err != nil {
+		return log
+	}
+	return status.Filename + ":" + strconv.Itoa(status.Line) + " " + status.Message
+}
@@ -0,0 +1,46 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logger
+
+import (
+	"context"
+	"log/syslog"
+)
+
+// syslogWriter is the subset of *syslog.Writer used by the syslog logger.
+type syslogWriter interface {
+	Info(m string) error
+	Warning(m string) error
+	Err(m string) error
+	Crit(m string) error
+}
+
+// NewSyslogPlugin creates a logger plugin that writes the osquery logs to
+// the local syslog daemon with the given facility (such as
+// syslog.LOG_DAEMON) and tag. Status logs are written with the syslog
+// severity matching their osquery severity and the source location of the
+// log, other logs as informational messages.
+func NewSyslogPlugin(name string, facility syslog.Priority, tag string) (*Plugin, error) {
+	w, err := syslog.New(facility|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlugin(name, syslogLogFunc(w)), nil
+}
+
+func syslogLogFunc(w syslogWriter) LogFunc {
+	return func(ctx context.Context, typ LogType, log string) error {
+		msg := logMessage(typ, log)
+		switch logSeverity(typ, log) {
+		case SeverityWarning:
+			return w.Warning(msg)
+		case SeverityError:
+			return w.Err(msg)
+		case SeverityFatal:
+			return w.Crit(msg)
+		default:
+			return w.Info(msg)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSyslogWriter struct {
+	messages []string
+}
+
+func (w *mockSyslogWriter) Info(m string) error    { return w.write("info", m) }
+func (w *mockSyslogWriter) Warning(m string) error { return w.write("warning", m) }
+func (w *mockSyslogWriter) Err(m string) error     { return w.write("err", m) }
+func (w *mockSyslogWriter) Crit(m string) error    { return w.write("crit", m) }
+
+func (w *mockSyslogWriter) write(severity, m string) error {
+	w.messages = append(w.messages, severity+" "+m)
+	return nil
+}
+
+func TestSyslogLogFunc(t *testing.T) {
+	w := &mockSyslogWriter{}
+	logFn := syslogLogFunc(w)
+	ctx := context.Background()
+
+	assert.NoError(t, logFn(ctx, LogTypeString, `{"name":"q"}`))
+	assert.NoError(t, logFn(ctx, LogTypeStatus, `{"s":"1","f":"events.cpp","i":"828","m":"publisher failed"}`))
+	assert.NoError(t, logFn(ctx, LogTypeStatus, `{"s":2,"f":"config.cpp","i":10,"m":"bad config"}`))
+	assert.NoError(t, logFn(ctx, LogTypeStatus, `{"s":3,"f":"main.cpp","i":1,"m":"fatal"}`))
+	assert.NoError(t, logFn(ctx, LogTypeStatus, `not json`))
+
+	assert.Equal(t, []string{
+		`info {"name":"q"}`,
+		"warning events.cpp:828 publisher failed",
+		"err config.cpp:10 bad config",
+		"crit main.cpp:1 fatal",
+		"info not json",
+	}, w.messages)
+}
@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ThrottleConfig configures Throttle.
+type ThrottleConfig struct {
+	// DedupWindow drops a log identical to one logged less than
+	// DedupWindow ago. Status logs are compared by severity, source
+	// location and message, ignoring their timestamps. Zero disables
+	// deduplication.
+	DedupWindow time.Duration
+	// RateLimit is the maximum number of logs per second, with bursts of
+	// up to Burst logs (at least 1). Zero disables rate limiting.
+	RateLimit float64
+	Burst     int
+	// Types are the log types that are throttled. If nil, only status logs
+	// are throttled, so that query results are never dropped.
+	Types []LogType
+	// OnDrop, if set, is called for every dropped log, for example to
+	// count them.
+	OnDrop func(typ LogType, log string)
+}
+
+// Throttle returns a LogFunc that deduplicates and rate limits the logs
+// passed to fn, protecting downstream systems from floods such as a failing
+// event publisher logging the same status thousands of times. Dropped logs
+// are not reported as errors to osquery.
+func Throttle(fn LogFunc, config ThrottleConfig) LogFunc {
+	return newThrottle(fn, config, time.Now).log
+}
+
+type throttle struct {
+	fn     LogFunc
+	config ThrottleConfig
+	types  map[LogType]bool
+	now    func() time.Time
+
+	mutex sync.Mutex
+	// seen holds the last time each deduplication key was logged.
+	seen map[string]time.Time
+	// swept is the last time expired keys were removed from seen.
+	swept time.Time
+	// tokens and refilled are the token bucket of the rate limit.
+	tokens   float64
+	refilled time.Time
+}
+
+func newThrottle(fn LogFunc, config ThrottleConfig, now func() time.Time) *throttle {
+	types := config.Types
+	if types == nil {
+		types = []LogType{LogTypeStatus}
+	}
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	t := &throttle{
+		fn:       fn,
+		config:   config,
+		types:    map[LogType]bool{},
+		now:      now,
+		seen:     map[string]time.Time{},
+		swept:    now(),
+		tokens:   float64(config.Burst),
+		refilled: now(),
+	}
+	for _, typ := range types {
+		t.types[typ] = true
+	}
+	return t
+}
+
+func (t *throttle) log(ctx context.Context, typ LogType, log string) error {
+	if t.types[typ] && !t.allow(typ, log) {
+		if t.config.OnDrop != nil {
+			t.config.OnDrop(typ, log)
+		}
+		return nil
+	}
+	return t.fn(ctx, typ, log)
+}
+
+// allow returns whether a log passes deduplication and the rate limit.
+func (t *throttle) allow(typ LogType, log string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := t.now()
+
+	if t.config.DedupWindow > 0 {
+		key := dedupKey(typ, log)
+		if last, ok := t.seen[key]; ok && now.Sub(last) < t.config.DedupWindow {
+			return false
+		}
+		t.seen[key] = now
+		// Forget expired keys so that the map doesn't grow forever, at
+		// most once per window to keep logging cheap.
+		if now.Sub(t.swept) >= t.config.DedupWindow {
+			for k, last := range t.seen {
+				if now.Sub(last) >= t.config.DedupWindow {
+					delete(t.seen, k)
+				}
+			}
+			t.swept = now
+		}
+	}
+
+	if t.config.RateLimit > 0 {
+		t.tokens += now.Sub(t.refilled).Seconds() * t.config.RateLimit
+		if t.tokens > float64(t.config.Burst) {
+			t.tokens = float64(t.config.Burst)
+		}
+		t.refilled = now
+		if t.tokens < 1 {
+			return false
+		}
+		t.tokens--
+	}
+	return true
+}
+
+// dedupKey returns the key under which identical logs are deduplicated.
+func dedupKey(typ LogType, log string) string {
+	if typ == LogTypeStatus {
+		if status, err := ParseStatusLog(log); err == nil {
+			return typ.String() + "\x00" + status.Severity.String() + "\x00" + status.Filename + "\x00" +
+				strconv.Itoa(status.Line) + "\x00" + status.Message
+		}
+	}
+	return typ.String() + "\x00" + log
+}
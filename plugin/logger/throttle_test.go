@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	var logged, dropped []string
+	logFn := func(ctx context.Context, typ LogType, log string) error {
+		logged = append(logged, log)
+		return nil
+	}
+	now := time.Unix(1700000000, 0)
+	th := newThrottle(logFn, ThrottleConfig{
+		DedupWindow: time.Minute,
+		RateLimit:   1,
+		Burst:       2,
+		OnDrop:      func(typ LogType, log string) { dropped = append(dropped, log) },
+	}, func() time.Time { return now })
+	ctx := context.Background()
+
+	status := func(msg string, unix int) string {
+		return `{"s":"1","f":"events.cpp","i":"828","m":"` + msg + `","u":` + strconv.Itoa(1700000000+unix) + `}`
+	}
+
+	// Identical status logs are deduplicated, ignoring the timestamp
+	th.log(ctx, LogTypeStatus, status("publisher failed", 1))
+	th.log(ctx, LogTypeStatus, status("publisher failed", 2))
+	assert.Len(t, logged, 1)
+	assert.Len(t, dropped, 1)
+
+	// The burst is exhausted
+	th.log(ctx, LogTypeStatus, status("other", 1))
+	th.log(ctx, LogTypeStatus, status("third", 1))
+	assert.Len(t, logged, 2)
+	assert.Len(t, dropped, 2)
+
+	// Results are not throttled by default
+	for i := 0; i < 5; i++ {
+		th.log(ctx, LogTypeString, `{"name":"q"}`)
+	}
+	assert.Len(t, logged, 7)
+
+	// Tokens refill and duplicates expire
+	now = now.Add(time.Minute)
+	th.log(ctx, LogTypeStatus, status("publisher failed", 3))
+	assert.Len(t, logged, 8)
+	assert.Len(t, dropped, 2)
+	// Expired keys are forgotten
+	assert.Len(t, th.seen, 1)
+
+	// Throttled types are configurable
+	logged, dropped = nil, nil
+	logFn2 := Throttle(logFn, ThrottleConfig{DedupWindow: time.Hour, Types: []LogType{LogTypeString}})
+	logFn2(ctx, LogTypeString, "same")
+	logFn2(ctx, LogTypeString, "same")
+	logFn2(ctx, LogTypeStatus, "same")
+	logFn2(ctx, LogTypeStatus, "same")
+	assert.Equal(t, []string{"same", "same", "same"}, logged)
+}
@@ -0,0 +1,14 @@
+package plugintest
+
+import (
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// GenConfig requests the configs of a config plugin, and returns them by
+// source.
+func GenConfig(t testing.TB, plugin Plugin) map[string]string {
+	t.Helper()
+	return first(t, plugin, Call(t, plugin, osquery.ExtensionPluginRequest{"action": "genConfig"}))
+}
@@ -0,0 +1,60 @@
+package plugintest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/distributed"
+)
+
+// GetQueries requests the distributed queries of a distributed plugin.
+func GetQueries(t testing.TB, plugin Plugin) distributed.GetQueriesResult {
+	t.Helper()
+	resp := first(t, plugin, Call(t, plugin, osquery.ExtensionPluginRequest{"action": "getQueries"}))
+	var queries distributed.GetQueriesResult
+	if err := json.Unmarshal([]byte(resp["results"]), &queries); err != nil {
+		t.Fatalf("%s %s: unmarshaling queries: %v", plugin.RegistryName(), plugin.Name(), err)
+	}
+	return queries
+}
+
+// WriteResults sends the results of distributed queries to a distributed
+// plugin, in the format of osquery: queries without rows are sent as an
+// empty string, and the statuses as strings.
+func WriteResults(t testing.TB, plugin Plugin, results ...distributed.Result) {
+	t.Helper()
+	Call(t, plugin, osquery.ExtensionPluginRequest{
+		"action":  "writeResults",
+		"results": ResultsJSON(t, results...),
+	})
+}
+
+// ResultsJSON returns the results in the format osquery writes them to
+// distributed plugins.
+func ResultsJSON(t testing.TB, results ...distributed.Result) string {
+	t.Helper()
+	queries := map[string]interface{}{}
+	statuses := map[string]string{}
+	messages := map[string]string{}
+	stats := map[string]distributed.Stats{}
+	for _, r := range results {
+		queries[r.QueryName] = r.Rows
+		if len(r.Rows) == 0 {
+			queries[r.QueryName] = ""
+		}
+		statuses[r.QueryName] = formatInt(int64(r.Status))
+		if r.Message != "" {
+			messages[r.QueryName] = r.Message
+		}
+		if r.QueryStats != nil {
+			stats[r.QueryName] = *r.QueryStats
+		}
+	}
+	return marshal(t, map[string]interface{}{
+		"queries":  queries,
+		"statuses": statuses,
+		"messages": messages,
+		"stats":    stats,
+	})
+}
@@ -0,0 +1,38 @@
+package plugintest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/logger"
+)
+
+// Log sends a log of the type to a logger plugin, as osquery does for
+// results (logger.LogTypeString), snapshots, health and init logs. Use
+// LogStatus for status logs.
+func Log(t testing.TB, plugin Plugin, typ logger.LogType, log string) {
+	t.Helper()
+	switch typ {
+	case logger.LogTypeString, logger.LogTypeSnapshot, logger.LogTypeHealth, logger.LogTypeInit:
+	default:
+		t.Fatalf("unsupported log type %s", typ)
+	}
+	Call(t, plugin, osquery.ExtensionPluginRequest{typ.String(): log})
+}
+
+// LogStatus sends status logs to a logger plugin in the malformed JSON
+// osquery uses, an object whose keys are all empty. Each status is the JSON
+// object of a status log, with the "s" (severity), "f" (file), "i" (line)
+// and "m" (message) keys.
+func LogStatus(t testing.TB, plugin Plugin, statuses ...string) {
+	t.Helper()
+	entries := make([]string, len(statuses))
+	for i, status := range statuses {
+		entries[i] = `"":` + status
+	}
+	Call(t, plugin, osquery.ExtensionPluginRequest{
+		"status": "true",
+		"log":    "{" + strings.Join(entries, ",") + "}",
+	})
+}
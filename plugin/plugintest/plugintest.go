@@ -0,0 +1,80 @@
+// Package plugintest feeds the requests osquery sends to plugins, in the
+// format osquery uses, to any plugin and asserts on the responses, so that
+// plugin authors get realistic coverage without running osqueryd.
+//
+//	func TestProcessesTable(t *testing.T) {
+//		plugin := table.NewPlugin("processes", columns, generate)
+//		rows := plugintest.Generate(t, plugin, plugintest.Context().Where("pid", table.OperatorEquals, "1"))
+//		assert.Len(t, rows, 1)
+//	}
+//
+// The helpers fail the test with t.Fatalf when the plugin returns a non-OK
+// status, except CallError which expects one.
+package plugintest
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// Plugin is the part of the osquery plugin interface the helpers use. It is
+// implemented by the table, logger, config and distributed plugins.
+type Plugin interface {
+	Name() string
+	RegistryName() string
+	Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse
+}
+
+// Call sends the request to the plugin, and returns the response if its
+// status is OK.
+func Call(t testing.TB, plugin Plugin, request osquery.ExtensionPluginRequest) osquery.ExtensionPluginResponse {
+	t.Helper()
+	resp := plugin.Call(context.Background(), request)
+	if resp.Status == nil {
+		t.Fatalf("%s %s: no status in response to %v", plugin.RegistryName(), plugin.Name(), request)
+	}
+	if resp.Status.Code != 0 {
+		t.Fatalf("%s %s: status %d in response to %v: %s", plugin.RegistryName(), plugin.Name(), resp.Status.Code, request, resp.Status.Message)
+	}
+	return resp.Response
+}
+
+// CallError sends the request to the plugin, and returns the status of the
+// response, expecting a failure.
+func CallError(t testing.TB, plugin Plugin, request osquery.ExtensionPluginRequest) *osquery.ExtensionStatus {
+	t.Helper()
+	resp := plugin.Call(context.Background(), request)
+	if resp.Status == nil || resp.Status.Code == 0 {
+		t.Fatalf("%s %s: expected an error in response to %v, got %v", plugin.RegistryName(), plugin.Name(), request, resp.Status)
+	}
+	return resp.Status
+}
+
+// marshal returns the JSON of v, failing the test on error.
+func marshal(t testing.TB, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshaling request: %v", err)
+	}
+	return string(data)
+}
+
+// first returns the first map of the response, failing the test if there is
+// none.
+func first(t testing.TB, plugin Plugin, response osquery.ExtensionPluginResponse) map[string]string {
+	t.Helper()
+	if len(response) == 0 {
+		t.Fatalf("%s %s: empty response", plugin.RegistryName(), plugin.Name())
+	}
+	return response[0]
+}
+
+// formatInt formats the integer the way osquery does in requests.
+func formatInt(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
@@ -0,0 +1,125 @@
+package plugintest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/osquery/osquery-go/plugin/distributed"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable(t *testing.T) {
+	var got table.QueryContext
+	var inserted table.RowValues
+	plugin := table.NewWritablePlugin("users",
+		[]table.ColumnDefinition{table.TextColumn("name"), table.IntegerColumn("uid")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			got = queryContext
+			return []map[string]string{{"name": "root", "uid": "0"}}, nil
+		},
+		func(ctx context.Context, id *table.RowID, row table.RowValues) (table.RowID, error) {
+			inserted = row
+			return 7, nil
+		},
+		nil, nil,
+	)
+
+	rows := Generate(t, plugin, Context().
+		Where("uid", table.OperatorEquals, "0").
+		Where("uid", table.OperatorEquals, "1").
+		Affinity("uid", table.ColumnTypeInteger).
+		Columns("name", "uid").
+		Limit(10),
+	)
+	assert.Equal(t, []map[string]string{{"name": "root", "uid": "0"}}, rows)
+	require.Contains(t, got.Constraints, "uid")
+	assert.Equal(t, table.ColumnType(table.ColumnTypeInteger), got.Constraints["uid"].Affinity)
+	assert.Equal(t, []table.Constraint{
+		{Operator: table.OperatorEquals, Expression: "0"},
+		{Operator: table.OperatorEquals, Expression: "1"},
+	}, got.Constraints["uid"].Constraints)
+
+	Generate(t, plugin, nil)
+	assert.Empty(t, got.Constraints)
+
+	assert.Len(t, Columns(t, plugin), 2)
+
+	status := Insert(t, plugin, nil, "admin", 501)
+	assert.Equal(t, "7", status["id"])
+	assert.Equal(t, "admin", inserted["name"])
+
+	status = Update(t, plugin, 7, "admin", 502)
+	assert.Equal(t, table.WriteStatusReadOnly, status["status"])
+}
+
+func TestCallError(t *testing.T) {
+	plugin := table.NewPlugin("users", nil, nil)
+	status := CallError(t, plugin, osquery.ExtensionPluginRequest{"action": "bogus"})
+	assert.Equal(t, int32(1), status.Code)
+}
+
+func TestLog(t *testing.T) {
+	type entry struct {
+		typ logger.LogType
+		log string
+	}
+	var logs []entry
+	plugin := logger.NewPlugin("log", func(ctx context.Context, typ logger.LogType, log string) error {
+		logs = append(logs, entry{typ, log})
+		return nil
+	})
+
+	Log(t, plugin, logger.LogTypeString, `{"name":"q"}`)
+	Log(t, plugin, logger.LogTypeSnapshot, `{"name":"s"}`)
+	LogStatus(t, plugin, `{"s":0,"f":"a.cpp","i":1,"m":"one"}`, `{"s":1,"f":"b.cpp","i":2,"m":"two"}`)
+
+	require.Len(t, logs, 4)
+	assert.Equal(t, entry{logger.LogTypeString, `{"name":"q"}`}, logs[0])
+	assert.Equal(t, entry{logger.LogTypeSnapshot, `{"name":"s"}`}, logs[1])
+	assert.Equal(t, logger.LogTypeStatus, logs[2].typ)
+	assert.Contains(t, logs[2].log, `"m":"one"`)
+	assert.Contains(t, logs[3].log, `"m":"two"`)
+}
+
+func TestGenConfig(t *testing.T) {
+	plugin := config.NewPlugin("config", func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"main": `{"options":{}}`}, nil
+	})
+	assert.Equal(t, map[string]string{"main": `{"options":{}}`}, GenConfig(t, plugin))
+}
+
+func TestDistributed(t *testing.T) {
+	var written []distributed.Result
+	plugin := distributed.NewPlugin("dist",
+		func(ctx context.Context) (*distributed.GetQueriesResult, error) {
+			return &distributed.GetQueriesResult{Queries: map[string]string{"time": "select * from time"}}, nil
+		},
+		func(ctx context.Context, results []distributed.Result) error {
+			written = results
+			return nil
+		},
+	)
+
+	queries := GetQueries(t, plugin)
+	assert.Equal(t, map[string]string{"time": "select * from time"}, queries.Queries)
+
+	WriteResults(t, plugin,
+		distributed.Result{QueryName: "time", Rows: []map[string]string{{"hour": "1"}}},
+		distributed.Result{QueryName: "empty"},
+		distributed.Result{QueryName: "bad", Status: 1, Message: "no such table"},
+	)
+	require.Len(t, written, 3)
+	byName := map[string]distributed.Result{}
+	for _, r := range written {
+		byName[r.QueryName] = r
+	}
+	assert.Equal(t, []map[string]string{{"hour": "1"}}, byName["time"].Rows)
+	assert.Empty(t, byName["empty"].Rows)
+	assert.Equal(t, 1, byName["bad"].Status)
+	assert.Equal(t, "no such table", byName["bad"].Message)
+}
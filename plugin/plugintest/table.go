@@ -0,0 +1,151 @@
+package plugintest
+
+import (
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// QueryContext builds the query context osquery sends with the generate
+// requests of a query, see Context.
+type QueryContext struct {
+	constraints []constraintList
+	colsUsed    []string
+	limit       *int
+}
+
+type constraintList struct {
+	Name     string
+	Affinity string
+	List     []constraint
+}
+
+type constraint struct {
+	Op   table.Operator `json:"op"`
+	Expr string         `json:"expr"`
+}
+
+// Context returns the context of a query without constraints.
+func Context() *QueryContext {
+	return &QueryContext{}
+}
+
+// Where adds a constraint on the column, as for WHERE column = 'expr' with
+// table.OperatorEquals. Constraints on a column added several times are all
+// sent, as for WHERE column IN ('a', 'b'). The affinity of the column is
+// TEXT unless set with Affinity.
+func (c *QueryContext) Where(column string, op table.Operator, expr string) *QueryContext {
+	list := c.list(column)
+	list.List = append(list.List, constraint{Op: op, Expr: expr})
+	return c
+}
+
+// Affinity sets the type of the column, sent by osquery with its
+// constraints.
+func (c *QueryContext) Affinity(column string, affinity table.ColumnType) *QueryContext {
+	c.list(column).Affinity = string(affinity)
+	return c
+}
+
+// Columns sets the columns used by the query.
+func (c *QueryContext) Columns(columns ...string) *QueryContext {
+	c.colsUsed = columns
+	return c
+}
+
+// Limit sets the LIMIT of the query.
+func (c *QueryContext) Limit(limit int) *QueryContext {
+	c.limit = &limit
+	return c
+}
+
+func (c *QueryContext) list(column string) *constraintList {
+	for i := range c.constraints {
+		if c.constraints[i].Name == column {
+			return &c.constraints[i]
+		}
+	}
+	c.constraints = append(c.constraints, constraintList{Name: column, Affinity: string(table.ColumnTypeText)})
+	return &c.constraints[len(c.constraints)-1]
+}
+
+// JSON returns the context in the format of osquery.
+func (c *QueryContext) JSON(t testing.TB) string {
+	t.Helper()
+	// osquery sends an empty string rather than an empty list
+	lists := make([]map[string]interface{}, len(c.constraints))
+	for i, l := range c.constraints {
+		lists[i] = map[string]interface{}{"name": l.Name, "affinity": l.Affinity, "list": l.List}
+		if len(l.List) == 0 {
+			lists[i]["list"] = ""
+		}
+	}
+	ctx := map[string]interface{}{"constraints": lists}
+	if c.colsUsed != nil {
+		ctx["colsUsed"] = c.colsUsed
+	}
+	if c.limit != nil {
+		ctx["limit"] = *c.limit
+	}
+	return marshal(t, ctx)
+}
+
+// Generate requests the rows of a table for a query with the context, or
+// without constraints if queryContext is nil.
+func Generate(t testing.TB, plugin Plugin, queryContext *QueryContext) []map[string]string {
+	t.Helper()
+	if queryContext == nil {
+		queryContext = Context()
+	}
+	return Call(t, plugin, osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": queryContext.JSON(t),
+	})
+}
+
+// Columns requests the columns of a table, returned in the format of its
+// routes.
+func Columns(t testing.TB, plugin Plugin) []map[string]string {
+	t.Helper()
+	return Call(t, plugin, osquery.ExtensionPluginRequest{"action": "columns"})
+}
+
+// Insert inserts a row in a writable table, as for INSERT INTO with a value
+// for each column in order, nil for NULL, and returns the status map of the
+// response, with the "status" and "id" of the row. A nil id lets the table
+// choose the rowid.
+func Insert(t testing.TB, plugin Plugin, id *int64, values ...interface{}) map[string]string {
+	t.Helper()
+	request := osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"json_value_array": marshal(t, values),
+		"auto_rowid":       "true",
+	}
+	if id != nil {
+		request["auto_rowid"] = "false"
+		request["id"] = formatInt(*id)
+	}
+	return first(t, plugin, Call(t, plugin, request))
+}
+
+// Update updates the row of a writable table with the rowid, with a value
+// for each column in order, and returns the status map of the response.
+func Update(t testing.TB, plugin Plugin, id int64, values ...interface{}) map[string]string {
+	t.Helper()
+	return first(t, plugin, Call(t, plugin, osquery.ExtensionPluginRequest{
+		"action":           "update",
+		"id":               formatInt(id),
+		"json_value_array": marshal(t, values),
+	}))
+}
+
+// Delete deletes the row of a writable table with the rowid, and returns the
+// status map of the response.
+func Delete(t testing.TB, plugin Plugin, id int64) map[string]string {
+	t.Helper()
+	return first(t, plugin, Call(t, plugin, osquery.ExtensionPluginRequest{
+		"action": "delete",
+		"id":     formatInt(id),
+	}))
+}
@@ -0,0 +1,129 @@
+// Package recorder records the requests osquery sends to a plugin, and the
+// responses of the plugin, to an NDJSON file, and replays the recorded
+// requests to a plugin, so that hard-to-trigger interactions with osqueryd
+// can be reproduced in tests or while debugging.
+//
+//	rec, err := recorder.Open(config.NewPlugin("my_config", generate), "/tmp/config.ndjson")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer rec.Close()
+//	server.RegisterPlugin(rec)
+//
+// Later, the recording is replayed to the plugin:
+//
+//	entries, err := recorder.ReadFile("/tmp/config.ndjson")
+//	...
+//	for _, result := range recorder.Replay(ctx, plugin, entries) {
+//		if !result.Matches() {
+//			t.Errorf("response changed for %v", result.Entry.Request)
+//		}
+//	}
+//
+// Requests and responses are recorded as is: recordings of config and
+// logger plugins may hold secrets and should be handled accordingly.
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	gen "github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+)
+
+// Entry is a recorded call of a plugin, one line of a recording.
+type Entry struct {
+	// Time is when the call started, and Duration how long it took.
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	// Registry and Plugin identify the plugin.
+	Registry string `json:"registry"`
+	Plugin   string `json:"plugin"`
+	// Request is the request of osquery, and Status and Response the
+	// response of the plugin.
+	Request  gen.ExtensionPluginRequest  `json:"request"`
+	Status   *gen.ExtensionStatus        `json:"status"`
+	Response gen.ExtensionPluginResponse `json:"response"`
+}
+
+// Recorder is a plugin recording the calls of the plugin it wraps. It is
+// registered with an extension server in place of the wrapped plugin.
+type Recorder struct {
+	osquery.OsqueryPlugin
+
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+	err     error
+}
+
+// New returns a Recorder of the plugin writing the entries to w, one JSON
+// object per line.
+func New(plugin osquery.OsqueryPlugin, w io.Writer) *Recorder {
+	return &Recorder{OsqueryPlugin: plugin, encoder: json.NewEncoder(w)}
+}
+
+// Open returns a Recorder of the plugin appending the entries to the file
+// at path, created if needed. The file is closed by Close.
+func Open(plugin osquery.OsqueryPlugin, path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening recording")
+	}
+	r := New(plugin, file)
+	r.closer = file
+	return r, nil
+}
+
+// Call calls the wrapped plugin and records the call. Failures to record
+// don't fail the call, they are reported by Err.
+func (r *Recorder) Call(ctx context.Context, request gen.ExtensionPluginRequest) gen.ExtensionResponse {
+	start := time.Now()
+	response := r.OsqueryPlugin.Call(ctx, request)
+	entry := Entry{
+		Time:     start,
+		Duration: time.Since(start),
+		Registry: r.RegistryName(),
+		Plugin:   r.Name(),
+		Request:  request,
+		Status:   response.Status,
+		Response: response.Response,
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err == nil {
+		if err := r.encoder.Encode(entry); err != nil {
+			r.err = errors.Wrap(err, "writing recording")
+		}
+	}
+	return response
+}
+
+// Err returns the first error writing an entry. No entries are recorded
+// after an error.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+// Close closes the file of a Recorder created with Open, and returns Err.
+// The wrapped plugin isn't shut down.
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closer != nil {
+		if err := r.closer.Close(); err != nil && r.err == nil {
+			r.err = errors.Wrap(err, "closing recording")
+		}
+		r.closer = nil
+	}
+	return r.err
+}
@@ -0,0 +1,128 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	gen "github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/osquery/osquery-go/plugin/plugintest"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ osquery.OsqueryPlugin = (*Recorder)(nil)
+
+func newTable(rows ...map[string]string) *table.Plugin {
+	return table.NewPlugin("recorded", []table.ColumnDefinition{table.TextColumn("text")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return rows, nil
+		})
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.ndjson")
+	rec, err := Open(newTable(map[string]string{"text": "hello"}), path)
+	require.NoError(t, err)
+
+	rows := plugintest.Generate(t, rec, plugintest.Context().Where("text", table.OperatorEquals, "hello"))
+	assert.Equal(t, []map[string]string{{"text": "hello"}}, rows)
+	plugintest.Columns(t, rec)
+	require.NoError(t, rec.Close())
+
+	entries, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "table", entries[0].Registry)
+	assert.Equal(t, "recorded", entries[0].Plugin)
+	assert.Equal(t, "generate", entries[0].Request["action"])
+	assert.Contains(t, entries[0].Request["context"], `"expr":"hello"`)
+	assert.Equal(t, int32(0), entries[0].Status.Code)
+	assert.Equal(t, gen.ExtensionPluginResponse{{"text": "hello"}}, entries[0].Response)
+	assert.False(t, entries[0].Time.IsZero())
+	assert.Equal(t, "columns", entries[1].Request["action"])
+
+	// Replaying to the same plugin gives the same responses
+	results := Replay(context.Background(), newTable(map[string]string{"text": "hello"}), entries)
+	require.Len(t, results, 2)
+	for _, result := range results {
+		assert.True(t, result.Matches())
+	}
+
+	// A changed plugin gives different responses
+	results = Replay(context.Background(), newTable(map[string]string{"text": "bye"}), entries)
+	require.Len(t, results, 2)
+	assert.False(t, results[0].Matches())
+	assert.True(t, results[1].Matches())
+
+	// Recordings are appended to
+	rec, err = Open(newTable(), path)
+	require.NoError(t, err)
+	plugintest.Columns(t, rec)
+	require.NoError(t, rec.Close())
+	entries, err = ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestReplaySkipsOtherPlugins(t *testing.T) {
+	var buf bytes.Buffer
+	rec := New(config.NewPlugin("recorded", func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"main": "{}"}, nil
+	}), &buf)
+	plugintest.GenConfig(t, rec)
+	plugintest.Columns(t, New(newTable(), &buf))
+	require.NoError(t, rec.Err())
+
+	entries, err := Read(&buf)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	results := Replay(context.Background(), newTable(), entries)
+	require.Len(t, results, 1)
+	assert.Equal(t, "columns", results[0].Entry.Request["action"])
+}
+
+func TestReplayTiming(t *testing.T) {
+	now := time.Now()
+	entries := []Entry{
+		{Time: now, Registry: "table", Plugin: "recorded", Request: gen.ExtensionPluginRequest{"action": "columns"}},
+		{Time: now.Add(200 * time.Millisecond), Registry: "table", Plugin: "recorded", Request: gen.ExtensionPluginRequest{"action": "columns"}},
+	}
+
+	start := time.Now()
+	results := Replay(context.Background(), newTable(), entries, WithTiming())
+	assert.Len(t, results, 2)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// Replay stops once ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results = Replay(ctx, newTable(), entries, WithTiming())
+	assert.Len(t, results, 1)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRecordError(t *testing.T) {
+	rec := New(newTable(), failingWriter{})
+	// The call succeeds even though it can't be recorded
+	plugintest.Columns(t, rec)
+	assert.ErrorContains(t, rec.Err(), "disk full")
+	assert.Error(t, rec.Close())
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Read(bytes.NewBufferString("{}\nnot json\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,125 @@
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"time"
+
+	gen "github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+)
+
+// maxEntrySize is the maximum size of an entry read from a recording.
+const maxEntrySize = 64 << 20
+
+// Read returns the entries of a recording.
+func Read(r io.Reader) ([]Entry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxEntrySize)
+	var entries []Entry
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Wrapf(err, "parsing recording line %d", line)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading recording")
+	}
+	return entries, nil
+}
+
+// ReadFile returns the entries of the recording at path.
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening recording")
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// CallPlugin is the part of osquery.OsqueryPlugin used by Replay.
+type CallPlugin interface {
+	Name() string
+	RegistryName() string
+	Call(ctx context.Context, request gen.ExtensionPluginRequest) gen.ExtensionResponse
+}
+
+// Result is the response of a plugin to a replayed entry.
+type Result struct {
+	Entry    Entry
+	Response gen.ExtensionResponse
+}
+
+// Matches returns whether the response has the recorded status code and
+// rows.
+func (r Result) Matches() bool {
+	return statusCode(r.Response.Status) == statusCode(r.Entry.Status) &&
+		len(r.Response.Response) == len(r.Entry.Response) &&
+		(len(r.Entry.Response) == 0 || reflect.DeepEqual(r.Response.Response, r.Entry.Response))
+}
+
+func statusCode(status *gen.ExtensionStatus) int32 {
+	if status == nil {
+		return 0
+	}
+	return status.Code
+}
+
+// ReplayOption configures Replay.
+type ReplayOption func(*replayOptions)
+
+type replayOptions struct {
+	timing bool
+}
+
+// WithTiming spaces the replayed requests as the recorded calls were, to
+// reproduce interactions that depend on timing.
+func WithTiming() ReplayOption {
+	return func(o *replayOptions) {
+		o.timing = true
+	}
+}
+
+// Replay sends the recorded requests of the plugin to it, in order, and
+// returns its responses. Entries of other plugins, identified by their
+// registry and name, are skipped. Replay stops when ctx is done.
+func Replay(ctx context.Context, plugin CallPlugin, entries []Entry, opts ...ReplayOption) []Result {
+	var o replayOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var (
+		results     []Result
+		first, from time.Time
+	)
+	for _, entry := range entries {
+		if entry.Registry != plugin.RegistryName() || entry.Plugin != plugin.Name() {
+			continue
+		}
+		if first.IsZero() {
+			first, from = entry.Time, time.Now()
+		}
+		if o.timing {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Until(from.Add(entry.Time.Sub(first)))):
+			}
+		}
+		if ctx.Err() != nil {
+			return results
+		}
+		results = append(results, Result{Entry: entry, Response: plugin.Call(ctx, entry.Request)})
+	}
+	return results
+}
@@ -0,0 +1,261 @@
+package table
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Apply returns the rows that satisfy the constraints in the query context.
+// Tables that generate all of their rows in memory can use it to honor the
+// WHERE clause of the query before sending the rows to osquery.
+//
+// Comparisons follow the affinity of the constrained column: INTEGER, BIGINT
+// and UNSIGNED BIGINT values are compared as integers, DOUBLE values as
+// floats and TEXT values as strings. LIKE, GLOB and REGEXP are evaluated as
+// in SQLite.
+//
+// osquery sends a query with column IN (a, b) as one OperatorEquals
+// constraint per value, so the equality constraints of a column are ORed:
+// a row matches if it equals any of the values, and satisfies all the other
+// constraints of the column.
+//
+// Because osquery filters the results again, Apply is conservative: a row is
+// only removed if it definitely does not match. Rows missing a constrained
+// column, values that cannot be parsed with the column affinity and
+// constraints that cannot be evaluated (such as MATCH, or an invalid regular
+// expression) do not cause rows to be removed.
+func (qc QueryContext) Apply(rows []map[string]string) []map[string]string {
+	var matchers []constraintMatcher
+	for column, cl := range qc.Constraints {
+		var equals []constraintMatcher
+		for _, c := range cl.Constraints {
+			m := newConstraintMatcher(column, cl.Affinity, c)
+			switch {
+			case m == nil:
+			case c.Operator == OperatorEquals:
+				equals = append(equals, m)
+			default:
+				matchers = append(matchers, m)
+			}
+		}
+		if len(equals) > 0 {
+			matchers = append(matchers, anyMatcher(equals))
+		}
+	}
+	if len(matchers) == 0 {
+		return rows
+	}
+
+	results := make([]map[string]string, 0, len(rows))
+rows:
+	for _, row := range rows {
+		for _, m := range matchers {
+			if !m(row) {
+				continue rows
+			}
+		}
+		results = append(results, row)
+	}
+	return results
+}
+
+// constraintMatcher reports whether a row may satisfy a constraint.
+type constraintMatcher func(row map[string]string) bool
+
+// anyMatcher returns a matcher of the rows matched by any of the matchers.
+func anyMatcher(matchers []constraintMatcher) constraintMatcher {
+	return func(row map[string]string) bool {
+		for _, m := range matchers {
+			if m(row) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// newConstraintMatcher returns the matcher for the constraint, or nil if the
+// constraint cannot be evaluated.
+func newConstraintMatcher(column string, affinity ColumnType, c Constraint) constraintMatcher {
+	var match func(value string) bool
+	switch c.Operator {
+	case OperatorEquals, OperatorGreaterThan, OperatorGreaterThanOrEquals,
+		OperatorLessThan, OperatorLessThanOrEquals:
+		op, expr := c.Operator, c.Expression
+		match = func(value string) bool {
+			cmp, ok := compareValues(affinity, value, expr)
+			if !ok {
+				return true
+			}
+			switch op {
+			case OperatorEquals:
+				return cmp == 0
+			case OperatorGreaterThan:
+				return cmp > 0
+			case OperatorGreaterThanOrEquals:
+				return cmp >= 0
+			case OperatorLessThan:
+				return cmp < 0
+			default:
+				return cmp <= 0
+			}
+		}
+	case OperatorLike:
+		re, err := regexp.Compile(likeToRegexp(c.Expression))
+		if err != nil {
+			return nil
+		}
+		match = re.MatchString
+	case OperatorGlob:
+		re, err := regexp.Compile(globToRegexp(c.Expression))
+		if err != nil {
+			return nil
+		}
+		match = re.MatchString
+	case OperatorRegexp:
+		re, err := regexp.Compile(c.Expression)
+		if err != nil {
+			return nil
+		}
+		match = re.MatchString
+	default:
+		return nil
+	}
+
+	return func(row map[string]string) bool {
+		value, ok := row[column]
+		if !ok {
+			return true
+		}
+		return match(value)
+	}
+}
+
+// compareValues compares a column value to a constraint expression according
+// to the column affinity. The boolean result is false if the values cannot
+// be compared with that affinity.
+func compareValues(affinity ColumnType, value, expr string) (int, bool) {
+	switch affinity {
+	case ColumnTypeUnsignedBigInt:
+		a, errA := strconv.ParseUint(value, 10, 64)
+		b, errB := strconv.ParseUint(expr, 10, 64)
+		if errA == nil && errB == nil {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+		return compareValues(ColumnTypeDouble, value, expr)
+	case ColumnTypeInteger, ColumnTypeBigInt:
+		a, errA := strconv.ParseInt(value, 10, 64)
+		b, errB := strconv.ParseInt(expr, 10, 64)
+		if errA == nil && errB == nil {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+		// One of the values may be a float, which SQLite compares
+		// numerically as well.
+		fallthrough
+	case ColumnTypeDouble:
+		a, errA := strconv.ParseFloat(value, 64)
+		b, errB := strconv.ParseFloat(expr, 64)
+		if errA != nil || errB != nil {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		default:
+			return 0, true
+		}
+	default:
+		return strings.Compare(value, expr), true
+	}
+}
+
+// likeToRegexp converts an SQL LIKE pattern to a regular expression. As in
+// SQLite, % matches any sequence of characters, _ matches any single
+// character and the match is case insensitive.
+func likeToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// globToRegexp converts an SQLite GLOB pattern to a regular expression. *
+// matches any sequence of characters, ? matches any single character and
+// [...] matches a character class ([^...] negated). The match is case
+// sensitive.
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := i + 1
+			if end < len(runes) && runes[end] == '^' {
+				end++
+			}
+			// A ] immediately after the opening bracket is part of
+			// the class.
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				// Unterminated class, match [ literally.
+				b.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+			class := runes[i+1 : end]
+			b.WriteString("[")
+			if len(class) > 0 && class[0] == '^' {
+				b.WriteString("^")
+				class = class[1:]
+			}
+			for _, c := range class {
+				if c == '\\' || c == ']' || c == '[' || c == '^' {
+					b.WriteString(`\`)
+				}
+				b.WriteRune(c)
+			}
+			b.WriteString("]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
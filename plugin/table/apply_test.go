@@ -0,0 +1,170 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryContextApply(t *testing.T) {
+	rows := []map[string]string{
+		{"name": "bash", "pid": "9", "cpu": "0.5"},
+		{"name": "Bashful", "pid": "10", "cpu": "1.25"},
+		{"name": "zsh", "pid": "100", "cpu": "12"},
+		{"name": "[kworker]", "pid": "2"},
+	}
+
+	names := func(rows []map[string]string) []string {
+		var n []string
+		for _, r := range rows {
+			n = append(n, r["name"])
+		}
+		return n
+	}
+
+	var testCases = []struct {
+		name        string
+		constraints map[string]ConstraintList
+		expected    []string
+	}{
+		{
+			name:     "no constraints",
+			expected: []string{"bash", "Bashful", "zsh", "[kworker]"},
+		},
+		{
+			name: "text equals",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorEquals, "zsh"}}},
+			},
+			expected: []string{"zsh"},
+		},
+		{
+			// name IN ('bash', 'zsh') is sent as one constraint per value.
+			name: "text in",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{
+					{OperatorEquals, "bash"},
+					{OperatorEquals, "zsh"},
+				}},
+			},
+			expected: []string{"bash", "zsh"},
+		},
+		{
+			// pid IN (9, 10, 100) AND pid > 9
+			name: "integer in with range",
+			constraints: map[string]ConstraintList{
+				"pid": {ColumnTypeInteger, []Constraint{
+					{OperatorEquals, "9"},
+					{OperatorEquals, "10"},
+					{OperatorEquals, "100"},
+					{OperatorGreaterThan, "9"},
+				}},
+			},
+			expected: []string{"Bashful", "zsh"},
+		},
+		{
+			// Numeric comparison, "9" < "10" as strings would be false.
+			name: "integer range",
+			constraints: map[string]ConstraintList{
+				"pid": {ColumnTypeInteger, []Constraint{
+					{OperatorGreaterThanOrEquals, "9"},
+					{OperatorLessThan, "100"},
+				}},
+			},
+			expected: []string{"bash", "Bashful"},
+		},
+		{
+			name: "bigint compared to float",
+			constraints: map[string]ConstraintList{
+				"pid": {ColumnTypeBigInt, []Constraint{{OperatorLessThanOrEquals, "9.5"}}},
+			},
+			expected: []string{"bash", "[kworker]"},
+		},
+		{
+			// The [kworker] row has no cpu column and is kept.
+			name: "double greater than",
+			constraints: map[string]ConstraintList{
+				"cpu": {ColumnTypeDouble, []Constraint{{OperatorGreaterThan, "1"}}},
+			},
+			expected: []string{"Bashful", "zsh", "[kworker]"},
+		},
+		{
+			name: "text ordering",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorLessThan, "c"}}},
+			},
+			expected: []string{"bash", "Bashful", "[kworker]"},
+		},
+		{
+			name: "like is case insensitive",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorLike, "bash%"}}},
+			},
+			expected: []string{"bash", "Bashful"},
+		},
+		{
+			name: "like single character",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorLike, "_sh"}}},
+			},
+			expected: []string{"zsh"},
+		},
+		{
+			name: "glob is case sensitive",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorGlob, "B*"}}},
+			},
+			expected: []string{"Bashful"},
+		},
+		{
+			name: "glob character class",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorGlob, "[a-z]sh"}}},
+			},
+			expected: []string{"zsh"},
+		},
+		{
+			name: "glob literal bracket",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorGlob, "[[]k*"}}},
+			},
+			expected: []string{"[kworker]"},
+		},
+		{
+			name: "regexp",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorRegexp, "sh$"}}},
+			},
+			expected: []string{"bash", "zsh"},
+		},
+		{
+			name: "invalid regexp keeps rows",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorRegexp, "("}}},
+			},
+			expected: []string{"bash", "Bashful", "zsh", "[kworker]"},
+		},
+		{
+			name: "multiple columns",
+			constraints: map[string]ConstraintList{
+				"name": {ColumnTypeText, []Constraint{{OperatorLike, "%sh%"}}},
+				"pid":  {ColumnTypeInteger, []Constraint{{OperatorGreaterThan, "9"}}},
+			},
+			expected: []string{"Bashful", "zsh"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := QueryContext{Constraints: tt.constraints}
+			assert.Equal(t, tt.expected, names(qc.Apply(rows)))
+		})
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	assert.Equal(t, `(?s)^a.*b.c$`, globToRegexp("a*b?c"))
+	assert.Equal(t, `(?s)^[^\]x]$`, globToRegexp("[^]x]"))
+	assert.Equal(t, `(?s)^\[abc$`, globToRegexp("[abc"))
+	assert.Equal(t, `(?s)^\.txt$`, globToRegexp(".txt"))
+}
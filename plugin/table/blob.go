@@ -0,0 +1,63 @@
+package table
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+// BlobEncoding selects how binary data is represented in the string values
+// of BLOB columns. Column values are exchanged with osquery as strings, so
+// binary data must be encoded to survive the round trip through JSON.
+type BlobEncoding int
+
+const (
+	// BlobEncodingHex encodes binary data as lower case hexadecimal. This
+	// is the representation osquery uses for binary data in its own
+	// tables and is the default.
+	BlobEncodingHex BlobEncoding = iota
+	// BlobEncodingBase64 encodes binary data as standard base64.
+	BlobEncodingBase64
+)
+
+// EncodeBlob encodes binary data as a BLOB column value using the hex
+// encoding.
+func EncodeBlob(b []byte) string {
+	return BlobEncodingHex.Encode(b)
+}
+
+// DecodeBlob decodes a BLOB column value encoded with EncodeBlob, for
+// example the value of a BLOB column in a row inserted into a writable
+// table.
+func DecodeBlob(s string) ([]byte, error) {
+	return BlobEncodingHex.Decode(s)
+}
+
+// Encode encodes binary data as a BLOB column value.
+func (e BlobEncoding) Encode(b []byte) string {
+	switch e {
+	case BlobEncodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return hex.EncodeToString(b)
+	}
+}
+
+// Decode decodes a BLOB column value.
+func (e BlobEncoding) Decode(s string) ([]byte, error) {
+	var (
+		b   []byte
+		err error
+	)
+	switch e {
+	case BlobEncodingBase64:
+		b, err = base64.StdEncoding.DecodeString(s)
+	default:
+		b, err = hex.DecodeString(s)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding blob")
+	}
+	return b, nil
+}
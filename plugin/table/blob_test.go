@@ -0,0 +1,60 @@
+package table
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobEncoding(t *testing.T) {
+	data := []byte{0x00, 0xde, 0xad, 0xbe, 0xef}
+
+	assert.Equal(t, "00deadbeef", EncodeBlob(data))
+	decoded, err := DecodeBlob("00DEADBEEF")
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+
+	assert.Equal(t, "AN6tvu8=", BlobEncodingBase64.Encode(data))
+	decoded, err = BlobEncodingBase64.Decode("AN6tvu8=")
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+
+	_, err = DecodeBlob("xyz")
+	assert.Error(t, err)
+	_, err = BlobEncodingBase64.Decode("!!")
+	assert.Error(t, err)
+}
+
+func TestBlobStructColumn(t *testing.T) {
+	type certificate struct {
+		Subject string
+		DER     []byte `column:"der"`
+	}
+
+	var inserted *certificate
+	plugin, err := NewRowPlugin("certificates", certificate{},
+		func(ctx context.Context, queryContext QueryContext) (interface{}, error) {
+			return []certificate{{Subject: "example.com", DER: []byte{0x30, 0x82}}}, nil
+		},
+		WithInsertRow(func(ctx context.Context, row interface{}) (RowID, error) {
+			inserted = row.(*certificate)
+			return 1, nil
+		}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []ColumnDefinition{TextColumn("subject"), BlobColumn("der")}, plugin.columns)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"subject": "example.com", "der": "3082"}}, resp.Response)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "true",
+		"json_value_array": `["example.org", "deadbeef"]`,
+	})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, &certificate{Subject: "example.org", DER: []byte{0xde, 0xad, 0xbe, 0xef}}, inserted)
+}
@@ -0,0 +1,111 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CacheKeyFunc returns the key under which the rows generated for a query
+// context are cached. Queries with the same key share cached rows.
+type CacheKeyFunc func(queryContext QueryContext) string
+
+// WithCache returns a copy of the table plugin that caches the generated
+// rows for ttl. Rows are cached under the key returned by keyFn, or
+// QueryContextKey if keyFn is nil, so that queries with different
+// constraints don't share results. Errors are not cached.
+//
+// This is useful for tables backed by slow data sources (cloud APIs, WMI)
+// that are queried repeatedly in a short period of time.
+func WithCache(plugin *Plugin, ttl time.Duration, keyFn CacheKeyFunc) *Plugin {
+	cached := *plugin
+	cached.generate = newGenerateCache(plugin.generate, ttl, keyFn).Generate
+	return &cached
+}
+
+// QueryContextKey returns a key that identifies the constraints and used
+// columns of a query context. It is the default key of WithCache.
+func QueryContextKey(queryContext QueryContext) string {
+	type constraintsKey struct {
+		Column      string
+		Constraints []Constraint
+	}
+	key := struct {
+		Constraints []constraintsKey
+		ColumnsUsed []string
+	}{
+		ColumnsUsed: queryContext.ColumnsUsed,
+	}
+	for column, cl := range queryContext.Constraints {
+		if len(cl.Constraints) == 0 {
+			continue
+		}
+		key.Constraints = append(key.Constraints, constraintsKey{column, cl.Constraints})
+	}
+	sort.Slice(key.Constraints, func(i, j int) bool {
+		return key.Constraints[i].Column < key.Constraints[j].Column
+	})
+
+	b, _ := json.Marshal(key)
+	return string(b)
+}
+
+type cacheEntry struct {
+	rows    []map[string]string
+	expires time.Time
+}
+
+// generateCache caches the results of a GenerateFunc.
+type generateCache struct {
+	generate GenerateFunc
+	ttl      time.Duration
+	keyFn    CacheKeyFunc
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func newGenerateCache(generate GenerateFunc, ttl time.Duration, keyFn CacheKeyFunc) *generateCache {
+	if keyFn == nil {
+		keyFn = QueryContextKey
+	}
+	return &generateCache{
+		generate: generate,
+		ttl:      ttl,
+		keyFn:    keyFn,
+		now:      time.Now,
+		entries:  map[string]cacheEntry{},
+	}
+}
+
+func (c *generateCache) Generate(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+	key := c.keyFn(queryContext)
+
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	c.mutex.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.rows, nil
+	}
+
+	rows, err := c.generate(ctx, queryContext)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := c.now()
+	// Drop expired entries so that the cache doesn't grow with every
+	// distinct query.
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{rows: rows, expires: now.Add(c.ttl)}
+	return rows, nil
+}
@@ -0,0 +1,97 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCache(t *testing.T) {
+	var calls int
+	var fail bool
+	plugin := NewPlugin("slow", []ColumnDefinition{TextColumn("name")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			calls++
+			if fail {
+				return nil, errors.New("backend unavailable")
+			}
+			name, _ := queryContext.Equals("name")
+			return []map[string]string{{"name": name}}, nil
+		})
+	now := time.Unix(1700000000, 0)
+	c := newGenerateCache(plugin.generate, time.Minute, nil)
+	c.now = func() time.Time { return now }
+	cached := NewPlugin("slow", plugin.columns, c.Generate)
+
+	query := func(ctx string) osquery.ExtensionResponse {
+		return cached.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": ctx})
+	}
+	foo := `{"constraints":[{"name":"name","list":[{"op":2,"expr":"foo"}],"affinity":"TEXT"}]}`
+	bar := `{"constraints":[{"name":"name","list":[{"op":2,"expr":"bar"}],"affinity":"TEXT"}]}`
+
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "foo"}}, query(foo).Response)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "foo"}}, query(foo).Response)
+	assert.Equal(t, 1, calls)
+
+	// Different constraints are cached separately
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "bar"}}, query(bar).Response)
+	assert.Equal(t, 2, calls)
+
+	// Entries expire after the TTL
+	now = now.Add(time.Minute)
+	query(foo)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, c.entries, 1)
+
+	// Errors are not cached
+	now = now.Add(time.Minute)
+	fail = true
+	assert.Equal(t, int32(1), query(foo).Status.Code)
+	fail = false
+	assert.Equal(t, int32(0), query(foo).Status.Code)
+	assert.Equal(t, 5, calls)
+}
+
+func TestQueryContextKey(t *testing.T) {
+	a := QueryContext{Constraints: map[string]ConstraintList{
+		"name": {ColumnTypeText, []Constraint{{OperatorEquals, "foo"}}},
+		"pid":  {ColumnTypeInteger, []Constraint{{OperatorGreaterThan, "1"}}},
+		"path": {ColumnTypeText, []Constraint{}},
+	}}
+	b := QueryContext{Constraints: map[string]ConstraintList{
+		"pid":  {ColumnTypeInteger, []Constraint{{OperatorGreaterThan, "1"}}},
+		"name": {ColumnTypeText, []Constraint{{OperatorEquals, "foo"}}},
+	}}
+	assert.Equal(t, QueryContextKey(a), QueryContextKey(b))
+
+	b.Constraints["name"] = ConstraintList{ColumnTypeText, []Constraint{{OperatorEquals, "bar"}}}
+	assert.NotEqual(t, QueryContextKey(a), QueryContextKey(b))
+
+	a.ColumnsUsed = []string{"name"}
+	assert.NotEqual(t, QueryContextKey(a), QueryContextKey(QueryContext{Constraints: a.Constraints}))
+}
+
+func TestWithCustomCacheKey(t *testing.T) {
+	var calls int
+	plugin := NewPlugin("slow", []ColumnDefinition{TextColumn("name")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			calls++
+			return nil, nil
+		})
+	cached := WithCache(plugin, time.Minute, func(QueryContext) string { return "" })
+
+	for _, ctx := range []string{`{}`, `{"constraints":[{"name":"name","list":[{"op":2,"expr":"foo"}],"affinity":"TEXT"}]}`} {
+		resp := cached.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": ctx})
+		require.Equal(t, int32(0), resp.Status.Code)
+	}
+	assert.Equal(t, 1, calls)
+
+	// The original plugin is not cached
+	plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, 2, calls)
+}
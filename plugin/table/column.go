@@ -0,0 +1,163 @@
+package table
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ColumnDefinition defines the relevant information for a column in a table
+// plugin. Name and Type are mandatory. Prefer using the *Column helpers to
+// create ColumnDefinition structs.
+type ColumnDefinition struct {
+	Name    string
+	Type    ColumnType
+	Options ColumnOptions
+	// Default is the value of the column in rows inserted into a
+	// writable table without a value for it, or nil if there is no
+	// default. See ColumnDefault.
+	Default *string
+}
+
+// ColumnOptions is a bit field of the osquery column options, reported to
+// osquery as the "op" of the column route.
+type ColumnOptions int
+
+// The following column options are defined in osquery tables.h.
+const (
+	// ColumnOptionIndex marks a column that uniquely identifies a row,
+	// which allows osquery to optimize joins.
+	ColumnOptionIndex ColumnOptions = 1
+	// ColumnOptionRequired marks a column that must be constrained in the
+	// WHERE clause for the table to generate rows. Queries without a
+	// constraint on the column fail without calling the GenerateFunc.
+	ColumnOptionRequired ColumnOptions = 2
+	// ColumnOptionAdditional marks a column that can be used to generate
+	// additional rows, such as a path the table would not list by default.
+	ColumnOptionAdditional ColumnOptions = 4
+	// ColumnOptionOptimized marks a column that the table uses to optimize
+	// generation when it is constrained.
+	ColumnOptionOptimized ColumnOptions = 8
+	// ColumnOptionHidden marks a column that is not returned by SELECT *.
+	ColumnOptionHidden ColumnOptions = 16
+)
+
+// ColumnOpt sets optional attributes of a column created with the *Column
+// helpers.
+type ColumnOpt func(*ColumnDefinition)
+
+// ColumnIndex marks the column as an index. See ColumnOptionIndex.
+func ColumnIndex() ColumnOpt {
+	return withColumnOptions(ColumnOptionIndex)
+}
+
+// ColumnRequired marks the column as required. See ColumnOptionRequired.
+func ColumnRequired() ColumnOpt {
+	return withColumnOptions(ColumnOptionRequired)
+}
+
+// ColumnAdditional marks the column as additional. See
+// ColumnOptionAdditional.
+func ColumnAdditional() ColumnOpt {
+	return withColumnOptions(ColumnOptionAdditional)
+}
+
+// ColumnOptimized marks the column as optimized. See ColumnOptionOptimized.
+func ColumnOptimized() ColumnOpt {
+	return withColumnOptions(ColumnOptionOptimized)
+}
+
+// ColumnHidden hides the column from SELECT *. See ColumnOptionHidden.
+func ColumnHidden() ColumnOpt {
+	return withColumnOptions(ColumnOptionHidden)
+}
+
+// ColumnDefault sets the value used for the column when a row is inserted
+// into a writable table without a value for it, such as with INSERT ...
+// DEFAULT VALUES. The value is formatted as it would be returned to osquery
+// (for example "1" for an INTEGER column).
+func ColumnDefault(value string) ColumnOpt {
+	return func(c *ColumnDefinition) {
+		c.Default = &value
+	}
+}
+
+func withColumnOptions(options ColumnOptions) ColumnOpt {
+	return func(c *ColumnDefinition) {
+		c.Options |= options
+	}
+}
+
+func newColumn(name string, typ ColumnType, opts []ColumnOpt) ColumnDefinition {
+	c := ColumnDefinition{
+		Name: name,
+		Type: typ,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// TextColumn is a helper for defining columns containing strings.
+func TextColumn(name string, opts ...ColumnOpt) ColumnDefinition {
+	return newColumn(name, ColumnTypeText, opts)
+}
+
+// IntegerColumn is a helper for defining columns containing integers.
+func IntegerColumn(name string, opts ...ColumnOpt) ColumnDefinition {
+	return newColumn(name, ColumnTypeInteger, opts)
+}
+
+// BigIntColumn is a helper for defining columns containing big integers.
+func BigIntColumn(name string, opts ...ColumnOpt) ColumnDefinition {
+	return newColumn(name, ColumnTypeBigInt, opts)
+}
+
+// UnsignedBigIntColumn is a helper for defining columns containing unsigned
+// 64 bit integers. Use FormatUnsignedBigInt to produce the column values.
+func UnsignedBigIntColumn(name string, opts ...ColumnOpt) ColumnDefinition {
+	return newColumn(name, ColumnTypeUnsignedBigInt, opts)
+}
+
+// DoubleColumn is a helper for defining columns containing floating point
+// values.
+func DoubleColumn(name string, opts ...ColumnOpt) ColumnDefinition {
+	return newColumn(name, ColumnTypeDouble, opts)
+}
+
+// BlobColumn is a helper for defining columns containing binary data. Use
+// EncodeBlob to produce the column values.
+func BlobColumn(name string, opts ...ColumnOpt) ColumnDefinition {
+	return newColumn(name, ColumnTypeBlob, opts)
+}
+
+// ColumnType is a strongly typed representation of the data type string for a
+// column definition. The named constants should be used.
+type ColumnType string
+
+// The following column types are defined in osquery tables.h.
+const (
+	ColumnTypeText           ColumnType = "TEXT"
+	ColumnTypeInteger                   = "INTEGER"
+	ColumnTypeBigInt                    = "BIGINT"
+	ColumnTypeUnsignedBigInt            = "UNSIGNED BIGINT"
+	ColumnTypeDouble                    = "DOUBLE"
+	ColumnTypeBlob                      = "BLOB"
+)
+
+// FormatUnsignedBigInt formats a value for an UNSIGNED BIGINT column.
+func FormatUnsignedBigInt(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+// ParseUnsignedBigInt parses and validates the value of an UNSIGNED BIGINT
+// column, such as a value inserted into a writable table. Negative numbers
+// and values that overflow 64 bits are rejected.
+func ParseUnsignedBigInt(s string) (uint64, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid UNSIGNED BIGINT value %q", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,96 @@
+package table
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnOptions(t *testing.T) {
+	assert.Equal(t, ColumnDefinition{Name: "path", Type: ColumnTypeText, Options: ColumnOptionRequired | ColumnOptionIndex},
+		TextColumn("path", ColumnRequired(), ColumnIndex()))
+	assert.Equal(t, ColumnDefinition{Name: "size", Type: ColumnTypeUnsignedBigInt},
+		UnsignedBigIntColumn("size"))
+
+	plugin := NewPlugin("files",
+		[]ColumnDefinition{
+			TextColumn("path", ColumnRequired(), ColumnIndex()),
+			UnsignedBigIntColumn("inode", ColumnOptimized()),
+			IntegerColumn("pid", ColumnAdditional()),
+			BlobColumn("data", ColumnHidden()),
+		},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) { return nil, nil },
+	)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "path", "type": "TEXT", "op": "3"},
+		{"id": "column", "name": "inode", "type": "UNSIGNED BIGINT", "op": "8"},
+		{"id": "column", "name": "pid", "type": "INTEGER", "op": "4"},
+		{"id": "column", "name": "data", "type": "BLOB", "op": "16"},
+	}, plugin.Routes())
+}
+
+func TestUnsignedBigInt(t *testing.T) {
+	assert.Equal(t, "18446744073709551615", FormatUnsignedBigInt(math.MaxUint64))
+
+	v, err := ParseUnsignedBigInt("18446744073709551615")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), v)
+
+	for _, s := range []string{"-1", "18446744073709551616", "1.5", ""} {
+		_, err := ParseUnsignedBigInt(s)
+		assert.Error(t, err, s)
+	}
+
+	type disk struct {
+		Size uint64
+	}
+	plugin, err := NewRowPlugin("disks", disk{}, func(ctx context.Context, queryContext QueryContext) (interface{}, error) {
+		return []disk{{Size: math.MaxUint64}}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []ColumnDefinition{UnsignedBigIntColumn("size")}, plugin.columns)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"size": "18446744073709551615"}}, resp.Response)
+
+	// Unsigned values beyond the range of BIGINT are compared correctly.
+	qc := QueryContext{Constraints: map[string]ConstraintList{
+		"size": {ColumnTypeUnsignedBigInt, []Constraint{{OperatorGreaterThan, "9223372036854775807"}}},
+	}}
+	rows := []map[string]string{{"size": "18446744073709551615"}, {"size": "1"}}
+	assert.Equal(t, rows[:1], qc.Apply(rows))
+}
+
+func TestRequiredColumns(t *testing.T) {
+	var called bool
+	plugin := NewPlugin("file",
+		[]ColumnDefinition{
+			TextColumn("path", ColumnRequired()),
+			TextColumn("name"),
+		},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			called = true
+			path, _ := queryContext.Equals("path")
+			return []map[string]string{{"path": path}}, nil
+		})
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": `{"constraints":[{"name":"name","list":[{"op":2,"expr":"hosts"}],"affinity":"TEXT"},{"name":"path","list":"","affinity":"TEXT"}]}`,
+	})
+	assert.False(t, called)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "table file requires a constraint on column path, for example: WHERE path = ...", resp.Status.Message)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": `{"constraints":[{"name":"path","list":[{"op":2,"expr":"/etc/hosts"}],"affinity":"TEXT"}]}`,
+	})
+	assert.True(t, called)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"path": "/etc/hosts"}}, resp.Response)
+}
@@ -0,0 +1,148 @@
+package table
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ExecCommand describes the command run by a table created with
+// NewExecPlugin.
+type ExecCommand struct {
+	// Path is the executable to run. If it contains no path separators it
+	// is looked up in the PATH.
+	Path string
+	// Args are the arguments passed to the command.
+	Args []string
+	// Format is the format of the rows written by the command to stdout.
+	Format Format
+	// Env holds additional environment variables, in the form
+	// "KEY=value", set for the command on top of the environment of the
+	// extension.
+	Env []string
+	// Dir is the working directory of the command. If empty, the command
+	// runs in the working directory of the extension.
+	Dir string
+	// Timeout is the maximum run time of the command. Zero means no
+	// timeout other than the cancellation of the query.
+	Timeout time.Duration
+	// ConstraintArgs appends the equality constraints of the query to the
+	// arguments of the command, as --column=value.
+	ConstraintArgs bool
+}
+
+// NewExecPlugin creates a table plugin whose rows are generated by running
+// an external command and parsing its output in cmd.Format. Only the
+// declared columns of the output rows are returned to osquery.
+//
+// The query context is passed to the command in its environment. The
+// OSQUERY_QUERY_CONTEXT variable holds the JSON encoded constraints and used
+// columns, and for every column with an equality constraint,
+// OSQUERY_CONSTRAINT_<COLUMN> holds the constrained value (the column name is
+// upper cased). The command fails the query if it exits with a non-zero
+// status, in which case its stderr is reported in the error.
+func NewExecPlugin(name string, columns []ColumnDefinition, cmd ExecCommand, opts ...Option) *Plugin {
+	return NewPlugin(name, columns, func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		rows, err := cmd.run(ctx, columns, queryContext)
+		if err != nil {
+			return nil, err
+		}
+		return projectRows(rows, columns), nil
+	}, opts...)
+}
+
+func (c ExecCommand) run(ctx context.Context, columns []ColumnDefinition, queryContext QueryContext) ([]map[string]string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	args := append([]string{}, c.Args...)
+	env := append(os.Environ(), c.Env...)
+	contextJSON, err := json.Marshal(queryContextEnv(queryContext))
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding query context")
+	}
+	env = append(env, "OSQUERY_QUERY_CONTEXT="+string(contextJSON))
+	for _, col := range columns {
+		values := queryContext.EqualsAll(col.Name)
+		if len(values) == 0 {
+			continue
+		}
+		env = append(env, "OSQUERY_CONSTRAINT_"+strings.ToUpper(col.Name)+"="+values[0])
+		if c.ConstraintArgs {
+			for _, value := range values {
+				args = append(args, "--"+col.Name+"="+value)
+			}
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, c.Path, args...)
+	cmd.Env = env
+	cmd.Dir = c.Dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Wrapf(ctx.Err(), "running %s", c.Path)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Wrapf(err, "running %s: %s", c.Path, msg)
+		}
+		return nil, errors.Wrapf(err, "running %s", c.Path)
+	}
+
+	rows, err := ParseRows(&stdout, c.Format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "output of %s", c.Path)
+	}
+	return rows, nil
+}
+
+// queryContextEnvJSON is the JSON encoding of a query context passed to
+// external commands.
+type queryContextEnvJSON struct {
+	Constraints map[string][]constraintEnvJSON `json:"constraints"`
+	ColumnsUsed []string                       `json:"columns_used,omitempty"`
+}
+
+type constraintEnvJSON struct {
+	Operator   Operator `json:"op"`
+	Expression string   `json:"expr"`
+}
+
+func queryContextEnv(queryContext QueryContext) queryContextEnvJSON {
+	env := queryContextEnvJSON{
+		Constraints: map[string][]constraintEnvJSON{},
+		ColumnsUsed: queryContext.ColumnsUsed,
+	}
+	for column, cl := range queryContext.Constraints {
+		for _, c := range cl.Constraints {
+			env.Constraints[column] = append(env.Constraints[column], constraintEnvJSON{c.Operator, c.Expression})
+		}
+	}
+	return env
+}
+
+// projectRows returns the rows restricted to the given columns.
+func projectRows(rows []map[string]string, columns []ColumnDefinition) []map[string]string {
+	results := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		projected := make(map[string]string, len(columns))
+		for _, col := range columns {
+			if value, ok := row[col.Name]; ok {
+				projected[col.Name] = value
+			}
+		}
+		results = append(results, projected)
+	}
+	return results
+}
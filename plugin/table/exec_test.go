@@ -0,0 +1,102 @@
+package table
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExecHelperProcess is run as the command of the exec tables in the
+// tests. It writes its constraint arguments and environment as rows.
+func TestExecHelperProcess(t *testing.T) {
+	if os.Getenv("OSQUERY_GO_EXEC_HELPER") == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	switch os.Getenv("OSQUERY_GO_EXEC_HELPER") {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "something went wrong")
+		os.Exit(3)
+	case "sleep":
+		time.Sleep(time.Minute)
+	case "csv":
+		fmt.Println("name,value,extra")
+		fmt.Println("a,1,x")
+		return
+	}
+
+	var args []string
+	for i, arg := range os.Args {
+		if arg == "--" {
+			args = os.Args[i+1:]
+		}
+	}
+	fmt.Printf(`{"name": "args", "value": %q, "extra": true}`+"\n", strings.Join(args, " "))
+	fmt.Printf(`{"name": "env", "value": %q}`+"\n", os.Getenv("OSQUERY_CONSTRAINT_NAME"))
+	fmt.Printf(`{"name": "context", "value": %q}`+"\n", os.Getenv("OSQUERY_QUERY_CONTEXT"))
+}
+
+func TestExecPlugin(t *testing.T) {
+	columns := []ColumnDefinition{TextColumn("name"), TextColumn("value")}
+	// The timeout is generous as the race detector slows down the helper
+	// process, except for the command that is expected to time out.
+	helper := func(mode string, constraintArgs bool) *Plugin {
+		timeout := 30 * time.Second
+		if mode == "sleep" {
+			timeout = time.Second
+		}
+		return NewExecPlugin("exec", columns, ExecCommand{
+			Path:           os.Args[0],
+			Args:           []string{"-test.run=TestExecHelperProcess", "--"},
+			Format:         FormatNDJSON,
+			Env:            []string{"OSQUERY_GO_EXEC_HELPER=" + mode},
+			Timeout:        timeout,
+			ConstraintArgs: constraintArgs,
+		})
+	}
+	generate := func(plugin *Plugin, queryContext string) osquery.ExtensionResponse {
+		return plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": queryContext})
+	}
+
+	ctx := `{"constraints":[{"name":"name","list":[{"op":2,"expr":"foo"},{"op":2,"expr":"bar"}],"affinity":"TEXT"}]}`
+	resp := generate(helper("json", true), ctx)
+	assert.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	if assert.Len(t, resp.Response, 3) {
+		assert.Equal(t, map[string]string{"name": "args", "value": "--name=foo --name=bar"}, resp.Response[0])
+		assert.Equal(t, map[string]string{"name": "env", "value": "foo"}, resp.Response[1])
+		assert.JSONEq(t, `{"constraints":{"name":[{"op":2,"expr":"foo"},{"op":2,"expr":"bar"}]}}`, resp.Response[2]["value"])
+	}
+
+	resp = generate(helper("json", false), "{}")
+	assert.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	if assert.Len(t, resp.Response, 3) {
+		assert.Equal(t, "", resp.Response[0]["value"])
+		assert.Equal(t, "", resp.Response[1]["value"])
+	}
+
+	plugin := NewExecPlugin("exec", columns, ExecCommand{
+		Path:   os.Args[0],
+		Args:   []string{"-test.run=TestExecHelperProcess"},
+		Format: FormatCSV,
+		Env:    []string{"OSQUERY_GO_EXEC_HELPER=csv"},
+	})
+	resp = generate(plugin, "{}")
+	assert.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "a", "value": "1"}}, resp.Response)
+
+	resp = generate(helper("fail", false), "{}")
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "something went wrong")
+
+	plugin = helper("sleep", false)
+	resp = generate(plugin, "{}")
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "deadline exceeded")
+}
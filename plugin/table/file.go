@@ -0,0 +1,62 @@
+package table
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NewFilePlugin creates a table plugin exposing the rows of a file in the
+// given format, such as a JSON array of objects or a CSV file with a header.
+// Only the declared columns of the rows are returned to osquery.
+//
+// The file is read on the first query and again only when its modification
+// time or size changes, so it can be updated in place (or replaced) while
+// the extension runs. A missing file fails the query.
+func NewFilePlugin(name string, columns []ColumnDefinition, path string, format Format, opts ...Option) *Plugin {
+	f := &fileRows{path: path, format: format, columns: columns}
+	return NewPlugin(name, columns, f.Generate, opts...)
+}
+
+// fileRows caches the rows of a file until it changes.
+type fileRows struct {
+	path    string
+	format  Format
+	columns []ColumnDefinition
+
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	rows    []map[string]string
+}
+
+func (f *fileRows) Generate(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if f.rows != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.rows, nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows, err := ParseRows(file, f.format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", f.path)
+	}
+	f.rows = projectRows(rows, f.columns)
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return f.rows, nil
+}
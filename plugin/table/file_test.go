@@ -0,0 +1,50 @@
+package table
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePlugin(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts.ndjson")
+	write := func(content string, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	plugin := NewFilePlugin("hosts", []ColumnDefinition{TextColumn("name"), IntegerColumn("port")}, path, FormatNDJSON)
+	generate := func() osquery.ExtensionResponse {
+		return plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	}
+
+	// Missing file
+	resp := generate()
+	assert.Equal(t, int32(1), resp.Status.Code)
+
+	modTime := time.Unix(1700000000, 0)
+	write(`{"name": "a", "port": 80, "owner": "x"}`, modTime)
+	resp = generate()
+	assert.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "a", "port": "80"}}, resp.Response)
+
+	// Same mtime and size: the cached rows are returned
+	write(`{"name": "b", "port": 80, "owner": "x"}`, modTime)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "a", "port": "80"}}, generate().Response)
+
+	// The file is read again when it changes
+	write("{\"name\": \"b\", \"port\": 443}\n{\"name\": \"c\"}", modTime.Add(time.Second))
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "b", "port": "443"}, {"name": "c"}}, generate().Response)
+
+	write("not json", modTime.Add(2*time.Second))
+	resp = generate()
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "reading "+path)
+}
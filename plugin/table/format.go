@@ -0,0 +1,134 @@
+package table
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Format is the encoding of rows read from an external source, such as the
+// output of a command run by NewExecPlugin or a file read by NewFilePlugin.
+type Format int
+
+const (
+	// FormatJSON is a JSON array of objects, one per row.
+	FormatJSON Format = iota
+	// FormatNDJSON is newline delimited JSON, with one object per line.
+	// Blank lines are ignored.
+	FormatNDJSON
+	// FormatCSV is CSV with a header record naming the columns.
+	FormatCSV
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "JSON"
+	case FormatNDJSON:
+		return "NDJSON"
+	case FormatCSV:
+		return "CSV"
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// ParseRows reads rows in the given format. JSON values are converted to
+// strings following osquery conventions: booleans become 1 or 0, null
+// becomes an empty value and nested arrays and objects are kept as JSON.
+func ParseRows(r io.Reader, format Format) ([]map[string]string, error) {
+	switch format {
+	case FormatJSON:
+		var objects []map[string]json.RawMessage
+		dec := json.NewDecoder(r)
+		if err := dec.Decode(&objects); err != nil {
+			return nil, errors.Wrap(err, "decoding JSON rows")
+		}
+		return jsonRows(objects)
+
+	case FormatNDJSON:
+		var objects []map[string]json.RawMessage
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+				return nil, errors.Wrapf(err, "decoding NDJSON line %d", line)
+			}
+			objects = append(objects, object)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrap(err, "reading NDJSON rows")
+		}
+		return jsonRows(objects)
+
+	case FormatCSV:
+		records, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding CSV rows")
+		}
+		if len(records) == 0 {
+			return []map[string]string{}, nil
+		}
+		header, records := records[0], records[1:]
+		rows := make([]map[string]string, 0, len(records))
+		for _, record := range records {
+			row := make(map[string]string, len(header))
+			for i, name := range header {
+				row[name] = record[i]
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+	return nil, errors.Errorf("unknown format %s", format)
+}
+
+func jsonRows(objects []map[string]json.RawMessage) ([]map[string]string, error) {
+	rows := make([]map[string]string, 0, len(objects))
+	for i, object := range objects {
+		row := make(map[string]string, len(object))
+		for k, raw := range object {
+			value, err := jsonValue(raw)
+			if err != nil {
+				return nil, errors.Wrapf(err, "row %d column %s", i, k)
+			}
+			row[k] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// jsonValue converts a JSON value to a column value.
+func jsonValue(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "", nil
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	case 't':
+		return "1", nil
+	case 'f':
+		return "0", nil
+	case 'n':
+		return "", nil
+	case '[', '{':
+		var buf bytes.Buffer
+		err := json.Compact(&buf, raw)
+		return buf.String(), err
+	}
+	// Numbers are kept as they are
+	return string(raw), nil
+}
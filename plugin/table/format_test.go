@@ -0,0 +1,42 @@
+package table
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRows(t *testing.T) {
+	rows, err := ParseRows(strings.NewReader(`[
+		{"name": "a", "count": 3, "ratio": 0.5, "ok": true, "missing": null, "tags": ["x", "y"]},
+		{"name": "b", "ok": false, "meta": {"k": "v"}}
+	]`), FormatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"name": "a", "count": "3", "ratio": "0.5", "ok": "1", "missing": "", "tags": `["x","y"]`},
+		{"name": "b", "ok": "0", "meta": `{"k":"v"}`},
+	}, rows)
+
+	rows, err = ParseRows(strings.NewReader("{\"name\": \"a\"}\n\n{\"name\": \"b\"}\n"), FormatNDJSON)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"name": "a"}, {"name": "b"}}, rows)
+
+	rows, err = ParseRows(strings.NewReader("name,count\na,3\n\"b,c\",4\n"), FormatCSV)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"name": "a", "count": "3"}, {"name": "b,c", "count": "4"}}, rows)
+
+	rows, err = ParseRows(strings.NewReader(""), FormatCSV)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	_, err = ParseRows(strings.NewReader(`{"name": "a"}`), FormatJSON)
+	assert.Error(t, err)
+	_, err = ParseRows(strings.NewReader("{\"name\": \"a\"}\nnot json\n"), FormatNDJSON)
+	assert.EqualError(t, err, "decoding NDJSON line 2: invalid character 'o' in literal null (expecting 'u')")
+	_, err = ParseRows(strings.NewReader("a,b\n1\n"), FormatCSV)
+	assert.Error(t, err)
+	_, err = ParseRows(strings.NewReader(""), Format(42))
+	assert.EqualError(t, err, "unknown format Format(42)")
+}
@@ -0,0 +1,124 @@
+package table
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPSource describes the endpoint queried by a table created with
+// NewHTTPPlugin.
+type HTTPSource struct {
+	// URL is the endpoint returning the rows of the table.
+	URL string
+	// Format is the format of the response body.
+	Format Format
+	// Client is the client making the requests. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+	// Authorize, if set, is called before each request is sent, for
+	// example to set an Authorization header. An error fails the query.
+	Authorize func(req *http.Request) error
+	// Params maps column names to URL query parameters. The values of the
+	// equality constraints on a column are added to the URL as the given
+	// parameter, so that the endpoint can filter the rows.
+	Params map[string]string
+	// TTL is how long the rows of a request are cached. Requests with the
+	// same URL (including the query parameters) share the cached rows.
+	// Zero disables caching.
+	TTL time.Duration
+}
+
+// NewHTTPPlugin creates a table plugin whose rows are fetched with an HTTP
+// GET request to source.URL and parsed in source.Format. Only the declared
+// columns of the rows are returned to osquery. Responses with a status other
+// than 2xx fail the query.
+//
+// This makes it possible to expose internal inventory APIs as osquery tables
+// with little code.
+func NewHTTPPlugin(name string, columns []ColumnDefinition, source HTTPSource, opts ...Option) *Plugin {
+	generate := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		u, err := source.requestURL(queryContext)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := source.fetch(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		return projectRows(rows, columns), nil
+	}
+	if source.TTL > 0 {
+		generate = newGenerateCache(generate, source.TTL, func(queryContext QueryContext) string {
+			u, _ := source.requestURL(queryContext)
+			return u
+		}).Generate
+	}
+	return NewPlugin(name, columns, generate, opts...)
+}
+
+// requestURL returns the URL of the request for the query.
+func (s HTTPSource) requestURL(queryContext QueryContext) (string, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing URL")
+	}
+	if len(s.Params) == 0 {
+		return u.String(), nil
+	}
+
+	columns := make([]string, 0, len(s.Params))
+	for column := range s.Params {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	query := u.Query()
+	for _, column := range columns {
+		for _, value := range queryContext.EqualsAll(column) {
+			query.Add(s.Params[column], value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func (s HTTPSource) fetch(ctx context.Context, u string) ([]map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	req = req.WithContext(ctx)
+	if s.Authorize != nil {
+		if err := s.Authorize(req); err != nil {
+			return nil, errors.Wrap(err, "authorizing request")
+		}
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, errors.Errorf("GET %s: %s: %s", u, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	rows, err := ParseRows(resp.Body, s.Format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "GET %s", u)
+	}
+	return rows, nil
+}
@@ -0,0 +1,73 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPPlugin(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `[{"hostname": "web1", "owner": %q, "ignored": 1}]`, r.URL.Query().Get("team"))
+	}))
+	defer server.Close()
+
+	token := "secret"
+	columns := []ColumnDefinition{TextColumn("hostname"), TextColumn("owner")}
+	plugin := NewHTTPPlugin("inventory", columns, HTTPSource{
+		URL:    server.URL + "/hosts?active=1",
+		Format: FormatJSON,
+		Authorize: func(req *http.Request) error {
+			if token == "" {
+				return errors.New("no token")
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		},
+		Params: map[string]string{"owner": "team"},
+		TTL:    time.Minute,
+	})
+	generate := func(queryContext string) osquery.ExtensionResponse {
+		return plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": queryContext})
+	}
+	infra := `{"constraints":[{"name":"owner","list":[{"op":2,"expr":"infra"}],"affinity":"TEXT"}]}`
+
+	resp := generate(infra)
+	assert.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"hostname": "web1", "owner": "infra"}}, resp.Response)
+	assert.Equal(t, []string{"/hosts?active=1&team=infra"}, requests)
+
+	// Cached by URL
+	generate(infra)
+	assert.Len(t, requests, 1)
+	resp = generate("{}")
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"hostname": "web1", "owner": ""}}, resp.Response)
+	assert.Equal(t, "/hosts?active=1", requests[1])
+
+	// Errors
+	token = ""
+	resp = generate(`{"constraints":[{"name":"owner","list":[{"op":2,"expr":"web"}],"affinity":"TEXT"}]}`)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "authorizing request: no token")
+
+	plugin = NewHTTPPlugin("inventory", columns, HTTPSource{
+		URL:       server.URL,
+		Authorize: func(req *http.Request) error { return nil },
+	})
+	resp = generate("{}")
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "401 Unauthorized: unauthorized")
+}
@@ -0,0 +1,107 @@
+package table
+
+import "fmt"
+
+// ResultLimit caps the size of the rows a table returns to osquery. osquery
+// drops extension responses that exceed its message size limits, so tables
+// that may generate very large results should set a limit to fail (or
+// truncate) predictably instead. See WithResultLimit.
+type ResultLimit struct {
+	// MaxRows is the maximum number of rows returned. Zero means no limit.
+	MaxRows int
+	// MaxBytes is the maximum total size of the returned rows, counted as
+	// the lengths of all column names and values. It approximates the size
+	// of the serialized response. Zero means no limit.
+	MaxBytes int
+	// Truncate returns the rows that fit within the limit when it is
+	// exceeded, instead of failing the query.
+	Truncate bool
+	// Logf, if set, is called with a debug message whenever the limit is
+	// exceeded. It is compatible with log.Printf.
+	Logf func(format string, v ...interface{})
+}
+
+// ResultSizeError is the error of a query whose rows exceed the ResultLimit
+// of the table.
+type ResultSizeError struct {
+	// Table is the name of the table.
+	Table string
+	// Rows and Bytes are the size of the generated rows.
+	Rows  int
+	Bytes int
+	// Limit is the limit that was exceeded.
+	Limit ResultLimit
+}
+
+func (e *ResultSizeError) Error() string {
+	return fmt.Sprintf("table %s generated %d rows (%d bytes), exceeding the limit of %s",
+		e.Table, e.Rows, e.Bytes, e.Limit)
+}
+
+// String describes the limit, for example "100 rows and 65536 bytes".
+func (l ResultLimit) String() string {
+	switch {
+	case l.MaxRows > 0 && l.MaxBytes > 0:
+		return fmt.Sprintf("%d rows and %d bytes", l.MaxRows, l.MaxBytes)
+	case l.MaxRows > 0:
+		return fmt.Sprintf("%d rows", l.MaxRows)
+	case l.MaxBytes > 0:
+		return fmt.Sprintf("%d bytes", l.MaxBytes)
+	}
+	return "none"
+}
+
+// WithResultLimit limits the size of the rows returned by the table. When
+// the generated rows exceed the limit, the query fails with a
+// ResultSizeError, unless limit.Truncate is set.
+func WithResultLimit(limit ResultLimit) Option {
+	return func(p *Plugin) {
+		p.resultLimit = limit
+	}
+}
+
+// limitRows applies the result limit of the table to the rows.
+func (t *Plugin) limitRows(rows []map[string]string) ([]map[string]string, error) {
+	limit := t.resultLimit
+	if limit.MaxRows <= 0 && limit.MaxBytes <= 0 {
+		return rows, nil
+	}
+
+	// fit is the number of rows within the limit.
+	fit, size, fitSize := len(rows), 0, 0
+	for i, row := range rows {
+		size += rowSize(row)
+		if fit == len(rows) && ((limit.MaxRows > 0 && i >= limit.MaxRows) || (limit.MaxBytes > 0 && size > limit.MaxBytes)) {
+			fit = i
+		}
+		if fit == len(rows) {
+			fitSize = size
+		}
+	}
+	if fit == len(rows) {
+		return rows, nil
+	}
+
+	if !limit.Truncate {
+		err := &ResultSizeError{Table: t.name, Rows: len(rows), Bytes: size, Limit: limit}
+		if limit.Logf != nil {
+			limit.Logf("osquery-go: %s", err)
+		}
+		return nil, err
+	}
+
+	if limit.Logf != nil {
+		limit.Logf("osquery-go: table %s generated %d rows (%d bytes), truncated to %d rows (%d bytes) to fit the limit of %s",
+			t.name, len(rows), size, fit, fitSize, limit)
+	}
+	return rows[:fit], nil
+}
+
+// rowSize returns the approximate serialized size of a row.
+func rowSize(row map[string]string) int {
+	size := 0
+	for k, v := range row {
+		size += len(k) + len(v)
+	}
+	return size
+}
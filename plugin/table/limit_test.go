@@ -0,0 +1,53 @@
+package table
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithResultLimit(t *testing.T) {
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		// each row is 8 bytes
+		return []map[string]string{{"name": "aaaa"}, {"name": "bbbb"}, {"name": "cccc"}}, nil
+	}
+	generate := func(limit ResultLimit) osquery.ExtensionResponse {
+		plugin := NewPlugin("big", []ColumnDefinition{TextColumn("name")}, gen, WithResultLimit(limit))
+		return plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	}
+
+	// Within the limits
+	resp := generate(ResultLimit{MaxRows: 3, MaxBytes: 24})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Len(t, resp.Response, 3)
+
+	// Exceeding the limits fails the query
+	resp = generate(ResultLimit{MaxRows: 2})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "table big generated 3 rows (24 bytes), exceeding the limit of 2 rows")
+
+	var logs []string
+	logf := func(format string, v ...interface{}) { logs = append(logs, fmt.Sprintf(format, v...)) }
+	resp = generate(ResultLimit{MaxBytes: 20, Logf: logf})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0], "exceeding the limit of 20 bytes")
+
+	// Truncation returns the rows that fit
+	logs = nil
+	resp = generate(ResultLimit{MaxBytes: 20, Truncate: true, Logf: logf})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "aaaa"}, {"name": "bbbb"}}, resp.Response)
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0], "truncated to 2 rows (16 bytes)")
+
+	resp = generate(ResultLimit{MaxRows: 1, MaxBytes: 20, Truncate: true})
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "aaaa"}}, resp.Response)
+
+	err := &ResultSizeError{Table: "big", Rows: 3, Bytes: 24, Limit: ResultLimit{MaxRows: 2, MaxBytes: 20}}
+	assert.Equal(t, "table big generated 3 rows (24 bytes), exceeding the limit of 2 rows and 20 bytes", err.Error())
+}
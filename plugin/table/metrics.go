@@ -0,0 +1,119 @@
+package table
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// GenerateMetrics describes a single generation of a table's rows.
+type GenerateMetrics struct {
+	// Table is the name of the table.
+	Table string
+	// Rows is the number of rows generated.
+	Rows int
+	// Duration is how long the generation took.
+	Duration time.Duration
+	// Err is the error returned by the generation, if any.
+	Err error
+}
+
+// MetricsFunc is called after every generation of a table wrapped with
+// WithMetrics.
+type MetricsFunc func(metrics GenerateMetrics)
+
+// WithMetrics returns a copy of the table plugin that reports metrics for
+// every generation to fn. Use a MetricsRecorder to aggregate the metrics of
+// several tables.
+func WithMetrics(plugin *Plugin, fn MetricsFunc) *Plugin {
+	name, generate := plugin.name, plugin.generate
+	measured := *plugin
+	measured.generate = func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		start := time.Now()
+		rows, err := generate(ctx, queryContext)
+		fn(GenerateMetrics{
+			Table:    name,
+			Rows:     len(rows),
+			Duration: time.Since(start),
+			Err:      err,
+		})
+		return rows, err
+	}
+	return &measured
+}
+
+// TableStats are the metrics of a table aggregated by a MetricsRecorder.
+type TableStats struct {
+	// Generates is the number of generations.
+	Generates uint64
+	// Errors is the number of generations that returned an error.
+	Errors uint64
+	// Rows is the total number of rows generated.
+	Rows uint64
+	// TotalDuration is the total time spent generating rows.
+	TotalDuration time.Duration
+	// MaxDuration is the duration of the slowest generation.
+	MaxDuration time.Duration
+	// LastError is the error returned by the most recent failed
+	// generation.
+	LastError error
+}
+
+// AverageDuration returns the average duration of a generation.
+func (s TableStats) AverageDuration() time.Duration {
+	if s.Generates == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Generates)
+}
+
+// ErrorRate returns the fraction of generations that returned an error.
+func (s TableStats) ErrorRate() float64 {
+	if s.Generates == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Generates)
+}
+
+// MetricsRecorder aggregates the metrics of tables wrapped with WithMetrics.
+// It is safe for concurrent use.
+type MetricsRecorder struct {
+	mutex sync.Mutex
+	stats map[string]TableStats
+}
+
+// NewMetricsRecorder creates an empty MetricsRecorder.
+func NewMetricsRecorder() *MetricsRecorder {
+	return &MetricsRecorder{stats: map[string]TableStats{}}
+}
+
+// Record adds the metrics of a generation. It can be passed to WithMetrics.
+func (r *MetricsRecorder) Record(metrics GenerateMetrics) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s := r.stats[metrics.Table]
+	s.Generates++
+	s.Rows += uint64(metrics.Rows)
+	s.TotalDuration += metrics.Duration
+	if metrics.Duration > s.MaxDuration {
+		s.MaxDuration = metrics.Duration
+	}
+	if metrics.Err != nil {
+		s.Errors++
+		s.LastError = metrics.Err
+	}
+	r.stats[metrics.Table] = s
+}
+
+// Stats returns a snapshot of the aggregated metrics, keyed by table name.
+func (r *MetricsRecorder) Stats() map[string]TableStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := make(map[string]TableStats, len(r.stats))
+	for k, v := range r.stats {
+		stats[k] = v
+	}
+	return stats
+}
@@ -0,0 +1,59 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMetrics(t *testing.T) {
+	fail := errors.New("backend unavailable")
+	var shouldFail bool
+	plugin := NewPlugin("slow", []ColumnDefinition{TextColumn("name")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			time.Sleep(time.Millisecond)
+			if shouldFail {
+				return nil, fail
+			}
+			return []map[string]string{{"name": "a"}, {"name": "b"}}, nil
+		})
+
+	recorder := NewMetricsRecorder()
+	var last GenerateMetrics
+	measured := WithMetrics(plugin, func(m GenerateMetrics) {
+		last = m
+		recorder.Record(m)
+	})
+
+	generate := func() {
+		measured.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	}
+
+	generate()
+	assert.Equal(t, "slow", last.Table)
+	assert.Equal(t, 2, last.Rows)
+	assert.Nil(t, last.Err)
+	assert.True(t, last.Duration >= time.Millisecond)
+
+	generate()
+	shouldFail = true
+	generate()
+	assert.Equal(t, fail, last.Err)
+
+	stats := recorder.Stats()["slow"]
+	assert.Equal(t, uint64(3), stats.Generates)
+	assert.Equal(t, uint64(1), stats.Errors)
+	assert.Equal(t, uint64(4), stats.Rows)
+	assert.Equal(t, fail, stats.LastError)
+	assert.InDelta(t, 1.0/3, stats.ErrorRate(), 0.0001)
+	assert.True(t, stats.MaxDuration >= time.Millisecond)
+	assert.True(t, stats.AverageDuration() <= stats.MaxDuration)
+
+	assert.Equal(t, TableStats{}, recorder.Stats()["other"])
+	assert.Equal(t, time.Duration(0), TableStats{}.AverageDuration())
+	assert.Equal(t, 0.0, TableStats{}.ErrorRate())
+}
@@ -0,0 +1,164 @@
+package table
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// RowID is the osquery rowid of a row in a writable table.
+type RowID int64
+
+// Option configures optional behavior of a table Plugin.
+type Option func(*Plugin)
+
+// TableAttribute is a bit flag describing a table to osquery. The values are
+// defined in osquery tables.h.
+type TableAttribute int
+
+// The following table attributes are defined in osquery tables.h.
+const (
+	// TableAttributeUtility marks a table as a utility table that does not
+	// depend on the host.
+	TableAttributeUtility TableAttribute = 1
+	// TableAttributeUserBased marks a table whose results depend on the
+	// user, usually through a uid column.
+	TableAttributeUserBased TableAttribute = 2
+	// TableAttributeEventBased marks an evented table, which returns the
+	// events buffered since the previous query.
+	TableAttributeEventBased TableAttribute = 4
+	// TableAttributeCacheable marks a table whose results osquery may cache
+	// between queries in the same schedule interval.
+	TableAttributeCacheable TableAttribute = 8
+)
+
+// WithAttributes sets table attributes that are reported to osquery in the
+// table routes. Multiple attributes are combined.
+func WithAttributes(attrs ...TableAttribute) Option {
+	return func(p *Plugin) {
+		for _, attr := range attrs {
+			p.attributes |= attr
+		}
+	}
+}
+
+// WithEventBased declares the table as evented. See TableAttributeEventBased.
+func WithEventBased() Option {
+	return WithAttributes(TableAttributeEventBased)
+}
+
+// WithCacheable declares the table as cacheable. See TableAttributeCacheable.
+func WithCacheable() Option {
+	return WithAttributes(TableAttributeCacheable)
+}
+
+// RowDefinition is a value of the Go struct type that defines the columns of
+// a table created with NewRowPlugin, for example MyRow{} or &MyRow{}.
+//
+// Each exported field is a column. The column name is taken from the
+// `column` struct tag, or derived from the field name (FooBar becomes
+// foo_bar) when there is no tag. Fields tagged `column:"-"` are skipped. The
+// column type is derived from the field type: strings are TEXT, bools, signed
+// integers up to 32 bits and unsigned integers up to 16 bits are INTEGER,
+// other integers are BIGINT (UNSIGNED BIGINT for uint and uint64) and floats
+// are DOUBLE. time.Time fields are BIGINT columns holding Unix timestamps in
+// seconds, and []byte fields are BLOB columns encoded with EncodeBlob. A
+// field of type RowID holds the osquery rowid of the row and is not a column.
+type RowDefinition interface{}
+
+// GenerateRowsImpl returns the rows of a table created with NewRowPlugin. The
+// returned value must be a slice of the row struct type, or of pointers to
+// it.
+type GenerateRowsImpl func(ctx context.Context, queryContext QueryContext) (interface{}, error)
+
+// InsertRowImpl inserts a row into a table created with NewRowPlugin. The
+// row argument is a pointer to the row struct. If osquery provided an
+// explicit rowid, it is set in the RowID field (if any). The returned RowID
+// is reported to osquery as the rowid of the new row.
+type InsertRowImpl func(ctx context.Context, row interface{}) (RowID, error)
+
+// UpdateRowImpl replaces the row identified by id in a table created with
+// NewRowPlugin. The row argument is a pointer to the row struct. Its RowID
+// field (if any) holds the new rowid of the row, which is id unless the
+// update changes it.
+type UpdateRowImpl func(ctx context.Context, id RowID, row interface{}) error
+
+// DeleteRowImpl deletes the row identified by id.
+type DeleteRowImpl func(ctx context.Context, id RowID) error
+
+// NewRowPlugin creates a table plugin whose columns are defined by the fields
+// of a Go struct (see RowDefinition). The table is read-only unless
+// WithInsertRow, WithUpdateRow or WithDeleteRow are provided.
+func NewRowPlugin(name string, definition RowDefinition, generate GenerateRowsImpl, opts ...Option) (*Plugin, error) {
+	rt, err := newRowType(reflect.TypeOf(definition))
+	if err != nil {
+		return nil, errors.Wrapf(err, "table %s", name)
+	}
+
+	p := &Plugin{
+		name:    name,
+		columns: rt.columns(),
+		rowType: rt,
+		generate: func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			rows, err := generate(ctx, queryContext)
+			if err != nil {
+				return nil, err
+			}
+			return rt.encodeSlice(rows)
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// WithInsertRow makes a table created with NewRowPlugin support INSERT.
+func WithInsertRow(fn InsertRowImpl) Option {
+	return func(p *Plugin) {
+		rt := p.rowType
+		p.insert = func(ctx context.Context, id *RowID, row RowValues) (RowID, error) {
+			if rt == nil {
+				return 0, errors.New("typed insert requires a table created with NewRowPlugin")
+			}
+			v, err := rt.decode(row.strings())
+			if err != nil {
+				return 0, err
+			}
+			if id != nil {
+				rt.setRowID(v, *id)
+			}
+			return fn(ctx, v.Interface())
+		}
+	}
+}
+
+// WithUpdateRow makes a table created with NewRowPlugin support UPDATE.
+func WithUpdateRow(fn UpdateRowImpl) Option {
+	return func(p *Plugin) {
+		rt := p.rowType
+		p.update = func(ctx context.Context, id RowID, newID *RowID, row RowValues) error {
+			if rt == nil {
+				return errors.New("typed update requires a table created with NewRowPlugin")
+			}
+			v, err := rt.decode(row.strings())
+			if err != nil {
+				return err
+			}
+			if newID != nil {
+				rt.setRowID(v, *newID)
+			} else {
+				rt.setRowID(v, id)
+			}
+			return fn(ctx, id, v.Interface())
+		}
+	}
+}
+
+// WithDeleteRow makes a table support DELETE.
+func WithDeleteRow(fn DeleteRowImpl) Option {
+	return func(p *Plugin) {
+		p.delete = DeleteFunc(fn)
+	}
+}
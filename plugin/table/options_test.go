@@ -0,0 +1,222 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRow struct {
+	ID       RowID
+	Name     string
+	Size     int64 `column:"bytes"`
+	Enabled  bool  `column:"enabled"`
+	Ratio    float64
+	UserID   uint32
+	internal string
+	Ignored  string `column:"-"`
+}
+
+func TestRowPluginColumns(t *testing.T) {
+	plugin, err := NewRowPlugin("rows", testRow{}, func(ctx context.Context, queryContext QueryContext) (interface{}, error) {
+		return nil, nil
+	})
+	require.Nil(t, err)
+
+	assert.Equal(t, []ColumnDefinition{
+		TextColumn("name"),
+		BigIntColumn("bytes"),
+		IntegerColumn("enabled"),
+		DoubleColumn("ratio"),
+		BigIntColumn("user_id"),
+	}, plugin.columns)
+}
+
+func TestRowPluginInvalidDefinition(t *testing.T) {
+	gen := func(ctx context.Context, queryContext QueryContext) (interface{}, error) { return nil, nil }
+
+	_, err := NewRowPlugin("rows", "not a struct", gen)
+	assert.NotNil(t, err)
+
+	_, err = NewRowPlugin("rows", struct{ C chan int }{}, gen)
+	assert.NotNil(t, err)
+
+	_, err = NewRowPlugin("rows", struct {
+		A string `column:"a"`
+		B string `column:"a"`
+	}{}, gen)
+	assert.NotNil(t, err)
+}
+
+func TestRowPluginGenerate(t *testing.T) {
+	plugin, err := NewRowPlugin("rows", &testRow{}, func(ctx context.Context, queryContext QueryContext) (interface{}, error) {
+		return []*testRow{
+			{ID: 1, Name: "foo", Size: 1024, Enabled: true, Ratio: 0.5, UserID: 501},
+			nil,
+			{ID: 2, Name: "bar"},
+		}, nil
+	})
+	require.Nil(t, err)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"rowid": "1", "name": "foo", "bytes": "1024", "enabled": "1", "ratio": "0.5", "user_id": "501"},
+		{"rowid": "2", "name": "bar", "bytes": "0", "enabled": "0", "ratio": "0", "user_id": "0"},
+	}, resp.Response)
+}
+
+func TestRowPluginWritable(t *testing.T) {
+	rows := map[RowID]testRow{}
+	plugin, err := NewRowPlugin("rows", testRow{},
+		func(ctx context.Context, queryContext QueryContext) (interface{}, error) {
+			return nil, nil
+		},
+		WithInsertRow(func(ctx context.Context, row interface{}) (RowID, error) {
+			r := row.(*testRow)
+			if r.ID == 0 {
+				r.ID = RowID(len(rows) + 1)
+			}
+			rows[r.ID] = *r
+			return r.ID, nil
+		}),
+		WithUpdateRow(func(ctx context.Context, id RowID, row interface{}) error {
+			r := row.(*testRow)
+			delete(rows, id)
+			rows[r.ID] = *r
+			return nil
+		}),
+		WithDeleteRow(func(ctx context.Context, id RowID) error {
+			if _, ok := rows[id]; !ok {
+				return errors.New("no such row")
+			}
+			delete(rows, id)
+			return nil
+		}),
+	)
+	require.Nil(t, err)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "true",
+		"json_value_array": `["foo", 1024, 1, 0.5, null]`,
+	})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success", "id": "1"}}, resp.Response)
+	assert.Equal(t, testRow{ID: 1, Name: "foo", Size: 1024, Enabled: true, Ratio: 0.5}, rows[1])
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "false",
+		"id":               "10",
+		"json_value_array": `["bar", null, null, null, 501]`,
+	})
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success", "id": "10"}}, resp.Response)
+	assert.Equal(t, testRow{ID: 10, Name: "bar", UserID: 501}, rows[10])
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "update",
+		"id":               "10",
+		"new_id":           "11",
+		"json_value_array": `["baz", 1, 0, 1.5, 502]`,
+	})
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success"}}, resp.Response)
+	assert.NotContains(t, rows, RowID(10))
+	assert.Equal(t, testRow{ID: 11, Name: "baz", Size: 1, Ratio: 1.5, UserID: 502}, rows[11])
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "delete", "id": "11"})
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success"}}, resp.Response)
+	assert.NotContains(t, rows, RowID(11))
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "delete", "id": "11"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, StatusError("no such row"), resp.Response)
+
+	// Wrong number of values
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "true",
+		"json_value_array": `["foo"]`,
+	})
+	assert.Equal(t, int32(1), resp.Status.Code)
+
+	// Value that can't be parsed into the field
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "true",
+		"json_value_array": `["foo", "big", null, null, null]`,
+	})
+	assert.Equal(t, int32(1), resp.Status.Code)
+}
+
+func TestRowPluginReadOnly(t *testing.T) {
+	plugin, err := NewRowPlugin("rows", testRow{}, func(ctx context.Context, queryContext QueryContext) (interface{}, error) {
+		return nil, nil
+	})
+	require.Nil(t, err)
+
+	for _, action := range []string{"insert", "update", "delete"} {
+		resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+			"action":           action,
+			"id":               "1",
+			"json_value_array": `["foo", 1, 1, 1, 1]`,
+		})
+		assert.Equal(t, int32(0), resp.Status.Code, action)
+		assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "readonly"}}, resp.Response, action)
+	}
+}
+
+func TestTableAttributes(t *testing.T) {
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) { return nil, nil }
+
+	plugin := NewPlugin("events", []ColumnDefinition{TextColumn("text")}, gen, WithEventBased(), WithCacheable())
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "text", "type": "TEXT", "op": "0"},
+		{"id": "attributes", "attributes": "12"},
+	}, plugin.Routes())
+
+	plugin = NewPlugin("users", []ColumnDefinition{TextColumn("text")}, gen,
+		WithAttributes(TableAttributeUserBased, TableAttributeUtility))
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "text", "type": "TEXT", "op": "0"},
+		{"id": "attributes", "attributes": "3"},
+	}, plugin.Routes())
+
+	// No attributes route by default
+	plugin = NewPlugin("plain", []ColumnDefinition{TextColumn("text")}, gen)
+	assert.Len(t, plugin.Routes(), 1)
+}
+
+func TestRowsFromStructs(t *testing.T) {
+	type user struct {
+		UID      int64
+		Username string `column:"username"`
+		Admin    bool
+	}
+
+	rows, err := RowsFromStructs([]user{{UID: 501, Username: "alice", Admin: true}, {UID: 502, Username: "bob"}})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"uid": "501", "username": "alice", "admin": "1"},
+		{"uid": "502", "username": "bob", "admin": "0"},
+	}, rows)
+
+	rows, err = RowsFromStructs([]*user{{UID: 0, Username: "root"}, nil})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"uid": "0", "username": "root", "admin": "0"}}, rows)
+
+	rows, err = RowsFromStructs([]user{})
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	_, err = RowsFromStructs(user{})
+	assert.Error(t, err)
+	_, err = RowsFromStructs(nil)
+	assert.Error(t, err)
+	_, err = RowsFromStructs([]string{"foo"})
+	assert.Error(t, err)
+}
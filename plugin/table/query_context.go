@@ -0,0 +1,72 @@
+package table
+
+// ConstraintsFor returns the constraints on the given column, or nil if there
+// are none.
+func (qc QueryContext) ConstraintsFor(column string) []Constraint {
+	return qc.Constraints[column].Constraints
+}
+
+// HasConstraint returns whether the query constrains the given column with
+// the given operator.
+func (qc QueryContext) HasConstraint(column string, op Operator) bool {
+	for _, c := range qc.ConstraintsFor(column) {
+		if c.Operator == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Equals returns the expression of the first equality constraint on the given
+// column (as in WHERE column = 'value'). The boolean result is false if there
+// is no such constraint.
+func (qc QueryContext) Equals(column string) (string, bool) {
+	for _, c := range qc.ConstraintsFor(column) {
+		if c.Operator == OperatorEquals {
+			return c.Expression, true
+		}
+	}
+	return "", false
+}
+
+// EqualsAll returns the expressions of all the equality constraints on the
+// given column. osquery provides one equality constraint per value for
+// queries like WHERE column IN ('a', 'b'), so this is how a table can
+// generate only the requested rows.
+func (qc QueryContext) EqualsAll(column string) []string {
+	var values []string
+	for _, c := range qc.ConstraintsFor(column) {
+		if c.Operator == OperatorEquals {
+			values = append(values, c.Expression)
+		}
+	}
+	return values
+}
+
+// IsColumnUsed returns whether the query uses the given column. Tables can use
+// it to skip computing expensive columns the query did not ask for. It
+// returns true for every column if osquery did not report the used columns.
+func (qc QueryContext) IsColumnUsed(column string) bool {
+	if qc.ColumnsUsed == nil {
+		return true
+	}
+	for _, c := range qc.ColumnsUsed {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the LIMIT of the query, if osquery provided one in the query
+// context. Expensive tables can use it to stop generating rows early. Note
+// that osquery applies the limit after filtering the generated rows with the
+// constraints, so it is only safe to stop early when the table honors all of
+// the constraints (for example, by using Apply). The boolean result is false
+// if there is no limit, or if the osquery version does not report it.
+func (qc QueryContext) Limit() (int, bool) {
+	if qc.limit == nil || *qc.limit < 0 {
+		return 0, false
+	}
+	return *qc.limit, true
+}
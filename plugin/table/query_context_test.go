@@ -0,0 +1,83 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryContextHelpers(t *testing.T) {
+	qc := QueryContext{Constraints: map[string]ConstraintList{
+		"path": ConstraintList{ColumnTypeText, []Constraint{
+			{OperatorEquals, "/etc/hosts"},
+			{OperatorLike, "/etc/%"},
+			{OperatorEquals, "/etc/passwd"},
+		}},
+		"size": ConstraintList{ColumnTypeBigInt, []Constraint{
+			{OperatorGreaterThan, "100"},
+		}},
+		"mode": ConstraintList{ColumnTypeText, []Constraint{}},
+	}}
+
+	assert.Len(t, qc.ConstraintsFor("path"), 3)
+	assert.Empty(t, qc.ConstraintsFor("mode"))
+	assert.Nil(t, qc.ConstraintsFor("missing"))
+
+	assert.True(t, qc.HasConstraint("path", OperatorLike))
+	assert.True(t, qc.HasConstraint("size", OperatorGreaterThan))
+	assert.False(t, qc.HasConstraint("size", OperatorEquals))
+	assert.False(t, qc.HasConstraint("missing", OperatorEquals))
+
+	val, ok := qc.Equals("path")
+	assert.True(t, ok)
+	assert.Equal(t, "/etc/hosts", val)
+	_, ok = qc.Equals("size")
+	assert.False(t, ok)
+	_, ok = qc.Equals("missing")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"/etc/hosts", "/etc/passwd"}, qc.EqualsAll("path"))
+	assert.Nil(t, qc.EqualsAll("size"))
+
+	// The zero QueryContext has no constraints.
+	assert.Nil(t, QueryContext{}.ConstraintsFor("path"))
+	_, ok = QueryContext{}.Equals("path")
+	assert.False(t, ok)
+}
+
+func TestQueryContextColumnsUsed(t *testing.T) {
+	qc, err := parseQueryContext(`{"constraints":[],"colsUsed":["name","pid"],"colsUsedBitset":3}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "pid"}, qc.ColumnsUsed)
+	assert.True(t, qc.IsColumnUsed("name"))
+	assert.True(t, qc.IsColumnUsed("pid"))
+	assert.False(t, qc.IsColumnUsed("cmdline"))
+
+	// Older osquery versions don't report the used columns.
+	qc, err = parseQueryContext(`{"constraints":[]}`)
+	require.NoError(t, err)
+	assert.Nil(t, qc.ColumnsUsed)
+	assert.True(t, qc.IsColumnUsed("cmdline"))
+}
+
+func TestQueryContextLimit(t *testing.T) {
+	qc, err := parseQueryContext(`{"constraints":[],"limit":10}`)
+	require.NoError(t, err)
+	limit, ok := qc.Limit()
+	assert.True(t, ok)
+	assert.Equal(t, 10, limit)
+
+	qc, err = parseQueryContext(`{"constraints":[],"limit":-1}`)
+	require.NoError(t, err)
+	_, ok = qc.Limit()
+	assert.False(t, ok)
+
+	qc, err = parseQueryContext(`{"constraints":[]}`)
+	require.NoError(t, err)
+	_, ok = qc.Limit()
+	assert.False(t, ok)
+
+	_, ok = QueryContext{}.Limit()
+	assert.False(t, ok)
+}
@@ -0,0 +1,83 @@
+package table
+
+import (
+	"strconv"
+	"time"
+)
+
+// Row is a generated table row, mapping column names to values. It has
+// setters that format Go values following osquery conventions. Since a Row
+// is a map[string]string, it can be returned directly from a GenerateFunc:
+//
+//	row := table.NewRow().
+//		SetInt("pid", int64(p.Pid)).
+//		SetString("name", p.Name).
+//		SetTime("start_time", p.Started).
+//		SetBool("on_disk", p.OnDisk)
+//	rows = append(rows, row)
+type Row map[string]string
+
+// NewRow returns an empty Row.
+func NewRow() Row {
+	return Row{}
+}
+
+// SetString sets a TEXT column.
+func (r Row) SetString(column, value string) Row {
+	r[column] = value
+	return r
+}
+
+// SetInt sets an INTEGER or BIGINT column.
+func (r Row) SetInt(column string, value int64) Row {
+	r[column] = strconv.FormatInt(value, 10)
+	return r
+}
+
+// SetUint sets an UNSIGNED BIGINT column (or any integer column, if the
+// value is in range).
+func (r Row) SetUint(column string, value uint64) Row {
+	r[column] = FormatUnsignedBigInt(value)
+	return r
+}
+
+// SetFloat sets a DOUBLE column.
+func (r Row) SetFloat(column string, value float64) Row {
+	r[column] = strconv.FormatFloat(value, 'f', -1, 64)
+	return r
+}
+
+// SetBool sets an INTEGER column to 1 if value is true and 0 otherwise, as
+// osquery represents booleans.
+func (r Row) SetBool(column string, value bool) Row {
+	r[column] = formatBool(value)
+	return r
+}
+
+// SetTime sets a BIGINT column to the time as a Unix timestamp in seconds,
+// which is how osquery represents times. The zero time is set as an empty
+// value.
+func (r Row) SetTime(column string, value time.Time) Row {
+	r[column] = formatTime(value)
+	return r
+}
+
+// SetBlob sets a BLOB column to data encoded with EncodeBlob.
+func (r Row) SetBlob(column string, data []byte) Row {
+	r[column] = EncodeBlob(data)
+	return r
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
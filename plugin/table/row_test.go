@@ -0,0 +1,46 @@
+package table
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRow(t *testing.T) {
+	row := NewRow().
+		SetString("name", "launchd").
+		SetInt("pid", 1).
+		SetInt("parent", -1).
+		SetUint("size", math.MaxUint64).
+		SetFloat("cpu", 0.25).
+		SetBool("on_disk", true).
+		SetBool("elevated", false).
+		SetTime("start_time", time.Unix(1700000000, 0)).
+		SetTime("end_time", time.Time{}).
+		SetBlob("hash", []byte{0xca, 0xfe})
+
+	assert.Equal(t, Row{
+		"name":       "launchd",
+		"pid":        "1",
+		"parent":     "-1",
+		"size":       "18446744073709551615",
+		"cpu":        "0.25",
+		"on_disk":    "1",
+		"elevated":   "0",
+		"start_time": "1700000000",
+		"end_time":   "",
+		"hash":       "cafe",
+	}, row)
+
+	// Rows can be returned from a GenerateFunc directly.
+	plugin := NewPlugin("processes", []ColumnDefinition{TextColumn("name")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			return []map[string]string{NewRow().SetString("name", "init")}, nil
+		})
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "init"}}, resp.Response)
+}
@@ -0,0 +1,69 @@
+package table
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// processStart approximates the start time of the extension process.
+var processStart = time.Now()
+
+// NewRuntimePlugin creates a table named <extension>_go_runtime that returns
+// a single row with the Go runtime statistics of the extension process:
+// goroutines, heap and GC statistics, uptime and versions. Registering it
+// lets operators check the health of the extension from osquery itself.
+func NewRuntimePlugin(extension string, opts ...Option) *Plugin {
+	columns := []ColumnDefinition{
+		IntegerColumn("pid"),
+		TextColumn("go_version"),
+		TextColumn("module_version"),
+		BigIntColumn("uptime"),
+		IntegerColumn("goroutines"),
+		IntegerColumn("num_cpu"),
+		IntegerColumn("gomaxprocs"),
+		UnsignedBigIntColumn("heap_alloc"),
+		UnsignedBigIntColumn("heap_sys"),
+		UnsignedBigIntColumn("heap_objects"),
+		UnsignedBigIntColumn("total_alloc"),
+		UnsignedBigIntColumn("sys"),
+		BigIntColumn("num_gc"),
+		UnsignedBigIntColumn("gc_pause_total_ns"),
+		BigIntColumn("last_gc"),
+	}
+	return NewPlugin(extension+"_go_runtime", columns, generateRuntime, opts...)
+}
+
+func generateRuntime(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	var moduleVersion string
+	if info, ok := debug.ReadBuildInfo(); ok {
+		moduleVersion = info.Main.Version
+	}
+	var lastGC time.Time
+	if mem.LastGC != 0 {
+		lastGC = time.Unix(0, int64(mem.LastGC))
+	}
+
+	row := NewRow().
+		SetInt("pid", int64(os.Getpid())).
+		SetString("go_version", runtime.Version()).
+		SetString("module_version", moduleVersion).
+		SetInt("uptime", int64(time.Since(processStart).Seconds())).
+		SetInt("goroutines", int64(runtime.NumGoroutine())).
+		SetInt("num_cpu", int64(runtime.NumCPU())).
+		SetInt("gomaxprocs", int64(runtime.GOMAXPROCS(0))).
+		SetUint("heap_alloc", mem.HeapAlloc).
+		SetUint("heap_sys", mem.HeapSys).
+		SetUint("heap_objects", mem.HeapObjects).
+		SetUint("total_alloc", mem.TotalAlloc).
+		SetUint("sys", mem.Sys).
+		SetInt("num_gc", int64(mem.NumGC)).
+		SetUint("gc_pause_total_ns", mem.PauseTotalNs).
+		SetTime("last_gc", lastGC)
+	return []map[string]string{row}, nil
+}
@@ -0,0 +1,28 @@
+package table
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimePlugin(t *testing.T) {
+	plugin := NewRuntimePlugin("myext", WithRowValidation(ValidateError))
+	assert.Equal(t, "myext_go_runtime", plugin.Name())
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	require.Len(t, resp.Response, 1)
+
+	row := resp.Response[0]
+	assert.Equal(t, runtime.Version(), row["go_version"])
+	goroutines, err := strconv.Atoi(row["goroutines"])
+	require.NoError(t, err)
+	assert.True(t, goroutines > 0)
+	assert.Len(t, row, len(plugin.columns))
+}
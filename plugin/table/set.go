@@ -0,0 +1,123 @@
+package table
+
+import (
+	"context"
+	"sync"
+)
+
+// Set groups tables that share state, such as a client for the API the
+// tables query, and manages the initialization and shutdown of that state.
+//
+// The tables returned by Tables should be registered with the extension
+// server instead of the tables the Set was created with. The init function
+// runs before the first call to any of them, and is retried on the next call
+// if it fails. The shutdown function runs once, when Shutdown is called on
+// the Set or any of its tables.
+type Set struct {
+	tables []*Plugin
+
+	init     func(ctx context.Context) error
+	teardown func()
+
+	mutex       sync.Mutex
+	initialized bool
+	closed      bool
+}
+
+// NewSet creates a Set of tables with shared initialization and shutdown.
+// Either init or shutdown may be nil.
+func NewSet(init func(ctx context.Context) error, shutdown func(), tables ...*Plugin) *Set {
+	s := &Set{
+		init:     init,
+		teardown: shutdown,
+	}
+	for _, t := range tables {
+		s.tables = append(s.tables, s.wrap(t))
+	}
+	return s
+}
+
+// Tables returns the tables of the Set, to be registered with the extension
+// server:
+//
+//	for _, t := range set.Tables() {
+//		server.RegisterPlugin(t)
+//	}
+func (s *Set) Tables() []*Plugin {
+	return s.tables
+}
+
+// Init runs the shared initialization if it hasn't run successfully yet.
+// It is called automatically before the first call to any of the tables, but
+// may be called explicitly to detect errors at startup.
+func (s *Set) Init(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.initialized || s.init == nil {
+		return nil
+	}
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+	s.initialized = true
+	return nil
+}
+
+// Shutdown runs the shared shutdown function. Only the first call has any
+// effect.
+func (s *Set) Shutdown() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.teardown != nil {
+		s.teardown()
+	}
+}
+
+// wrap returns a copy of the table that initializes the Set before each
+// call and shuts it down with the table.
+func (s *Set) wrap(t *Plugin) *Plugin {
+	wrapped := *t
+	wrapped.shutdown = func() {
+		t.Shutdown()
+		s.Shutdown()
+	}
+
+	generate := t.generate
+	wrapped.generate = func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		if err := s.Init(ctx); err != nil {
+			return nil, err
+		}
+		return generate(ctx, queryContext)
+	}
+	if insert := t.insert; insert != nil {
+		wrapped.insert = func(ctx context.Context, id *RowID, row RowValues) (RowID, error) {
+			if err := s.Init(ctx); err != nil {
+				return 0, err
+			}
+			return insert(ctx, id, row)
+		}
+	}
+	if update := t.update; update != nil {
+		wrapped.update = func(ctx context.Context, id RowID, newID *RowID, row RowValues) error {
+			if err := s.Init(ctx); err != nil {
+				return err
+			}
+			return update(ctx, id, newID, row)
+		}
+	}
+	if del := t.delete; del != nil {
+		wrapped.delete = func(ctx context.Context, id RowID) error {
+			if err := s.Init(ctx); err != nil {
+				return err
+			}
+			return del(ctx, id)
+		}
+	}
+	return &wrapped
+}
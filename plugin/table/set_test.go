@@ -0,0 +1,81 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet(t *testing.T) {
+	type apiClient struct {
+		users, groups []string
+	}
+
+	var (
+		client    *apiClient
+		initCalls int
+		shutdowns int
+		initErr   = errors.New("auth failed")
+	)
+	set := NewSet(
+		func(ctx context.Context) error {
+			initCalls++
+			if initCalls == 1 {
+				return initErr
+			}
+			client = &apiClient{users: []string{"alice"}, groups: []string{"admins"}}
+			return nil
+		},
+		func() {
+			shutdowns++
+			client = nil
+		},
+		NewPlugin("api_users", []ColumnDefinition{TextColumn("name")},
+			func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+				return []map[string]string{{"name": client.users[0]}}, nil
+			}),
+		NewPlugin("api_groups", []ColumnDefinition{TextColumn("name")},
+			func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+				return []map[string]string{{"name": client.groups[0]}}, nil
+			},
+			WithDeleteRow(func(ctx context.Context, id RowID) error {
+				client.groups = nil
+				return nil
+			})),
+	)
+
+	tables := set.Tables()
+	require.Len(t, tables, 2)
+	assert.Equal(t, "api_users", tables[0].Name())
+	assert.Equal(t, "api_groups", tables[1].Name())
+
+	generate := func(p *Plugin) osquery.ExtensionResponse {
+		return p.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	}
+
+	// A failed initialization is reported and retried
+	resp := generate(tables[0])
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, "auth failed")
+
+	resp = generate(tables[0])
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "alice"}}, resp.Response)
+	resp = generate(tables[1])
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "admins"}}, resp.Response)
+	assert.Equal(t, 2, initCalls)
+
+	resp = tables[1].Call(context.Background(), osquery.ExtensionPluginRequest{"action": "delete", "id": "1"})
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success"}}, resp.Response)
+	assert.Nil(t, client.groups)
+
+	// Shutting down any table shuts the set down, once
+	tables[0].Shutdown()
+	tables[1].Shutdown()
+	set.Shutdown()
+	assert.Equal(t, 1, shutdowns)
+	assert.Nil(t, client)
+}
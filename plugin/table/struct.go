@@ -0,0 +1,326 @@
+package table
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	rowIDType = reflect.TypeOf(RowID(0))
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// rowType maps the fields of a struct type to table columns.
+type rowType struct {
+	typ    reflect.Type
+	fields []structColumn
+	// rowID is the index of the RowID field, or nil if there is none.
+	rowID []int
+}
+
+// structColumn is a struct field holding a column value.
+type structColumn struct {
+	index  []int
+	column ColumnDefinition
+}
+
+func newRowType(t reflect.Type) (*rowType, error) {
+	if t == nil {
+		return nil, errors.New("row definition must be a struct")
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("row definition must be a struct, got %s", t)
+	}
+
+	rt := &rowType{typ: t}
+	seen := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		if f.Type == rowIDType {
+			rt.rowID = f.Index
+			continue
+		}
+
+		name := f.Tag.Get("column")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = columnName(f.Name)
+		}
+		if seen[name] {
+			return nil, errors.Errorf("duplicate column %s", name)
+		}
+		seen[name] = true
+
+		typ, err := columnTypeOf(f.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %s", f.Name)
+		}
+		rt.fields = append(rt.fields, structColumn{
+			index:  f.Index,
+			column: ColumnDefinition{Name: name, Type: typ},
+		})
+	}
+
+	if len(rt.fields) == 0 {
+		return nil, errors.Errorf("%s has no columns", t)
+	}
+	return rt, nil
+}
+
+func (rt *rowType) columns() []ColumnDefinition {
+	cols := make([]ColumnDefinition, 0, len(rt.fields))
+	for _, f := range rt.fields {
+		cols = append(cols, f.column)
+	}
+	return cols
+}
+
+// encodeSlice converts a slice of rows (structs or pointers to structs) into
+// osquery rows.
+func (rt *rowType) encodeSlice(rows interface{}) ([]map[string]string, error) {
+	v := reflect.ValueOf(rows)
+	if !v.IsValid() {
+		return []map[string]string{}, nil
+	}
+	if v.Kind() != reflect.Slice {
+		return nil, errors.Errorf("generated rows must be a slice, got %s", v.Type())
+	}
+
+	results := make([]map[string]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		row, err := rt.encode(v.Index(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "row %d", i)
+		}
+		if row != nil {
+			results = append(results, row)
+		}
+	}
+	return results, nil
+}
+
+// encode converts a single struct (or pointer to struct) into an osquery row.
+// A nil pointer results in a nil row.
+func (rt *rowType) encode(v reflect.Value) (map[string]string, error) {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() != rt.typ {
+		return nil, errors.Errorf("expected %s, got %s", rt.typ, v.Type())
+	}
+
+	row := make(map[string]string, len(rt.fields)+1)
+	for _, f := range rt.fields {
+		row[f.column.Name] = formatValue(v.FieldByIndex(f.index))
+	}
+	if rt.rowID != nil {
+		row["rowid"] = strconv.FormatInt(v.FieldByIndex(rt.rowID).Int(), 10)
+	}
+	return row, nil
+}
+
+// decode converts an osquery row into a pointer to a new struct. Columns
+// missing from the row are left at their zero value.
+func (rt *rowType) decode(row map[string]string) (reflect.Value, error) {
+	v := reflect.New(rt.typ)
+	for _, f := range rt.fields {
+		s, ok := row[f.column.Name]
+		if !ok {
+			continue
+		}
+		if err := parseValue(s, v.Elem().FieldByIndex(f.index)); err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "column %s", f.column.Name)
+		}
+	}
+	return v, nil
+}
+
+// setRowID sets the RowID field of the struct pointed to by v, if there is
+// one.
+func (rt *rowType) setRowID(v reflect.Value, id RowID) {
+	if rt.rowID != nil {
+		v.Elem().FieldByIndex(rt.rowID).SetInt(int64(id))
+	}
+}
+
+// columnName converts a Go field name to a snake_case column name.
+func columnName(field string) string {
+	var b strings.Builder
+	runes := []rune(field)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word at a lower to upper transition, or at
+			// the last upper case letter of an acronym (IDValue ->
+			// id_value).
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// columnTypeOf returns the column type used for fields of type t.
+func columnTypeOf(t reflect.Type) (ColumnType, error) {
+	switch t {
+	case timeType:
+		return ColumnTypeBigInt, nil
+	case bytesType:
+		return ColumnTypeBlob, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return ColumnTypeText, nil
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return ColumnTypeInteger, nil
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return ColumnTypeBigInt, nil
+	case reflect.Uint, reflect.Uint64:
+		return ColumnTypeUnsignedBigInt, nil
+	case reflect.Float32, reflect.Float64:
+		return ColumnTypeDouble, nil
+	default:
+		return "", errors.Errorf("unsupported column type %s", t)
+	}
+}
+
+// formatValue formats a struct field as an osquery column value. Times are
+// formatted as Unix timestamps in seconds, with the zero time formatted as an
+// empty value. Byte slices are encoded with EncodeBlob.
+func formatValue(v reflect.Value) string {
+	switch v.Type() {
+	case timeType:
+		return formatTime(v.Interface().(time.Time))
+	case bytesType:
+		return EncodeBlob(v.Bytes())
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return formatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// parseValue parses an osquery column value into a struct field.
+func parseValue(s string, v reflect.Value) error {
+	switch v.Type() {
+	case timeType:
+		if s == "" {
+			v.Set(reflect.ValueOf(time.Time{}))
+			return nil
+		}
+		sec, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(time.Unix(sec, 0)))
+		return nil
+	case bytesType:
+		b, err := DecodeBlob(s)
+		if err != nil {
+			return err
+		}
+		v.SetBytes(b)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := parseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			v.SetInt(0)
+			return nil
+		}
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			v.SetUint(0)
+			return nil
+		}
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			v.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errors.Errorf("unsupported column type %s", v.Type())
+	}
+	return nil
+}
+
+// parseBool parses the osquery representation of a boolean ("1"/"0"), also
+// accepting the values understood by strconv.ParseBool.
+func parseBool(s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+// RowsFromStructs converts a slice of structs, or of pointers to structs,
+// into osquery rows. Columns are mapped from the struct fields as described
+// for RowDefinition. Nil pointers are skipped.
+func RowsFromStructs(slice interface{}) ([]map[string]string, error) {
+	t := reflect.TypeOf(slice)
+	if t == nil || t.Kind() != reflect.Slice {
+		return nil, errors.Errorf("expected a slice of structs, got %T", slice)
+	}
+	rt, err := newRowType(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return rt.encodeSlice(slice)
+}
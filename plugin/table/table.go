@@ -21,14 +21,39 @@ type Plugin struct {
 	name     string
 	columns  []ColumnDefinition
 	generate GenerateFunc
+
+	// The following are set for writable tables, see Option.
+	insert InsertFunc
+	update UpdateFunc
+	delete DeleteFunc
+
+	// rowType is the struct type of tables created with NewRowPlugin.
+	rowType *rowType
+
+	// attributes are the table attributes reported in the routes, see
+	// WithAttributes.
+	attributes TableAttribute
+
+	// validation is the row validation mode, see WithRowValidation.
+	validation ValidationMode
+
+	// resultLimit caps the size of generated rows, see WithResultLimit.
+	resultLimit ResultLimit
+
+	// shutdown is called by Shutdown, if set. See Set.
+	shutdown func()
 }
 
-func NewPlugin(name string, columns []ColumnDefinition, gen GenerateFunc) *Plugin {
-	return &Plugin{
+func NewPlugin(name string, columns []ColumnDefinition, gen GenerateFunc, opts ...Option) *Plugin {
+	p := &Plugin{
 		name:     name,
 		columns:  columns,
 		generate: gen,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (t *Plugin) Name() string {
@@ -46,15 +71,22 @@ func (t *Plugin) Routes() osquery.ExtensionPluginResponse {
 			"id":   "column",
 			"name": col.Name,
 			"type": string(col.Type),
-			"op":   "0",
+			"op":   strconv.Itoa(int(col.Options)),
+		})
+	}
+	if t.attributes != 0 {
+		routes = append(routes, map[string]string{
+			"id":         "attributes",
+			"attributes": strconv.Itoa(int(t.attributes)),
 		})
 	}
 	return routes
 }
 
-func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) (resp osquery.ExtensionResponse) {
 	ctx, span := traces.StartSpan(ctx, "Table.Call", "action", request["action"])
 	defer span.End()
+	defer func() { traces.RecordStatus(span, resp.Status) }()
 
 	ok := osquery.ExtensionStatus{Code: 0, Message: "OK"}
 	switch request["action"] {
@@ -69,7 +101,22 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 			}
 		}
 
+		if err := t.checkRequiredColumns(*queryContext); err != nil {
+			return osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: err.Error(),
+				},
+			}
+		}
+
 		rows, err := t.generate(ctx, *queryContext)
+		if err == nil {
+			rows, err = t.validateRows(rows)
+		}
+		if err == nil {
+			rows, err = t.limitRows(rows)
+		}
 		if err != nil {
 			return osquery.ExtensionResponse{
 				Status: &osquery.ExtensionStatus{
@@ -90,6 +137,15 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 			Response: t.Routes(),
 		}
 
+	case "insert":
+		return t.callInsert(ctx, request)
+
+	case "update":
+		return t.callUpdate(ctx, request)
+
+	case "delete":
+		return t.callDelete(ctx, request)
+
 	default:
 		return osquery.ExtensionResponse{
 			Status: &osquery.ExtensionStatus{
@@ -101,65 +157,31 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 
 }
 
-func (t *Plugin) Ping() osquery.ExtensionStatus {
-	return osquery.ExtensionStatus{Code: 0, Message: "OK"}
-}
-
-func (t *Plugin) Shutdown() {}
-
-// ColumnDefinition defines the relevant information for a column in a table
-// plugin. Both values are mandatory. Prefer using the *Column helpers to
-// create ColumnDefinition structs.
-type ColumnDefinition struct {
-	Name string
-	Type ColumnType
-}
-
-// TextColumn is a helper for defining columns containing strings.
-func TextColumn(name string) ColumnDefinition {
-	return ColumnDefinition{
-		Name: name,
-		Type: ColumnTypeText,
-	}
-}
-
-// IntegerColumn is a helper for defining columns containing integers.
-func IntegerColumn(name string) ColumnDefinition {
-	return ColumnDefinition{
-		Name: name,
-		Type: ColumnTypeInteger,
+// checkRequiredColumns returns an error if the query doesn't constrain a
+// column declared with ColumnRequired, since the table can't generate rows
+// without it.
+func (t *Plugin) checkRequiredColumns(queryContext QueryContext) error {
+	for _, col := range t.columns {
+		if col.Options&ColumnOptionRequired == 0 {
+			continue
+		}
+		if len(queryContext.ConstraintsFor(col.Name)) == 0 {
+			return errors.Errorf("table %s requires a constraint on column %s, for example: WHERE %s = ...", t.name, col.Name, col.Name)
+		}
 	}
+	return nil
 }
 
-// BigIntColumn is a helper for defining columns containing big integers.
-func BigIntColumn(name string) ColumnDefinition {
-	return ColumnDefinition{
-		Name: name,
-		Type: ColumnTypeBigInt,
-	}
+func (t *Plugin) Ping() osquery.ExtensionStatus {
+	return osquery.ExtensionStatus{Code: 0, Message: "OK"}
 }
 
-// DoubleColumn is a helper for defining columns containing floating point
-// values.
-func DoubleColumn(name string) ColumnDefinition {
-	return ColumnDefinition{
-		Name: name,
-		Type: ColumnTypeDouble,
+func (t *Plugin) Shutdown() {
+	if t.shutdown != nil {
+		t.shutdown()
 	}
 }
 
-// ColumnType is a strongly typed representation of the data type string for a
-// column definition. The named constants should be used.
-type ColumnType string
-
-// The following column types are defined in osquery tables.h.
-const (
-	ColumnTypeText    ColumnType = "TEXT"
-	ColumnTypeInteger            = "INTEGER"
-	ColumnTypeBigInt             = "BIGINT"
-	ColumnTypeDouble             = "DOUBLE"
-)
-
 // QueryContext contains the constraints from the WHERE clause of the query,
 // that can optionally be used to optimize the table generation. Note that the
 // osquery SQLite engine will perform the filtering with these constraints, so
@@ -168,6 +190,14 @@ type QueryContext struct {
 	// Constraints is a map from column name to the details of the
 	// constraints on that column.
 	Constraints map[string]ConstraintList
+	// ColumnsUsed lists the columns used by the query, as provided by
+	// osquery in the colsUsed field of the query context. It is nil when
+	// osquery did not provide it, in which case all columns should be
+	// generated. See IsColumnUsed.
+	ColumnsUsed []string
+
+	// limit is the LIMIT of the query, if osquery provided one. See Limit.
+	limit *int
 }
 
 // ConstraintList contains the details of the constraints for the given column.
@@ -204,6 +234,8 @@ const (
 // JSON and are not made public.
 type queryContextJSON struct {
 	Constraints []constraintListJSON `json:"constraints"`
+	ColsUsed    []string             `json:"colsUsed"`
+	Limit       *int                 `json:"limit"`
 }
 
 type constraintListJSON struct {
@@ -220,7 +252,11 @@ func parseQueryContext(ctxJSON string) (*QueryContext, error) {
 		return nil, errors.Wrap(err, "unmarshaling context JSON")
 	}
 
-	ctx := QueryContext{map[string]ConstraintList{}}
+	ctx := QueryContext{
+		Constraints: map[string]ConstraintList{},
+		ColumnsUsed: parsed.ColsUsed,
+		limit:       parsed.Limit,
+	}
 	for _, cList := range parsed.Constraints {
 		constraints, err := parseConstraintList(cList.List)
 		if err != nil {
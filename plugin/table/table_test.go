@@ -57,7 +57,7 @@ func TestTablePlugin(t *testing.T) {
 
 	// Call with good action and context
 	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
-	assert.Equal(t, QueryContext{map[string]ConstraintList{}}, calledQueryCtx)
+	assert.Equal(t, QueryContext{Constraints: map[string]ConstraintList{}}, calledQueryCtx)
 	assert.Equal(t, &StatusOK, resp.Status)
 	assert.Equal(t, osquery.ExtensionPluginResponse{
 		{
@@ -189,7 +189,7 @@ func TestParseQueryContext(t *testing.T) {
     }
   ]
 }`,
-			context: QueryContext{map[string]ConstraintList{
+			context: QueryContext{Constraints: map[string]ConstraintList{
 				"big_int": ConstraintList{ColumnTypeBigInt, []Constraint{}},
 				"double":  ConstraintList{ColumnTypeDouble, []Constraint{}},
 				"integer": ConstraintList{ColumnTypeInteger, []Constraint{}},
@@ -233,7 +233,7 @@ func TestParseQueryContext(t *testing.T) {
   ]
 }
 `,
-			context: QueryContext{map[string]ConstraintList{
+			context: QueryContext{Constraints: map[string]ConstraintList{
 				"big_int": ConstraintList{ColumnTypeBigInt, []Constraint{}},
 				"double":  ConstraintList{ColumnTypeDouble, []Constraint{{OperatorGreaterThanOrEquals, "3.1"}}},
 				"integer": ConstraintList{ColumnTypeInteger, []Constraint{}},
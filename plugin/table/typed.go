@@ -0,0 +1,36 @@
+package table
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// NewTypedPlugin creates a table plugin whose columns are derived from the
+// fields of the struct type T, following the same rules as RowDefinition. The
+// gen function returns the rows as values of T, which are converted to
+// osquery rows automatically.
+func NewTypedPlugin[T any](name string, gen func(ctx context.Context, queryContext QueryContext) ([]T, error), opts ...Option) (*Plugin, error) {
+	rt, err := newRowType(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, errors.Wrapf(err, "table %s", name)
+	}
+
+	p := &Plugin{
+		name:    name,
+		columns: rt.columns(),
+		rowType: rt,
+		generate: func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			rows, err := gen(ctx, queryContext)
+			if err != nil {
+				return nil, err
+			}
+			return rt.encodeSlice(rows)
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
@@ -0,0 +1,62 @@
+package table
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type process struct {
+	PID       int32 `column:"pid"`
+	Name      string
+	Running   bool
+	StartTime time.Time
+}
+
+func TestTypedPlugin(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	plugin, err := NewTypedPlugin("processes", func(ctx context.Context, queryContext QueryContext) ([]process, error) {
+		return []process{
+			{PID: 1, Name: "init", Running: true, StartTime: start},
+			{PID: 2, Name: "zombie"},
+		}, nil
+	})
+	require.Nil(t, err)
+
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "pid", "type": "INTEGER", "op": "0"},
+		{"id": "column", "name": "name", "type": "TEXT", "op": "0"},
+		{"id": "column", "name": "running", "type": "INTEGER", "op": "0"},
+		{"id": "column", "name": "start_time", "type": "BIGINT", "op": "0"},
+	}, plugin.Routes())
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"pid": "1", "name": "init", "running": "1", "start_time": "1700000000"},
+		{"pid": "2", "name": "zombie", "running": "0", "start_time": ""},
+	}, resp.Response)
+}
+
+func TestTypedPluginPointers(t *testing.T) {
+	plugin, err := NewTypedPlugin("processes", func(ctx context.Context, queryContext QueryContext) ([]*process, error) {
+		return []*process{{PID: 1, Name: "init"}, nil}, nil
+	})
+	require.Nil(t, err)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"pid": "1", "name": "init", "running": "0", "start_time": ""},
+	}, resp.Response)
+}
+
+func TestTypedPluginInvalid(t *testing.T) {
+	_, err := NewTypedPlugin("strings", func(ctx context.Context, queryContext QueryContext) ([]string, error) {
+		return nil, nil
+	})
+	assert.NotNil(t, err)
+}
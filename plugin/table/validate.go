@@ -0,0 +1,138 @@
+package table
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ValidationMode selects what a table does with generated rows that don't
+// match its declared columns. See WithRowValidation.
+type ValidationMode int
+
+const (
+	// ValidateNone sends generated rows to osquery as they are. This is
+	// the default.
+	ValidateNone ValidationMode = iota
+	// ValidateFix removes unknown columns and clears numeric values that
+	// can't be parsed. Rows missing a required column are dropped.
+	ValidateFix
+	// ValidateDrop drops invalid rows.
+	ValidateDrop
+	// ValidateError fails the query if any row is invalid.
+	ValidateError
+)
+
+// WithRowValidation validates each generated row against the declared
+// columns before it is returned to osquery. A row is invalid if it has keys
+// that are not columns (other than "rowid"), if it is missing a value for a
+// column declared with ColumnRequired, or if the value of an INTEGER,
+// BIGINT, UNSIGNED BIGINT or DOUBLE column is not a number of that type.
+// Empty values are valid in all columns. This is meant to catch schema bugs
+// during development.
+func WithRowValidation(mode ValidationMode) Option {
+	return func(p *Plugin) {
+		p.validation = mode
+	}
+}
+
+// validateRows applies the validation mode of the table to the rows.
+func (t *Plugin) validateRows(rows []map[string]string) ([]map[string]string, error) {
+	if t.validation == ValidateNone {
+		return rows, nil
+	}
+
+	columns := make(map[string]ColumnDefinition, len(t.columns))
+	for _, col := range t.columns {
+		columns[col.Name] = col
+	}
+
+	results := make([]map[string]string, 0, len(rows))
+	for i, row := range rows {
+		problems := validateRow(columns, row)
+		if len(problems) == 0 {
+			results = append(results, row)
+			continue
+		}
+
+		switch t.validation {
+		case ValidateError:
+			return nil, errors.Errorf("invalid row %d: %s", i, problems[0].err)
+		case ValidateFix:
+			if fixed, ok := fixRow(row, problems); ok {
+				results = append(results, fixed)
+			}
+		}
+	}
+	return results, nil
+}
+
+// rowProblem is a column of a row that failed validation.
+type rowProblem struct {
+	column string
+	err    string
+	// fixable is true if the problem is fixed by removing the value.
+	fixable bool
+}
+
+func validateRow(columns map[string]ColumnDefinition, row map[string]string) []rowProblem {
+	var problems []rowProblem
+	for key, value := range row {
+		col, ok := columns[key]
+		if !ok {
+			if key != "rowid" {
+				problems = append(problems, rowProblem{key, "unknown column " + key, true})
+			}
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		if err := validateValue(col.Type, value); err != nil {
+			problems = append(problems, rowProblem{key, err.Error(), true})
+		}
+	}
+	for name, col := range columns {
+		if col.Options&ColumnOptionRequired != 0 && row[name] == "" {
+			problems = append(problems, rowProblem{name, "missing value for required column " + name, false})
+		}
+	}
+
+	// Report problems in a stable order
+	sort.Slice(problems, func(i, j int) bool { return problems[i].column < problems[j].column })
+	return problems
+}
+
+// validateValue checks that a non-empty value is valid for the column type.
+func validateValue(typ ColumnType, value string) error {
+	var err error
+	switch typ {
+	case ColumnTypeInteger, ColumnTypeBigInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case ColumnTypeUnsignedBigInt:
+		_, err = strconv.ParseUint(value, 10, 64)
+	case ColumnTypeDouble:
+		_, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		return errors.Errorf("invalid %s value %q", typ, value)
+	}
+	return nil
+}
+
+// fixRow returns a copy of the row with the fixable problems removed. The
+// boolean result is false if the row can't be fixed.
+func fixRow(row map[string]string, problems []rowProblem) (map[string]string, bool) {
+	fixed := make(map[string]string, len(row))
+	for k, v := range row {
+		fixed[k] = v
+	}
+	for _, p := range problems {
+		if !p.fixable {
+			return nil, false
+		}
+		delete(fixed, p.column)
+	}
+	return fixed, true
+}
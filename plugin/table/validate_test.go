@@ -0,0 +1,56 @@
+package table
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRowValidation(t *testing.T) {
+	columns := []ColumnDefinition{
+		TextColumn("path", ColumnRequired()),
+		IntegerColumn("mode"),
+		UnsignedBigIntColumn("size"),
+		DoubleColumn("ratio"),
+	}
+	rows := []map[string]string{
+		{"path": "/etc/hosts", "mode": "644", "size": "", "ratio": "0.5", "rowid": "1"},
+		{"path": "/etc/passwd", "mode": "rw-r--r--", "size": "-1"},
+		{"path": "/etc/shadow", "owner": "root"},
+		{"mode": "600"},
+	}
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return rows, nil
+	}
+	generate := func(plugin *Plugin) osquery.ExtensionResponse {
+		return plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+			"action":  "generate",
+			"context": `{"constraints":[{"name":"path","list":[{"op":65,"expr":"/etc/%"}],"affinity":"TEXT"}]}`,
+		})
+	}
+
+	// Rows are returned unchanged by default
+	resp := generate(NewPlugin("files", columns, gen))
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Len(t, resp.Response, 4)
+
+	resp = generate(NewPlugin("files", columns, gen, WithRowValidation(ValidateFix)))
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"path": "/etc/hosts", "mode": "644", "size": "", "ratio": "0.5", "rowid": "1"},
+		{"path": "/etc/passwd"},
+		{"path": "/etc/shadow"},
+	}, resp.Response)
+	// The generated rows are not modified
+	assert.Equal(t, "rw-r--r--", rows[1]["mode"])
+
+	resp = generate(NewPlugin("files", columns, gen, WithRowValidation(ValidateDrop)))
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{rows[0]}, resp.Response)
+
+	resp = generate(NewPlugin("files", columns, gen, WithRowValidation(ValidateError)))
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, `error generating table: invalid row 1: invalid INTEGER value "rw-r--r--"`, resp.Status.Message)
+}
@@ -0,0 +1,131 @@
+package table
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// RowValues are the column values of a row inserted into or updated in a
+// writable table, keyed by column name. Columns osquery provided no value
+// for (NULL) are set to Unset, unless the column has a default (see
+// ColumnDefault) and the row is being inserted. The type of other values
+// depends on the column type:
+//
+//	TEXT             string
+//	INTEGER, BIGINT  int64
+//	UNSIGNED BIGINT  uint64
+//	DOUBLE           float64
+//	BLOB             []byte (decoded with DecodeBlob)
+type RowValues map[string]interface{}
+
+// Unset is the value of columns osquery provided no value for in RowValues,
+// for example columns omitted from an INSERT statement.
+var Unset = unset{}
+
+type unset struct{}
+
+// Has returns whether the row has a value for the column, that is, whether
+// the column is present and not Unset.
+func (r RowValues) Has(column string) bool {
+	v, ok := r[column]
+	return ok && v != Unset
+}
+
+// IsUnset returns whether the column is Unset.
+func (r RowValues) IsUnset(column string) bool {
+	return r[column] == Unset
+}
+
+// String returns the value of a TEXT column.
+func (r RowValues) String(column string) (string, bool) {
+	v, ok := r[column].(string)
+	return v, ok
+}
+
+// Int returns the value of an INTEGER or BIGINT column.
+func (r RowValues) Int(column string) (int64, bool) {
+	v, ok := r[column].(int64)
+	return v, ok
+}
+
+// Uint returns the value of an UNSIGNED BIGINT column.
+func (r RowValues) Uint(column string) (uint64, bool) {
+	v, ok := r[column].(uint64)
+	return v, ok
+}
+
+// Float returns the value of a DOUBLE column.
+func (r RowValues) Float(column string) (float64, bool) {
+	v, ok := r[column].(float64)
+	return v, ok
+}
+
+// Bytes returns the value of a BLOB column.
+func (r RowValues) Bytes(column string) ([]byte, bool) {
+	v, ok := r[column].([]byte)
+	return v, ok
+}
+
+// strings formats the values as they would be returned to osquery. Unset
+// columns are omitted.
+func (r RowValues) strings() map[string]string {
+	row := make(map[string]string, len(r))
+	for k, v := range r {
+		switch v := v.(type) {
+		case string:
+			row[k] = v
+		case int64:
+			row[k] = strconv.FormatInt(v, 10)
+		case uint64:
+			row[k] = strconv.FormatUint(v, 10)
+		case float64:
+			row[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case []byte:
+			row[k] = EncodeBlob(v)
+		}
+	}
+	return row
+}
+
+// parseColumnValue converts a non-null value decoded from a JSON value array
+// (with json.Decoder.UseNumber) to the Go type of the column type.
+func parseColumnValue(typ ColumnType, value interface{}) (interface{}, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case bool:
+		s = formatBool(v)
+	default:
+		return nil, errors.Errorf("unexpected value %v", v)
+	}
+
+	switch typ {
+	case ColumnTypeInteger, ColumnTypeBigInt:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid %s value %q", typ, s)
+		}
+		return i, nil
+	case ColumnTypeUnsignedBigInt:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid %s value %q", typ, s)
+		}
+		return u, nil
+	case ColumnTypeDouble:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid %s value %q", typ, s)
+		}
+		return f, nil
+	case ColumnTypeBlob:
+		return DecodeBlob(s)
+	default:
+		return s, nil
+	}
+}
@@ -0,0 +1,275 @@
+package table
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+)
+
+// The following are the values of the "status" key osquery expects in the
+// response to insert, update and delete requests.
+const (
+	WriteStatusSuccess    = "success"
+	WriteStatusReadOnly   = "readonly"
+	WriteStatusFailure    = "failure"
+	WriteStatusConstraint = "constraint"
+)
+
+// WriteError is an error returned by insert, update and delete callbacks to
+// report a specific osquery write status, such as a constraint violation,
+// instead of a generic failure.
+type WriteError struct {
+	// Status is one of the WriteStatus constants.
+	Status string
+	// Message describes the error.
+	Message string
+}
+
+func (e *WriteError) Error() string {
+	return e.Message
+}
+
+var (
+	// ErrConstraint reports that a write violates a constraint of the
+	// table, for example a duplicate key. osquery reports it as a
+	// constraint failure of the statement.
+	ErrConstraint = &WriteError{Status: WriteStatusConstraint, Message: "constraint violation"}
+	// ErrReadOnly reports that the table (or row) can't be modified.
+	ErrReadOnly = &WriteError{Status: WriteStatusReadOnly, Message: "table is read-only"}
+)
+
+// ConstraintError returns a constraint violation error with the given
+// message. See ErrConstraint.
+func ConstraintError(message string) error {
+	return &WriteError{Status: WriteStatusConstraint, Message: message}
+}
+
+// StatusSuccess returns the response to a successful write. The id is the
+// rowid of the inserted row, and is ignored by osquery for updates and
+// deletes.
+func StatusSuccess(id RowID) osquery.ExtensionPluginResponse {
+	return osquery.ExtensionPluginResponse{{
+		"status": WriteStatusSuccess,
+		"id":     strconv.FormatInt(int64(id), 10),
+	}}
+}
+
+// StatusConstraint returns the response to a write that violates a
+// constraint of the table.
+func StatusConstraint() osquery.ExtensionPluginResponse {
+	return osquery.ExtensionPluginResponse{{"status": WriteStatusConstraint}}
+}
+
+// StatusReadOnly returns the response to a write to a read-only table.
+func StatusReadOnly() osquery.ExtensionPluginResponse {
+	return osquery.ExtensionPluginResponse{{"status": WriteStatusReadOnly}}
+}
+
+// StatusError returns the response to a write that failed with the given
+// message.
+func StatusError(message string) osquery.ExtensionPluginResponse {
+	return osquery.ExtensionPluginResponse{{
+		"status":  WriteStatusFailure,
+		"message": message,
+	}}
+}
+
+// InsertFunc inserts a row into a writable table. The id argument is the
+// rowid requested by osquery, or nil if osquery lets the table choose one.
+// The returned RowID is reported to osquery as the rowid of the new row.
+type InsertFunc func(ctx context.Context, id *RowID, row RowValues) (RowID, error)
+
+// UpdateFunc replaces the row identified by id in a writable table. The
+// newID argument is the new rowid of the row if the update changes it, and
+// nil otherwise.
+type UpdateFunc func(ctx context.Context, id RowID, newID *RowID, row RowValues) error
+
+// DeleteFunc deletes the row identified by id from a writable table.
+type DeleteFunc func(ctx context.Context, id RowID) error
+
+// NewWritablePlugin creates a table plugin that supports INSERT, UPDATE and
+// DELETE statements in addition to SELECT. The values osquery provides for
+// inserted and updated rows are parsed according to the column types before
+// being passed to insert and update.
+//
+// Any of insert, update and del may be nil, in which case the corresponding
+// statements fail with osquery's read-only error. WithInsert, WithUpdate and
+// WithDelete can be used with NewPlugin instead, to enable only some of the
+// operations.
+func NewWritablePlugin(name string, columns []ColumnDefinition, gen GenerateFunc, insert InsertFunc, update UpdateFunc, del DeleteFunc, opts ...Option) *Plugin {
+	p := NewPlugin(name, columns, gen, opts...)
+	p.insert = insert
+	p.update = update
+	p.delete = del
+	return p
+}
+
+// WithInsert makes a table support INSERT statements.
+func WithInsert(fn InsertFunc) Option {
+	return func(p *Plugin) {
+		p.insert = fn
+	}
+}
+
+// WithUpdate makes a table support UPDATE statements.
+func WithUpdate(fn UpdateFunc) Option {
+	return func(p *Plugin) {
+		p.update = fn
+	}
+}
+
+// WithDelete makes a table support DELETE statements.
+func WithDelete(fn DeleteFunc) Option {
+	return func(p *Plugin) {
+		p.delete = fn
+	}
+}
+
+func (t *Plugin) callInsert(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	if t.insert == nil {
+		return writeResponse(StatusReadOnly())
+	}
+
+	row, err := t.parseValueArray(request["json_value_array"], true)
+	if err != nil {
+		return writeError("error parsing inserted row: ", err)
+	}
+
+	var id *RowID
+	if request["auto_rowid"] != "true" && request["id"] != "" {
+		parsed, err := parseRowID(request["id"])
+		if err != nil {
+			return writeError("error parsing rowid: ", err)
+		}
+		id = &parsed
+	}
+
+	newID, err := t.insert(ctx, id, row)
+	if err != nil {
+		return writeError("error inserting row: ", err)
+	}
+
+	return writeResponse(StatusSuccess(newID))
+}
+
+func (t *Plugin) callUpdate(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	if t.update == nil {
+		return writeResponse(StatusReadOnly())
+	}
+
+	id, err := parseRowID(request["id"])
+	if err != nil {
+		return writeError("error parsing rowid: ", err)
+	}
+
+	var newID *RowID
+	if request["new_id"] != "" {
+		parsed, err := parseRowID(request["new_id"])
+		if err != nil {
+			return writeError("error parsing new rowid: ", err)
+		}
+		newID = &parsed
+	}
+
+	row, err := t.parseValueArray(request["json_value_array"], false)
+	if err != nil {
+		return writeError("error parsing updated row: ", err)
+	}
+
+	if err := t.update(ctx, id, newID, row); err != nil {
+		return writeError("error updating row: ", err)
+	}
+
+	return writeResponse(osquery.ExtensionPluginResponse{{"status": WriteStatusSuccess}})
+}
+
+func (t *Plugin) callDelete(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	if t.delete == nil {
+		return writeResponse(StatusReadOnly())
+	}
+
+	id, err := parseRowID(request["id"])
+	if err != nil {
+		return writeError("error parsing rowid: ", err)
+	}
+
+	if err := t.delete(ctx, id); err != nil {
+		return writeError("error deleting row: ", err)
+	}
+
+	return writeResponse(osquery.ExtensionPluginResponse{{"status": WriteStatusSuccess}})
+}
+
+// parseValueArray converts the JSON array of column values osquery sends
+// for inserts and updates into a row keyed by column name. Values are in
+// the order of the table's columns, and are parsed according to the column
+// types (see RowValues). Null values are Unset, or replaced by the column
+// defaults if useDefaults is true.
+func (t *Plugin) parseValueArray(valueArray string, useDefaults bool) (RowValues, error) {
+	dec := json.NewDecoder(bytes.NewBufferString(valueArray))
+	dec.UseNumber()
+
+	var values []interface{}
+	if err := dec.Decode(&values); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling value array")
+	}
+	if len(values) != len(t.columns) {
+		return nil, errors.Errorf("got %d values for %d columns", len(values), len(t.columns))
+	}
+
+	row := make(RowValues, len(values))
+	for i, value := range values {
+		col := t.columns[i]
+		if value == nil {
+			if !useDefaults || col.Default == nil {
+				row[col.Name] = Unset
+				continue
+			}
+			value = *col.Default
+		}
+		v, err := parseColumnValue(col.Type, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "column %s", col.Name)
+		}
+		row[col.Name] = v
+	}
+	return row, nil
+}
+
+func parseRowID(s string) (RowID, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid rowid %q", s)
+	}
+	return RowID(id), nil
+}
+
+// writeResponse returns the response to a write request.
+func writeResponse(response osquery.ExtensionPluginResponse) osquery.ExtensionResponse {
+	return osquery.ExtensionResponse{
+		Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+		Response: response,
+	}
+}
+
+// writeError returns the response to a write request that failed with err.
+// A WriteError is reported with its status, other errors as failures.
+func writeError(prefix string, err error) osquery.ExtensionResponse {
+	if werr, ok := errors.Cause(err).(*WriteError); ok && werr.Status != WriteStatusFailure {
+		return writeResponse(osquery.ExtensionPluginResponse{{
+			"status":  werr.Status,
+			"message": werr.Message,
+		}})
+	}
+	return osquery.ExtensionResponse{
+		Status: &osquery.ExtensionStatus{
+			Code:    1,
+			Message: prefix + err.Error(),
+		},
+		Response: StatusError(err.Error()),
+	}
+}
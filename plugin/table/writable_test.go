@@ -0,0 +1,304 @@
+package table
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritablePlugin(t *testing.T) {
+	var (
+		insertedID  *RowID
+		insertedRow RowValues
+		updatedID   RowID
+		updatedNew  *RowID
+		updatedRow  RowValues
+		deletedID   RowID
+	)
+	plugin := NewWritablePlugin("writable",
+		[]ColumnDefinition{
+			TextColumn("text"),
+			IntegerColumn("integer"),
+			BigIntColumn("big_int"),
+			UnsignedBigIntColumn("unsigned"),
+			DoubleColumn("double"),
+			BlobColumn("blob"),
+		},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			return nil, nil
+		},
+		func(ctx context.Context, id *RowID, row RowValues) (RowID, error) {
+			insertedID, insertedRow = id, row
+			return 42, nil
+		},
+		func(ctx context.Context, id RowID, newID *RowID, row RowValues) error {
+			updatedID, updatedNew, updatedRow = id, newID, row
+			return nil
+		},
+		func(ctx context.Context, id RowID) error {
+			deletedID = id
+			return nil
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "true",
+		"json_value_array": `["hello", 1, -9007199254740993, 18446744073709551615, 3.5, "cafe"]`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success", "id": "42"}}, resp.Response)
+	assert.Nil(t, insertedID)
+	assert.Equal(t, RowValues{
+		"text":     "hello",
+		"integer":  int64(1),
+		"big_int":  int64(-9007199254740993),
+		"unsigned": uint64(18446744073709551615),
+		"double":   3.5,
+		"blob":     []byte{0xca, 0xfe},
+	}, insertedRow)
+
+	text, ok := insertedRow.String("text")
+	assert.True(t, ok)
+	assert.Equal(t, "hello", text)
+	i, ok := insertedRow.Int("big_int")
+	assert.True(t, ok)
+	assert.Equal(t, int64(-9007199254740993), i)
+	u, ok := insertedRow.Uint("unsigned")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(18446744073709551615), u)
+	f, ok := insertedRow.Float("double")
+	assert.True(t, ok)
+	assert.Equal(t, 3.5, f)
+	b, ok := insertedRow.Bytes("blob")
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0xca, 0xfe}, b)
+	_, ok = insertedRow.Int("text")
+	assert.False(t, ok)
+
+	// Explicit rowid, numbers given as text and unset values
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "false",
+		"id":               "7",
+		"json_value_array": `[5, "2", null, null, "1.5", null]`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	require.NotNil(t, insertedID)
+	assert.Equal(t, RowID(7), *insertedID)
+	assert.Equal(t, RowValues{
+		"text":     "5",
+		"integer":  int64(2),
+		"big_int":  Unset,
+		"unsigned": Unset,
+		"double":   1.5,
+		"blob":     Unset,
+	}, insertedRow)
+	assert.False(t, insertedRow.Has("big_int"))
+	assert.True(t, insertedRow.IsUnset("big_int"))
+	assert.True(t, insertedRow.Has("text"))
+	assert.False(t, insertedRow.IsUnset("text"))
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "update",
+		"id":               "7",
+		"json_value_array": `["updated", null, null, null, null, null]`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, RowID(7), updatedID)
+	assert.Nil(t, updatedNew)
+	assert.Equal(t, RowValues{
+		"text":     "updated",
+		"integer":  Unset,
+		"big_int":  Unset,
+		"unsigned": Unset,
+		"double":   Unset,
+		"blob":     Unset,
+	}, updatedRow)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "delete", "id": "7"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, RowID(7), deletedID)
+
+	// Values that don't match the column types are rejected before
+	// reaching the callbacks.
+	for _, values := range []string{
+		`[null, "one", null, null, null, null]`,
+		`[null, null, null, -1, null, null]`,
+		`[null, null, null, null, "x", null]`,
+		`[null, null, null, null, null, "xyz"]`,
+		`[null, null, null, null, null, [1]]`,
+	} {
+		insertedRow = nil
+		resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+			"action":           "insert",
+			"auto_rowid":       "true",
+			"json_value_array": values,
+		})
+		assert.Equal(t, int32(1), resp.Status.Code, values)
+		assert.Equal(t, "failure", resp.Response[0]["status"], values)
+		assert.Nil(t, insertedRow, values)
+	}
+}
+
+func TestPartialWritablePlugin(t *testing.T) {
+	rows := map[RowID]string{}
+	columns := []ColumnDefinition{TextColumn("text")}
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return nil, nil
+	}
+	insert := func(ctx context.Context, id *RowID, row RowValues) (RowID, error) {
+		newID := RowID(len(rows) + 1)
+		rows[newID], _ = row.String("text")
+		return newID, nil
+	}
+	del := func(ctx context.Context, id RowID) error {
+		delete(rows, id)
+		return nil
+	}
+
+	for name, plugin := range map[string]*Plugin{
+		"options":  NewPlugin("partial", columns, gen, WithInsert(insert), WithDelete(del)),
+		"writable": NewWritablePlugin("partial", columns, gen, insert, nil, del),
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+				"action":           "insert",
+				"auto_rowid":       "true",
+				"json_value_array": `["foo"]`,
+			})
+			assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success", "id": "1"}}, resp.Response)
+			assert.Equal(t, map[RowID]string{1: "foo"}, rows)
+
+			// UPDATE is not supported
+			resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+				"action":           "update",
+				"id":               "1",
+				"json_value_array": `["bar"]`,
+			})
+			assert.Equal(t, int32(0), resp.Status.Code)
+			assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "readonly"}}, resp.Response)
+			assert.Equal(t, map[RowID]string{1: "foo"}, rows)
+
+			resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "delete", "id": "1"})
+			assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success"}}, resp.Response)
+			assert.Empty(t, rows)
+		})
+	}
+}
+
+func TestWritablePluginStatuses(t *testing.T) {
+	var insertErr error
+	plugin := NewPlugin("statuses", []ColumnDefinition{TextColumn("text")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			return nil, nil
+		},
+		WithInsert(func(ctx context.Context, id *RowID, row RowValues) (RowID, error) {
+			return 0, insertErr
+		}),
+	)
+	insert := func() osquery.ExtensionResponse {
+		return plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+			"action":           "insert",
+			"auto_rowid":       "true",
+			"json_value_array": `["foo"]`,
+		})
+	}
+
+	insertErr = ErrConstraint
+	resp := insert()
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, "constraint", resp.Response[0]["status"])
+
+	// Wrapped errors keep their status
+	insertErr = errors.Wrap(ConstraintError("duplicate text"), "inserting")
+	resp = insert()
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "constraint", "message": "duplicate text"}}, resp.Response)
+
+	insertErr = ErrReadOnly
+	resp = insert()
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, "readonly", resp.Response[0]["status"])
+
+	insertErr = errors.New("disk full")
+	resp = insert()
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error inserting row: disk full", resp.Status.Message)
+	assert.Equal(t, StatusError("disk full"), resp.Response)
+}
+
+func TestStatusHelpers(t *testing.T) {
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "success", "id": "3"}}, StatusSuccess(3))
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "constraint"}}, StatusConstraint())
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "readonly"}}, StatusReadOnly())
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "failure", "message": "oops"}}, StatusError("oops"))
+	assert.Equal(t, "constraint violation", ErrConstraint.Error())
+}
+
+func TestWritablePluginDefaults(t *testing.T) {
+	var inserted, updated RowValues
+	plugin := NewWritablePlugin("defaults",
+		[]ColumnDefinition{
+			TextColumn("name"),
+			IntegerColumn("enabled", ColumnDefault("1")),
+			TextColumn("comment", ColumnDefault("")),
+		},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			return nil, nil
+		},
+		func(ctx context.Context, id *RowID, row RowValues) (RowID, error) {
+			inserted = row
+			return 1, nil
+		},
+		func(ctx context.Context, id RowID, newID *RowID, row RowValues) error {
+			updated = row
+			return nil
+		},
+		nil,
+	)
+
+	// INSERT INTO defaults DEFAULT VALUES
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "true",
+		"json_value_array": `[null, null, null]`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, RowValues{"name": Unset, "enabled": int64(1), "comment": ""}, inserted)
+
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "true",
+		"json_value_array": `["foo", 0, "bar"]`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, RowValues{"name": "foo", "enabled": int64(0), "comment": "bar"}, inserted)
+
+	// Defaults don't apply to updates, which set columns to NULL explicitly
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "update",
+		"id":               "1",
+		"json_value_array": `["foo", null, null]`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, RowValues{"name": "foo", "enabled": Unset, "comment": Unset}, updated)
+
+	// Invalid defaults are reported as errors
+	plugin = NewWritablePlugin("defaults",
+		[]ColumnDefinition{IntegerColumn("enabled", ColumnDefault("yes"))},
+		nil,
+		func(ctx context.Context, id *RowID, row RowValues) (RowID, error) { return 1, nil },
+		nil, nil,
+	)
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":           "insert",
+		"auto_rowid":       "true",
+		"json_value_array": `[null]`,
+	})
+	assert.Equal(t, int32(1), resp.Status.Code)
+}
@@ -0,0 +1,97 @@
+package osquery
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pprofUnixPrefix prefixes the addresses of ServerPprof that are unix socket
+// paths.
+const pprofUnixPrefix = "unix:"
+
+// ServerPprof serves net/http/pprof at /debug/pprof/ while the server runs,
+// so that production extensions can be profiled without rebuilding. The
+// address is either a unix socket path prefixed with "unix:", created with
+// owner-only permissions, or a loopback TCP address such as
+// "localhost:6060". Start fails if it can't be listened on.
+func ServerPprof(addr string) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.pprofAddr = addr
+	}
+}
+
+// startPprof serves pprof on the address of ServerPprof, unless it is
+// already served. The caller must hold s.mutex.
+func (s *ExtensionManagerServer) startPprof() error {
+	if s.pprofAddr == "" || s.pprofListener != nil {
+		return nil
+	}
+	listener, err := listenPprof(s.pprofAddr)
+	if err != nil {
+		return errors.Wrap(err, "serving pprof")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	s.pprofListener = listener
+	s.pprofServer = server
+	return nil
+}
+
+// stopPprof stops serving pprof. The caller must hold s.mutex.
+func (s *ExtensionManagerServer) stopPprof() {
+	if s.pprofServer == nil {
+		return
+	}
+	_ = s.pprofServer.Close()
+	// The server only closes the listener once Serve is running, close it
+	// here too so that the unix socket is removed before returning.
+	_ = s.pprofListener.Close()
+	s.pprofServer = nil
+	s.pprofListener = nil
+}
+
+// listenPprof listens on a unix socket path prefixed with "unix:", or on a
+// loopback TCP address.
+func listenPprof(addr string) (net.Listener, error) {
+	if path := strings.TrimPrefix(addr, pprofUnixPrefix); path != addr {
+		// Remove a socket left by a process that didn't stop cleanly
+		_ = os.Remove(path)
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return nil, errors.Errorf("%s is not a loopback address", addr)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
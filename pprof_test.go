@@ -0,0 +1,90 @@
+package osquery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerPprof(t *testing.T) {
+	dir := t.TempDir()
+	pprofPath := filepath.Join(dir, "pprof.sock")
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() {},
+	}
+	server := &ExtensionManagerServer{serverClient: mock, sockPath: filepath.Join(dir, "osquery.sock")}
+	ServerPprof("unix:" + pprofPath)(server)
+
+	completed := make(chan struct{})
+	go func() {
+		assert.NoError(t, server.Start())
+		close(completed)
+	}()
+	<-server.Ready()
+
+	info, err := os.Stat(pprofPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", pprofPath)
+		},
+	}}
+	resp, err := client.Get("http://pprof/debug/pprof/goroutine?debug=1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	select {
+	case <-completed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hung on shutdown")
+	}
+	_, err = os.Stat(pprofPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServerPprofStartFailure(t *testing.T) {
+	dir := t.TempDir()
+	pprofPath := filepath.Join(dir, "pprof.sock")
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 1, Message: "denied"}, nil
+		},
+		CloseFunc: func() {},
+	}
+	server := &ExtensionManagerServer{serverClient: mock, sockPath: filepath.Join(dir, "osquery.sock")}
+	ServerPprof("unix:" + pprofPath)(server)
+
+	assert.Error(t, server.Start())
+	assert.Nil(t, server.pprofServer)
+	_, err := os.Stat(pprofPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListenPprof(t *testing.T) {
+	listener, err := listenPprof("127.0.0.1:0")
+	require.NoError(t, err)
+	listener.Close()
+
+	_, err = listenPprof("0.0.0.0:0")
+	assert.Error(t, err)
+	_, err = listenPprof("example.com:6060")
+	assert.Error(t, err)
+}
@@ -0,0 +1,479 @@
+// Package runner runs osqueryd as a child process of a Go program, together
+// with an extension serving the plugins of the program, so that the program
+// fully manages its osquery.
+//
+// The runner generates the flags of osqueryd from its options, restarts
+// osqueryd when it exits, and keeps the extension registered across the
+// restarts:
+//
+//	r, err := runner.New("example",
+//		runner.WithRootDirectory("/var/lib/example"),
+//		runner.WithConfigPlugin("example_config"),
+//	)
+//	r.RegisterPlugin(config.NewPlugin("example_config", genConfig))
+//	err = r.Run(ctx)
+package runner
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultStartTimeout = 30 * time.Second
+	defaultRestartDelay = time.Second
+	stopTimeout         = 10 * time.Second
+)
+
+// Event identifies a transition of the osqueryd process made by Run.
+type Event int
+
+const (
+	// EventStarting is reported before each start of osqueryd.
+	EventStarting Event = iota
+	// EventRunning is reported once osqueryd accepts extensions.
+	EventRunning
+	// EventExited is reported when osqueryd exited, or failed to start.
+	// The error passed to the hook is the reason.
+	EventExited
+	// EventStopped is reported when Run stopped osqueryd because its
+	// context is done.
+	EventStopped
+)
+
+// String implements the fmt.Stringer interface for Event.
+func (e Event) String() string {
+	switch e {
+	case EventStarting:
+		return "starting"
+	case EventRunning:
+		return "running"
+	case EventExited:
+		return "exited"
+	case EventStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// EventHook is called by Run on each transition, for example to log it.
+type EventHook func(event Event, err error)
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithBinary sets the path of the osqueryd binary. It defaults to osqueryd
+// in the PATH.
+func WithBinary(path string) Option {
+	return func(r *Runner) {
+		r.binary = path
+	}
+}
+
+// WithRootDirectory sets the directory of the database, pidfile and
+// extensions socket of osqueryd. It defaults to a temporary directory,
+// removed when Run returns.
+func WithRootDirectory(dir string) Option {
+	return func(r *Runner) {
+		r.rootDir = dir
+	}
+}
+
+// WithConfigPlugin sets the config plugin osqueryd uses, typically one
+// registered with RegisterPlugin.
+func WithConfigPlugin(name string) Option {
+	return func(r *Runner) {
+		r.configPlugin = name
+	}
+}
+
+// WithLoggerPlugin sets the logger plugins osqueryd uses, typically ones
+// registered with RegisterPlugin.
+func WithLoggerPlugin(names ...string) Option {
+	return func(r *Runner) {
+		r.loggerPlugins = append(r.loggerPlugins, names...)
+	}
+}
+
+// WithDistributedPlugin sets the distributed plugin osqueryd uses, and
+// enables distributed queries.
+func WithDistributedPlugin(name string) Option {
+	return func(r *Runner) {
+		r.distributedPlugin = name
+	}
+}
+
+// WithFlags adds command line flags of osqueryd. They are passed after the
+// generated flags, overriding them.
+func WithFlags(flags ...string) Option {
+	return func(r *Runner) {
+		r.flags = append(r.flags, flags...)
+	}
+}
+
+// WithOutput sets where the standard output and error of osqueryd are
+// written. They are discarded by default.
+func WithOutput(w io.Writer) Option {
+	return func(r *Runner) {
+		r.output = w
+	}
+}
+
+// WithRestartDelay sets how long to wait before restarting osqueryd after it
+// exited. It defaults to one second.
+func WithRestartDelay(delay time.Duration) Option {
+	return func(r *Runner) {
+		r.restartDelay = delay
+	}
+}
+
+// WithMaxRestarts sets how many times in a row osqueryd is restarted after
+// exiting without becoming ready, before Run gives up. Zero, the default,
+// restarts it forever.
+func WithMaxRestarts(n int) Option {
+	return func(r *Runner) {
+		r.maxRestarts = n
+	}
+}
+
+// WithStartTimeout sets how long to wait for osqueryd to accept extensions
+// after starting it. It defaults to 30 seconds.
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(r *Runner) {
+		r.startTimeout = timeout
+	}
+}
+
+// WithEventHook sets a hook that is called for every transition of
+// osqueryd.
+func WithEventHook(hook EventHook) Option {
+	return func(r *Runner) {
+		r.hook = hook
+	}
+}
+
+// WithServerOptions sets the options of the extension server.
+func WithServerOptions(opts ...osquery.ServerOption) Option {
+	return func(r *Runner) {
+		r.serverOpts = append(r.serverOpts, opts...)
+	}
+}
+
+// Runner runs osqueryd and an extension registered with it.
+type Runner struct {
+	name              string
+	binary            string
+	rootDir           string
+	configPlugin      string
+	loggerPlugins     []string
+	distributedPlugin string
+	flags             []string
+	output            io.Writer
+	restartDelay      time.Duration
+	maxRestarts       int
+	startTimeout      time.Duration
+	hook              EventHook
+	serverOpts        []osquery.ServerOption
+
+	mutex    sync.Mutex
+	plugins  []osquery.OsqueryPlugin
+	server   *osquery.ExtensionManagerServer
+	process  *os.Process
+	restarts int
+	ready    chan struct{}
+}
+
+// New returns a runner of osqueryd with an extension of the name.
+func New(name string, opts ...Option) (*Runner, error) {
+	r := &Runner{
+		name:         name,
+		restartDelay: defaultRestartDelay,
+		startTimeout: defaultStartTimeout,
+		ready:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.binary == "" {
+		path, err := exec.LookPath("osqueryd")
+		if err != nil {
+			return nil, errors.Wrap(err, "finding osqueryd")
+		}
+		r.binary = path
+	}
+	return r, nil
+}
+
+// RegisterPlugin adds plugins to the extension. Plugins must be registered
+// before Run.
+func (r *Runner) RegisterPlugin(plugins ...osquery.OsqueryPlugin) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.plugins = append(r.plugins, plugins...)
+}
+
+// Ready returns a channel that is closed once the extension is first
+// registered with osqueryd.
+func (r *Runner) Ready() <-chan struct{} {
+	return r.ready
+}
+
+// Server returns the extension server connected to osqueryd, or nil before
+// osqueryd first accepted extensions.
+func (r *Runner) Server() *osquery.ExtensionManagerServer {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.server
+}
+
+// SocketPath returns the path of the extensions socket of osqueryd.
+func (r *Runner) SocketPath() string {
+	if runtime.GOOS == "windows" {
+		return `\\.\pipe\` + r.name + ".em"
+	}
+	return filepath.Join(r.rootDir, "osquery.em")
+}
+
+// Pid returns the process ID of osqueryd, or zero if it is not running.
+func (r *Runner) Pid() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.process == nil {
+		return 0
+	}
+	return r.process.Pid
+}
+
+// Restarts returns how many times osqueryd was restarted after exiting.
+func (r *Runner) Restarts() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.restarts
+}
+
+// Flags returns the flags osqueryd is run with.
+func (r *Runner) Flags() []string {
+	flags := []string{
+		"--extensions_socket=" + r.SocketPath(),
+		"--extensions_require=" + r.name,
+		"--extensions_timeout=" + strconv.Itoa(int(r.startTimeout.Seconds())),
+		"--extensions_interval=1",
+		"--database_path=" + filepath.Join(r.rootDir, "osquery.db"),
+		"--pidfile=" + filepath.Join(r.rootDir, "osqueryd.pid"),
+		"--disable_watchdog",
+		"--force",
+	}
+	if r.configPlugin != "" {
+		flags = append(flags, "--config_plugin="+r.configPlugin)
+	}
+	if len(r.loggerPlugins) > 0 {
+		flags = append(flags, "--logger_plugin="+strings.Join(r.loggerPlugins, ","))
+	}
+	if r.distributedPlugin != "" {
+		flags = append(flags, "--distributed_plugin="+r.distributedPlugin, "--disable_distributed=false")
+	}
+	return append(flags, r.flags...)
+}
+
+// Run runs osqueryd and the extension until ctx is done, restarting osqueryd
+// when it exits. It returns nil once ctx is done and osqueryd is stopped,
+// or an error if osqueryd can't be run.
+func (r *Runner) Run(ctx context.Context) error {
+	if r.rootDir == "" {
+		dir, err := os.MkdirTemp("", "osqueryd")
+		if err != nil {
+			return errors.Wrap(err, "creating root directory")
+		}
+		defer os.RemoveAll(dir)
+		r.rootDir = dir
+	}
+	if err := os.MkdirAll(r.rootDir, 0700); err != nil {
+		return errors.Wrap(err, "creating root directory")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	serverErr := make(chan error, 1)
+	failures := 0
+	for {
+		r.event(EventStarting, nil)
+		exited, err := r.start(ctx)
+		if err == nil {
+			err = r.serve(ctx, serverErr)
+		}
+		if err == nil {
+			failures = 0
+			r.event(EventRunning, nil)
+			select {
+			case <-ctx.Done():
+			case err = <-exited:
+				exited = nil
+			case err = <-serverErr:
+				r.stop(exited)
+				return errors.Wrap(err, "running extension")
+			}
+		}
+		if ctx.Err() != nil {
+			r.shutdown(exited, serverErr)
+			return nil
+		}
+		if exited != nil {
+			r.stop(exited)
+		}
+		r.event(EventExited, err)
+
+		failures++
+		if r.maxRestarts > 0 && failures > r.maxRestarts {
+			return errors.Wrapf(err, "osqueryd failed %d times", failures)
+		}
+		select {
+		case <-ctx.Done():
+			r.shutdown(nil, serverErr)
+			return nil
+		case <-time.After(r.restartDelay):
+		}
+		r.mutex.Lock()
+		r.restarts++
+		r.mutex.Unlock()
+	}
+}
+
+// shutdown waits for the extension to shut down, as it does once the
+// context of Run is done, deregistering before osqueryd stops. Then it stops
+// osqueryd if exited is not nil.
+func (r *Runner) shutdown(exited <-chan error, serverErr <-chan error) {
+	if server := r.Server(); server != nil {
+		<-serverErr
+	}
+	if exited != nil {
+		r.stop(exited)
+	}
+	r.event(EventStopped, nil)
+}
+
+// start starts osqueryd and waits for it to accept extensions. The returned
+// channel receives the error of osqueryd when it exits, it is nil if
+// osqueryd already exited.
+func (r *Runner) start(ctx context.Context) (<-chan error, error) {
+	cmd := exec.Command(r.binary, r.Flags()...)
+	cmd.Stdout = r.output
+	cmd.Stderr = r.output
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "starting osqueryd")
+	}
+	r.mutex.Lock()
+	r.process = cmd.Process
+	r.mutex.Unlock()
+
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		if err == nil {
+			err = errors.New("exit status 0")
+		}
+		r.mutex.Lock()
+		r.process = nil
+		r.mutex.Unlock()
+		exited <- errors.Wrap(err, "osqueryd exited")
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, r.startTimeout)
+	defer cancel()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		err := r.ping()
+		if err == nil {
+			return exited, nil
+		}
+		select {
+		case err := <-exited:
+			return nil, err
+		case <-ctx.Done():
+			return exited, errors.Wrapf(err, "waiting for osqueryd: %v", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// ping pings osqueryd on its extensions socket.
+func (r *Runner) ping() error {
+	client, err := osquery.NewClient(r.SocketPath(), 500*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	status, err := client.Ping()
+	if err != nil {
+		return err
+	}
+	if status.Code != 0 {
+		return errors.Errorf("ping status %d: %s", status.Code, status.Message)
+	}
+	return nil
+}
+
+// serve starts the extension the first time osqueryd runs. Afterwards the
+// extension registers again by itself when osqueryd restarts.
+func (r *Runner) serve(ctx context.Context, serverErr chan<- error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.server != nil {
+		return nil
+	}
+
+	server, err := osquery.NewExtensionManagerServer(r.name, r.SocketPath(), r.serverOpts...)
+	if err != nil {
+		return errors.Wrap(err, "creating extension")
+	}
+	server.RegisterPlugin(r.plugins...)
+	r.server = server
+	go func() {
+		serverErr <- server.RunForever(ctx)
+	}()
+	go func() {
+		if server.WaitReady(ctx) == nil {
+			close(r.ready)
+		}
+	}()
+	return nil
+}
+
+// stop stops osqueryd, killing it if it doesn't exit in time.
+func (r *Runner) stop(exited <-chan error) {
+	r.mutex.Lock()
+	process := r.process
+	r.mutex.Unlock()
+	if process != nil {
+		if err := process.Signal(syscall.SIGTERM); err != nil {
+			process.Kill()
+		}
+	}
+	select {
+	case <-exited:
+	case <-time.After(stopTimeout):
+		if process != nil {
+			process.Kill()
+		}
+		<-exited
+	}
+}
+
+func (r *Runner) event(event Event, err error) {
+	if r.hook != nil {
+		r.hook(event, err)
+	}
+}
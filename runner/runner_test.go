@@ -0,0 +1,181 @@
+//go:build !windows
+// +build !windows
+
+package runner
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	osquery "github.com/osquery/osquery-go"
+	"github.com/osquery/osquery-go/fakeosqueryd"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// helperEnv makes the test binary run as a fake osqueryd.
+const helperEnv = "RUNNER_FAKE_OSQUERYD"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "1" {
+		runFakeOsqueryd()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeOsqueryd serves a fake extension manager on the socket given with
+// --extensions_socket until terminated.
+func runFakeOsqueryd() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	for _, arg := range os.Args[1:] {
+		if path := strings.TrimPrefix(arg, "--extensions_socket="); path != arg {
+			osqueryd, err := fakeosqueryd.Start(path)
+			if err != nil {
+				os.Exit(1)
+			}
+			<-signals
+			osqueryd.Close()
+			return
+		}
+	}
+	os.Exit(2)
+}
+
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (e *eventRecorder) hook(event Event, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *eventRecorder) get() []Event {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]Event(nil), e.events...)
+}
+
+// registered reports whether osqueryd has the extension registered.
+func registered(socketPath, name string) bool {
+	client, err := osquery.NewClient(socketPath, time.Second)
+	if err != nil {
+		return false
+	}
+	defer client.Close()
+	extensions, err := client.Extensions()
+	if err != nil {
+		return false
+	}
+	for _, ext := range extensions {
+		if ext.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunner(t *testing.T) {
+	t.Setenv(helperEnv, "1")
+	var events eventRecorder
+	r, err := New("runner_test",
+		WithBinary(os.Args[0]),
+		WithRootDirectory(t.TempDir()),
+		WithRestartDelay(10*time.Millisecond),
+		WithEventHook(events.hook),
+		WithServerOptions(osquery.ServerPingInterval(100*time.Millisecond)),
+	)
+	require.NoError(t, err)
+	r.RegisterPlugin(table.NewPlugin("runner_test", []table.ColumnDefinition{table.TextColumn("name")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return []map[string]string{{"name": "a"}}, nil
+		},
+	))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r.Run(ctx)
+	}()
+
+	select {
+	case <-r.Ready():
+	case <-time.After(10 * time.Second):
+		t.Fatal("extension not registered")
+	}
+	require.NotNil(t, r.Server())
+	assert.True(t, registered(r.SocketPath(), "runner_test"))
+
+	// osqueryd crashes, it is restarted and the extension registers again.
+	pid := r.Pid()
+	require.NotZero(t, pid)
+	require.NoError(t, syscall.Kill(pid, syscall.SIGKILL))
+	assert.Eventually(t, func() bool {
+		return r.Restarts() == 1 && r.Pid() != 0 && r.Pid() != pid && registered(r.SocketPath(), "runner_test")
+	}, 10*time.Second, 50*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return")
+	}
+	assert.Zero(t, r.Pid())
+	assert.Equal(t, []Event{EventStarting, EventRunning, EventExited, EventStarting, EventRunning, EventStopped}, events.get())
+}
+
+func TestRunnerMaxRestarts(t *testing.T) {
+	var events eventRecorder
+	r, err := New("runner_test",
+		WithBinary("/bin/false"),
+		WithRootDirectory(t.TempDir()),
+		WithRestartDelay(time.Millisecond),
+		WithMaxRestarts(2),
+		WithEventHook(events.hook),
+	)
+	require.NoError(t, err)
+
+	err = r.Run(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 2, r.Restarts())
+	assert.Equal(t, []Event{EventStarting, EventExited, EventStarting, EventExited, EventStarting, EventExited}, events.get())
+}
+
+func TestFlags(t *testing.T) {
+	r, err := New("example",
+		WithBinary("osqueryd"),
+		WithRootDirectory("/var/osquery"),
+		WithConfigPlugin("example_config"),
+		WithLoggerPlugin("filesystem", "example_logger"),
+		WithDistributedPlugin("example_distributed"),
+		WithFlags("--verbose"),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"--extensions_socket=/var/osquery/osquery.em",
+		"--extensions_require=example",
+		"--extensions_timeout=30",
+		"--extensions_interval=1",
+		"--database_path=/var/osquery/osquery.db",
+		"--pidfile=/var/osquery/osqueryd.pid",
+		"--disable_watchdog",
+		"--force",
+		"--config_plugin=example_config",
+		"--logger_plugin=filesystem,example_logger",
+		"--distributed_plugin=example_distributed",
+		"--disable_distributed=false",
+		"--verbose",
+	}, r.Flags())
+}
@@ -3,6 +3,12 @@ package osquery
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
 	"sync"
 	"time"
 
@@ -12,6 +18,7 @@ import (
 	"github.com/osquery/osquery-go/traces"
 	"github.com/osquery/osquery-go/transport"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 )
 
 type OsqueryPlugin interface {
@@ -59,13 +66,42 @@ type ExtensionManagerServer struct {
 	serverClient               ExtensionManager
 	serverClientShouldShutdown bool // Whether to shutdown the client during server shutdown
 	registry                   map[string](map[string]OsqueryPlugin)
+	extraRegistryNames         map[string]bool // Registry names added with ServerRegistryNames
+	allowAnyRegistryName       bool
+	callSlots                  chan struct{} // Limits concurrent plugin calls when non-nil
+	aggregateHealth            bool          // Ping reports plugin health
+	healthErrorWindow          time.Duration // How long a failed call marks the extension unhealthy
+	lastCallError              string
+	lastCallErrorAt            time.Time
+	statsTable                 bool                    // Register the extension stats table
+	pluginStats                map[string]*PluginStats // Call statistics by registry/item
 	server                     thrift.TServer
 	transport                  thrift.TServerTransport
+	listenPath                 string
 	timeout                    time.Duration
 	pingInterval               time.Duration // How often to ping osquery server
+	framing                    transport.Framing
+	peerVerifier               transport.PeerVerifier
+	restrictPeersToOsquery     bool
+	supervisorHook             SupervisorHook
+	skipDeregistration         bool  // Never deregister on shutdown
+	osqueryGone                bool  // Set when a ping failed, deregistration is skipped
+	maxSocketPathCharacters    int   // Zero disables the socket path length check
+	socketFromEnv              bool  // Read an empty socket path from SocketEnv
+	envErr                     error // Error parsing the environment in ServerFromEnv
 	mutex                      sync.Mutex
 	uuid                       osquery.ExtensionRouteUUID
-	started                    bool // Used to ensure tests wait until the server is actually started
+	ready                      chan struct{} // Closed once the server is registered and listening
+	done                       chan struct{} // Closed once Start has returned
+	doneErr                    error         // Terminal error reported by Wait
+	state                      ServerState
+	registeredAt               time.Time
+	lastPing                   time.Time // Last successful ping of osquery from Run
+	reconnects                 uint64    // Reconnections to osquery by RunForever
+	expvarEnabled              bool      // Publish the server stats with expvar
+	pprofAddr                  string    // Address to serve pprof on, see ServerPprof
+	pprofListener              net.Listener
+	pprofServer                *http.Server
 }
 
 // validRegistryNames contains the allowable RegistryName() values. If a plugin
@@ -97,6 +133,92 @@ func ServerPingInterval(interval time.Duration) ServerOption {
 	}
 }
 
+// ServerFraming sets the Thrift message framing used both by the extension's
+// listen socket and by the client the server creates to talk to osquery.
+func ServerFraming(f transport.Framing) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.framing = f
+	}
+}
+
+// ServerPeerVerifier sets a function that verifies the credentials of every
+// process connecting to the extension's listen socket. Connections that fail
+// verification are closed before any request is processed. On Windows only
+// the PID of the peer is known, and UID is -1.
+func ServerPeerVerifier(verify transport.PeerVerifier) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.peerVerifier = verify
+	}
+}
+
+// ServerRestrictPeersToOsquery only accepts connections on the extension's
+// listen socket from the process (same UID and PID) that serves the osquery
+// socket the extension registered with. Rejected connections are logged.
+// Start fails on platforms where peer credentials cannot be read, other than
+// Windows, where the named pipe only accepts LocalSystem and Administrators.
+func ServerRestrictPeersToOsquery() ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.restrictPeersToOsquery = true
+	}
+}
+
+// ServerMaxSocketPathCharacters overrides the platform default
+// MaxSocketPathCharacters used to validate the socket path. A value of zero
+// disables the check.
+func ServerMaxSocketPathCharacters(max int) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.maxSocketPathCharacters = max
+	}
+}
+
+// ServerSkipDeregistration disables deregistering the extension during
+// Shutdown. osquery removes the extension's routes by itself once it can no
+// longer reach the extension, so this is safe when osquery is expected to be
+// gone (or going away) whenever the extension shuts down. Even without this
+// option, deregistration is skipped once Run has detected that osquery is
+// gone.
+func ServerSkipDeregistration() ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.skipDeregistration = true
+	}
+}
+
+// ServerRegistryNames adds registry names that plugins may use in addition
+// to the built in ones (see validRegistryNames). Use this to register plugins
+// for osquery registries that this package does not know about.
+func ServerRegistryNames(names ...string) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		if s.extraRegistryNames == nil {
+			s.extraRegistryNames = make(map[string]bool)
+		}
+		for _, name := range names {
+			s.extraRegistryNames[name] = true
+		}
+	}
+}
+
+// ServerAllowAnyRegistryName disables the validation of plugin registry
+// names in RegisterPlugin. osquery will reject registries it does not
+// support when the extension registers.
+func ServerAllowAnyRegistryName() ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.allowAnyRegistryName = true
+	}
+}
+
+// ServerMaxConcurrentCalls limits the number of plugin calls executing at the
+// same time. Further calls wait for a running call to complete. A value of
+// zero (the default) does not limit calls.
+func ServerMaxConcurrentCalls(max int) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		if max > 0 {
+			s.callSlots = make(chan struct{}, max)
+		} else {
+			s.callSlots = nil
+		}
+	}
+}
+
 // ServerSideConnectivityCheckInterval Sets a thrift package variable for the ticker
 // interval used by connectivity check in thrift compiled TProcessorFunc implementations.
 // See the thrift docs for more information
@@ -117,21 +239,12 @@ func WithClient(client ExtensionManager) ServerOption {
 	}
 }
 
-// MaxSocketPathCharacters is set to 97 because a ".12345" uuid is added to the socket down stream
-// if the provided socket is greater than 97 we may exceed the limit of 103 (104 causes an error)
-// why 103 limit? https://unix.stackexchange.com/questions/367008/why-is-socket-path-length-limited-to-a-hundred-chars
-const MaxSocketPathCharacters = 97
-
 // NewExtensionManagerServer creates a new extension management server
-// communicating with osquery over the socket at the provided path. If
-// resolving the address or connecting to the socket fails, this function will
-// error.
+// communicating with osquery over the socket at the provided path. An empty
+// path is resolved to the first existing default socket (see
+// DefaultSocketPath), failing if there is none. If resolving the address or
+// connecting to the socket fails, this function will error.
 func NewExtensionManagerServer(name string, sockPath string, opts ...ServerOption) (*ExtensionManagerServer, error) {
-
-	if len(sockPath) > MaxSocketPathCharacters {
-		return nil, errors.Errorf("socket path %s (%d characters) exceeded the maximum socket path character length of %d", sockPath, len(sockPath), MaxSocketPathCharacters)
-	}
-
 	// Initialize nested registry maps
 	registry := make(map[string](map[string]OsqueryPlugin))
 	for reg := range validRegistryNames {
@@ -139,19 +252,45 @@ func NewExtensionManagerServer(name string, sockPath string, opts ...ServerOptio
 	}
 
 	manager := &ExtensionManagerServer{
-		name:         name,
-		sockPath:     sockPath,
-		registry:     registry,
-		timeout:      defaultTimeout,
-		pingInterval: defaultPingInterval,
+		name:                    name,
+		sockPath:                sockPath,
+		registry:                registry,
+		timeout:                 defaultTimeout,
+		pingInterval:            defaultPingInterval,
+		maxSocketPathCharacters: MaxSocketPathCharacters,
 	}
 
 	for _, opt := range opts {
 		opt(manager)
 	}
 
+	if manager.envErr != nil {
+		return nil, manager.envErr
+	}
+	if manager.socketFromEnv && sockPath == "" {
+		sockPath = os.Getenv(SocketEnv)
+	}
+	// The listen socket of the extension is derived from the path, which
+	// must be known even with a client set with WithClient.
+	if sockPath == "" {
+		path, err := findSocketPath()
+		if err != nil {
+			return nil, errors.Wrap(err, "no socket path given")
+		}
+		sockPath = path
+	}
+	manager.sockPath = sockPath
+
+	if manager.statsTable {
+		manager.RegisterPlugin(manager.newStatsTable())
+	}
+
+	if manager.maxSocketPathCharacters > 0 && len(sockPath) > manager.maxSocketPathCharacters {
+		return nil, errors.Errorf("socket path %s (%d characters) exceeded the maximum socket path character length of %d", sockPath, len(sockPath), manager.maxSocketPathCharacters)
+	}
+
 	if manager.serverClient == nil {
-		serverClient, err := NewClient(sockPath, manager.timeout)
+		serverClient, err := NewClient(sockPath, manager.timeout, ClientFraming(manager.framing))
 		if err != nil {
 			if serverClient != nil {
 				serverClient.Close()
@@ -162,6 +301,10 @@ func NewExtensionManagerServer(name string, sockPath string, opts ...ServerOptio
 		manager.serverClientShouldShutdown = true
 	}
 
+	if manager.expvarEnabled {
+		manager.publishExpvar()
+	}
+
 	return manager, nil
 }
 
@@ -170,13 +313,26 @@ func (s *ExtensionManagerServer) RegisterPlugin(plugins ...OsqueryPlugin) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	for _, plugin := range plugins {
-		if !validRegistryNames[plugin.RegistryName()] {
-			panic("invalid registry name: " + plugin.RegistryName())
+		regName := plugin.RegistryName()
+		if !s.validRegistryName(regName) {
+			panic("invalid registry name: " + regName)
 		}
-		s.registry[plugin.RegistryName()][plugin.Name()] = plugin
+		if s.registry == nil {
+			s.registry = make(map[string](map[string]OsqueryPlugin))
+		}
+		if s.registry[regName] == nil {
+			s.registry[regName] = make(map[string]OsqueryPlugin)
+		}
+		s.registry[regName][plugin.Name()] = plugin
 	}
 }
 
+// validRegistryName returns whether plugins may be registered in the named
+// registry.
+func (s *ExtensionManagerServer) validRegistryName(name string) bool {
+	return s.allowAnyRegistryName || validRegistryNames[name] || s.extraRegistryNames[name]
+}
+
 func (s *ExtensionManagerServer) genRegistry() osquery.ExtensionRegistry {
 	registry := osquery.ExtensionRegistry{}
 	for regName := range s.registry {
@@ -192,14 +348,59 @@ func (s *ExtensionManagerServer) genRegistry() osquery.ExtensionRegistry {
 // for requests from the osquery process. All plugins should be registered with
 // RegisterPlugin() before calling Start().
 func (s *ExtensionManagerServer) Start() error {
+	err := s.start()
+	s.recordErr(err)
+
+	s.mutex.Lock()
+	close(s.doneChan())
+	s.mutex.Unlock()
+
+	return err
+}
+
+func (s *ExtensionManagerServer) start() error {
 	var server thrift.TServer
 	err := func() error {
 		s.mutex.Lock()
 		defer s.mutex.Unlock()
+
+		// Wait reports on the most recent Start.
+		select {
+		case <-s.doneChan():
+			s.done = nil
+			s.doneErr = nil
+		default:
+		}
+
 		// check after the lock the serverClient is present. It could have gone away on very short restart loops
 		if s.serverClient == nil {
 			return errors.New("cannot start, shutdown in progress")
 		}
+		s.state = ServerStateStarting
+		s.osqueryGone = false
+		if err := s.startPprof(); err != nil {
+			return err
+		}
+		verify := s.peerVerifier
+		restrictPipe := false
+		if s.restrictPeersToOsquery {
+			peer, err := transport.SocketPeerCredentials(s.sockPath, s.timeout)
+			switch {
+			case errors.Cause(err) == transport.ErrPeerCredentialsUnsupported && runtime.GOOS == "windows":
+				// Named pipes are restricted by their security
+				// descriptor instead.
+				restrictPipe = true
+			case errors.Cause(err) == transport.ErrPeerCredentialsUnsupported:
+				// The listen socket could not verify any peer
+				// either, and would drop every connection.
+				return errors.Wrap(err, "restricting peers to osquery")
+			case err != nil:
+				return errors.Wrap(err, "reading osquery peer credentials")
+			default:
+				verify = transport.AllowPeer(peer.UID, peer.PID)
+			}
+		}
+
 		registry := s.genRegistry()
 
 		stat, err := s.serverClient.RegisterExtension(
@@ -217,12 +418,28 @@ func (s *ExtensionManagerServer) Start() error {
 			return errors.Errorf("status %d registering extension: %s", stat.Code, stat.Message)
 		}
 		s.uuid = stat.UUID
+		s.registeredAt = time.Now()
+		s.state = ServerStateRegistered
 
 		listenPath := fmt.Sprintf("%s.%d", s.sockPath, stat.UUID)
+		logAt(slog.LevelDebug, "registered extension", "extension", s.name, "uuid", stat.UUID, "socket", listenPath)
 
 		processor := osquery.NewExtensionProcessor(s)
 
-		s.transport, err = transport.OpenServer(listenPath, s.timeout)
+		s.listenPath = listenPath
+		if verify != nil || restrictPipe {
+			opts := []transport.VerifiedServerOption{
+				transport.OnRejectedPeer(func(err error) {
+					logAt(slog.LevelWarn, "rejected connection", "extension", s.name, "err", err)
+				}),
+			}
+			if restrictPipe {
+				opts = append(opts, transport.RestrictPipeToAdministrators())
+			}
+			s.transport, err = transport.OpenVerifiedServer(listenPath, s.timeout, verify, opts...)
+		} else {
+			s.transport, err = transport.OpenServer(listenPath, s.timeout)
+		}
 		if err != nil {
 			openError := errors.Wrapf(err, "opening server socket (%s)", listenPath)
 			_, err = s.serverClient.DeregisterExtension(stat.UUID)
@@ -232,15 +449,39 @@ func (s *ExtensionManagerServer) Start() error {
 			return openError
 		}
 
-		s.server = thrift.NewTSimpleServer2(processor, s.transport)
+		// Listen before reporting ready so that osquery (and anyone
+		// waiting on Ready) can connect immediately.
+		if err := s.transport.Listen(); err != nil {
+			listenError := errors.Wrapf(err, "listening on server socket (%s)", listenPath)
+			_, err = s.serverClient.DeregisterExtension(stat.UUID)
+			if err != nil {
+				return errors.Wrapf(err, "deregistering extension - follows %s", listenError.Error())
+			}
+			return listenError
+		}
+
+		s.server = thrift.NewTSimpleServer4(
+			processor,
+			s.transport,
+			s.framing.TransportFactory(),
+			s.framing.ProtocolFactory(),
+		)
 		server = s.server
 
-		s.started = true
+		s.state = ServerStateRunning
+		close(s.readyChan())
 
 		return nil
 	}()
 
 	if err != nil {
+		s.mutex.Lock()
+		if s.state == ServerStateStarting || s.state == ServerStateRegistered {
+			s.state = ServerStateCreated
+		}
+		// Don't leave pprof listening for an extension that isn't.
+		s.stopPprof()
+		s.mutex.Unlock()
 		return err
 	}
 
@@ -250,50 +491,123 @@ func (s *ExtensionManagerServer) Start() error {
 // Run starts the extension manager and runs until osquery calls for a shutdown
 // or the osquery instance goes away.
 func (s *ExtensionManagerServer) Run() error {
-	errc := make(chan error)
-	go func() {
-		errc <- s.Start()
-	}()
+	return s.run(context.Background())
+}
 
-	// Watch for the osquery process going away. If so, initiate shutdown.
-	go func() {
-		for {
-			time.Sleep(s.pingInterval)
+// RunWithSignals behaves like Run, but additionally shuts the server down
+// cleanly, deregistering from osquery, when the process receives SIGINT or
+// SIGTERM, or a stop request from the Windows service manager. A shutdown
+// caused by one of these returns nil.
+func (s *ExtensionManagerServer) RunWithSignals() error {
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
 
-			s.mutex.Lock()
-			serverClient := s.serverClient
-			s.mutex.Unlock()
+	ctx, stopService := notifyServiceStop(ctx, s.name, s.Wait)
+	defer stopService()
 
-			// can't ping if s.Shutdown has already happened
-			if serverClient == nil {
-				break
-			}
+	return s.run(ctx)
+}
 
-			status, err := serverClient.Ping()
-			if err != nil {
-				errc <- errors.Wrap(err, "extension ping failed")
-				break
-			}
-			if status.Code != 0 {
-				errc <- errors.Errorf("ping returned status %d", status.Code)
-				break
-			}
+// run implements Run, additionally shutting down when ctx is done. All
+// goroutines started by run have returned by the time it returns.
+func (s *ExtensionManagerServer) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		// Once the server stops for any reason there is nothing left
+		// to watch.
+		defer cancel()
+		return s.Start()
+	})
+
+	g.Go(func() error {
+		return s.watchOsquery(ctx)
+	})
+
+	g.Go(func() error {
+		<-ctx.Done()
+		_ = s.Shutdown(context.Background())
+		return nil
+	})
+
+	return g.Wait()
+}
+
+// watchOsquery pings osquery every pingInterval until ctx is done, returning
+// an error if osquery has gone away.
+func (s *ExtensionManagerServer) watchOsquery(ctx context.Context) error {
+	ticker := time.NewTicker(s.pingIntervalOrDefault())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
 		}
-	}()
 
-	err := <-errc
-	_ = s.Shutdown(context.Background())
-	return err
+		s.mutex.Lock()
+		serverClient := s.serverClient
+		s.mutex.Unlock()
+
+		// can't ping if s.Shutdown has already happened
+		if serverClient == nil {
+			return nil
+		}
+
+		_, span := traces.StartSpan(ctx, "ExtensionManagerServer.ping")
+		status, err := serverClient.Ping()
+		if err == nil && status.Code != 0 {
+			err = errors.Errorf("ping returned status %d", status.Code)
+		} else if err != nil {
+			// osquery cannot be reached, so there is no point in
+			// deregistering from it during shutdown.
+			s.mutex.Lock()
+			s.osqueryGone = true
+			s.mutex.Unlock()
+			err = errors.Wrap(err, "extension ping failed")
+		}
+		traces.RecordError(span, err)
+		span.End()
+		if err != nil {
+			logAt(slog.LevelWarn, "pinging osquery failed, stopping extension", "extension", s.name, "err", err)
+			// Record the error before shutting down, so that it is
+			// the one reported by Wait.
+			s.recordErr(err)
+			return err
+		}
+
+		s.mutex.Lock()
+		s.lastPing = time.Now()
+		s.mutex.Unlock()
+	}
+}
+
+// pingIntervalOrDefault returns the ping interval, falling back to the
+// default for servers created without NewExtensionManagerServer.
+func (s *ExtensionManagerServer) pingIntervalOrDefault() time.Duration {
+	if s.pingInterval <= 0 {
+		return defaultPingInterval
+	}
+	return s.pingInterval
 }
 
-// Ping implements the basic health check.
+// Ping implements the basic health check. With ServerAggregateHealth, the
+// health of the registered plugins is reported as well.
 func (s *ExtensionManagerServer) Ping(ctx context.Context) (*osquery.ExtensionStatus, error) {
+	if s.aggregateHealth {
+		return s.health(), nil
+	}
 	return &osquery.ExtensionStatus{Code: 0, Message: "OK"}, nil
 }
 
 // Call routes a call from the osquery process to the appropriate registered
-// plugin.
+// plugin. The trace context carried by the request, if any, becomes the
+// parent of the span of the call, see traces.ExtractRequest.
 func (s *ExtensionManagerServer) Call(ctx context.Context, registry string, item string, request osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+	ctx, request = traces.ExtractRequest(ctx, request)
 	ctx, span := traces.StartSpan(ctx, "ExtensionManagerServer.Call",
 		"registry", registry,
 		"item", item,
@@ -320,19 +634,83 @@ func (s *ExtensionManagerServer) Call(ctx context.Context, registry string, item
 		}, nil
 	}
 
+	if s.callSlots != nil {
+		select {
+		case s.callSlots <- struct{}{}:
+			defer func() { <-s.callSlots }()
+		case <-ctx.Done():
+			return &osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: "waiting for call slot: " + ctx.Err().Error(),
+				},
+			}, nil
+		}
+	}
+
+	start := time.Now()
 	response := plugin.Call(ctx, request)
+	traces.RecordStatus(span, response.Status)
+	duration := time.Since(start)
+	failed := response.Status != nil && response.Status.Code != 0
+	s.recordCall(registry, item, duration, failed)
+	logAt(slog.LevelDebug, "plugin call", "extension", s.name, "registry", registry, "item", item,
+		"action", request["action"], "duration", duration, "failed", failed)
+	if s.aggregateHealth && response.Status != nil && response.Status.Code != 0 {
+		s.recordCallError(registry, item, response.Status.Message)
+	}
 	return &response, nil
 }
 
 // Shutdown deregisters the extension, stops the server and closes all sockets.
-func (s *ExtensionManagerServer) Shutdown(ctx context.Context) (err error) {
+// The context bounds the deregistration request. If the context has a
+// deadline, Shutdown also waits (up to that deadline) for the server to stop.
+// A *ShutdownTimeoutError is returned when the context is done first.
+func (s *ExtensionManagerServer) Shutdown(ctx context.Context) error {
+	stopped, err := s.shutdown(ctx)
+
+	// Without a deadline the server stops asynchronously, waiting could
+	// deadlock if a shutdown request from osquery is being processed.
+	if _, ok := ctx.Deadline(); ok && stopped != nil {
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			if err == nil {
+				err = &ShutdownTimeoutError{Op: "stopping server", Err: ctx.Err()}
+			}
+		}
+	}
+
+	return err
+}
+
+// shutdown does the work of Shutdown while holding the lock. The returned
+// channel, if any, is closed once the server has stopped.
+func (s *ExtensionManagerServer) shutdown(ctx context.Context) (stopped chan struct{}, err error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if s.serverClient != nil {
+	s.state = ServerStateShuttingDown
+	defer func() {
+		s.state = ServerStateStopped
+	}()
+	logAt(slog.LevelDebug, "shutting down extension", "extension", s.name, "uuid", s.uuid)
+
+	// A subsequent Start will signal readiness on a new channel.
+	select {
+	case <-s.readyChan():
+		s.ready = nil
+	default:
+	}
+
+	// Closed once a deregistration that timed out has returned.
+	var deregistering <-chan struct{}
+	if s.serverClient != nil && !s.skipDeregistration && !s.osqueryGone {
 		var stat *osquery.ExtensionStatus
-		stat, err = s.serverClient.DeregisterExtension(s.uuid)
-		err = errors.Wrap(err, "deregistering extension")
+		stat, deregistering, err = s.deregister(ctx)
+		if _, ok := err.(*ShutdownTimeoutError); !ok {
+			err = errors.Wrap(err, "deregistering extension")
+		}
 		if err == nil && stat.Code != 0 {
 			err = errors.Errorf("status %d deregistering extension: %s", stat.Code, stat.Message)
 		}
@@ -340,34 +718,156 @@ func (s *ExtensionManagerServer) Shutdown(ctx context.Context) (err error) {
 
 	if s.server != nil {
 		server := s.server
+		listenPath := s.listenPath
 		s.server = nil
+		stopped = make(chan struct{})
 		// Stop the server asynchronously so that the current request
 		// can complete. Otherwise, this is vulnerable to deadlock if a
 		// shutdown request is being processed when Shutdown is
 		// explicitly called.
 		go func() {
 			server.Stop()
+			_ = transport.CloseServer(listenPath)
+			close(stopped)
 		}()
 	}
 
-	// Shutdown the client, if appropriate
+	s.stopPprof()
+
+	// Shutdown the client, if appropriate. A deregistration that timed
+	// out may still be using it, it is closed once that call returns.
 	if s.serverClientShouldShutdown && s.serverClient != nil {
-		s.serverClient.Close()
+		client := s.serverClient
+		if deregistering != nil {
+			go func() {
+				<-deregistering
+				client.Close()
+			}()
+		} else {
+			client.Close()
+		}
 		s.serverClient = nil
 	}
 
-	return
+	return stopped, err
 }
 
-// Useful for testing
-func (s *ExtensionManagerServer) waitStarted() {
-	for {
-		s.mutex.Lock()
-		started := s.started
-		s.mutex.Unlock()
-		if started {
-			time.Sleep(10 * time.Millisecond)
-			break
+// deregister deregisters the extension, giving up once ctx is done. When it
+// gives up, the returned channel is closed once the call to osquery, which
+// still uses the client, has returned. The caller must hold s.mutex.
+func (s *ExtensionManagerServer) deregister(ctx context.Context) (*osquery.ExtensionStatus, <-chan struct{}, error) {
+	type result struct {
+		stat *osquery.ExtensionStatus
+		err  error
+	}
+
+	client := s.serverClient
+	uuid := s.uuid
+	resc := make(chan result, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var res result
+		if c, ok := client.(interface {
+			DeregisterExtensionContext(context.Context, osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error)
+		}); ok {
+			res.stat, res.err = c.DeregisterExtensionContext(ctx, uuid)
+		} else {
+			res.stat, res.err = client.DeregisterExtension(uuid)
 		}
+		resc <- res
+	}()
+
+	select {
+	case res := <-resc:
+		return res.stat, nil, res.err
+	case <-ctx.Done():
+		return nil, done, &ShutdownTimeoutError{Op: "deregistering extension", Err: ctx.Err()}
+	}
+}
+
+// ShutdownTimeoutError is returned by Shutdown when its context is done
+// before the shutdown completed.
+type ShutdownTimeoutError struct {
+	// Op is the step of the shutdown that did not complete.
+	Op string
+	// Err is the context error.
+	Err error
+}
+
+func (e *ShutdownTimeoutError) Error() string {
+	return fmt.Sprintf("timeout %s: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the context error.
+func (e *ShutdownTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports that the error is a timeout, see net.Error.
+func (e *ShutdownTimeoutError) Timeout() bool {
+	return true
+}
+
+// Ready returns a channel that is closed once the server has registered
+// with osquery and is listening for requests. After Shutdown, a subsequent
+// Start will signal on a new channel.
+func (s *ExtensionManagerServer) Ready() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.readyChan()
+}
+
+// WaitReady blocks until the server is ready (see Ready) or the context is
+// done, in which case the context error is returned.
+func (s *ExtensionManagerServer) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.Ready():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait blocks until the server has stopped and returns the error that
+// stopped it. This is the error returned by Run, or by Start if the server
+// was started directly. It is nil for a clean Shutdown.
+func (s *ExtensionManagerServer) Wait() error {
+	s.mutex.Lock()
+	done := s.doneChan()
+	s.mutex.Unlock()
+
+	<-done
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.doneErr
+}
+
+// recordErr stores err as the terminal error for Wait, unless an earlier
+// error was already recorded.
+func (s *ExtensionManagerServer) recordErr(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.doneErr == nil {
+		s.doneErr = err
+	}
+}
+
+// doneChan returns the current done channel, creating it if necessary. The
+// caller must hold s.mutex.
+func (s *ExtensionManagerServer) doneChan() chan struct{} {
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	return s.done
+}
+
+// readyChan returns the current ready channel, creating it if necessary. The
+// caller must hold s.mutex.
+func (s *ExtensionManagerServer) readyChan() chan struct{} {
+	if s.ready == nil {
+		s.ready = make(chan struct{})
 	}
+	return s.ready
 }
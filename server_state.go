@@ -0,0 +1,93 @@
+package osquery
+
+import (
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// ServerState describes where an ExtensionManagerServer is in its lifecycle.
+type ServerState int
+
+const (
+	// ServerStateCreated is the state of a server that has not been
+	// started, or failed to start.
+	ServerStateCreated ServerState = iota
+	// ServerStateStarting is the state while the server registers with
+	// osquery.
+	ServerStateStarting
+	// ServerStateRegistered is the state once osquery accepted the
+	// registration, but before the server is listening for requests.
+	ServerStateRegistered
+	// ServerStateRunning is the state while the server is serving
+	// requests from osquery.
+	ServerStateRunning
+	// ServerStateShuttingDown is the state while the server deregisters
+	// and stops.
+	ServerStateShuttingDown
+	// ServerStateStopped is the state once Shutdown has completed.
+	ServerStateStopped
+)
+
+// String implements the fmt.Stringer interface for ServerState.
+func (s ServerState) String() string {
+	switch s {
+	case ServerStateCreated:
+		return "created"
+	case ServerStateStarting:
+		return "starting"
+	case ServerStateRegistered:
+		return "registered"
+	case ServerStateRunning:
+		return "running"
+	case ServerStateShuttingDown:
+		return "shutting_down"
+	case ServerStateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// Name returns the name of the extension.
+func (s *ExtensionManagerServer) Name() string {
+	return s.name
+}
+
+// State returns the current lifecycle state of the server.
+func (s *ExtensionManagerServer) State() ServerState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+// UUID returns the route UUID osquery assigned to the extension when it
+// registered. It is zero until the server is registered.
+func (s *ExtensionManagerServer) UUID() osquery.ExtensionRouteUUID {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.uuid
+}
+
+// RegisteredAt returns the time at which osquery accepted the extension's
+// registration, or the zero time if it has not registered.
+func (s *ExtensionManagerServer) RegisteredAt() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.registeredAt
+}
+
+// LastPing returns the time of the last successful ping of osquery made by
+// Run, or the zero time if there has been none.
+func (s *ExtensionManagerServer) LastPing() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastPing
+}
+
+// Reconnects returns the number of times RunForever reconnected to osquery.
+func (s *ExtensionManagerServer) Reconnects() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reconnects
+}
@@ -5,22 +5,25 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"os"
-	"runtime/pprof"
+	"runtime"
 	"strings"
 	"sync"
 	"syscall"
 	"testing"
 	"time"
 
-	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
 	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/osquery/osquery-go/transport"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var _ ExtensionManager = (*mock.Manager)(nil)
+
 // Verify that an error in server.Start will return an error instead of deadlock.
 func TestNoDeadlockOnError(t *testing.T) {
 	registry := make(map[string](map[string]OsqueryPlugin))
@@ -96,114 +99,10 @@ func TestShutdownWhenPingFails(t *testing.T) {
 	err = server.Run()
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "broken pipe")
-	assert.True(t, mock.DeRegisterExtensionFuncInvoked)
+	// osquery is gone, so deregistration is skipped.
+	assert.False(t, mock.DeRegisterExtensionFuncInvoked)
 	assert.True(t, mock.CloseFuncInvoked)
-}
-
-// How many parallel tests to run (because sync issues do not occur on every
-// run, this maximizes our chances of seeing any issue by quickly executing
-// many runs of the test).
-const parallelTestShutdownDeadlock = 20
-
-func TestShutdownDeadlock(t *testing.T) {
-	for i := 0; i < parallelTestShutdownDeadlock; i++ {
-		i := i
-		t.Run("", func(t *testing.T) {
-			t.Parallel()
-			testShutdownDeadlock(t, i)
-		})
-	}
-}
-
-func testShutdownDeadlock(t *testing.T, uuid int) {
-	tempPath, err := ioutil.TempFile("", "")
-	require.Nil(t, err)
-	defer os.Remove(tempPath.Name())
-
-	retUUID := osquery.ExtensionRouteUUID(uuid)
-	mock := &MockExtensionManager{
-		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
-			return &osquery.ExtensionStatus{Code: 0, UUID: retUUID}, nil
-		},
-		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
-			return &osquery.ExtensionStatus{}, nil
-		},
-		CloseFunc: func() {},
-	}
-	server := ExtensionManagerServer{
-		serverClient:               mock,
-		sockPath:                   tempPath.Name(),
-		timeout:                    defaultTimeout,
-		serverClientShouldShutdown: true,
-	}
-
-	var wait sync.WaitGroup
-
-	go func() {
-		// We do not wait for this routine to finish because thrift.TServer.Serve
-		// seems to sometimes hang after shutdowns. (This test is just testing
-		// the Shutdown doesn't hang.)
-		err := server.Start()
-		require.NoError(t, err)
-	}()
-
-	// Wait for server to be set up
-	server.waitStarted()
-
-	// Create a raw client to access the shutdown method that is not
-	// usually exposed.
-	listenPath := fmt.Sprintf("%s.%d", tempPath.Name(), retUUID)
-	addr, err := net.ResolveUnixAddr("unix", listenPath)
-	require.Nil(t, err)
-	timeout := 500 * time.Millisecond
-	opened := false
-	attempt := 0
-	var transport *thrift.TSocket
-	for !opened && attempt < 10 {
-		transport = thrift.NewTSocketFromAddrTimeout(addr, timeout, timeout)
-		err = transport.Open()
-		attempt++
-		if err != nil {
-			time.Sleep(1 * time.Second)
-		} else {
-			opened = true
-		}
-	}
-	require.NoError(t, err)
-	client := osquery.NewExtensionManagerClientFactory(transport,
-		thrift.NewTBinaryProtocolFactoryDefault())
-
-	// Simultaneously call shutdown through a request from the client and
-	// directly on the server object.
-	wait.Add(1)
-	go func() {
-		defer wait.Done()
-		client.Shutdown(context.Background())
-	}()
-
-	wait.Add(1)
-	go func() {
-		defer wait.Done()
-		err = server.Shutdown(context.Background())
-		require.NoError(t, err)
-	}()
-
-	// Track whether shutdown completed
-	completed := make(chan struct{})
-	go func() {
-		wait.Wait()
-		close(completed)
-	}()
-
-	// either indicate successful shutdown, or fatal the test because it
-	// hung
-	select {
-	case <-completed:
-		// Success. Do nothing.
-	case <-time.After(10 * time.Second):
-		pprof.Lookup("goroutine").WriteTo(os.Stdout, 1)
-		t.Fatal("hung on shutdown")
-	}
+	assert.Equal(t, err, server.Wait())
 }
 
 func TestShutdownBasic(t *testing.T) {
@@ -239,7 +138,7 @@ func TestShutdownBasic(t *testing.T) {
 			close(completed)
 		}()
 
-		server.waitStarted()
+		<-server.Ready()
 
 		err := server.Shutdown(context.Background())
 		require.NoError(t, err)
@@ -273,9 +172,12 @@ func TestNewExtensionManagerServer(t *testing.T) {
 		args           args
 		want           *ExtensionManagerServer
 		errContainsStr string
+		skipWindows    bool
 	}{
 		{
-			name: "socket path too long",
+			// Named pipes have no length limit, the check is disabled.
+			name:        "socket path too long",
+			skipWindows: true,
 			args: args{
 				name:     "socket_path_too_long",
 				sockPath: strings.Repeat("a", MaxSocketPathCharacters+1),
@@ -283,10 +185,22 @@ func TestNewExtensionManagerServer(t *testing.T) {
 			},
 			errContainsStr: "exceeded the maximum socket path character length",
 		},
+		{
+			name: "socket path too long for override",
+			args: args{
+				name:     "socket_path_too_long_for_override",
+				sockPath: strings.Repeat("a", 11),
+				opts:     []ServerOption{ServerMaxSocketPathCharacters(10)},
+			},
+			errContainsStr: "maximum socket path character length of 10",
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
+			if tt.skipWindows && runtime.GOOS == "windows" {
+				t.Skip("MaxSocketPathCharacters is 0 on Windows")
+			}
 			t.Parallel()
 			got, err := NewExtensionManagerServer(tt.args.name, tt.args.sockPath, tt.args.opts...)
 			if tt.errContainsStr != "" {
@@ -299,3 +213,337 @@ func TestNewExtensionManagerServer(t *testing.T) {
 		})
 	}
 }
+
+func TestServerFraming(t *testing.T) {
+	for _, framing := range []transport.Framing{transport.FramingNone, transport.FramingFramed, transport.FramingHeader} {
+		framing := framing
+		t.Run(framing.String(), func(t *testing.T) {
+			tempPath, err := os.CreateTemp(t.TempDir(), "")
+			require.NoError(t, err)
+
+			retUUID := osquery.ExtensionRouteUUID(7)
+			mock := &MockExtensionManager{
+				RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+					return &osquery.ExtensionStatus{Code: 0, UUID: retUUID}, nil
+				},
+				DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+					return &osquery.ExtensionStatus{}, nil
+				},
+				CloseFunc: func() {},
+			}
+			server := &ExtensionManagerServer{
+				serverClient: mock,
+				sockPath:     tempPath.Name(),
+				timeout:      defaultTimeout,
+				framing:      framing,
+			}
+
+			go server.Start()
+			<-server.Ready()
+			defer server.Shutdown(context.Background())
+
+			client, err := NewClient(fmt.Sprintf("%s.%d", tempPath.Name(), retUUID), 5*time.Second, ClientFraming(framing))
+			require.NoError(t, err)
+			defer client.Close()
+
+			status, err := client.Ping()
+			require.NoError(t, err)
+			assert.Equal(t, int32(0), status.Code)
+		})
+	}
+}
+
+func TestServerState(t *testing.T) {
+	tempPath, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+
+	retUUID := osquery.ExtensionRouteUUID(42)
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, UUID: retUUID}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() {},
+	}
+	server := &ExtensionManagerServer{serverClient: mock, sockPath: tempPath.Name()}
+
+	assert.Equal(t, ServerStateCreated, server.State())
+	assert.True(t, server.RegisteredAt().IsZero())
+	assert.True(t, server.LastPing().IsZero())
+
+	go server.Start()
+	<-server.Ready()
+
+	assert.Equal(t, ServerStateRunning, server.State())
+	assert.Equal(t, retUUID, server.UUID())
+	assert.False(t, server.RegisteredAt().IsZero())
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.Equal(t, ServerStateStopped, server.State())
+	assert.Equal(t, "stopped", server.State().String())
+}
+
+func TestWaitReadyContext(t *testing.T) {
+	server := &ExtensionManagerServer{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, server.WaitReady(ctx), context.DeadlineExceeded)
+
+	select {
+	case <-server.Ready():
+		t.Fatal("server should not be ready")
+	default:
+	}
+}
+
+func TestWait(t *testing.T) {
+	tempPath, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+
+	registerErr := errors.New("boom!")
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() {},
+	}
+	server := &ExtensionManagerServer{serverClient: mock, sockPath: tempPath.Name()}
+
+	go server.Start()
+	<-server.Ready()
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.NoError(t, server.Wait())
+
+	// A failed Start is reported by the next Wait.
+	mock.RegisterExtensionFunc = func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+		return nil, registerErr
+	}
+	err = server.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom!")
+	assert.Equal(t, err, server.Wait())
+}
+
+func TestShutdownSkipDeregistration(t *testing.T) {
+	tempPath, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() {},
+	}
+	server := &ExtensionManagerServer{serverClient: mock, sockPath: tempPath.Name()}
+	ServerSkipDeregistration()(server)
+
+	go server.Start()
+	<-server.Ready()
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.False(t, mock.DeRegisterExtensionFuncInvoked)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	tempPath, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+
+	block := make(chan struct{})
+	defer close(block)
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			// As if osquery stopped responding
+			<-block
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() {},
+	}
+	server := &ExtensionManagerServer{serverClient: mock, sockPath: tempPath.Name()}
+
+	go server.Start()
+	<-server.Ready()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = server.Shutdown(ctx)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	var timeoutErr *ShutdownTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, "deregistering extension", timeoutErr.Op)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The server is still stopped.
+	assert.NoError(t, server.Wait())
+}
+
+func TestShutdownDeadlineClosesClientAfterDeregistration(t *testing.T) {
+	tempPath, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+
+	block := make(chan struct{})
+	closed := make(chan struct{})
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			<-block
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() { close(closed) },
+	}
+	server := &ExtensionManagerServer{serverClient: mock, serverClientShouldShutdown: true, sockPath: tempPath.Name()}
+
+	go server.Start()
+	<-server.Ready()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Error(t, server.Shutdown(ctx))
+
+	// The client is still in use by the deregistration
+	select {
+	case <-closed:
+		t.Fatal("client closed while deregistering")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(block)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client not closed after deregistration")
+	}
+}
+
+type customPlugin struct {
+	logger.Plugin
+	registry string
+}
+
+func (p *customPlugin) RegistryName() string {
+	return p.registry
+}
+
+func TestRegisterPluginCustomRegistry(t *testing.T) {
+	log := func(ctx context.Context, typ logger.LogType, logText string) error { return nil }
+	plugin := &customPlugin{Plugin: *logger.NewPlugin("custom", log), registry: "enroll"}
+
+	server := &ExtensionManagerServer{}
+	assert.Panics(t, func() { server.RegisterPlugin(plugin) })
+
+	server = &ExtensionManagerServer{}
+	ServerRegistryNames("enroll")(server)
+	server.RegisterPlugin(plugin)
+	assert.Contains(t, server.genRegistry(), "enroll")
+
+	server = &ExtensionManagerServer{}
+	ServerAllowAnyRegistryName()(server)
+	plugin.registry = "anything"
+	server.RegisterPlugin(plugin)
+	assert.Contains(t, server.genRegistry(), "anything")
+}
+
+func TestServerMaxConcurrentCalls(t *testing.T) {
+	var mut sync.Mutex
+	running, maxRunning := 0, 0
+	release := make(chan struct{})
+	log := func(ctx context.Context, typ logger.LogType, logText string) error {
+		mut.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mut.Unlock()
+
+		<-release
+
+		mut.Lock()
+		running--
+		mut.Unlock()
+		return nil
+	}
+
+	server := &ExtensionManagerServer{}
+	ServerMaxConcurrentCalls(2)(server)
+	server.RegisterPlugin(logger.NewPlugin("testLogger", log))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := server.Call(context.Background(), "logger", "testLogger", osquery.ExtensionPluginRequest{"string": "log"})
+			assert.NoError(t, err)
+			assert.Equal(t, int32(0), resp.Status.Code)
+		}()
+	}
+
+	// A call waiting for a slot gives up when its context is done.
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	resp, err := server.Call(ctx, "logger", "testLogger", osquery.ExtensionPluginRequest{"string": "log"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 2, maxRunning)
+}
+
+type recordingPlugin struct {
+	logger.Plugin
+	call func(ctx context.Context, request osquery.ExtensionPluginRequest)
+}
+
+func (p *recordingPlugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	p.call(ctx, request)
+	return osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 0, Message: "OK"}}
+}
+
+func TestTracePropagation(t *testing.T) {
+	var (
+		gotCtx     context.Context
+		gotRequest osquery.ExtensionPluginRequest
+	)
+	log := func(ctx context.Context, typ logger.LogType, logText string) error { return nil }
+	plugin := &recordingPlugin{Plugin: *logger.NewPlugin("recording", log), call: func(ctx context.Context, request osquery.ExtensionPluginRequest) {
+		gotCtx, gotRequest = ctx, request
+	}}
+	server := &ExtensionManagerServer{}
+	server.RegisterPlugin(plugin)
+
+	// osqueryd forwards the requests of the client to the extension
+	manager := &mock.ExtensionManager{
+		CallFunc: func(ctx context.Context, registry, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+			return server.Call(context.Background(), registry, item, req)
+		},
+	}
+	client, err := NewClient("", 5*time.Second, WithOsqueryThriftClient(manager))
+	require.NoError(t, err)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	request := osquery.ExtensionPluginRequest{"action": "test"}
+	_, err = client.CallContext(ctx, "logger", "recording", request)
+	require.NoError(t, err)
+
+	assert.Equal(t, request, gotRequest)
+	assert.Equal(t, sc.TraceID(), trace.SpanContextFromContext(gotCtx).TraceID())
+}
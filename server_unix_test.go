@@ -0,0 +1,130 @@
+//go:build !windows
+// +build !windows
+
+package osquery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/fakeosqueryd"
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithSignals(t *testing.T) {
+	tempPath, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		PingFunc: func() (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() {},
+	}
+	server := &ExtensionManagerServer{
+		serverClient: mock,
+		sockPath:     tempPath.Name(),
+		pingInterval: time.Second,
+	}
+
+	errc := make(chan error)
+	go func() {
+		errc <- server.RunWithSignals()
+	}()
+
+	<-server.Ready()
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunWithSignals did not return after SIGTERM")
+	}
+	assert.True(t, mock.DeRegisterExtensionFuncInvoked)
+}
+
+// How many parallel tests to run (because sync issues do not occur on every
+// run, this maximizes our chances of seeing any issue by quickly executing
+// many runs of the test).
+const parallelTestShutdownDeadlock = 20
+
+func TestShutdownDeadlock(t *testing.T) {
+	for i := 0; i < parallelTestShutdownDeadlock; i++ {
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+			testShutdownDeadlock(t)
+		})
+	}
+}
+
+func testShutdownDeadlock(t *testing.T) {
+	osqueryd, err := fakeosqueryd.Start(filepath.Join(t.TempDir(), "osquery.em"))
+	require.NoError(t, err)
+	defer osqueryd.Close()
+
+	server, err := NewExtensionManagerServer("shutdown_deadlock", osqueryd.SocketPath())
+	require.NoError(t, err)
+
+	var wait sync.WaitGroup
+
+	go func() {
+		// We do not wait for this routine to finish because thrift.TServer.Serve
+		// seems to sometimes hang after shutdowns. (This test is just testing
+		// the Shutdown doesn't hang.)
+		err := server.Start()
+		require.NoError(t, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ext, err := osqueryd.WaitRegistered(ctx, "shutdown_deadlock")
+	require.NoError(t, err)
+	<-server.Ready()
+
+	// Simultaneously call shutdown through a request from osquery and
+	// directly on the server object.
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		osqueryd.ShutdownExtension(ctx, ext.UUID)
+	}()
+
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		err := server.Shutdown(context.Background())
+		require.NoError(t, err)
+	}()
+
+	// Track whether shutdown completed
+	completed := make(chan struct{})
+	go func() {
+		wait.Wait()
+		close(completed)
+	}()
+
+	// either indicate successful shutdown, or fatal the test because it
+	// hung
+	select {
+	case <-completed:
+		// Success. Do nothing.
+	case <-time.After(10 * time.Second):
+		pprof.Lookup("goroutine").WriteTo(os.Stdout, 1)
+		t.Fatal("hung on shutdown")
+	}
+}
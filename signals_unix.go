@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package osquery
+
+import (
+	"context"
+	"os"
+	"syscall"
+)
+
+// shutdownSignals are the signals that cause RunWithSignals to shut down.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// notifyServiceStop is a noop outside of Windows.
+func notifyServiceStop(ctx context.Context, name string, wait func() error) (context.Context, func()) {
+	return ctx, func() {}
+}
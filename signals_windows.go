@@ -0,0 +1,53 @@
+package osquery
+
+import (
+	"context"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// shutdownSignals are the signals that cause RunWithSignals to shut down. Go
+// delivers SIGTERM for console close, logoff and shutdown events on Windows.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// notifyServiceStop returns a context that is canceled when the service
+// manager asks the process to stop, if it is running as a Windows service.
+// The service is only reported as stopped once wait returns, so that the
+// extension has a chance to deregister.
+func notifyServiceStop(ctx context.Context, name string, wait func() error) (context.Context, func()) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		_ = svc.Run(name, &serviceHandler{stop: cancel, wait: wait})
+	}()
+	return ctx, cancel
+}
+
+// serviceHandler implements svc.Handler by canceling the run on a stop or
+// shutdown request.
+type serviceHandler struct {
+	stop func()
+	wait func() error
+}
+
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			changes <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			changes <- svc.Status{State: svc.StopPending}
+			h.stop()
+			_ = h.wait()
+			return false, 0
+		}
+	}
+	return false, 0
+}
@@ -0,0 +1,29 @@
+package osquery
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultSocketPath returns the path to the extensions socket of osqueryd as
+// installed on the platform: /var/osquery/osquery.em, the Homebrew paths on
+// macOS, or \\.\pipe\osquery.em on Windows. The first existing path is
+// returned, or the first path if none exists.
+func DefaultSocketPath() string {
+	if path, err := findSocketPath(); err == nil {
+		return path
+	}
+	return defaultSocketPaths[0]
+}
+
+// findSocketPath returns the first existing default socket path.
+func findSocketPath() (string, error) {
+	for _, path := range defaultSocketPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.Errorf("no osquery extensions socket found at %s", strings.Join(defaultSocketPaths, ", "))
+}
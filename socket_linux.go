@@ -0,0 +1,9 @@
+package osquery
+
+// MaxSocketPathCharacters is set to 101 because a ".12345" uuid is added to the socket down stream
+// and Linux limits socket paths to 107 characters (108 including the terminating NUL).
+const MaxSocketPathCharacters = 101
+
+// defaultSocketPaths are the extensions sockets of osqueryd installed by the
+// osquery packages.
+var defaultSocketPaths = []string{"/var/osquery/osquery.em"}
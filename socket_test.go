@@ -0,0 +1,25 @@
+package osquery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultSocketPath(t *testing.T) {
+	assert.NotEmpty(t, DefaultSocketPath())
+
+	dir := t.TempDir()
+	missing, existing := filepath.Join(dir, "missing.em"), filepath.Join(dir, "existing.em")
+	defer func(paths []string) { defaultSocketPaths = paths }(defaultSocketPaths)
+
+	defaultSocketPaths = []string{missing, filepath.Join(dir, "other.em")}
+	assert.Equal(t, missing, DefaultSocketPath())
+
+	require.NoError(t, os.WriteFile(existing, nil, 0644))
+	defaultSocketPaths = []string{missing, existing}
+	assert.Equal(t, existing, DefaultSocketPath())
+}
@@ -0,0 +1,17 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package osquery
+
+// MaxSocketPathCharacters is set to 97 because a ".12345" uuid is added to the socket down stream
+// if the provided socket is greater than 97 we may exceed the limit of 103 (104 causes an error)
+// why 103 limit? https://unix.stackexchange.com/questions/367008/why-is-socket-path-length-limited-to-a-hundred-chars
+const MaxSocketPathCharacters = 97
+
+// defaultSocketPaths are the extensions sockets of osqueryd installed by the
+// osquery packages, then by Homebrew on Apple silicon and Intel macOS.
+var defaultSocketPaths = []string{
+	"/var/osquery/osquery.em",
+	"/opt/homebrew/var/osquery/osquery.em",
+	"/usr/local/var/osquery/osquery.em",
+}
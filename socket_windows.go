@@ -0,0 +1,9 @@
+package osquery
+
+// MaxSocketPathCharacters is 0 on Windows, disabling the length check. Named
+// pipes are not subject to the unix socket path limit.
+const MaxSocketPathCharacters = 0
+
+// defaultSocketPaths are the extensions named pipes of osqueryd installed by
+// the osquery packages.
+var defaultSocketPaths = []string{`\\.\pipe\osquery.em`}
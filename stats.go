@@ -0,0 +1,110 @@
+package osquery
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// PluginStats are the call statistics of a registered plugin.
+type PluginStats struct {
+	// Registry and Name identify the plugin.
+	Registry string
+	Name     string
+	// Calls is the number of calls routed to the plugin.
+	Calls uint64
+	// Errors is the number of calls that returned a non-zero status.
+	Errors uint64
+	// TotalDuration is the total time spent in the plugin's Call.
+	TotalDuration time.Duration
+}
+
+// AverageDuration returns the average duration of a call.
+func (s PluginStats) AverageDuration() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Calls)
+}
+
+// ServerStatsTable registers a table named <extension>_extension_stats that
+// reports the PluginStats of every plugin of the extension, so that its
+// performance can be observed from osquery with plain SQL.
+func ServerStatsTable() ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.statsTable = true
+	}
+}
+
+// PluginStats returns the call statistics of the registered plugins, sorted
+// by registry and name.
+func (s *ExtensionManagerServer) PluginStats() []PluginStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var stats []PluginStats
+	for registry, subreg := range s.registry {
+		for name := range subreg {
+			st := PluginStats{Registry: registry, Name: name}
+			if recorded, ok := s.pluginStats[registry+"/"+name]; ok {
+				st = *recorded
+			}
+			stats = append(stats, st)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Registry != stats[j].Registry {
+			return stats[i].Registry < stats[j].Registry
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// recordCall adds a plugin call to the plugin statistics.
+func (s *ExtensionManagerServer) recordCall(registry, item string, duration time.Duration, failed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := registry + "/" + item
+	if s.pluginStats == nil {
+		s.pluginStats = map[string]*PluginStats{}
+	}
+	st, ok := s.pluginStats[key]
+	if !ok {
+		st = &PluginStats{Registry: registry, Name: item}
+		s.pluginStats[key] = st
+	}
+	st.Calls++
+	st.TotalDuration += duration
+	if failed {
+		st.Errors++
+	}
+}
+
+// newStatsTable creates the table registered by ServerStatsTable.
+func (s *ExtensionManagerServer) newStatsTable() *table.Plugin {
+	columns := []table.ColumnDefinition{
+		table.TextColumn("registry"),
+		table.TextColumn("name"),
+		table.BigIntColumn("calls"),
+		table.BigIntColumn("errors"),
+		table.DoubleColumn("average_latency_ms"),
+	}
+	return table.NewPlugin(s.name+"_extension_stats", columns, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		var rows []map[string]string
+		for _, st := range s.PluginStats() {
+			rows = append(rows, map[string]string{
+				"registry":           st.Registry,
+				"name":               st.Name,
+				"calls":              strconv.FormatUint(st.Calls, 10),
+				"errors":             strconv.FormatUint(st.Errors, 10),
+				"average_latency_ms": strconv.FormatFloat(float64(st.AverageDuration())/float64(time.Millisecond), 'f', -1, 64),
+			})
+		}
+		return rows, nil
+	})
+}
@@ -0,0 +1,50 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerStatsTable(t *testing.T) {
+	var fail bool
+	logFn := func(ctx context.Context, typ logger.LogType, logText string) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	server, err := NewExtensionManagerServer("myext", "/tmp/osquery.sock", WithClient(&MockExtensionManager{}), ServerStatsTable())
+	require.NoError(t, err)
+	server.RegisterPlugin(logger.NewPlugin("mylogger", logFn))
+
+	for i := 0; i < 3; i++ {
+		fail = i == 2
+		_, err = server.Call(context.Background(), "logger", "mylogger", osquery.ExtensionPluginRequest{"string": "log"})
+		require.NoError(t, err)
+	}
+
+	stats := server.PluginStats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "logger", stats[0].Registry)
+	assert.Equal(t, "mylogger", stats[0].Name)
+	assert.Equal(t, uint64(3), stats[0].Calls)
+	assert.Equal(t, uint64(1), stats[0].Errors)
+	assert.Equal(t, PluginStats{Registry: "table", Name: "myext_extension_stats"}, stats[1])
+
+	resp, err := server.Call(context.Background(), "table", "myext_extension_stats", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	require.Len(t, resp.Response, 2)
+	assert.Equal(t, "mylogger", resp.Response[0]["name"])
+	assert.Equal(t, "3", resp.Response[0]["calls"])
+	assert.Equal(t, "1", resp.Response[0]["errors"])
+	assert.NotEmpty(t, resp.Response[0]["average_latency_ms"])
+	assert.Equal(t, "0", resp.Response[1]["calls"])
+}
@@ -0,0 +1,149 @@
+package osquery
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/pkg/errors"
+)
+
+// SupervisorEvent identifies a transition made by RunForever.
+type SupervisorEvent int
+
+const (
+	// SupervisorStarting is reported before each attempt to register
+	// with osquery and serve requests.
+	SupervisorStarting SupervisorEvent = iota
+	// SupervisorStopped is reported when the server stopped. The error
+	// passed to the hook is the reason, or nil if osquery requested the
+	// shutdown.
+	SupervisorStopped
+	// SupervisorWaiting is reported when the osquery socket is
+	// unavailable and RunForever is waiting for it to come back. The
+	// error passed to the hook is the last connection error.
+	SupervisorWaiting
+	// SupervisorReconnected is reported once a new connection to the
+	// osquery socket has been established.
+	SupervisorReconnected
+)
+
+// String implements the fmt.Stringer interface for SupervisorEvent.
+func (e SupervisorEvent) String() string {
+	switch e {
+	case SupervisorStarting:
+		return "starting"
+	case SupervisorStopped:
+		return "stopped"
+	case SupervisorWaiting:
+		return "waiting"
+	case SupervisorReconnected:
+		return "reconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// SupervisorHook is called by RunForever on each transition, for example to
+// log it.
+type SupervisorHook func(event SupervisorEvent, err error)
+
+// ServerSupervisorHook sets a hook that is called for every transition made
+// by RunForever.
+func ServerSupervisorHook(hook SupervisorHook) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.supervisorHook = hook
+	}
+}
+
+// RunForever runs the extension like Run, but keeps it alive across osquery
+// restarts. When the server stops (because osquery stopped responding to
+// pings or requested a shutdown), RunForever waits for the osquery socket
+// to come back, registers all plugins again and resumes serving. It only
+// returns once ctx is done, after shutting the server down.
+func (s *ExtensionManagerServer) RunForever(ctx context.Context) error {
+	for {
+		s.supervisorEvent(SupervisorStarting, nil)
+		err := s.run(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		s.supervisorEvent(SupervisorStopped, err)
+
+		if err := s.reconnect(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// reconnect waits until osquery can be reached again. If the server owns its
+// client, a new client is connected to the socket. A client supplied with
+// WithClient cannot be recreated, so it is pinged until it responds.
+func (s *ExtensionManagerServer) reconnect(ctx context.Context) error {
+	s.mutex.Lock()
+	ownsClient := s.serverClientShouldShutdown
+	serverClient := s.serverClient
+	s.mutex.Unlock()
+
+	waiting := false
+	for {
+		// Always pause before (re)connecting, so that a server that
+		// fails to start right after connecting does not spin.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.pingIntervalOrDefault()):
+		}
+
+		var err error
+		if ownsClient {
+			var client *ExtensionManagerClient
+			client, err = NewClient(s.sockPath, s.timeout, ClientFraming(s.framing))
+			if err == nil {
+				s.mutex.Lock()
+				s.serverClient = client
+				s.mutex.Unlock()
+				s.supervisorEvent(SupervisorReconnected, nil)
+				return nil
+			}
+		} else {
+			if serverClient == nil {
+				return errors.New("no client to reconnect with")
+			}
+			var status *osquery.ExtensionStatus
+			status, err = serverClient.Ping()
+			if err == nil && status.Code != 0 {
+				err = errors.Errorf("ping returned status %d", status.Code)
+			}
+			if err == nil {
+				s.supervisorEvent(SupervisorReconnected, nil)
+				return nil
+			}
+		}
+
+		if !waiting {
+			s.supervisorEvent(SupervisorWaiting, err)
+			waiting = true
+		}
+	}
+}
+
+func (s *ExtensionManagerServer) supervisorEvent(event SupervisorEvent, err error) {
+	if err != nil {
+		logAt(slog.LevelDebug, "supervisor "+event.String(), "extension", s.name, "err", err)
+	} else {
+		logAt(slog.LevelDebug, "supervisor "+event.String(), "extension", s.name)
+	}
+	if event == SupervisorReconnected {
+		s.mutex.Lock()
+		s.reconnects++
+		s.mutex.Unlock()
+	}
+	if s.supervisorHook != nil {
+		s.supervisorHook(event, err)
+	}
+}
@@ -0,0 +1,110 @@
+package osquery
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunForeverReregisters(t *testing.T) {
+	tempPath, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mut sync.Mutex
+	registrations := 0
+	pings := 0
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			mut.Lock()
+			defer mut.Unlock()
+			registrations++
+			if registrations == 2 {
+				cancel()
+			}
+			return &osquery.ExtensionStatus{UUID: osquery.ExtensionRouteUUID(registrations)}, nil
+		},
+		PingFunc: func() (*osquery.ExtensionStatus, error) {
+			mut.Lock()
+			defer mut.Unlock()
+			pings++
+			// The first ping fails as if osquery went away, the next
+			// one fails while osquery restarts.
+			if pings <= 2 {
+				return nil, syscall.EPIPE
+			}
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() {},
+	}
+
+	var events []SupervisorEvent
+	server := &ExtensionManagerServer{
+		serverClient: mock,
+		sockPath:     tempPath.Name(),
+		pingInterval: 10 * time.Millisecond,
+		supervisorHook: func(event SupervisorEvent, err error) {
+			events = append(events, event)
+		},
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- server.RunForever(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunForever did not return")
+	}
+
+	mut.Lock()
+	defer mut.Unlock()
+	assert.Equal(t, 2, registrations)
+	assert.Equal(t, []SupervisorEvent{
+		SupervisorStarting,
+		SupervisorStopped,
+		SupervisorWaiting,
+		SupervisorReconnected,
+		SupervisorStarting,
+	}, events)
+}
+
+func TestReconnectDefaultInterval(t *testing.T) {
+	var mut sync.Mutex
+	pings := 0
+	server := &ExtensionManagerServer{
+		serverClient: &MockExtensionManager{
+			PingFunc: func() (*osquery.ExtensionStatus, error) {
+				mut.Lock()
+				defer mut.Unlock()
+				pings++
+				return nil, syscall.EPIPE
+			},
+		},
+	}
+
+	// Without a ping interval, reconnect waits for the default interval
+	// instead of spinning.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, server.reconnect(ctx))
+
+	mut.Lock()
+	defer mut.Unlock()
+	assert.Equal(t, 0, pings)
+}
@@ -0,0 +1,18 @@
+// Package tables reads well-known osquery core tables into typed structs.
+//
+// The accessors run SELECT * on the table with QueryRowsContext, filtered by
+// the WHERE clause built from their options, and parse the rows:
+//
+//	client, err := osquery.NewClient("", 5*time.Second)
+//	...
+//	procs, err := tables.Processes(ctx, client, tables.ProcessesOptions{Names: []string{"osqueryd"}})
+//	for _, p := range procs {
+//		fmt.Println(p.PID, p.Path)
+//	}
+//
+// Empty options don't filter. Columns of other platforms are left to their
+// zero value. The row structs and the Query functions, which take a raw
+// WHERE clause, are generated by cmd/schemagen from schema.json.
+package tables
+
+//go:generate go run ../cmd/schemagen -schema schema.json -tables processes,users,listening_ports -package tables -out generated.go
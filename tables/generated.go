@@ -0,0 +1,342 @@
+// Code generated by schemagen. DO NOT EDIT.
+
+package tables
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Querier runs SQL queries on osquery. It is implemented by
+// *osquery.ExtensionManagerClient.
+type Querier interface {
+	QueryRowsContext(ctx context.Context, sql string) ([]map[string]string, error)
+}
+
+// selectQuery returns the query selecting all the columns of the table,
+// filtered by the WHERE clause where if not empty.
+func selectQuery(table, where string) string {
+	if where == "" {
+		return "SELECT * FROM " + table
+	}
+	return "SELECT * FROM " + table + " WHERE " + where
+}
+
+// ProcessesRow is a row of the processes table.
+//
+// All running processes on the host system.
+type ProcessesRow struct {
+	// Process (or thread) ID
+	PID int64 `column:"pid"`
+	// The process path or shorthand argv[0]
+	Name string `column:"name"`
+	// Path to executed binary
+	Path string `column:"path"`
+	// Complete argv
+	Cmdline string `column:"cmdline"`
+	// Process state
+	State string `column:"state"`
+	// Unsigned user ID
+	UID int64 `column:"uid"`
+	// Unsigned group ID
+	GID int64 `column:"gid"`
+	// Process parent's PID
+	Parent int64 `column:"parent"`
+	// Bytes of private memory used by process
+	ResidentSize int64 `column:"resident_size"`
+	// CPU time in milliseconds spent in user space
+	UserTime int64 `column:"user_time"`
+	// Process start time in seconds since Epoch, in case of error -1
+	StartTime int64 `column:"start_time"`
+	// Number of threads used by process
+	Threads int32 `column:"threads"`
+	// Indicates the specific processor designed for installation.
+	CPUType int32 `column:"cpu_type"`
+	// Process uses elevated token yes=1, no=0
+	ElevatedToken int32 `column:"elevated_token"`
+}
+
+// ParseProcessesRow converts a row of the processes table returned by
+// osquery. Missing columns, such as the columns of other platforms, and empty
+// values are left to the zero value.
+func ParseProcessesRow(row map[string]string) (ProcessesRow, error) {
+	var r ProcessesRow
+	if v := row["pid"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("processes column pid: %w", err)
+		}
+		r.PID = n
+	}
+	r.Name = row["name"]
+	r.Path = row["path"]
+	r.Cmdline = row["cmdline"]
+	r.State = row["state"]
+	if v := row["uid"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("processes column uid: %w", err)
+		}
+		r.UID = n
+	}
+	if v := row["gid"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("processes column gid: %w", err)
+		}
+		r.GID = n
+	}
+	if v := row["parent"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("processes column parent: %w", err)
+		}
+		r.Parent = n
+	}
+	if v := row["resident_size"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("processes column resident_size: %w", err)
+		}
+		r.ResidentSize = n
+	}
+	if v := row["user_time"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("processes column user_time: %w", err)
+		}
+		r.UserTime = n
+	}
+	if v := row["start_time"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("processes column start_time: %w", err)
+		}
+		r.StartTime = n
+	}
+	if v := row["threads"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return r, fmt.Errorf("processes column threads: %w", err)
+		}
+		r.Threads = int32(n)
+	}
+	if v := row["cpu_type"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return r, fmt.Errorf("processes column cpu_type: %w", err)
+		}
+		r.CPUType = int32(n)
+	}
+	if v := row["elevated_token"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return r, fmt.Errorf("processes column elevated_token: %w", err)
+		}
+		r.ElevatedToken = int32(n)
+	}
+	return r, nil
+}
+
+// QueryProcesses runs SELECT * FROM processes with the WHERE clause where,
+// unless empty, and returns the parsed rows. The clause is sent as is, so
+// values in it must be quoted and escaped by the caller.
+func QueryProcesses(ctx context.Context, q Querier, where string) ([]ProcessesRow, error) {
+	rows, err := q.QueryRowsContext(ctx, selectQuery("processes", where))
+	if err != nil {
+		return nil, fmt.Errorf("querying processes: %w", err)
+	}
+	results := make([]ProcessesRow, 0, len(rows))
+	for _, row := range rows {
+		r, err := ParseProcessesRow(row)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// UsersRow is a row of the users table.
+//
+// Local user accounts (including domain accounts that have logged on locally (Windows)).
+type UsersRow struct {
+	// User ID
+	UID int64 `column:"uid"`
+	// Group ID (unsigned)
+	GID int64 `column:"gid"`
+	// User ID as int64 signed (Apple)
+	UIDSigned int64 `column:"uid_signed"`
+	// Default group ID as int64 signed (Apple)
+	GIDSigned int64 `column:"gid_signed"`
+	// Username
+	Username string `column:"username"`
+	// Optional user description
+	Description string `column:"description"`
+	// User's home directory
+	Directory string `column:"directory"`
+	// User's configured default shell
+	Shell string `column:"shell"`
+	// User's UUID (Apple) or SID (Windows)
+	UUID string `column:"uuid"`
+	// Whether the account is roaming (domain), local, or a system profile
+	Type string `column:"type"`
+}
+
+// ParseUsersRow converts a row of the users table returned by
+// osquery. Missing columns, such as the columns of other platforms, and empty
+// values are left to the zero value.
+func ParseUsersRow(row map[string]string) (UsersRow, error) {
+	var r UsersRow
+	if v := row["uid"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("users column uid: %w", err)
+		}
+		r.UID = n
+	}
+	if v := row["gid"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("users column gid: %w", err)
+		}
+		r.GID = n
+	}
+	if v := row["uid_signed"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("users column uid_signed: %w", err)
+		}
+		r.UIDSigned = n
+	}
+	if v := row["gid_signed"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("users column gid_signed: %w", err)
+		}
+		r.GIDSigned = n
+	}
+	r.Username = row["username"]
+	r.Description = row["description"]
+	r.Directory = row["directory"]
+	r.Shell = row["shell"]
+	r.UUID = row["uuid"]
+	r.Type = row["type"]
+	return r, nil
+}
+
+// QueryUsers runs SELECT * FROM users with the WHERE clause where,
+// unless empty, and returns the parsed rows. The clause is sent as is, so
+// values in it must be quoted and escaped by the caller.
+func QueryUsers(ctx context.Context, q Querier, where string) ([]UsersRow, error) {
+	rows, err := q.QueryRowsContext(ctx, selectQuery("users", where))
+	if err != nil {
+		return nil, fmt.Errorf("querying users: %w", err)
+	}
+	results := make([]UsersRow, 0, len(rows))
+	for _, row := range rows {
+		r, err := ParseUsersRow(row)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// ListeningPortsRow is a row of the listening_ports table.
+//
+// Processes with listening (bound) network sockets/ports.
+type ListeningPortsRow struct {
+	// Process (or thread) ID
+	PID int32 `column:"pid"`
+	// Transport layer port
+	Port int32 `column:"port"`
+	// Transport protocol (TCP/UDP)
+	Protocol int32 `column:"protocol"`
+	// Network protocol (IPv4, IPv6)
+	Family int32 `column:"family"`
+	// Specific address for bind
+	Address string `column:"address"`
+	// Socket file descriptor number
+	Fd int64 `column:"fd"`
+	// Socket handle or inode number
+	Socket int64 `column:"socket"`
+	// Path for UNIX domain sockets
+	Path string `column:"path"`
+	// The inode number of the network namespace
+	NetNamespace string `column:"net_namespace"`
+}
+
+// ParseListeningPortsRow converts a row of the listening_ports table returned by
+// osquery. Missing columns, such as the columns of other platforms, and empty
+// values are left to the zero value.
+func ParseListeningPortsRow(row map[string]string) (ListeningPortsRow, error) {
+	var r ListeningPortsRow
+	if v := row["pid"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return r, fmt.Errorf("listening_ports column pid: %w", err)
+		}
+		r.PID = int32(n)
+	}
+	if v := row["port"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return r, fmt.Errorf("listening_ports column port: %w", err)
+		}
+		r.Port = int32(n)
+	}
+	if v := row["protocol"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return r, fmt.Errorf("listening_ports column protocol: %w", err)
+		}
+		r.Protocol = int32(n)
+	}
+	if v := row["family"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return r, fmt.Errorf("listening_ports column family: %w", err)
+		}
+		r.Family = int32(n)
+	}
+	r.Address = row["address"]
+	if v := row["fd"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("listening_ports column fd: %w", err)
+		}
+		r.Fd = n
+	}
+	if v := row["socket"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("listening_ports column socket: %w", err)
+		}
+		r.Socket = n
+	}
+	r.Path = row["path"]
+	r.NetNamespace = row["net_namespace"]
+	return r, nil
+}
+
+// QueryListeningPorts runs SELECT * FROM listening_ports with the WHERE clause where,
+// unless empty, and returns the parsed rows. The clause is sent as is, so
+// values in it must be quoted and escaped by the caller.
+func QueryListeningPorts(ctx context.Context, q Querier, where string) ([]ListeningPortsRow, error) {
+	rows, err := q.QueryRowsContext(ctx, selectQuery("listening_ports", where))
+	if err != nil {
+		return nil, fmt.Errorf("querying listening_ports: %w", err)
+	}
+	results := make([]ListeningPortsRow, 0, len(rows))
+	for _, row := range rows {
+		r, err := ParseListeningPortsRow(row)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
@@ -0,0 +1,104 @@
+package tables
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Protocols of the listening_ports table, as IANA protocol numbers.
+const (
+	ProtocolTCP int32 = 6
+	ProtocolUDP int32 = 17
+)
+
+// ProcessesOptions filters the rows returned by Processes. Each non-empty
+// field restricts the rows to the listed values of its column.
+type ProcessesOptions struct {
+	PIDs    []int64
+	Names   []string
+	Parents []int64
+	UIDs    []int64
+}
+
+// Processes returns the running processes matching the options.
+func Processes(ctx context.Context, q Querier, opts ProcessesOptions) ([]ProcessesRow, error) {
+	var w where
+	inInts(&w, "pid", opts.PIDs)
+	inStrings(&w, "name", opts.Names)
+	inInts(&w, "parent", opts.Parents)
+	inInts(&w, "uid", opts.UIDs)
+	return QueryProcesses(ctx, q, w.String())
+}
+
+// UsersOptions filters the rows returned by Users. Each non-empty field
+// restricts the rows to the listed values of its column.
+type UsersOptions struct {
+	UIDs      []int64
+	Usernames []string
+}
+
+// Users returns the local user accounts matching the options.
+func Users(ctx context.Context, q Querier, opts UsersOptions) ([]UsersRow, error) {
+	var w where
+	inInts(&w, "uid", opts.UIDs)
+	inStrings(&w, "username", opts.Usernames)
+	return QueryUsers(ctx, q, w.String())
+}
+
+// ListeningPortsOptions filters the rows returned by ListeningPorts. Each
+// non-empty field restricts the rows to the listed values of its column.
+type ListeningPortsOptions struct {
+	PIDs      []int64
+	Ports     []int32
+	Protocols []int32
+}
+
+// ListeningPorts returns the listening sockets matching the options.
+func ListeningPorts(ctx context.Context, q Querier, opts ListeningPortsOptions) ([]ListeningPortsRow, error) {
+	var w where
+	inInts(&w, "pid", opts.PIDs)
+	inInts(&w, "port", opts.Ports)
+	inInts(&w, "protocol", opts.Protocols)
+	return QueryListeningPorts(ctx, q, w.String())
+}
+
+// where builds a WHERE clause from conditions joined with AND.
+type where []string
+
+func (w where) String() string {
+	return strings.Join(w, " AND ")
+}
+
+// in adds the condition that column is one of the SQL literals, unless there
+// are none.
+func (w *where) in(column string, literals []string) {
+	switch len(literals) {
+	case 0:
+	case 1:
+		*w = append(*w, column+" = "+literals[0])
+	default:
+		*w = append(*w, column+" IN ("+strings.Join(literals, ", ")+")")
+	}
+}
+
+func inInts[T int32 | int64](w *where, column string, values []T) {
+	literals := make([]string, len(values))
+	for i, v := range values {
+		literals[i] = strconv.FormatInt(int64(v), 10)
+	}
+	w.in(column, literals)
+}
+
+func inStrings(w *where, column string, values []string) {
+	literals := make([]string, len(values))
+	for i, v := range values {
+		literals[i] = quote(v)
+	}
+	w.in(column, literals)
+}
+
+// quote returns s as an SQL string literal.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,71 @@
+package tables
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockQuerier struct {
+	sql  string
+	rows []map[string]string
+	err  error
+}
+
+func (m *mockQuerier) QueryRowsContext(ctx context.Context, sql string) ([]map[string]string, error) {
+	m.sql = sql
+	return m.rows, m.err
+}
+
+func TestProcesses(t *testing.T) {
+	q := &mockQuerier{rows: []map[string]string{
+		{"pid": "1", "name": "init", "parent": "0", "threads": "1", "cpu_type": ""},
+		// Columns of other platforms are missing
+		{"pid": "42", "name": "osqueryd", "path": "/opt/osquery/bin/osqueryd", "start_time": "-1"},
+	}}
+
+	procs, err := Processes(context.Background(), q, ProcessesOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM processes", q.sql)
+	assert.Equal(t, []ProcessesRow{
+		{PID: 1, Name: "init", Threads: 1},
+		{PID: 42, Name: "osqueryd", Path: "/opt/osquery/bin/osqueryd", StartTime: -1},
+	}, procs)
+
+	_, err = Processes(context.Background(), q, ProcessesOptions{PIDs: []int64{1, 42}, Names: []string{"o'brien"}})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM processes WHERE pid IN (1, 42) AND name = 'o''brien'", q.sql)
+}
+
+func TestUsers(t *testing.T) {
+	q := &mockQuerier{rows: []map[string]string{
+		{"uid": "0", "gid": "0", "username": "root", "directory": "/root", "shell": "/bin/bash"},
+	}}
+	users, err := Users(context.Background(), q, UsersOptions{Usernames: []string{"root"}})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users WHERE username = 'root'", q.sql)
+	assert.Equal(t, []UsersRow{{Username: "root", Directory: "/root", Shell: "/bin/bash"}}, users)
+}
+
+func TestListeningPorts(t *testing.T) {
+	q := &mockQuerier{rows: []map[string]string{
+		{"pid": "42", "port": "22", "protocol": "6", "family": "2", "address": "0.0.0.0", "socket": "1234"},
+	}}
+	ports, err := ListeningPorts(context.Background(), q, ListeningPortsOptions{Ports: []int32{22}, Protocols: []int32{ProtocolTCP}})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM listening_ports WHERE port = 22 AND protocol = 6", q.sql)
+	assert.Equal(t, []ListeningPortsRow{{PID: 42, Port: 22, Protocol: ProtocolTCP, Family: 2, Address: "0.0.0.0", Socket: 1234}}, ports)
+}
+
+func TestErrors(t *testing.T) {
+	q := &mockQuerier{err: errors.New("boom")}
+	_, err := Processes(context.Background(), q, ProcessesOptions{})
+	assert.EqualError(t, err, "querying processes: boom")
+
+	q = &mockQuerier{rows: []map[string]string{{"pid": "not a number"}}}
+	_, err = Processes(context.Background(), q, ProcessesOptions{})
+	assert.ErrorContains(t, err, "processes column pid")
+}
@@ -0,0 +1,81 @@
+package traces
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the W3C trace context carried in plugin requests. The client
+// adds them to the requests of CallContext, and the server removes them
+// before calling the plugin, starting its span as a child of the remote
+// span. As osqueryd forwards plugin requests unchanged, a call issued by a
+// Go client through osqueryd into a Go extension forms one trace.
+// Responses don't carry the trace context, as their maps are the rows of
+// the plugins.
+const (
+	TraceParentKey = "_traceparent"
+	TraceStateKey  = "_tracestate"
+)
+
+// requestKeyPrefix is prepended to the W3C header names to build the
+// request keys.
+const requestKeyPrefix = "_"
+
+var traceContext = propagation.TraceContext{}
+
+// requestCarrier adapts plugin requests to the W3C trace context
+// propagator.
+type requestCarrier map[string]string
+
+func (c requestCarrier) Get(key string) string {
+	return c[requestKeyPrefix+key]
+}
+
+func (c requestCarrier) Set(key, value string) {
+	c[requestKeyPrefix+key] = value
+}
+
+func (c requestCarrier) Keys() []string {
+	var keys []string
+	for key := range c {
+		if strings.HasPrefix(key, requestKeyPrefix) {
+			keys = append(keys, strings.TrimPrefix(key, requestKeyPrefix))
+		}
+	}
+	return keys
+}
+
+// InjectRequest returns a copy of the request carrying the trace context of
+// ctx. The request is returned unchanged if ctx has no span context.
+func InjectRequest(ctx context.Context, request map[string]string) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return request
+	}
+	injected := make(map[string]string, len(request)+2)
+	for key, value := range request {
+		injected[key] = value
+	}
+	traceContext.Inject(ctx, requestCarrier(injected))
+	return injected
+}
+
+// ExtractRequest returns ctx with the remote span context carried by the
+// request, if any, and a copy of the request without the trace context.
+func ExtractRequest(ctx context.Context, request map[string]string) (context.Context, map[string]string) {
+	_, hasParent := request[TraceParentKey]
+	_, hasState := request[TraceStateKey]
+	if !hasParent && !hasState {
+		return ctx, request
+	}
+	ctx = traceContext.Extract(ctx, requestCarrier(request))
+	extracted := make(map[string]string, len(request))
+	for key, value := range request {
+		if key != TraceParentKey && key != TraceStateKey {
+			extracted[key] = value
+		}
+	}
+	return ctx, extracted
+}
@@ -0,0 +1,39 @@
+package traces
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestPropagation(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	request := map[string]string{"action": "generate"}
+	injected := InjectRequest(ctx, request)
+	assert.Equal(t, map[string]string{"action": "generate"}, request)
+	assert.Equal(t, "00-01020300000000000000000000000000-0405060000000000-01", injected[TraceParentKey])
+	assert.Equal(t, "generate", injected["action"])
+
+	extractedCtx, extracted := ExtractRequest(context.Background(), injected)
+	assert.Equal(t, request, extracted)
+	remote := trace.SpanContextFromContext(extractedCtx)
+	require.True(t, remote.IsValid())
+	assert.True(t, remote.IsRemote())
+	assert.Equal(t, sc.TraceID(), remote.TraceID())
+	assert.Equal(t, sc.SpanID(), remote.SpanID())
+
+	// Without trace context
+	assert.Equal(t, request, InjectRequest(context.Background(), request))
+	extractedCtx, extracted = ExtractRequest(context.Background(), request)
+	assert.Equal(t, request, extracted)
+	assert.False(t, trace.SpanContextFromContext(extractedCtx).IsValid())
+}
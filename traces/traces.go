@@ -4,25 +4,40 @@ package traces
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime/debug"
+	"sync/atomic"
+	"time"
 
+	"github.com/osquery/osquery-go/gen/osquery"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
 const instrumentationPkg = "github.com/osquery/osquery-go"
 
 var (
-	internalVersion string // provides the instrumentation version for attribute `otel.scope.version`
-	tracerProvider  trace.TracerProvider
+	internalVersion string       // provides the instrumentation version for attribute `otel.scope.version`
+	tracerProvider  atomic.Value // holds a providerHolder
+	disabled        atomic.Bool
 )
 
+// providerHolder holds a tracer provider, as atomic.Value requires values
+// of the same concrete type.
+type providerHolder struct {
+	tp trace.TracerProvider
+}
+
+// noopTracerProvider is used while tracing is disabled.
+var noopTracerProvider = trace.NewNoopTracerProvider()
+
 // init sets `internalVersion` and a default tracer provider.
 func init() {
 	// By default, use the global tracer provider, which is a no-op provider.
-	tracerProvider = otel.GetTracerProvider()
+	tracerProvider.Store(providerHolder{otel.GetTracerProvider()})
 
 	// Look through build info to determine the current version of the osquery-go package.
 	if info, ok := debug.ReadBuildInfo(); ok {
@@ -43,16 +58,38 @@ func init() {
 }
 
 // SetTracerProvider allows consuming libraries to set a custom/non-global tracer provider.
+// It is safe to call at any time, and spans started afterwards use the new provider.
+// A nil provider turns tracing off until another provider is set.
 func SetTracerProvider(tp trace.TracerProvider) {
-	tracerProvider = tp
+	if tp == nil {
+		tp = noopTracerProvider
+	}
+	tracerProvider.Store(providerHolder{tp})
+}
+
+// SetEnabled turns tracing on or off at runtime, keeping the tracer provider,
+// so that long-running extensions can trace in detail temporarily, during an
+// investigation, without a restart. Tracing is enabled by default.
+func SetEnabled(enabled bool) {
+	disabled.Store(!enabled)
+}
+
+// Enabled returns true unless tracing was turned off with SetEnabled.
+func Enabled() bool {
+	return !disabled.Load()
 }
 
 // OsqueryGoTracer provides a tracer with a standardized name and version.
 // It should be used to start a span that requires `SpanStartOption`s that are
 // not supported by `StartSpan` below -- i.e., any `SpanStartOption` besides
 // `WithAttributes`.
+// While tracing is disabled, it returns a no-op tracer.
 func OsqueryGoTracer() trace.Tracer {
-	return tracerProvider.Tracer(instrumentationPkg, trace.WithInstrumentationVersion(internalVersion))
+	tp := tracerProvider.Load().(providerHolder).tp
+	if disabled.Load() {
+		tp = noopTracerProvider
+	}
+	return tp.Tracer(instrumentationPkg, trace.WithInstrumentationVersion(internalVersion))
 }
 
 // StartSpan is a wrapper around trace.Tracer.Start that simplifies passing in span attributes.
@@ -60,16 +97,72 @@ func OsqueryGoTracer() trace.Tracer {
 // the attribute key and the second in the pair is the attribute value.
 // The caller is always responsible for ending the returned span.
 // Any spans requiring more specific configuration can be created manually via OsqueryGoTracer().Start.
+// Use StartSpanWithAttributes for attributes that are not strings.
 func StartSpan(ctx context.Context, spanName string, keyVals ...string) (context.Context, trace.Span) {
-	attrs := make([]attribute.KeyValue, 0)
+	attrs := make([]attribute.KeyValue, 0, len(keyVals)/2)
 
 	for i := 0; i < len(keyVals); i += 2 {
+		attrs = append(attrs, attribute.String(keyVals[i], keyVals[i+1]))
+	}
+
+	return StartSpanWithAttributes(ctx, spanName, attrs...)
+}
+
+// StartSpanWithAttributes is like StartSpan, with typed attributes such as those
+// returned by String, Int, Bool and Duration, or by the attribute package.
+// The attribute keys are namespaced like those passed to StartSpan.
+func StartSpanWithAttributes(ctx context.Context, spanName string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	namespaced := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
 		// Ensure all attributes are appropriately namespaced
-		key := fmt.Sprintf("osquery-go.%s", keyVals[i])
-		attrs = append(attrs, attribute.String(key, keyVals[i+1]))
+		namespaced[i] = attribute.KeyValue{
+			Key:   attribute.Key(fmt.Sprintf("osquery-go.%s", attr.Key)),
+			Value: attr.Value,
+		}
 	}
 
-	opts := []trace.SpanStartOption{trace.WithAttributes(attrs...)}
+	opts := []trace.SpanStartOption{trace.WithAttributes(namespaced...)}
 
 	return OsqueryGoTracer().Start(ctx, spanName, opts...)
 }
+
+// String returns a string attribute for StartSpanWithAttributes.
+func String(key, value string) attribute.KeyValue {
+	return attribute.String(key, value)
+}
+
+// Int returns an integer attribute for StartSpanWithAttributes.
+func Int(key string, value int) attribute.KeyValue {
+	return attribute.Int(key, value)
+}
+
+// Bool returns a boolean attribute for StartSpanWithAttributes.
+func Bool(key string, value bool) attribute.KeyValue {
+	return attribute.Bool(key, value)
+}
+
+// Duration returns an integer attribute of the duration in milliseconds for
+// StartSpanWithAttributes.
+func Duration(key string, value time.Duration) attribute.KeyValue {
+	return attribute.Int64(key, value.Milliseconds())
+}
+
+// RecordError records the error on the span and marks the span as failed.
+// It does nothing if err is nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// RecordStatus marks the span as failed if the status of a plugin response
+// is not OK, recording its code and message.
+func RecordStatus(span trace.Span, status *osquery.ExtensionStatus) {
+	if status == nil || status.Code == 0 {
+		return
+	}
+	span.SetAttributes(attribute.Int("osquery-go.status_code", int(status.Code)))
+	RecordError(span, errors.New(status.Message))
+}
@@ -4,8 +4,14 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/osquery/osquery-go/gen/osquery"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestTraceInit(t *testing.T) {
@@ -25,3 +31,127 @@ func TestTraceInit(t *testing.T) {
 	wg.Wait()
 	assert.NotEmpty(t, internalVersion, "internal version should have been set")
 }
+
+// recordingTracerProvider records the attributes of the spans started.
+type recordingTracerProvider struct {
+	mutex sync.Mutex
+	spans map[string][]attribute.KeyValue
+}
+
+func newRecordingTracerProvider() *recordingTracerProvider {
+	return &recordingTracerProvider{spans: map[string][]attribute.KeyValue{}}
+}
+
+func (p *recordingTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{p}
+}
+
+func (p *recordingTracerProvider) attributes(spanName string) ([]attribute.KeyValue, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	attrs, ok := p.spans[spanName]
+	return attrs, ok
+}
+
+type recordingTracer struct {
+	provider *recordingTracerProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t.provider.mutex.Lock()
+	defer t.provider.mutex.Unlock()
+	config := trace.NewSpanStartConfig(opts...)
+	t.provider.spans[spanName] = config.Attributes()
+	return ctx, trace.SpanFromContext(ctx)
+}
+
+func TestStartSpanWithAttributes(t *testing.T) {
+	tp := newRecordingTracerProvider()
+	SetTracerProvider(tp)
+	defer SetTracerProvider(otel.GetTracerProvider())
+
+	_, span := StartSpan(context.Background(), "Strings", "registry", "table", "item", "processes")
+	span.End()
+	attrs, _ := tp.attributes("Strings")
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("osquery-go.registry", "table"),
+		attribute.String("osquery-go.item", "processes"),
+	}, attrs)
+
+	_, span = StartSpanWithAttributes(context.Background(), "Typed",
+		String("action", "generate"),
+		Int("rows", 3),
+		Bool("cached", true),
+		Duration("wait", 1500*time.Millisecond),
+		attribute.Float64("ratio", 0.5),
+	)
+	span.End()
+	attrs, _ = tp.attributes("Typed")
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("osquery-go.action", "generate"),
+		attribute.Int("osquery-go.rows", 3),
+		attribute.Bool("osquery-go.cached", true),
+		attribute.Int64("osquery-go.wait", 1500),
+		attribute.Float64("osquery-go.ratio", 0.5),
+	}, attrs)
+}
+
+func TestSetEnabled(t *testing.T) {
+	tp := newRecordingTracerProvider()
+	SetTracerProvider(tp)
+	defer SetTracerProvider(otel.GetTracerProvider())
+
+	assert.True(t, Enabled())
+	SetEnabled(false)
+	assert.False(t, Enabled())
+	_, span := StartSpan(context.Background(), "Disabled")
+	span.End()
+	_, ok := tp.attributes("Disabled")
+	assert.False(t, ok)
+
+	SetEnabled(true)
+	_, span = StartSpan(context.Background(), "Enabled")
+	span.End()
+	_, ok = tp.attributes("Enabled")
+	assert.True(t, ok)
+
+	// A nil provider turns tracing off
+	SetTracerProvider(nil)
+	_, span = StartSpan(context.Background(), "Nil")
+	span.End()
+	_, ok = tp.attributes("Nil")
+	assert.False(t, ok)
+	assert.False(t, span.IsRecording())
+}
+
+// statusSpan records the status set on it.
+type statusSpan struct {
+	trace.Span
+	code        codes.Code
+	description string
+	errs        []error
+}
+
+func (s *statusSpan) SetStatus(code codes.Code, description string) {
+	s.code, s.description = code, description
+}
+
+func (s *statusSpan) RecordError(err error, opts ...trace.EventOption) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *statusSpan) SetAttributes(kv ...attribute.KeyValue) {}
+
+func TestRecordStatus(t *testing.T) {
+	span := &statusSpan{}
+	RecordStatus(span, &osquery.ExtensionStatus{Code: 0, Message: "OK"})
+	RecordStatus(span, nil)
+	RecordError(span, nil)
+	assert.Equal(t, codes.Unset, span.code)
+	assert.Empty(t, span.errs)
+
+	RecordStatus(span, &osquery.ExtensionStatus{Code: 1, Message: "error generating table: boom"})
+	assert.Equal(t, codes.Error, span.code)
+	assert.Equal(t, "error generating table: boom", span.description)
+	assert.Len(t, span.errs, 1)
+}
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// Framing selects how Thrift messages are framed and encoded on top of the
+// underlying socket or named pipe. osquery itself speaks unframed binary
+// messages, which is the default. The other modes exist for interoperability
+// with osquery builds or proxies that require framed messages.
+type Framing int
+
+const (
+	// FramingNone sends unframed TBinaryProtocol messages. This is what
+	// osquery uses and is the default.
+	FramingNone Framing = iota
+	// FramingFramed wraps the transport in a TFramedTransport and sends
+	// TBinaryProtocol messages.
+	FramingFramed
+	// FramingHeader uses THeaderProtocol over a THeaderTransport.
+	FramingHeader
+)
+
+// String implements the fmt.Stringer interface for Framing.
+func (f Framing) String() string {
+	switch f {
+	case FramingNone:
+		return "none"
+	case FramingFramed:
+		return "framed"
+	case FramingHeader:
+		return "header"
+	default:
+		return "unknown"
+	}
+}
+
+// TransportFactory returns the thrift.TTransportFactory used to wrap
+// accepted server connections for this framing.
+func (f Framing) TransportFactory() thrift.TTransportFactory {
+	switch f {
+	case FramingFramed:
+		return thrift.NewTFramedTransportFactoryConf(thrift.NewTTransportFactory(), nil)
+	default:
+		// THeaderProtocol wraps the transport itself, so nothing is
+		// required for FramingHeader here.
+		return thrift.NewTTransportFactory()
+	}
+}
+
+// ProtocolFactory returns the thrift.TProtocolFactory for this framing.
+func (f Framing) ProtocolFactory() thrift.TProtocolFactory {
+	switch f {
+	case FramingHeader:
+		return thrift.NewTHeaderProtocolFactoryConf(nil)
+	default:
+		return thrift.NewTBinaryProtocolFactoryDefault()
+	}
+}
+
+// Wrap wraps a client transport (as returned by Open) according to the
+// framing. The returned transport should be used with ProtocolFactory.
+func (f Framing) Wrap(trans thrift.TTransport) thrift.TTransport {
+	switch f {
+	case FramingFramed:
+		return thrift.NewTFramedTransportConf(trans, nil)
+	default:
+		return trans
+	}
+}
@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PeerCredentials identifies the process on the other end of a socket
+// connection. Named pipes do not expose a UID, so UID is -1 on Windows.
+type PeerCredentials struct {
+	PID int
+	UID int
+}
+
+// PeerVerifier is called for each connection accepted on the extension's
+// listen socket. Returning an error causes the connection to be closed
+// before any request is read from it.
+type PeerVerifier func(peer PeerCredentials) error
+
+// ErrPeerCredentialsUnsupported is returned when peer credentials cannot be
+// read on the current platform.
+var ErrPeerCredentialsUnsupported = errors.New("peer credentials are not supported on this platform")
+
+// AllowPeer returns a PeerVerifier that only accepts connections from a
+// process with the given UID and PID. A negative value matches any UID or
+// PID respectively.
+func AllowPeer(uid, pid int) PeerVerifier {
+	return func(peer PeerCredentials) error {
+		if uid >= 0 && peer.UID != uid {
+			return errors.Errorf("peer uid %d does not match %d", peer.UID, uid)
+		}
+		if pid >= 0 && peer.PID != pid {
+			return errors.Errorf("peer pid %d does not match %d", peer.PID, pid)
+		}
+		return nil
+	}
+}
+
+// VerifiedServerOption configures OpenVerifiedServer.
+type VerifiedServerOption func(*verifiedServerOptions)
+
+type verifiedServerOptions struct {
+	onReject       func(err error)
+	restrictAdmins bool
+}
+
+// OnRejectedPeer sets a function called with the reason of each rejected
+// connection, so that peers locked out of the extension can be diagnosed.
+func OnRejectedPeer(fn func(err error)) VerifiedServerOption {
+	return func(o *verifiedServerOptions) {
+		o.onReject = fn
+	}
+}
+
+// RestrictPipeToAdministrators creates the named pipe with a security
+// descriptor that only allows LocalSystem and the built-in Administrators
+// group, which osqueryd runs as, to connect. It has no effect on unix domain
+// sockets.
+func RestrictPipeToAdministrators() VerifiedServerOption {
+	return func(o *verifiedServerOptions) {
+		o.restrictAdmins = true
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package transport
+
+import (
+	"syscall"
+)
+
+func getPeerCredentials(conn syscall.Conn) (PeerCredentials, error) {
+	return PeerCredentials{}, ErrPeerCredentialsUnsupported
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
@@ -37,15 +38,176 @@ func Open(sockPath string, timeout time.Duration) (*thrift.TSocket, error) {
 	return trans, nil
 }
 
+// OpenServer returns a TServerSocket listening on the unix domain socket at
+// listenPath. A stale socket file left behind by a crashed extension is
+// removed first, while a socket that still accepts connections causes an
+// error.
 func OpenServer(listenPath string, timeout time.Duration) (*thrift.TServerSocket, error) {
 	addr, err := net.ResolveUnixAddr("unix", listenPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "resolving addr (%s)", addr)
 	}
 
+	if err := removeStaleSocket(listenPath, timeout); err != nil {
+		return nil, err
+	}
+
 	return thrift.NewTServerSocketFromAddrTimeout(addr, 0), nil
 }
 
+// OpenVerifiedServer behaves like OpenServer, but every accepted connection
+// is checked with verify before it is handed to the thrift server.
+// Connections from peers that fail verification are closed immediately.
+func OpenVerifiedServer(listenPath string, timeout time.Duration, verify PeerVerifier, opts ...VerifiedServerOption) (*TServerVerifiedSocket, error) {
+	if err := removeStaleSocket(listenPath, timeout); err != nil {
+		return nil, err
+	}
+
+	var o verifiedServerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &TServerVerifiedSocket{listenPath: listenPath, verify: verify, onReject: o.onReject}, nil
+}
+
+// SocketPeerCredentials connects to the unix domain socket at sockPath and
+// returns the credentials of the process serving it. Use this to learn the
+// identity of the osquery process serving the extension manager socket.
+func SocketPeerCredentials(sockPath string, timeout time.Duration) (PeerCredentials, error) {
+	conn, err := net.DialTimeout("unix", sockPath, timeout)
+	if err != nil {
+		return PeerCredentials{}, errors.Wrapf(err, "dialing socket (%s)", sockPath)
+	}
+	defer conn.Close()
+
+	return getPeerCredentials(conn.(*net.UnixConn))
+}
+
+// TServerVerifiedSocket is a unix domain socket server transport that
+// verifies the credentials of each connecting peer.
+type TServerVerifiedSocket struct {
+	listenPath string
+	verify     PeerVerifier
+	onReject   func(err error)
+
+	// Protects the listener and interrupted values to make them thread safe.
+	mu          sync.RWMutex
+	listener    *net.UnixListener
+	interrupted bool
+}
+
+func (p *TServerVerifiedSocket) Listen() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.listener != nil {
+		return nil
+	}
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: p.listenPath, Net: "unix"})
+	if err != nil {
+		return thrift.NewTTransportExceptionFromError(err)
+	}
+
+	p.listener = l
+	return nil
+}
+
+// Accept returns the next connection whose peer passes verification.
+func (p *TServerVerifiedSocket) Accept() (thrift.TTransport, error) {
+	for {
+		p.mu.RLock()
+		interrupted := p.interrupted
+		listener := p.listener
+		p.mu.RUnlock()
+
+		if interrupted {
+			return nil, errors.New("transport interrupted")
+		}
+		if listener == nil {
+			return nil, thrift.NewTTransportException(thrift.NOT_OPEN, "no underlying server socket")
+		}
+
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			return nil, thrift.NewTTransportExceptionFromError(err)
+		}
+
+		peer, err := getPeerCredentials(conn)
+		if err != nil {
+			err = errors.Wrap(err, "reading peer credentials")
+		} else {
+			err = p.verify(peer)
+		}
+		if err != nil {
+			if p.onReject != nil {
+				p.onReject(err)
+			}
+			// Drop the connection but keep accepting, an error here
+			// would stop the thrift server.
+			conn.Close()
+			continue
+		}
+
+		return thrift.NewTSocketFromConnTimeout(conn, 0), nil
+	}
+}
+
+func (p *TServerVerifiedSocket) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.listener == nil {
+		return nil
+	}
+	err := p.listener.Close()
+	p.listener = nil
+	return err
+}
+
+func (p *TServerVerifiedSocket) Interrupt() error {
+	p.mu.Lock()
+	p.interrupted = true
+	p.mu.Unlock()
+
+	return p.Close()
+}
+
+// CloseServer removes the socket file at listenPath, if it still exists. It
+// should be called after the server transport has been closed.
+func CloseServer(listenPath string) error {
+	if err := os.Remove(listenPath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "removing socket (%s)", listenPath)
+	}
+	return nil
+}
+
+// removeStaleSocket removes the socket at sockPath if nothing is accepting
+// connections on it.
+func removeStaleSocket(sockPath string, timeout time.Duration) error {
+	info, err := os.Lstat(sockPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "checking for existing socket (%s)", sockPath)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.Errorf("%s exists and is not a socket", sockPath)
+	}
+
+	conn, err := net.DialTimeout("unix", sockPath, timeout)
+	if err == nil {
+		conn.Close()
+		return errors.Errorf("socket %s is in use by another process", sockPath)
+	}
+
+	if err := os.Remove(sockPath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "removing stale socket (%s)", sockPath)
+	}
+	return nil
+}
+
 func waitForSocket(sockPath string, timeout time.Duration) error {
 	ticker := time.NewTicker(200 * time.Millisecond)
 	defer ticker.Stop()
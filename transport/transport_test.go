@@ -0,0 +1,126 @@
+//go:build !windows
+// +build !windows
+
+package transport
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenServerRemovesStaleSocket(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "sock.1")
+
+	// Leave a socket file behind as a crashed extension would.
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: sockPath, Net: "unix"})
+	require.NoError(t, err)
+	l.SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+	_, err = os.Stat(sockPath)
+	require.NoError(t, err)
+
+	server, err := OpenServer(sockPath, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, server.Listen())
+	require.NoError(t, server.Close())
+
+	require.NoError(t, CloseServer(sockPath))
+	_, err = os.Stat(sockPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOpenServerLiveSocket(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "sock.1")
+
+	l, err := net.Listen("unix", sockPath)
+	require.NoError(t, err)
+	defer l.Close()
+
+	_, err = OpenServer(sockPath, 100*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "in use")
+}
+
+func TestOpenServerNotSocket(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "sock.1")
+	require.NoError(t, os.WriteFile(sockPath, []byte("data"), 0o600))
+
+	_, err := OpenServer(sockPath, 100*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a socket")
+}
+
+func TestOpenVerifiedServer(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("peer credentials not supported")
+	}
+
+	for _, tt := range []struct {
+		name    string
+		verify  PeerVerifier
+		allowed bool
+	}{
+		{"allowed", AllowPeer(os.Getuid(), os.Getpid()), true},
+		{"any", AllowPeer(-1, -1), true},
+		{"wrong uid", AllowPeer(os.Getuid()+1, -1), false},
+		{"wrong pid", AllowPeer(-1, os.Getpid()+1), false},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sockPath := filepath.Join(t.TempDir(), "sock.1")
+			rejected := make(chan error, 1)
+			server, err := OpenVerifiedServer(sockPath, 100*time.Millisecond, tt.verify,
+				OnRejectedPeer(func(err error) { rejected <- err }))
+			require.NoError(t, err)
+			require.NoError(t, server.Listen())
+			defer server.Close()
+
+			accepted := make(chan error, 1)
+			go func() {
+				trans, err := server.Accept()
+				if err == nil {
+					trans.Close()
+				}
+				accepted <- err
+			}()
+
+			conn, err := net.Dial("unix", sockPath)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			select {
+			case err := <-accepted:
+				require.True(t, tt.allowed, "connection should have been rejected")
+				require.NoError(t, err)
+			case err := <-rejected:
+				require.False(t, tt.allowed, "connection should have been accepted")
+				assert.Contains(t, err.Error(), "does not match")
+				require.NoError(t, server.Interrupt())
+			case <-time.After(time.Second):
+				t.Fatal("connection neither accepted nor rejected")
+			}
+		})
+	}
+}
+
+func TestSocketPeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("peer credentials not supported")
+	}
+
+	sockPath := filepath.Join(t.TempDir(), "sock")
+	l, err := net.Listen("unix", sockPath)
+	require.NoError(t, err)
+	defer l.Close()
+
+	peer, err := SocketPeerCredentials(sockPath, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, PeerCredentials{PID: os.Getpid(), UID: os.Getuid()}, peer)
+}
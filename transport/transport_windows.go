@@ -4,12 +4,16 @@
 package transport
 
 import (
+	"context"
 	"net"
+	"os"
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/Microsoft/go-winio"
 	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
 
 	"github.com/apache/thrift/lib/go/thrift"
 )
@@ -17,23 +21,123 @@ import (
 // Open opens the named pipe with the provided path and timeout,
 // returning a TTransport.
 func Open(path string, timeout time.Duration) (*thrift.TSocket, error) {
-	conn, err := winio.DialPipe(path, &timeout)
+	conn, err := dialPipe(path, timeout)
 	if err != nil {
 		return nil, errors.Wrapf(err, "dialing pipe '%s'", path)
 	}
 	return thrift.NewTSocketFromConnTimeout(conn, timeout), nil
 }
 
+// dialPipe connects to the named pipe, retrying until the timeout passes
+// while the pipe is busy or does not exist yet. winio retries
+// ERROR_PIPE_BUSY itself, but osqueryd briefly has no listening instance
+// between accepting one client and creating the next instance, which
+// surfaces as ERROR_FILE_NOT_FOUND. This mirrors the WaitNamedPipe
+// semantics of the C++ and python implementations.
+func dialPipe(path string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		conn, err := winio.DialPipeContext(ctx, path)
+		if err == nil {
+			return conn, nil
+		}
+		if !isPipeUnavailable(err) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(err, "timeout waiting for pipe")
+		case <-ticker.C:
+		}
+	}
+}
+
+// isPipeUnavailable returns true for the errors indicating that the pipe
+// may become available if the dial is retried.
+func isPipeUnavailable(err error) bool {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	return err == windows.ERROR_PIPE_BUSY ||
+		err == windows.ERROR_FILE_NOT_FOUND ||
+		err == context.DeadlineExceeded
+}
+
 func OpenServer(pipePath string, timeout time.Duration) (*TServerPipe, error) {
 	return NewTServerPipeTimeout(pipePath, timeout)
 }
 
+// restrictedPipeSecurityDescriptor only grants access to LocalSystem and the
+// built-in Administrators group, which is what osqueryd runs as.
+const restrictedPipeSecurityDescriptor = "D:P(A;;GA;;;SY)(A;;GA;;;BA)"
+
+// OpenVerifiedServer behaves like OpenServer, but every accepted connection
+// is checked with verify, if not nil, before it is handed to the thrift
+// server. Only the PID of the client is known. The pipe is restricted to
+// LocalSystem and Administrators with RestrictPipeToAdministrators.
+func OpenVerifiedServer(pipePath string, timeout time.Duration, verify PeerVerifier, opts ...VerifiedServerOption) (*TServerPipe, error) {
+	var o verifiedServerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	pipe, err := NewTServerPipeTimeout(pipePath, timeout)
+	if err != nil {
+		return nil, err
+	}
+	pipe.verify = verify
+	pipe.onReject = o.onReject
+	if o.restrictAdmins {
+		pipe.securityDescriptor = restrictedPipeSecurityDescriptor
+	}
+	return pipe, nil
+}
+
+var procGetNamedPipeClientProcessId = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetNamedPipeClientProcessId")
+
+// getPipeClientCredentials returns the PID of the client of a named pipe
+// connection.
+func getPipeClientCredentials(conn net.Conn) (PeerCredentials, error) {
+	f, ok := conn.(interface{ Fd() uintptr })
+	if !ok {
+		return PeerCredentials{}, ErrPeerCredentialsUnsupported
+	}
+	var pid uint32
+	r, _, err := procGetNamedPipeClientProcessId.Call(f.Fd(), uintptr(unsafe.Pointer(&pid)))
+	if r == 0 {
+		return PeerCredentials{}, errors.Wrap(err, "GetNamedPipeClientProcessId")
+	}
+	return PeerCredentials{PID: int(pid), UID: -1}, nil
+}
+
+// SocketPeerCredentials is not supported for named pipes.
+func SocketPeerCredentials(pipePath string, timeout time.Duration) (PeerCredentials, error) {
+	return PeerCredentials{}, ErrPeerCredentialsUnsupported
+}
+
+// CloseServer is a noop for named pipes, which do not leave anything behind
+// once the listener is closed.
+func CloseServer(pipePath string) error {
+	return nil
+}
+
 // TServerPipe is a windows named pipe implementation of the
 type TServerPipe struct {
 	listener      net.Listener
 	pipePath      string
 	clientTimeout time.Duration
 
+	// securityDescriptor is an optional SDDL string applied to the pipe.
+	securityDescriptor string
+	// verify, if set, checks every accepted connection.
+	verify   PeerVerifier
+	onReject func(err error)
+
 	// Protects the interrupted value to make it thread safe.
 	mu          sync.RWMutex
 	interrupted bool
@@ -51,7 +155,12 @@ func (p *TServerPipe) Listen() error {
 		return nil
 	}
 
-	l, err := winio.ListenPipe(p.pipePath, nil)
+	var config *winio.PipeConfig
+	if p.securityDescriptor != "" {
+		config = &winio.PipeConfig{SecurityDescriptor: p.securityDescriptor}
+	}
+
+	l, err := winio.ListenPipe(p.pipePath, config)
 	if err != nil {
 		return err
 	}
@@ -67,20 +176,41 @@ func (p *TServerPipe) IsListening() bool {
 
 // Accept wraps the standard net.Listener accept to return a thrift.TTransport.
 func (p *TServerPipe) Accept() (thrift.TTransport, error) {
-	p.mu.RLock()
-	interrupted := p.interrupted
-	listener := p.listener
-	p.mu.RUnlock()
-
-	if interrupted {
-		return nil, errors.New("transport interrupted")
-	}
-
-	conn, err := listener.Accept()
-	if err != nil {
-		return nil, thrift.NewTTransportExceptionFromError(err)
+	for {
+		p.mu.RLock()
+		interrupted := p.interrupted
+		listener := p.listener
+		p.mu.RUnlock()
+
+		if interrupted {
+			return nil, errors.New("transport interrupted")
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return nil, thrift.NewTTransportExceptionFromError(err)
+		}
+
+		if p.verify != nil {
+			peer, err := getPipeClientCredentials(conn)
+			if err != nil {
+				err = errors.Wrap(err, "reading peer credentials")
+			} else {
+				err = p.verify(peer)
+			}
+			if err != nil {
+				if p.onReject != nil {
+					p.onReject(err)
+				}
+				// Drop the connection but keep accepting, an error
+				// here would stop the thrift server.
+				conn.Close()
+				continue
+			}
+		}
+
+		return thrift.NewTSocketFromConnTimeout(conn, p.clientTimeout), nil
 	}
-	return thrift.NewTSocketFromConnTimeout(conn, p.clientTimeout), nil
 }
 
 func (p *TServerPipe) Close() error {